@@ -83,3 +83,20 @@ func (f *FliterMode) UnmarshalText(text []byte) error {
 	}
 	return nil
 }
+
+// 客户端过滤规则动作
+type FilterAction string
+
+const (
+	FilterAllow FilterAction = "allow" // 放行
+	FilterDeny  FilterAction = "deny"  // 拦截
+	FilterLog   FilterAction = "log"   // 仅记录日志，继续匹配之后的规则
+)
+
+// 字幕简繁转换方式
+type ChineseConversion string
+
+const (
+	ChineseS2T ChineseConversion = "s2t" // 简体转繁体
+	ChineseT2S ChineseConversion = "t2s" // 繁体转简体
+)
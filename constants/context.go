@@ -0,0 +1,5 @@
+package constants
+
+const (
+	RawQueryKey = "MediaWarp.RawQuery" // gin.Context 中记录的原始查询参数，参数名转为小写前保存
+)
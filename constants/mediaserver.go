@@ -69,6 +69,10 @@ func (m *MediaServerType) UnMarshalJSON(data []byte) error {
 	return nil
 }
 
+func (m MediaServerType) MarshalYAML() (any, error) {
+	return m.String(), nil
+}
+
 func (m *MediaServerType) UnmarshalYAML(value *yaml.Node) error {
 	var s string
 	if err := value.Decode(&s); err != nil {
@@ -31,7 +31,7 @@ var EmbyRegexp = &EmbyRegexps{
 		ModifyBaseHtmlPlayer: regexp.MustCompile(`(?i)^/web/modules/htmlvideoplayer/basehtmlplayer.js$`),
 		ModifyIndex:          regexp.MustCompile(`^/web/index.html$`),
 		ModifyPlaybackInfo:   regexp.MustCompile(`(?i)^(/emby)?/Items/\d+/PlaybackInfo$`),
-		ModifySubtitles:      regexp.MustCompile(`(?i)^(/emby)?/Videos/\d+/\w+/subtitles$`),
+		ModifySubtitles:      regexp.MustCompile(`(?i)^(/emby)?/Videos/\d+/[\w-]+/Subtitles/\d+(/\d+)?/Stream(\.\w+)?$`), // /emby/Videos/45/mediasource_45/Subtitles/0/0/Stream.subrip
 	},
 	Others: OthersRegexps{
 		VideoRedirectReg: regexp.MustCompile(`(?i)^(/emby)?/videos/(.*)/stream/(.*)`),
@@ -41,7 +41,7 @@ var EmbyRegexp = &EmbyRegexps{
 	// /emby/Items/123/Images/Chapter/0
 	Cache: CacheRegexps{
 		Image:    regexp.MustCompile(`(?i)^(/emby)?/Items/\d+/Images(/.*)?$`),
-		Subtitle: regexp.MustCompile(`(?i)/Videos/(.*)/Subtitles/(.*)/Stream\.(ass|ssa|srt|)?$`),
+		Subtitle: regexp.MustCompile(`(?i)/Videos/(.*)/Subtitles/(.*)/Stream(\.\w+)?$`),
 	},
 }
 
@@ -61,7 +61,7 @@ var JellyfinRegexp = &JellyfinRegexps{
 		VideosHandler:      regexp.MustCompile(`/Videos/[\w-]+/(stream|original)(\.\w+)?$`), // /Videos/813a630bcf9c3f693a2ec8c498f868d2/stream /Videos/205953b114bb8c9dc2c7ba7e44b8024c/stream.mp4
 		ModifyIndex:        regexp.MustCompile(`^/web/$`),
 		ModifyPlaybackInfo: regexp.MustCompile(`^/Items/\w+/PlaybackInfo$`),
-		ModifySubtitles:    regexp.MustCompile(`/Videos/\d+/\w+/subtitles$`),
+		ModifySubtitles:    regexp.MustCompile(`(?i)^/Videos/[\w-]+/[\w-]+/Subtitles/\d+(/\d+)?/Stream(\.\w+)?$`), // /Videos/6c252d46-952c-5b0d-5f0e-f6e3036c0a39/6c252d46952c5b0d5f0ef6e3036c0a39/Subtitles/2/0/Stream.ass
 	},
 	Cache: CacheRegexps{
 		// /Items/19ba9e43f0db12e2eea4294609ec1a0c/Images/Primary
@@ -70,20 +70,43 @@ var JellyfinRegexp = &JellyfinRegexps{
 		Image: regexp.MustCompile(`(?i)/Items/\w+/Images(/.*)?$`),
 
 		// /Videos/6c252d46-952c-5b0d-5f0e-f6e3036c0a39/6c252d46952c5b0d5f0ef6e3036c0a39/Subtitles/2/0/Stream.ass
-		Subtitle: regexp.MustCompile(`(?i)/Videos/(.*)/Subtitles/(.*)/Stream\.(ass|ssa|srt|)?$`),
+		Subtitle: regexp.MustCompile(`(?i)/Videos/(.*)/Subtitles/(.*)/Stream(\.\w+)?$`),
 	},
 }
 
 // 飞牛影视媒体服务器正则表达式
 type FNTVRouterRegexps struct {
-	StreamHandler *regexp.Regexp
-	Cache         CacheRegexps
+	StreamHandler   *regexp.Regexp
+	ModifySubtitles *regexp.Regexp // 字幕下载接口
+	Cache           CacheRegexps
 }
 
 var FNTVRegexp = &FNTVRouterRegexps{
-	StreamHandler: regexp.MustCompile(`^/v/api/v1/stream$`),
+	StreamHandler:   regexp.MustCompile(`^/v/api/v1/stream$`),
+	ModifySubtitles: regexp.MustCompile(`^/v/api/v1/subtitle/dl/[\d\w]+$`),
 	Cache: CacheRegexps{
 		Image:    regexp.MustCompile(`^/v/api/v1/sys/img/[\d\w]{2}/[\d\w]{2}/[\d\w]+\.[\d\w]+$`),
 		Subtitle: regexp.MustCompile(`^/v/api/v1/subtitle/dl/[\d\w]+$`),
 	},
 }
+
+// Plex 媒体服务器正则表达式
+type PlexRouterRegexps struct {
+	ModifyMetadata   *regexp.Regexp // 媒体元数据接口
+	PartsHandler     *regexp.Regexp // 媒体文件直接播放接口
+	TranscodeHandler *regexp.Regexp // 转码播放接口
+	Cache            CacheRegexps
+}
+
+var PlexRegexp = &PlexRouterRegexps{
+	ModifyMetadata:   regexp.MustCompile(`^/library/metadata/\d+$`),
+	PartsHandler:     regexp.MustCompile(`^/library/parts/(\d+)(/\d+)?/file(\.\w+)?$`),   // /library/parts/1234/1700000000/file.mkv
+	TranscodeHandler: regexp.MustCompile(`^/video/:/transcode/universal/start(\.\w+)?$`), // /video/:/transcode/universal/start.m3u8?path=/library/metadata/123
+	Cache: CacheRegexps{
+		// /library/metadata/123/thumb/1700000000
+		// /photo/:/transcode?width=300&height=450&url=/library/metadata/123/thumb/1700000000
+		Image: regexp.MustCompile(`^(/library/metadata/\d+/(thumb|art|banner|clearLogo)(/\d+)?|/photo/:/transcode)$`),
+		// /library/streams/456
+		Subtitle: regexp.MustCompile(`^/library/streams/\d+$`),
+	},
+}
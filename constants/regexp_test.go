@@ -1,5 +1,11 @@
 package constants_test
 
+import (
+	"MediaWarp/constants"
+	"regexp"
+	"testing"
+)
+
 // func TestEmbyRoute(t *testing.T) {
 // 	type RouteTestCase struct {
 // 		URI    string
@@ -45,3 +51,47 @@ package constants_test
 // 		})
 // 	}
 // }
+
+func TestModifySubtitlesRegexp(t *testing.T) {
+	tests := map[string]struct {
+		reg   *regexp.Regexp
+		path  string
+		match bool
+	}{
+		"Emby 字幕":         {constants.EmbyRegexp.Router.ModifySubtitles, "/Videos/88697/21ed6a9972693ffa82571197cb406b64/Subtitles/3/0/Stream", true},
+		"Emby 4.9+ 字幕":    {constants.EmbyRegexp.Router.ModifySubtitles, "/emby/Videos/45/mediasource_45/Subtitles/0/0/Stream.subrip", true},
+		"Emby 字幕（无起始时间）":  {constants.EmbyRegexp.Router.ModifySubtitles, "/emby/videos/146/mediasource_146/subtitles/3/stream.srt", true},
+		"Emby 视频":         {constants.EmbyRegexp.Router.ModifySubtitles, "/Videos/88697/stream", false},
+		"Jellyfin 字幕":     {constants.JellyfinRegexp.Router.ModifySubtitles, "/Videos/6c252d46-952c-5b0d-5f0e-f6e3036c0a39/6c252d46952c5b0d5f0ef6e3036c0a39/Subtitles/2/0/Stream.ass", true},
+		"Jellyfin HLS 字幕": {constants.JellyfinRegexp.Router.ModifySubtitles, "/Videos/6c252d46952c5b0d5f0ef6e3036c0a39/6c252d46952c5b0d5f0ef6e3036c0a39/Subtitles/2/subtitles.m3u8", false},
+		"飞牛影视字幕":          {constants.FNTVRegexp.ModifySubtitles, "/v/api/v1/subtitle/dl/1f2e3d4c5b6a", true},
+	}
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			if got := test.reg.MatchString(test.path); got != test.match {
+				t.Errorf("%s 匹配结果错误。期望: %t, 实际: %t", test.path, test.match, got)
+			}
+		})
+	}
+}
+
+func TestSubtitleCacheRegexp(t *testing.T) {
+	tests := map[string]struct {
+		reg   *regexp.Regexp
+		path  string
+		match bool
+	}{
+		"Emby SubRip 字幕":    {constants.EmbyRegexp.Cache.Subtitle, "/emby/Videos/45/mediasource_45/Subtitles/0/0/Stream.subrip", true},
+		"Emby WebVTT 字幕":    {constants.EmbyRegexp.Cache.Subtitle, "/emby/videos/45/mediasource_45/subtitles/0/stream.vtt", true},
+		"Emby 字幕（无扩展名）":     {constants.EmbyRegexp.Cache.Subtitle, "/Videos/88697/21ed6a9972693ffa82571197cb406b64/Subtitles/3/0/Stream", true},
+		"Jellyfin ASS 字幕":   {constants.JellyfinRegexp.Cache.Subtitle, "/Videos/6c252d46-952c-5b0d-5f0e-f6e3036c0a39/6c252d46952c5b0d5f0ef6e3036c0a39/Subtitles/2/0/Stream.ass", true},
+		"Jellyfin HLS 字幕列表": {constants.JellyfinRegexp.Cache.Subtitle, "/Videos/6c252d46952c5b0d5f0ef6e3036c0a39/6c252d46952c5b0d5f0ef6e3036c0a39/Subtitles/2/subtitles.m3u8", false},
+	}
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			if got := test.reg.MatchString(test.path); got != test.match {
+				t.Errorf("%s 匹配结果错误。期望: %t, 实际: %t", test.path, test.match, got)
+			}
+		})
+	}
+}
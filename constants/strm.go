@@ -1,5 +1,12 @@
 package constants
 
+import (
+	"fmt"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
 type StrmFileType uint8 // Strm 文件类型
 
 const (
@@ -18,3 +25,23 @@ func (s StrmFileType) String() string {
 		return "UnknownStrm"
 	}
 }
+
+func (s StrmFileType) MarshalYAML() (any, error) {
+	return s.String(), nil
+}
+
+func (s *StrmFileType) UnmarshalYAML(value *yaml.Node) error {
+	var str string
+	if err := value.Decode(&str); err != nil {
+		return err
+	}
+	switch strings.ToLower(str) {
+	case "httpstrm":
+		*s = HTTPStrm
+	case "aliststrm":
+		*s = AlistStrm
+	default:
+		return fmt.Errorf("unknown StrmFileType: %s", str)
+	}
+	return nil
+}
@@ -0,0 +1,65 @@
+package cache
+
+import (
+	"hash/fnv"
+	"time"
+)
+
+// 缓存接口
+type Cache interface {
+	Get(key string) ([]byte, bool)                   // 获取缓存，过期或不存在时返回 false
+	Set(key string, value []byte, ttl time.Duration) // 写入缓存，ttl <= 0 表示不缓存
+	Delete(key string)                               // 删除缓存
+	Len() int                                        // 缓存条目数量
+}
+
+// 计算键的哈希值（FNV-1a）
+func hashKey(key string) uint32 {
+	h := fnv.New32a()
+	h.Write([]byte(key))
+	return h.Sum32()
+}
+
+// 分层缓存
+//
+// 小于内存缓存单条上限的内容写入内存，其余写入磁盘
+type TieredCache struct {
+	memory *MemoryCache
+	disk   *DiskCache
+}
+
+func NewTieredCache(memory *MemoryCache, disk *DiskCache) *TieredCache {
+	return &TieredCache{memory: memory, disk: disk}
+}
+
+func (c *TieredCache) Get(key string) ([]byte, bool) {
+	if value, ok := c.memory.Get(key); ok {
+		return value, true
+	}
+	return c.disk.Get(key)
+}
+
+func (c *TieredCache) Set(key string, value []byte, ttl time.Duration) {
+	if len(value) <= c.memory.MaxEntrySize() {
+		c.memory.Set(key, value, ttl)
+		c.disk.Delete(key)
+		return
+	}
+	c.memory.Delete(key)
+	c.disk.Set(key, value, ttl)
+}
+
+func (c *TieredCache) Delete(key string) {
+	c.memory.Delete(key)
+	c.disk.Delete(key)
+}
+
+func (c *TieredCache) Len() int {
+	return c.memory.Len() + c.disk.Len()
+}
+
+var (
+	_ Cache = (*MemoryCache)(nil)
+	_ Cache = (*DiskCache)(nil)
+	_ Cache = (*TieredCache)(nil)
+)
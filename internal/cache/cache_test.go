@@ -0,0 +1,109 @@
+package cache_test
+
+import (
+	"MediaWarp/internal/cache"
+	"bytes"
+	"strconv"
+	"testing"
+	"time"
+)
+
+func TestMemoryCacheLRU(t *testing.T) {
+	c := cache.NewMemoryCache(0, 1, 3)
+	for i := 0; i < 3; i++ {
+		c.Set(strconv.Itoa(i), []byte{byte(i)}, time.Minute)
+	}
+	c.Get("0")                         // 0 成为最近使用
+	c.Set("3", []byte{3}, time.Minute) // 淘汰最久未使用的 1
+
+	if _, ok := c.Get("1"); ok {
+		t.Errorf("期望 1 已被淘汰")
+	}
+	for _, key := range []string{"0", "2", "3"} {
+		if _, ok := c.Get(key); !ok {
+			t.Errorf("期望 %s 仍在缓存中", key)
+		}
+	}
+	if c.Len() != 3 {
+		t.Errorf("缓存条目数错误。期望: 3, 实际: %d", c.Len())
+	}
+}
+
+func TestMemoryCacheMaxSize(t *testing.T) {
+	c := cache.NewMemoryCache(10, 1, 0)
+	c.Set("a", make([]byte, 6), time.Minute)
+	c.Set("b", make([]byte, 6), time.Minute) // 超出 10 字节，淘汰 a
+	if _, ok := c.Get("a"); ok {
+		t.Errorf("期望 a 已被淘汰")
+	}
+	c.Set("c", make([]byte, 11), time.Minute) // 超过单条目上限，不缓存
+	if _, ok := c.Get("c"); ok {
+		t.Errorf("期望 c 未被缓存")
+	}
+}
+
+func TestMemoryCacheTTL(t *testing.T) {
+	c := cache.NewMemoryCache(0, 4, 0)
+	c.Set("key", []byte("value"), 20*time.Millisecond)
+	if value, ok := c.Get("key"); !ok || string(value) != "value" {
+		t.Fatalf("期望命中缓存")
+	}
+	time.Sleep(30 * time.Millisecond)
+	if _, ok := c.Get("key"); ok {
+		t.Errorf("期望缓存已过期")
+	}
+	c.Set("zero", []byte("value"), 0)
+	if _, ok := c.Get("zero"); ok {
+		t.Errorf("ttl 为 0 时不应缓存")
+	}
+}
+
+func TestDiskCache(t *testing.T) {
+	dir := t.TempDir()
+	c, err := cache.NewDiskCache(dir, 10)
+	if err != nil {
+		t.Fatal(err)
+	}
+	c.Set("a", []byte("12345"), time.Minute)
+	c.Set("b", []byte("67890"), time.Minute)
+	c.Set("c", []byte("abcde"), time.Minute) // 超出 10 字节，淘汰 a
+
+	if _, ok := c.Get("a"); ok {
+		t.Errorf("期望 a 已被淘汰")
+	}
+
+	reloaded, err := cache.NewDiskCache(dir, 10) // 重新加载目录中的缓存文件
+	if err != nil {
+		t.Fatal(err)
+	}
+	if value, ok := reloaded.Get("c"); !ok || !bytes.Equal(value, []byte("abcde")) {
+		t.Errorf("重新加载后期望命中 c，实际: %q", value)
+	}
+	if reloaded.Len() != 2 {
+		t.Errorf("缓存条目数错误。期望: 2, 实际: %d", reloaded.Len())
+	}
+}
+
+func TestTieredCache(t *testing.T) {
+	disk, err := cache.NewDiskCache(t.TempDir(), 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	memory := cache.NewMemoryCache(0, 1, 0)
+	c := cache.NewTieredCache(memory, disk)
+
+	small := []byte("small")
+	large := make([]byte, cache.MaxMemoryEntrySize+1)
+	c.Set("small", small, time.Minute)
+	c.Set("large", large, time.Minute)
+
+	if _, ok := memory.Get("small"); !ok {
+		t.Errorf("期望 small 写入内存缓存")
+	}
+	if _, ok := disk.Get("large"); !ok {
+		t.Errorf("期望 large 写入磁盘缓存")
+	}
+	if value, ok := c.Get("large"); !ok || len(value) != len(large) {
+		t.Errorf("期望命中 large")
+	}
+}
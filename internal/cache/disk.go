@@ -0,0 +1,213 @@
+package cache
+
+import (
+	"MediaWarp/utils"
+	"container/list"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+	"time"
+)
+
+const diskHeaderSize = 8 // 文件头：过期时间（Unix 纳秒，大端序）
+
+type diskEntry struct {
+	name     string
+	size     int
+	expireAt time.Time
+}
+
+// 磁盘缓存
+//
+// 每个条目保存为一个文件，文件名为键的 MD5，超出容量时按 LRU 淘汰
+type DiskCache struct {
+	dir     string
+	maxSize int // 最大占用字节数，0 表示不限制
+
+	mutex sync.Mutex
+	items map[string]*list.Element
+	lru   *list.List
+	size  int
+}
+
+// 创建磁盘缓存
+//
+// 会读取目录中已有的缓存文件并清理过期条目
+func NewDiskCache(dir string, maxBytes int) (*DiskCache, error) {
+	if err := os.MkdirAll(dir, os.ModePerm); err != nil {
+		return nil, fmt.Errorf("创建缓存目录失败: %w", err)
+	}
+	c := DiskCache{
+		dir:     dir,
+		maxSize: maxBytes,
+		items:   make(map[string]*list.Element),
+		lru:     list.New(),
+	}
+	if err := c.load(); err != nil {
+		return nil, err
+	}
+	return &c, nil
+}
+
+// 加载已有的缓存文件
+func (c *DiskCache) load() error {
+	dirEntries, err := os.ReadDir(c.dir)
+	if err != nil {
+		return fmt.Errorf("读取缓存目录失败: %w", err)
+	}
+
+	type loaded struct {
+		entry   diskEntry
+		modTime time.Time
+	}
+	var entries []loaded
+	for _, dirEntry := range dirEntries {
+		if dirEntry.IsDir() {
+			continue
+		}
+		if filepath.Ext(dirEntry.Name()) == ".tmp" { // 未写入完成的临时文件
+			os.Remove(c.filePath(dirEntry.Name()))
+			continue
+		}
+		info, err := dirEntry.Info()
+		if err != nil {
+			continue
+		}
+		expireAt, err := c.readExpireAt(dirEntry.Name())
+		if err != nil || time.Now().After(expireAt) {
+			os.Remove(c.filePath(dirEntry.Name()))
+			continue
+		}
+		entries = append(entries, loaded{
+			entry: diskEntry{
+				name:     dirEntry.Name(),
+				size:     int(info.Size()) - diskHeaderSize,
+				expireAt: expireAt,
+			},
+			modTime: info.ModTime(),
+		})
+	}
+
+	sort.Slice(entries, func(i, j int) bool { // 最近修改的放在队首
+		return entries[i].modTime.After(entries[j].modTime)
+	})
+	for _, e := range entries {
+		entry := e.entry
+		c.items[entry.name] = c.lru.PushBack(&entry)
+		c.size += entry.size
+	}
+	c.evict()
+	return nil
+}
+
+func (c *DiskCache) filePath(name string) string {
+	return filepath.Join(c.dir, name)
+}
+
+func (c *DiskCache) readExpireAt(name string) (time.Time, error) {
+	file, err := os.Open(c.filePath(name))
+	if err != nil {
+		return time.Time{}, err
+	}
+	defer file.Close()
+
+	var header [diskHeaderSize]byte
+	if _, err = io.ReadFull(file, header[:]); err != nil {
+		return time.Time{}, err
+	}
+	return time.Unix(0, int64(binary.BigEndian.Uint64(header[:]))), nil
+}
+
+func (c *DiskCache) Get(key string) ([]byte, bool) {
+	name := utils.MD5Hash(key)
+
+	c.mutex.Lock()
+	element, ok := c.items[name]
+	if !ok {
+		c.mutex.Unlock()
+		return nil, false
+	}
+	entry := element.Value.(*diskEntry)
+	if time.Now().After(entry.expireAt) {
+		c.removeElement(element)
+		c.mutex.Unlock()
+		return nil, false
+	}
+	c.lru.MoveToFront(element)
+	c.mutex.Unlock()
+
+	data, err := os.ReadFile(c.filePath(name))
+	if err != nil || len(data) < diskHeaderSize {
+		c.Delete(key)
+		return nil, false
+	}
+	return data[diskHeaderSize:], true
+}
+
+func (c *DiskCache) Set(key string, value []byte, ttl time.Duration) {
+	if ttl <= 0 || (c.maxSize > 0 && len(value) > c.maxSize) {
+		return
+	}
+
+	name := utils.MD5Hash(key)
+	expireAt := time.Now().Add(ttl)
+
+	data := make([]byte, diskHeaderSize+len(value))
+	binary.BigEndian.PutUint64(data, uint64(expireAt.UnixNano()))
+	copy(data[diskHeaderSize:], value)
+
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	tmpPath := c.filePath(name + ".tmp")
+	if err := os.WriteFile(tmpPath, data, 0644); err != nil {
+		return
+	}
+	if err := os.Rename(tmpPath, c.filePath(name)); err != nil {
+		os.Remove(tmpPath)
+		return
+	}
+
+	if element, ok := c.items[name]; ok {
+		c.size -= element.Value.(*diskEntry).size
+		c.lru.Remove(element)
+	}
+	entry := diskEntry{name: name, size: len(value), expireAt: expireAt}
+	c.items[name] = c.lru.PushFront(&entry)
+	c.size += entry.size
+	c.evict()
+}
+
+func (c *DiskCache) Delete(key string) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	if element, ok := c.items[utils.MD5Hash(key)]; ok {
+		c.removeElement(element)
+	}
+}
+
+func (c *DiskCache) Len() int {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	return c.lru.Len()
+}
+
+// 淘汰超出容量的条目，调用前需持有锁
+func (c *DiskCache) evict() {
+	for c.maxSize > 0 && c.size > c.maxSize && c.lru.Len() > 0 {
+		c.removeElement(c.lru.Back())
+	}
+}
+
+// 调用前需持有锁
+func (c *DiskCache) removeElement(element *list.Element) {
+	entry := c.lru.Remove(element).(*diskEntry)
+	delete(c.items, entry.name)
+	c.size -= entry.size
+	os.Remove(c.filePath(entry.name))
+}
@@ -0,0 +1,41 @@
+package cache
+
+import "sync"
+
+type call struct {
+	wg    sync.WaitGroup
+	value []byte
+	err   error
+}
+
+// 请求合并
+//
+// 同一时刻对同一个键的多次调用只会执行一次，其余调用等待并共享结果
+type Group struct {
+	mutex sync.Mutex
+	calls map[string]*call
+}
+
+func (g *Group) Do(key string, fn func() ([]byte, error)) ([]byte, error) {
+	g.mutex.Lock()
+	if g.calls == nil {
+		g.calls = make(map[string]*call)
+	}
+	if c, ok := g.calls[key]; ok {
+		g.mutex.Unlock()
+		c.wg.Wait()
+		return c.value, c.err
+	}
+	c := new(call)
+	c.wg.Add(1)
+	g.calls[key] = c
+	g.mutex.Unlock()
+
+	c.value, c.err = fn()
+	c.wg.Done()
+
+	g.mutex.Lock()
+	delete(g.calls, key)
+	g.mutex.Unlock()
+	return c.value, c.err
+}
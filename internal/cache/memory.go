@@ -0,0 +1,136 @@
+package cache
+
+import (
+	"container/list"
+	"sync"
+	"time"
+)
+
+const MaxMemoryEntrySize = 256 * 1024 // 内存缓存单条目最大 256KB，超过不缓存
+
+type memoryEntry struct {
+	key      string
+	value    []byte
+	expireAt time.Time
+}
+
+type memoryShard struct {
+	mutex      sync.Mutex
+	items      map[string]*list.Element
+	lru        *list.List // 队首为最近使用
+	size       int        // 当前占用字节数
+	maxSize    int        // 最大占用字节数，0 表示不限制
+	maxEntries int        // 最大条目数，0 表示不限制
+}
+
+// 内存缓存
+//
+// 分片 LRU 缓存，同时限制内存占用和条目数量
+type MemoryCache struct {
+	shards []*memoryShard
+}
+
+// 创建内存缓存
+//
+// maxBytes: 最大内存占用（字节），0 表示不限制
+// shards: 分片数
+// maxEntriesPerShard: 每个分片最大条目数，0 表示不限制
+func NewMemoryCache(maxBytes int, shards int, maxEntriesPerShard int) *MemoryCache {
+	if shards <= 0 {
+		shards = 1
+	}
+	c := MemoryCache{shards: make([]*memoryShard, shards)}
+	for i := range c.shards {
+		c.shards[i] = &memoryShard{
+			items:      make(map[string]*list.Element),
+			lru:        list.New(),
+			maxSize:    maxBytes / shards,
+			maxEntries: maxEntriesPerShard,
+		}
+	}
+	return &c
+}
+
+func (c *MemoryCache) getShard(key string) *memoryShard {
+	return c.shards[hashKey(key)%uint32(len(c.shards))]
+}
+
+// 单条目最大字节数
+func (c *MemoryCache) MaxEntrySize() int {
+	maxSize := c.shards[0].maxSize
+	if maxSize == 0 || maxSize > MaxMemoryEntrySize {
+		return MaxMemoryEntrySize
+	}
+	return maxSize
+}
+
+func (c *MemoryCache) Get(key string) ([]byte, bool) {
+	shard := c.getShard(key)
+	shard.mutex.Lock()
+	defer shard.mutex.Unlock()
+
+	element, ok := shard.items[key]
+	if !ok {
+		return nil, false
+	}
+	entry := element.Value.(*memoryEntry)
+	if time.Now().After(entry.expireAt) {
+		shard.removeElement(element)
+		return nil, false
+	}
+	shard.lru.MoveToFront(element)
+	return entry.value, true
+}
+
+func (c *MemoryCache) Set(key string, value []byte, ttl time.Duration) {
+	if ttl <= 0 || len(value) > c.MaxEntrySize() {
+		return
+	}
+
+	shard := c.getShard(key)
+	shard.mutex.Lock()
+	defer shard.mutex.Unlock()
+
+	if element, ok := shard.items[key]; ok {
+		shard.removeElement(element)
+	}
+	entry := memoryEntry{
+		key:      key,
+		value:    value,
+		expireAt: time.Now().Add(ttl),
+	}
+	shard.items[key] = shard.lru.PushFront(&entry)
+	shard.size += len(value)
+
+	for shard.lru.Len() > 1 &&
+		((shard.maxSize > 0 && shard.size > shard.maxSize) || (shard.maxEntries > 0 && shard.lru.Len() > shard.maxEntries)) {
+		shard.removeElement(shard.lru.Back()) // 淘汰最久未使用的条目
+	}
+}
+
+func (c *MemoryCache) Delete(key string) {
+	shard := c.getShard(key)
+	shard.mutex.Lock()
+	defer shard.mutex.Unlock()
+
+	if element, ok := shard.items[key]; ok {
+		shard.removeElement(element)
+	}
+}
+
+func (c *MemoryCache) Len() int {
+	var n int
+	for _, shard := range c.shards {
+		shard.mutex.Lock()
+		n += shard.lru.Len()
+		shard.mutex.Unlock()
+	}
+	return n
+}
+
+// 调用前需持有锁
+func (shard *memoryShard) removeElement(element *list.Element) {
+	entry := shard.lru.Remove(element).(*memoryEntry)
+	delete(shard.items, entry.key)
+	shard.size -= len(entry.value)
+}
@@ -0,0 +1,119 @@
+package cache
+
+import (
+	"MediaWarp/internal/config"
+	"path/filepath"
+	"sync/atomic"
+	"time"
+)
+
+const (
+	defaultShards             = 256 // 默认分片数
+	defaultMaxEntriesPerShard = 500 // 默认每个分片最大条目数
+)
+
+// 一组按用途划分的缓存，未启用的缓存为 nil
+type Stores struct {
+	Image    Cache // 图片缓存
+	Subtitle Cache // 字幕缓存
+	AlistAPI Cache // Alist API 缓存
+	HTTPStrm Cache // HTTPStrm 最终 URL 缓存
+}
+
+var stores atomic.Pointer[Stores] // 当前使用的缓存
+
+func init() {
+	stores.Store(&Stores{})
+}
+
+// 初始化缓存
+//
+// 未启用缓存或 TTL 不大于 0 时对应缓存为 nil
+func Init() error {
+	s, err := New(config.Get())
+	if err != nil {
+		return err
+	}
+	Use(s)
+	return nil
+}
+
+// 根据配置创建一组缓存，不影响当前使用的缓存
+func New(cfg *config.Setting) (*Stores, error) {
+	var (
+		s   Stores
+		err error
+	)
+	if s.Image, err = newCacheFromConfig(cfg, "image", cfg.Cache.ImageTTL); err != nil {
+		return nil, err
+	}
+	if s.Subtitle, err = newCacheFromConfig(cfg, "subtitle", cfg.Cache.SubtitleTTL); err != nil {
+		return nil, err
+	}
+	if s.AlistAPI, err = newCacheFromConfig(cfg, "alist_api", cfg.Cache.AlistAPITTL); err != nil {
+		return nil, err
+	}
+	if s.HTTPStrm, err = newCacheFromConfig(cfg, "http_strm", cfg.Cache.HTTPStrmTTL); err != nil {
+		return nil, err
+	}
+	return &s, nil
+}
+
+// 替换当前使用的缓存
+func Use(s *Stores) {
+	stores.Store(s)
+}
+
+// 获取当前使用的缓存
+func Current() *Stores {
+	return stores.Load()
+}
+
+// 根据配置创建缓存实例
+//
+// name: 缓存名称，同时作为磁盘缓存的子目录名
+// 内存、磁盘大小限制按实例分别计算，四个缓存全部启用时总占用最多为设置值的 4 倍
+func newCacheFromConfig(cfg *config.Setting, name string, ttl time.Duration) (Cache, error) {
+	if !cfg.Cache.Enable || ttl <= 0 {
+		return nil, nil
+	}
+
+	shards := cfg.Cache.Shards
+	if shards <= 0 {
+		shards = defaultShards
+	}
+	maxEntriesPerShard := cfg.Cache.MaxEntriesPerShard
+	if maxEntriesPerShard <= 0 {
+		maxEntriesPerShard = defaultMaxEntriesPerShard
+	}
+	memory := NewMemoryCache(cfg.Cache.MaxMemoryMB*1024*1024, shards, maxEntriesPerShard)
+	if cfg.Cache.DiskDir == "" {
+		return memory, nil
+	}
+
+	disk, err := NewDiskCache(filepath.Join(cfg.Cache.DiskDir, name), cfg.Cache.MaxDiskMB*1024*1024)
+	if err != nil {
+		return nil, err
+	}
+	return NewTieredCache(memory, disk), nil
+}
+
+// 获取图片缓存，未启用时返回 nil
+func GetImageCache() Cache {
+	return stores.Load().Image
+}
+
+// 获取字幕缓存，未启用时返回 nil
+func GetSubtitleCache() Cache {
+	return stores.Load().Subtitle
+}
+
+// 获取 Alist API 缓存，未启用时返回 nil
+func GetAlistAPICache() Cache {
+	return stores.Load().AlistAPI
+}
+
+// 获取 HTTPStrm 最终 URL 缓存，未启用时返回 nil
+func GetHTTPStrmCache() Cache {
+	return stores.Load().HTTPStrm
+}
@@ -0,0 +1,334 @@
+package clientfilter
+
+import (
+	"MediaWarp/constants"
+	"MediaWarp/internal/config"
+	"fmt"
+	"net"
+	"net/http"
+	"regexp"
+	"slices"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+const (
+	RuleEmptyUA = "empty_user_agent" // 未提供 User-Agent 时的统计名称
+	RuleList    = "list"             // mode 和 list 过滤时的统计名称
+)
+
+var weekdays = map[string]time.Weekday{
+	"sun": time.Sunday, "mon": time.Monday, "tue": time.Tuesday, "wed": time.Wednesday,
+	"thu": time.Thursday, "fri": time.Friday, "sat": time.Saturday,
+}
+
+// 过滤结果
+type Result struct {
+	Allowed bool
+	Rule    string // 决定结果的规则名称，为空表示没有规则匹配
+	Status  int
+	Body    string
+	Logged  []string // 匹配的 log 规则
+}
+
+// 规则统计
+type Stats struct {
+	Rule    string `json:"rule"`
+	Matched uint64 `json:"matched"`
+	Blocked uint64 `json:"blocked"`
+}
+
+type counter struct {
+	matched atomic.Uint64
+	blocked atomic.Uint64
+}
+
+// 时间段，单位为当天的分钟数
+type window struct {
+	start, end int
+}
+
+func (w window) contains(minute int) bool {
+	if w.start <= w.end {
+		return minute >= w.start && minute < w.end
+	}
+	return minute >= w.start || minute < w.end // 跨越午夜
+}
+
+type headerMatcher struct {
+	name   string
+	regexp *regexp.Regexp
+}
+
+type rule struct {
+	name      string
+	userAgent *regexp.Regexp
+	headers   []headerMatcher
+	networks  []*net.IPNet
+	windows   []window
+	weekdays  []time.Weekday
+	action    constants.FilterAction
+	status    int
+	body      string
+}
+
+var (
+	mutex    sync.RWMutex
+	rules    []rule
+	counters sync.Map // 规则名称 -> *counter，重新加载配置后保留
+)
+
+// 初始化客户端过滤规则
+func Init() error {
+	publish, err := Prepare(config.Get())
+	if err != nil {
+		return err
+	}
+	publish()
+	return nil
+}
+
+// 根据配置编译客户端过滤规则，返回的函数用于替换当前使用的规则
+//
+// 规则无效时返回错误，不影响当前使用的规则
+func Prepare(cfg *config.Setting) (func(), error) {
+	compiled := make([]rule, 0, len(cfg.ClientFilter.Rules))
+	for i, setting := range cfg.ClientFilter.Rules {
+		r, err := compile(setting)
+		if err != nil {
+			return nil, fmt.Errorf("rules[%d]: %w", i, err)
+		}
+		if r.name == "" {
+			r.name = fmt.Sprintf("rules[%d]", i)
+		}
+		compiled = append(compiled, r)
+	}
+	if _, err := ParseNetworks(cfg.ClientFilter.TrustedProxies); err != nil {
+		return nil, fmt.Errorf("trusted_proxies: %w", err)
+	}
+
+	return func() {
+		mutex.Lock()
+		rules = compiled
+		mutex.Unlock()
+	}, nil
+}
+
+func compile(setting config.ClientFilterRuleSetting) (rule, error) {
+	r := rule{
+		name:   setting.Name,
+		action: setting.Action,
+		status: setting.Status,
+		body:   setting.Body,
+	}
+	switch r.action {
+	case constants.FilterAllow, constants.FilterDeny, constants.FilterLog:
+	case "":
+		r.action = constants.FilterDeny
+	default:
+		return r, fmt.Errorf("未知的 action: %s", setting.Action)
+	}
+	if r.status == 0 {
+		r.status = http.StatusForbidden
+	}
+
+	var err error
+	if setting.UserAgent != "" {
+		if r.userAgent, err = regexp.Compile(setting.UserAgent); err != nil {
+			return r, fmt.Errorf("user_agent 正则表达式无效: %w", err)
+		}
+	}
+	for name, expr := range setting.Headers {
+		re, err := regexp.Compile(expr)
+		if err != nil {
+			return r, fmt.Errorf("headers.%s 正则表达式无效: %w", name, err)
+		}
+		r.headers = append(r.headers, headerMatcher{name: http.CanonicalHeaderKey(name), regexp: re})
+	}
+	if r.networks, err = ParseNetworks(setting.IPs); err != nil {
+		return r, fmt.Errorf("ips: %w", err)
+	}
+	for _, value := range setting.Times {
+		w, err := parseWindow(value)
+		if err != nil {
+			return r, fmt.Errorf("times: %w", err)
+		}
+		r.windows = append(r.windows, w)
+	}
+	for _, value := range setting.Weekdays {
+		day, ok := parseWeekday(value)
+		if !ok {
+			return r, fmt.Errorf("weekdays: 无效的星期 %q", value)
+		}
+		r.weekdays = append(r.weekdays, day)
+	}
+	return r, nil
+}
+
+// 解析星期，支持 Sun、Sunday 等英文名称，不区分大小写
+func parseWeekday(value string) (time.Weekday, bool) {
+	value = strings.ToLower(strings.TrimSpace(value))
+	if len(value) < 3 {
+		return 0, false
+	}
+	day, ok := weekdays[value[:3]]
+	return day, ok && strings.HasPrefix(strings.ToLower(day.String()), value)
+}
+
+// 解析 IP 或 CIDR 列表
+//
+// 单个 IP 按 /32 或 /128 处理
+func ParseNetworks(values []string) ([]*net.IPNet, error) {
+	networks := make([]*net.IPNet, 0, len(values))
+	for _, value := range values {
+		value = strings.TrimSpace(value)
+		if !strings.Contains(value, "/") {
+			ip := net.ParseIP(value)
+			if ip == nil {
+				return nil, fmt.Errorf("无效的 IP: %q", value)
+			}
+			bits := net.IPv6len * 8
+			if ip4 := ip.To4(); ip4 != nil {
+				ip, bits = ip4, net.IPv4len*8
+			}
+			networks = append(networks, &net.IPNet{IP: ip, Mask: net.CIDRMask(bits, bits)})
+			continue
+		}
+		_, network, err := net.ParseCIDR(value)
+		if err != nil {
+			return nil, fmt.Errorf("无效的 CIDR: %q", value)
+		}
+		networks = append(networks, network)
+	}
+	return networks, nil
+}
+
+// 解析时间段，格式为 HH:MM-HH:MM，结束时间早于开始时间时表示跨越午夜
+func parseWindow(value string) (window, error) {
+	start, end, ok := strings.Cut(value, "-")
+	if !ok {
+		return window{}, fmt.Errorf("无效的时间段 %q，格式应为 HH:MM-HH:MM", value)
+	}
+	var w window
+	for i, s := range []string{start, end} {
+		t, err := time.Parse("15:04", strings.TrimSpace(s))
+		if err != nil {
+			return window{}, fmt.Errorf("无效的时间段 %q，格式应为 HH:MM-HH:MM", value)
+		}
+		if i == 0 {
+			w.start = t.Hour()*60 + t.Minute()
+		} else {
+			w.end = t.Hour()*60 + t.Minute()
+		}
+	}
+	return w, nil
+}
+
+// 规则是否匹配
+func (r *rule) match(req *http.Request, ip net.IP, now time.Time) bool {
+	if r.userAgent != nil && !r.userAgent.MatchString(req.UserAgent()) {
+		return false
+	}
+	for _, header := range r.headers {
+		if !header.regexp.MatchString(req.Header.Get(header.name)) {
+			return false
+		}
+	}
+	if len(r.networks) > 0 && !slices.ContainsFunc(r.networks, func(network *net.IPNet) bool { return ip != nil && network.Contains(ip) }) {
+		return false
+	}
+	if len(r.weekdays) > 0 && !slices.Contains(r.weekdays, now.Weekday()) {
+		return false
+	}
+	minute := now.Hour()*60 + now.Minute()
+	if len(r.windows) > 0 && !slices.ContainsFunc(r.windows, func(w window) bool { return w.contains(minute) }) {
+		return false
+	}
+	return true
+}
+
+// 过滤请求
+//
+// 按顺序匹配规则，第一个 allow 或 deny 规则决定结果，log 规则只记录并继续匹配
+// 没有规则决定结果时按 allow_empty_ua、mode 和 list 过滤 User-Agent
+func Evaluate(req *http.Request, clientIP string, now time.Time) Result {
+	cfg := config.Get()
+	mutex.RLock()
+	currentRules := rules
+	mutex.RUnlock()
+
+	var result Result
+	ip := net.ParseIP(clientIP)
+	for i := range currentRules {
+		r := &currentRules[i]
+		if !r.match(req, ip, now) {
+			continue
+		}
+		c := getCounter(r.name)
+		c.matched.Add(1)
+		switch r.action {
+		case constants.FilterLog:
+			result.Logged = append(result.Logged, r.name)
+			continue
+		case constants.FilterAllow:
+			result.Allowed = true
+		case constants.FilterDeny:
+			c.blocked.Add(1)
+			result.Status, result.Body = r.status, r.body
+		}
+		result.Rule = r.name
+		return result
+	}
+
+	userAgent := req.UserAgent()
+	if userAgent == "" {
+		if cfg.ClientFilter.AllowEmptyUA {
+			result.Allowed = true
+			return result
+		}
+		return block(result, RuleEmptyUA)
+	}
+	contains := slices.ContainsFunc(cfg.ClientFilter.ClientList, func(ua string) bool { return strings.Contains(userAgent, ua) })
+	switch cfg.ClientFilter.Mode {
+	case constants.WHITELIST:
+		result.Allowed = contains
+	case constants.BLACKLIST:
+		result.Allowed = !contains
+	}
+	if !result.Allowed {
+		return block(result, RuleList)
+	}
+	return result
+}
+
+func block(result Result, name string) Result {
+	c := getCounter(name)
+	c.matched.Add(1)
+	c.blocked.Add(1)
+	result.Rule, result.Status = name, http.StatusForbidden
+	return result
+}
+
+func getCounter(name string) *counter {
+	if c, ok := counters.Load(name); ok {
+		return c.(*counter)
+	}
+	c, _ := counters.LoadOrStore(name, new(counter))
+	return c.(*counter)
+}
+
+// 获取各规则的匹配和拦截次数
+//
+// 按规则名称排序，只包含匹配过的规则
+func GetStats() []Stats {
+	var stats []Stats
+	counters.Range(func(key, value any) bool {
+		c := value.(*counter)
+		stats = append(stats, Stats{Rule: key.(string), Matched: c.matched.Load(), Blocked: c.blocked.Load()})
+		return true
+	})
+	slices.SortFunc(stats, func(a, b Stats) int { return strings.Compare(a.Rule, b.Rule) })
+	return stats
+}
@@ -0,0 +1,86 @@
+package clientfilter_test
+
+import (
+	"MediaWarp/constants"
+	"MediaWarp/internal/clientfilter"
+	"MediaWarp/internal/config"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestEvaluate(t *testing.T) {
+	config.Set(&config.Setting{ClientFilter: config.ClientFilterSetting{
+		Enable:     true,
+		Mode:       constants.BLACKLIST,
+		ClientList: []string{"Fileball"},
+		Rules: []config.ClientFilterRuleSetting{
+			{Name: "lan", IPs: []string{"192.168.0.0/16", "10.0.0.1"}, Action: constants.FilterAllow},
+			{Name: "audit", Headers: map[string]string{"x-emby-client": "(?i)^emby web$"}, Action: constants.FilterLog},
+			{Name: "night", UserAgent: "(?i)mozilla", Times: []string{"23:00-07:00"}, Weekdays: []string{"Mon", "Tuesday"}, Status: http.StatusTeapot, Body: "夜间禁止访问"},
+		},
+	}})
+	if err := clientfilter.Init(); err != nil {
+		t.Fatal(err)
+	}
+
+	monday := time.Date(2025, 1, 6, 23, 30, 0, 0, time.Local)
+	tests := map[string]struct {
+		ua      string
+		client  string
+		ip      string
+		now     time.Time
+		allowed bool
+		rule    string
+		logged  int
+	}{
+		"局域网放行":          {ua: "Fileball", ip: "192.168.1.2", now: monday, allowed: true, rule: "lan"},
+		"单个 IP 放行":       {ua: "Mozilla/5.0", ip: "10.0.0.1", now: monday, allowed: true, rule: "lan"},
+		"跨越午夜的时间段拦截":     {ua: "Mozilla/5.0", client: "Emby Web", ip: "1.1.1.1", now: monday.Add(time.Hour), allowed: false, rule: "night", logged: 1},
+		"时间段外放行":         {ua: "Mozilla/5.0", ip: "1.1.1.1", now: monday.Add(-2 * time.Hour), allowed: true},
+		"星期不匹配":          {ua: "Mozilla/5.0", ip: "1.1.1.1", now: monday.AddDate(0, 0, 3), allowed: true},
+		"黑名单拦截":          {ua: "Fileball/1.0", ip: "1.1.1.1", now: monday, allowed: false, rule: clientfilter.RuleList},
+		"未提供 User-Agent": {ip: "1.1.1.1", now: monday, allowed: false, rule: clientfilter.RuleEmptyUA},
+	}
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, "/Items", nil)
+			req.Header.Set("User-Agent", test.ua)
+			req.Header.Set("X-Emby-Client", test.client)
+			result := clientfilter.Evaluate(req, test.ip, test.now)
+			if result.Allowed != test.allowed || result.Rule != test.rule || len(result.Logged) != test.logged {
+				t.Errorf("过滤结果错误。期望: %v %q %d，实际: %+v", test.allowed, test.rule, test.logged, result)
+			}
+			if result.Rule == "night" && (result.Status != http.StatusTeapot || result.Body != "夜间禁止访问") {
+				t.Errorf("拦截响应错误：%+v", result)
+			}
+		})
+	}
+
+	stats := make(map[string]clientfilter.Stats)
+	for _, s := range clientfilter.GetStats() {
+		stats[s.Rule] = s
+	}
+	if stats["lan"].Matched != 2 || stats["lan"].Blocked != 0 || stats["night"].Blocked != 1 || stats[clientfilter.RuleList].Blocked != 1 {
+		t.Errorf("规则统计错误：%+v", stats)
+	}
+}
+
+func TestInit(t *testing.T) {
+	tests := map[string]config.ClientFilterRuleSetting{
+		"无效的正则表达式": {UserAgent: "("},
+		"无效的 CIDR": {IPs: []string{"192.168.0.0/33"}},
+		"无效的时间段":   {Times: []string{"23:00"}},
+		"无效的星期":    {Weekdays: []string{"Mo"}},
+		"未知的动作":    {Action: "block"},
+	}
+	for name, rule := range tests {
+		t.Run(name, func(t *testing.T) {
+			config.Set(&config.Setting{ClientFilter: config.ClientFilterSetting{Rules: []config.ClientFilterRuleSetting{rule}}})
+			if err := clientfilter.Init(); err == nil {
+				t.Error("规则无效时未返回错误")
+			}
+		})
+	}
+}
@@ -0,0 +1,698 @@
+package config
+
+import (
+	"MediaWarp/constants"
+	"MediaWarp/utils"
+	"bytes"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"gopkg.in/yaml.v3"
+)
+
+// 检查结果级别
+type CheckLevel uint8
+
+const (
+	CheckPass    CheckLevel = iota // 通过
+	CheckWarning                   // 警告，不影响运行
+	CheckError                     // 错误
+)
+
+func (l CheckLevel) String() string {
+	switch l {
+	case CheckPass:
+		return "通过"
+	case CheckWarning:
+		return "警告"
+	default:
+		return "错误"
+	}
+}
+
+// 单项检查结果
+type CheckItem struct {
+	Level   CheckLevel
+	Name    string // 检查项名称
+	Message string
+}
+
+// 配置检查报告
+type CheckReport struct {
+	Items []CheckItem
+}
+
+func (r *CheckReport) Add(level CheckLevel, name string, format string, args ...any) {
+	r.Items = append(r.Items, CheckItem{Level: level, Name: name, Message: fmt.Sprintf(format, args...)})
+}
+
+// 是否存在错误
+func (r *CheckReport) Failed() bool {
+	for _, item := range r.Items {
+		if item.Level == CheckError {
+			return true
+		}
+	}
+	return false
+}
+
+// 输出检查报告
+func (r *CheckReport) Print(w io.Writer) {
+	var warnings, errs int
+	for _, item := range r.Items {
+		fmt.Fprintf(w, "[%s] %s: %s\n", item.Level, item.Name, item.Message)
+		switch item.Level {
+		case CheckWarning:
+			warnings++
+		case CheckError:
+			errs++
+		}
+	}
+	fmt.Fprintf(w, "共检查 %d 项，%d 个警告，%d 个错误\n", len(r.Items), warnings, errs)
+}
+
+// 检查配置文件
+//
+// 严格解析配置文件（存在未知字段时报错）并校验各项设置
+// 检查通过时返回解析后的配置，用于后续的连通性检测
+func Check(path string) (*Setting, *CheckReport) {
+	var report CheckReport
+
+	files, err := configFiles(path)
+	if err != nil {
+		report.Add(CheckError, "配置文件", "%v", err)
+		return nil, &report
+	}
+	for _, file := range files { // 逐个文件严格解析，以便定位未知字段所在的文件
+		data, err := os.ReadFile(file)
+		if err != nil {
+			report.Add(CheckError, "配置文件", "读取配置文件 %s 失败: %v", file, err)
+			continue
+		}
+		var fragment Setting
+		decoder := yaml.NewDecoder(bytes.NewReader(data))
+		decoder.KnownFields(true)
+		if err = decoder.Decode(&fragment); err != nil && !errors.Is(err, io.EOF) {
+			report.Add(CheckError, "配置文件", "解析配置文件 %s 失败: %v", file, err)
+		}
+	}
+	if report.Failed() {
+		return nil, &report
+	}
+
+	s, used, err := decodeConfig(path)
+	if err != nil {
+		report.Add(CheckError, "配置文件", "%v", err)
+		return nil, &report
+	}
+	report.Add(CheckPass, "配置文件", "%s 解析成功，共合并 %d 个文件，使用 %d 个环境变量", path, len(files), len(used))
+	for _, name := range unusedEnv(used) {
+		report.Add(CheckWarning, "环境变量", "%s 未对应任何配置项", name)
+	}
+
+	checkSetting(s, &report)
+	return s, &report
+}
+
+// 校验配置各项设置
+func checkSetting(s *Setting, report *CheckReport) {
+	if err := validateSetting(s); err != nil {
+		report.Add(CheckError, "基础设置", "%v", err)
+	}
+
+	checkAddr(report, "server.addr", s.MediaServer.ADDR)
+	if s.MediaServer.AUTH == "" && s.MediaServer.Type != constants.FNTV {
+		report.Add(CheckWarning, "server.auth", "未设置媒体服务器认证密钥，部分功能将无法使用")
+	}
+
+	if s.AlistStrm.Enable {
+		endpoints := make(map[string]int)
+		for i, alist := range s.AlistStrm.List {
+			name := fmt.Sprintf("alist_strm.list[%d].addr", i)
+			checkAddr(report, name, alist.ADDR)
+			endpoint := strings.TrimSuffix(alist.ADDR, "/")
+			if j, ok := endpoints[endpoint]; ok {
+				report.Add(CheckWarning, name, "与 alist_strm.list[%d] 地址重复", j)
+			}
+			endpoints[endpoint] = i
+			if alist.Token == nil && alist.Username == "" {
+				report.Add(CheckWarning, fmt.Sprintf("alist_strm.list[%d]", i), "未设置用户名或令牌，将以访客身份访问")
+			}
+			for j, backend := range alist.Backends {
+				name := fmt.Sprintf("alist_strm.list[%d].backends[%d].addr", i, j)
+				checkAddr(report, name, backend.ADDR)
+				endpoint := strings.TrimSuffix(backend.ADDR, "/")
+				if endpoint == strings.TrimSuffix(alist.ADDR, "/") {
+					report.Add(CheckWarning, name, "与 alist_strm.list[%d].addr 相同", i)
+				}
+			}
+			for j, mapping := range alist.PathMapping {
+				name := fmt.Sprintf("alist_strm.list[%d].path_mapping[%d]", i, j)
+				if mapping.Prefix != "" && mapping.Regex != "" {
+					report.Add(CheckError, name, "不能同时设置 prefix 和 regex")
+				}
+				if _, err := regexp.Compile(mapping.Regex); err != nil {
+					report.Add(CheckError, name, "regex 不是有效的正则表达式: %v", err)
+				}
+			}
+		}
+	}
+
+	if s.AlistStrm.HealthCheckInterval < 0 {
+		report.Add(CheckError, "alist_strm.health_check_interval", "不能为负数")
+	}
+
+	for i, rule := range s.Stream.Headers {
+		for _, host := range rule.Hosts {
+			if _, err := path.Match(host, ""); err != nil {
+				report.Add(CheckError, fmt.Sprintf("stream.headers[%d].hosts", i), "%s 不是有效的通配符: %v", host, err)
+			}
+		}
+	}
+
+	if s.PlayURL.Enable {
+		switch {
+		case s.MediaServer.Type != constants.EMBY && s.MediaServer.Type != constants.JELLYFIN:
+			report.Add(CheckWarning, "play_url", "仅 Emby、Jellyfin 支持签名播放链接")
+		case s.PlayURL.TTL < 0:
+			report.Add(CheckError, "play_url.ttl", "不能为负数")
+		}
+		if s.PlayURL.Secret == "" {
+			report.Add(CheckWarning, "play_url.secret", "未设置签名密钥，将在启动时随机生成，重启后已签发的播放链接失效")
+		}
+	}
+
+	checkPrefixOverlap(s, report)
+	checkStrmRules(s, report)
+	checkMount(s, report)
+	checkStrmGenerate(s, report)
+	checkWebhook(s, report)
+	checkClientFilter(s, report)
+	checkPolicy(s, report)
+
+	if s.Prefetch.Enable {
+		switch {
+		case s.MediaServer.Type != constants.EMBY && s.MediaServer.Type != constants.JELLYFIN:
+			report.Add(CheckWarning, "prefetch", "仅 Emby、Jellyfin 支持预取下一集")
+		case s.Prefetch.Count < 0 || s.Prefetch.Concurrency < 0 || s.Prefetch.TTL < 0:
+			report.Add(CheckError, "prefetch", "count、concurrency、ttl 不能为负数")
+		}
+	}
+
+	if s.Refresh.Enable {
+		switch {
+		case s.MediaServer.Type != constants.EMBY && s.MediaServer.Type != constants.JELLYFIN:
+			report.Add(CheckWarning, "library_refresh", "仅 Emby、Jellyfin 支持刷新媒体库")
+		case s.Refresh.Debounce < 0:
+			report.Add(CheckError, "library_refresh.debounce", "不能为负数")
+		}
+		for i, mapping := range s.Refresh.PathMapping {
+			name := fmt.Sprintf("library_refresh.path_mapping[%d]", i)
+			if mapping.Prefix != "" && mapping.Regex != "" {
+				report.Add(CheckError, name, "不能同时设置 prefix 和 regex")
+			}
+			if _, err := regexp.Compile(mapping.Regex); err != nil {
+				report.Add(CheckError, name, "regex 不是有效的正则表达式: %v", err)
+			}
+		}
+	}
+
+	if s.Subtitle.Enable && s.Subtitle.SRT2ASS {
+		checkASSStyle(s.Subtitle.ASSStyle, report)
+	}
+	if s.Subtitle.Enable {
+		if s.MediaServer.Type == constants.PLEX {
+			report.Add(CheckWarning, "subtitle", "Plex 不支持字幕处理")
+		}
+		checkSubtitleAdjust(s.Subtitle.Adjust, report)
+	}
+	if s.Subtitle.Enable && s.Subtitle.External {
+		if s.MediaServer.Type != constants.EMBY && s.MediaServer.Type != constants.JELLYFIN {
+			report.Add(CheckWarning, "subtitle.external", "仅 Emby、Jellyfin 支持添加 Alist 外挂字幕")
+		}
+		if !s.AlistStrm.Enable {
+			report.Add(CheckWarning, "subtitle.external", "未启用 alist_strm，external 不会生效")
+		}
+	}
+	if s.Subtitle.Enable && s.Subtitle.SubSet {
+		if s.Subtitle.FontDir == "" {
+			report.Add(CheckError, "subtitle.font_dir", "已启用字体子集化，但未设置字体目录")
+		} else if info, err := os.Stat(s.Subtitle.FontDir); err != nil || !info.IsDir() {
+			report.Add(CheckError, "subtitle.font_dir", "%s 不是有效的目录", s.Subtitle.FontDir)
+		}
+	}
+
+	if s.Cache.Enable {
+		if s.Cache.HTTPStrmTTL <= 0 && s.Cache.AlistAPITTL <= 0 && s.Cache.ImageTTL <= 0 && s.Cache.SubtitleTTL <= 0 {
+			report.Add(CheckWarning, "cache", "已启用缓存但所有有效期均不大于 0，缓存不会生效")
+		}
+		if s.Cache.MaxMemoryMB < 0 || s.Cache.MaxDiskMB < 0 || s.Cache.Shards < 0 || s.Cache.MaxEntriesPerShard < 0 {
+			report.Add(CheckError, "cache", "缓存容量相关设置不能为负数")
+		}
+	}
+}
+
+// 校验地址格式
+func checkAddr(report *CheckReport, name string, addr string) {
+	if addr == "" {
+		return // 已在 validateSetting 中检查
+	}
+	if !strings.HasPrefix(addr, "http") {
+		addr = "http://" + addr
+	}
+	u, err := url.Parse(addr)
+	if err != nil {
+		report.Add(CheckError, name, "地址格式错误: %v", err)
+		return
+	}
+	if u.Scheme != "http" && u.Scheme != "https" {
+		report.Add(CheckError, name, "不支持的协议: %s", u.Scheme)
+		return
+	}
+	if u.Host == "" {
+		report.Add(CheckError, name, "地址缺少主机名: %s", addr)
+		return
+	}
+	if u.Path != "" && u.Path != "/" {
+		report.Add(CheckWarning, name, "地址包含路径 %s，请确认是否正确", u.Path)
+		return
+	}
+	report.Add(CheckPass, name, "%s", addr)
+}
+
+// 检查 HTTPStrm 与 AlistStrm 前缀是否重叠
+//
+// HTTPStrm 优先匹配：
+// AlistStrm 前缀被 HTTPStrm 前缀完全覆盖时永远不会生效，视为错误
+// HTTPStrm 前缀位于 AlistStrm 前缀之下时仅提示
+func checkPrefixOverlap(s *Setting, report *CheckReport) {
+	if !s.HTTPStrm.Enable || !s.AlistStrm.Enable {
+		return
+	}
+	overlapped := false
+	for _, httpPrefix := range s.HTTPStrm.PrefixList {
+		for i, alist := range s.AlistStrm.List {
+			for _, alistPrefix := range alist.PrefixList {
+				switch {
+				case utils.HasPathPrefix(alistPrefix, httpPrefix):
+					overlapped = true
+					report.Add(
+						CheckError,
+						"prefix_list",
+						"alist_strm.list[%d].prefix_list 中的 %s 被 http_strm.prefix_list 中的 %s 覆盖，不会生效",
+						i, alistPrefix, httpPrefix,
+					)
+				case utils.HasPathPrefix(httpPrefix, alistPrefix):
+					overlapped = true
+					report.Add(
+						CheckWarning,
+						"prefix_list",
+						"http_strm.prefix_list 中的 %s 位于 alist_strm.list[%d].prefix_list 中的 %s 之下，该路径按 HTTPStrm 处理",
+						httpPrefix, i, alistPrefix,
+					)
+				}
+			}
+		}
+	}
+	if !overlapped {
+		report.Add(CheckPass, "prefix_list", "HTTPStrm 与 AlistStrm 路径前缀无重叠")
+	}
+}
+
+// 检查网盘挂载文件重定向设置
+func checkMount(s *Setting, report *CheckReport) {
+	if !s.Mount.Enable {
+		return
+	}
+	switch {
+	case !s.AlistStrm.Enable:
+		report.Add(CheckWarning, "mount", "alist_strm 未启用，网盘挂载文件重定向不会生效")
+	case len(s.Mount.PrefixList) == 0:
+		report.Add(CheckWarning, "mount", "未设置 prefix_list，网盘挂载文件重定向不会生效")
+	default:
+		for _, alist := range s.AlistStrm.List {
+			for _, mapping := range alist.PathMapping {
+				if mapping.Prefix != "" || mapping.Regex != "" {
+					report.Add(CheckPass, "mount", "网盘挂载文件重定向已启用")
+					return
+				}
+			}
+		}
+		report.Add(CheckWarning, "mount", "alist_strm.list 中没有设置 prefix 或 regex 路径映射，网盘挂载文件无法找到对应的 Alist 路径")
+	}
+}
+
+// 检查 Strm 匹配规则
+func checkStrmRules(s *Setting, report *CheckReport) {
+	for i, rule := range s.StrmRules {
+		name := fmt.Sprintf("strm_rules[%d]", i)
+		if rule.Name != "" {
+			name += "(" + rule.Name + ")"
+		}
+		failed := false
+		fail := func(format string, args ...any) {
+			failed = true
+			report.Add(CheckError, name, format, args...)
+		}
+
+		switch rule.Type {
+		case constants.HTTPStrm:
+			if !s.HTTPStrm.Enable {
+				report.Add(CheckWarning, name, "http_strm 未启用，规则不会生效")
+			}
+		case constants.AlistStrm:
+			if !s.AlistStrm.Enable {
+				report.Add(CheckWarning, name, "alist_strm 未启用，规则不会生效")
+			}
+			found := false
+			for _, alist := range s.AlistStrm.List {
+				if strings.TrimSuffix(alist.ADDR, "/") == strings.TrimSuffix(rule.Alist, "/") {
+					found = true
+				}
+			}
+			if !found {
+				fail("alist %q 未在 alist_strm.list 中配置", rule.Alist)
+			}
+		default:
+			fail("未设置 type（可选选项：HTTPStrm、AlistStrm）")
+		}
+		for field, pattern := range map[string]string{"regex": rule.Regex, "user_agent": rule.UserAgent} {
+			if _, err := regexp.Compile(pattern); err != nil {
+				fail("%s 不是有效的正则表达式: %v", field, err)
+			}
+		}
+		if !failed {
+			report.Add(CheckPass, name, "%s 规则有效", rule.Type)
+		}
+	}
+}
+
+// 检查 Strm 文件生成任务
+func checkStrmGenerate(s *Setting, report *CheckReport) {
+	if !s.StrmGenerate.Enable {
+		return
+	}
+	if s.StrmGenerate.Interval < 0 {
+		report.Add(CheckError, "strm_generate.interval", "不能为负数")
+	}
+	if !s.AlistStrm.Enable {
+		report.Add(CheckError, "strm_generate", "alist_strm 未启用，无法生成 Strm 文件")
+		return
+	}
+
+	names := make(map[string]int)
+	for i, task := range s.StrmGenerate.Tasks {
+		name := fmt.Sprintf("strm_generate.tasks[%d]", i)
+		if task.Name != "" {
+			name += "(" + task.Name + ")"
+			if j, ok := names[task.Name]; ok {
+				report.Add(CheckError, name, "与 strm_generate.tasks[%d] 名称重复", j)
+			}
+			names[task.Name] = i
+		}
+		failed := false
+		fail := func(format string, args ...any) {
+			failed = true
+			report.Add(CheckError, name, format, args...)
+		}
+
+		found := false
+		for _, alist := range s.AlistStrm.List {
+			if strings.TrimSuffix(alist.ADDR, "/") == strings.TrimSuffix(task.Alist, "/") {
+				found = true
+			}
+		}
+		if !found {
+			fail("alist %q 未在 alist_strm.list 中配置", task.Alist)
+		}
+		if task.Source == "" {
+			fail("未设置 source")
+		}
+		if task.Output == "" {
+			fail("未设置 output")
+		}
+		if task.Type != constants.AlistStrm && task.Type != constants.HTTPStrm {
+			fail("未设置 type（可选选项：HTTPStrm、AlistStrm）")
+		}
+		for j, other := range s.StrmGenerate.Tasks[:i] {
+			if task.Output != "" && other.Output != "" && pathOverlap(task.Output, other.Output) {
+				fail("output %s 与 strm_generate.tasks[%d] 的 output %s 重叠", task.Output, j, other.Output)
+			}
+		}
+		if !failed {
+			report.Add(CheckPass, name, "%s => %s", task.Source, task.Output)
+		}
+	}
+}
+
+// 两个本地目录是否相同或存在包含关系
+func pathOverlap(a string, b string) bool {
+	within := func(parent string, child string) bool {
+		rel, err := filepath.Rel(parent, child)
+		return err == nil && filepath.IsLocal(rel)
+	}
+	a, b = filepath.Clean(a), filepath.Clean(b)
+	return within(a, b) || within(b, a)
+}
+
+// 检查客户端过滤规则
+func checkClientFilter(s *Setting, report *CheckReport) {
+	if !s.ClientFilter.Enable {
+		return
+	}
+	for _, value := range s.ClientFilter.TrustedProxies {
+		if !validNetwork(value) {
+			report.Add(CheckError, "client.trusted_proxies", "%q 不是有效的 IP 或 CIDR", value)
+		}
+	}
+	useIP := false
+	for i, rule := range s.ClientFilter.Rules {
+		name := fmt.Sprintf("client.rules[%d]", i)
+		if rule.Name != "" {
+			name += "(" + rule.Name + ")"
+		}
+		failed := false
+		fail := func(format string, args ...any) {
+			failed = true
+			report.Add(CheckError, name, format, args...)
+		}
+
+		switch rule.Action {
+		case "", constants.FilterAllow, constants.FilterDeny, constants.FilterLog:
+		default:
+			fail("未知的 action %q（可选选项：allow、deny、log）", rule.Action)
+		}
+		if _, err := regexp.Compile(rule.UserAgent); err != nil {
+			fail("user_agent 不是有效的正则表达式: %v", err)
+		}
+		for header, pattern := range rule.Headers {
+			if _, err := regexp.Compile(pattern); err != nil {
+				fail("headers.%s 不是有效的正则表达式: %v", header, err)
+			}
+		}
+		for _, value := range rule.IPs {
+			useIP = true
+			if !validNetwork(value) {
+				fail("%q 不是有效的 IP 或 CIDR", value)
+			}
+		}
+		for _, value := range rule.Times {
+			start, end, ok := strings.Cut(value, "-")
+			_, err1 := time.Parse("15:04", strings.TrimSpace(start))
+			_, err2 := time.Parse("15:04", strings.TrimSpace(end))
+			if !ok || err1 != nil || err2 != nil {
+				fail("无效的时间段 %q，格式应为 HH:MM-HH:MM", value)
+			}
+		}
+		for _, value := range rule.Weekdays {
+			if !validWeekday(value) {
+				fail("无效的星期 %q（可选选项：Sun、Mon、Tue、Wed、Thu、Fri、Sat）", value)
+			}
+		}
+		if rule.Status != 0 && (rule.Status < 100 || rule.Status > 599) {
+			fail("无效的状态码 %d", rule.Status)
+		}
+		if !failed && rule.UserAgent == "" && len(rule.Headers) == 0 && len(rule.IPs) == 0 && len(rule.Times) == 0 && len(rule.Weekdays) == 0 {
+			report.Add(CheckWarning, name, "未设置任何条件，将匹配所有请求")
+		}
+	}
+	if useIP && len(s.ClientFilter.TrustedProxies) == 0 {
+		report.Add(CheckWarning, "client.trusted_proxies", "未设置可信代理，通过反向代理访问时按代理的 IP 匹配规则")
+	}
+}
+
+// 是否为有效的星期，支持 Sun、Sunday 等英文名称
+func validWeekday(value string) bool {
+	value = strings.ToLower(strings.TrimSpace(value))
+	for day := time.Sunday; day <= time.Saturday; day++ {
+		if len(value) >= 3 && strings.HasPrefix(strings.ToLower(day.String()), value) {
+			return true
+		}
+	}
+	return false
+}
+
+// 是否为有效的 IP 或 CIDR
+func validNetwork(value string) bool {
+	if _, _, err := net.ParseCIDR(value); err == nil {
+		return true
+	}
+	return net.ParseIP(value) != nil
+}
+
+// 检查访问策略
+func checkPolicy(s *Setting, report *CheckReport) {
+	if !s.Policy.Enable {
+		return
+	}
+	if s.MediaServer.Type != constants.EMBY && s.MediaServer.Type != constants.JELLYFIN {
+		report.Add(CheckWarning, "policy", "仅 Emby、Jellyfin 支持访问策略")
+	}
+	if s.Policy.CacheTTL < 0 {
+		report.Add(CheckError, "policy.cache_ttl", "不能为负数")
+	}
+	for i, rule := range s.Policy.Rules {
+		name := fmt.Sprintf("policy.rules[%d]", i)
+		if rule.Name != "" {
+			name += "(" + rule.Name + ")"
+		}
+		matchAll := len(rule.Users) == 0 && len(rule.Devices) == 0 && len(rule.Clients) == 0
+		switch {
+		case matchAll && rule.Deny:
+			report.Add(CheckWarning, name, "未设置 users、devices、clients，将拒绝所有请求")
+		case matchAll && i < len(s.Policy.Rules)-1:
+			report.Add(CheckWarning, name, "未设置 users、devices、clients，之后的规则不会生效")
+		case !rule.Deny && !rule.DisableStrm && !rule.ForceTranscode && len(rule.Libraries) == 0:
+			report.Add(CheckWarning, name, "未设置任何限制")
+		}
+	}
+}
+
+// 检查字幕调整规则
+func checkSubtitleAdjust(rules []SubtitleAdjustSetting, report *CheckReport) {
+	for i, rule := range rules {
+		name := fmt.Sprintf("subtitle.adjust[%d]", i)
+		switch rule.Chinese {
+		case "", constants.ChineseS2T, constants.ChineseT2S:
+		default:
+			report.Add(CheckError, name+".chinese", "无效的简繁转换方式 %s，仅支持 s2t、t2s", rule.Chinese)
+		}
+		if rule.FPS != "" {
+			from, to, ok := strings.Cut(rule.FPS, ":")
+			a, errA := strconv.ParseFloat(strings.TrimSpace(from), 64)
+			b, errB := strconv.ParseFloat(strings.TrimSpace(to), 64)
+			if !ok || errA != nil || errB != nil || a <= 0 || b <= 0 {
+				report.Add(CheckError, name+".fps", "格式应为 原帧率:目标帧率，如 25:23.976")
+			}
+		}
+		if rule.Bilingual && rule.Chinese == "" {
+			report.Add(CheckWarning, name+".bilingual", "未设置 chinese，bilingual 不会生效")
+		}
+		if len(rule.Libraries) == 0 && i < len(rules)-1 {
+			report.Add(CheckWarning, name, "未设置 libraries，之后的规则不会生效")
+		}
+	}
+}
+
+// 检查 Webhook 设置
+func checkWebhook(s *Setting, report *CheckReport) {
+	if !s.Webhook.Enable {
+		return
+	}
+	if s.MediaServer.Type != constants.EMBY && s.MediaServer.Type != constants.JELLYFIN {
+		report.Add(CheckWarning, "webhook", "仅 Emby、Jellyfin 支持 Webhook")
+	}
+
+	names := make(map[string]struct{})
+	for i, task := range s.StrmGenerate.Tasks {
+		if task.Name == "" {
+			task.Name = fmt.Sprintf("tasks[%d]", i)
+		}
+		names[task.Name] = struct{}{}
+	}
+	for i, setting := range s.Webhook.StrmGenerate {
+		name := fmt.Sprintf("webhook.strm_generate[%d]", i)
+		if !s.StrmGenerate.Enable {
+			report.Add(CheckError, name, "strm_generate 未启用")
+			continue
+		}
+		if setting.Delay < 0 {
+			report.Add(CheckError, name+".delay", "不能为负数")
+		}
+		for _, task := range setting.Tasks {
+			if _, ok := names[task]; !ok {
+				report.Add(CheckError, name, "任务 %q 未在 strm_generate.tasks 中配置", task)
+			}
+		}
+	}
+
+	for i, setting := range s.Webhook.Forward {
+		name := fmt.Sprintf("webhook.forward[%d].url", i)
+		u, err := url.Parse(setting.URL)
+		if err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			report.Add(CheckError, name, "%q 不是有效的 HTTP 地址", setting.URL)
+		}
+	}
+}
+
+// 检查 ASS 样式
+//
+// 第一行必须为 Format 行，之后的 Style 行字段数需要与 Format 一致
+func checkASSStyle(lines []string, report *CheckReport) {
+	const name = "subtitle.ass_style"
+	if len(lines) == 0 {
+		report.Add(CheckError, name, "已启用 SRT 字幕转 ASS 字幕，但未设置样式")
+		return
+	}
+
+	format, ok := strings.CutPrefix(strings.TrimSpace(lines[0]), "Format:")
+	if !ok {
+		report.Add(CheckError, name, "第 1 行必须以 Format: 开头")
+		return
+	}
+	fields := strings.Split(format, ",")
+	hasName := false
+	for _, field := range fields {
+		if strings.TrimSpace(field) == "Name" {
+			hasName = true
+		}
+	}
+	if !hasName {
+		report.Add(CheckError, name, "Format 行缺少 Name 字段")
+		return
+	}
+
+	failed := false
+	styles := 0
+	for i, line := range lines[1:] {
+		style, ok := strings.CutPrefix(strings.TrimSpace(line), "Style:")
+		if !ok {
+			failed = true
+			report.Add(CheckError, name, "第 %d 行必须以 Style: 开头", i+2)
+			continue
+		}
+		if n := len(strings.Split(style, ",")); n != len(fields) {
+			failed = true
+			report.Add(CheckError, name, "第 %d 行字段数为 %d，与 Format 行的 %d 不一致", i+2, n, len(fields))
+			continue
+		}
+		styles++
+	}
+	if styles == 0 && !failed {
+		report.Add(CheckError, name, "至少需要一行 Style")
+		return
+	}
+	if !failed {
+		report.Add(CheckPass, name, "共 %d 个样式", styles)
+	}
+}
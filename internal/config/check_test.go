@@ -0,0 +1,119 @@
+package config_test
+
+import (
+	"MediaWarp/internal/config"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+const baseConfig = `
+port: 9000
+server:
+  type: Emby
+  addr: http://localhost:8096
+  auth: key
+`
+
+func TestCheck(t *testing.T) {
+	tests := map[string]struct {
+		content string
+		failed  bool
+		message string // 报告中应包含的内容
+	}{
+		"有效配置": {
+			content: baseConfig,
+			failed:  false,
+		},
+		"未知字段": {
+			content: baseConfig + "subtitle:\n  art2ass: true\n",
+			failed:  true,
+			message: "art2ass",
+		},
+		"缺少媒体服务器地址": {
+			content: "port: 9000\nserver:\n  type: Emby\n",
+			failed:  true,
+			message: "server.addr",
+		},
+		"AlistStrm 前缀被覆盖": {
+			content: baseConfig + `
+http_strm:
+  enable: true
+  prefix_list: ["/media"]
+alist_strm:
+  enable: true
+  list:
+    - addr: http://localhost:5244
+      prefix_list: ["/media/alist"]
+`,
+			failed:  true,
+			message: "不会生效",
+		},
+		"HTTPStrm 前缀位于 AlistStrm 前缀之下": {
+			content: baseConfig + `
+http_strm:
+  enable: true
+  prefix_list: ["/media/http"]
+alist_strm:
+  enable: true
+  list:
+    - addr: http://localhost:5244
+      prefix_list: ["/media"]
+`,
+			failed:  false,
+			message: "按 HTTPStrm 处理",
+		},
+		"ASS 样式字段数不一致": {
+			content: baseConfig + `
+subtitle:
+  enable: true
+  srt2ass: true
+  ass_style:
+    - "Format: Name, Fontname, Fontsize"
+    - "Style: Default,楷体"
+`,
+			failed:  true,
+			message: "字段数为 2",
+		},
+		"Strm 生成任务输出目录重叠": {
+			content: baseConfig + `
+alist_strm:
+  enable: true
+  list:
+    - addr: http://localhost:5244
+strm_generate:
+  enable: true
+  tasks:
+    - {alist: http://localhost:5244, source: /movies, output: /media/strm, type: AlistStrm}
+    - {alist: http://localhost:5244, source: /tv, output: /media/strm/tv, type: AlistStrm}
+`,
+			failed:  true,
+			message: "重叠",
+		},
+		"签名播放链接未设置密钥": {
+			content: baseConfig + "play_url:\n  enable: true\n  ttl: 1h\n",
+			failed:  false,
+			message: "play_url.secret",
+		},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), "config.yaml")
+			if err := os.WriteFile(path, []byte(test.content), 0644); err != nil {
+				t.Fatal(err)
+			}
+			_, report := config.Check(path)
+
+			var output strings.Builder
+			report.Print(&output)
+			if report.Failed() != test.failed {
+				t.Errorf("检查结果错误。期望失败: %v，报告:\n%s", test.failed, output.String())
+			}
+			if !strings.Contains(output.String(), test.message) {
+				t.Errorf("报告中缺少 %q，报告:\n%s", test.message, output.String())
+			}
+		})
+	}
+}
@@ -1,10 +1,14 @@
 package config
 
 import (
+	"errors"
 	"fmt"
 	"os"
 	"path/filepath"
 	"runtime"
+	"strings"
+	"sync"
+	"sync/atomic"
 	"time"
 
 	"gopkg.in/yaml.v3"
@@ -20,16 +24,31 @@ var (
 		Arch:       runtime.GOARCH,
 	}
 
-	Port         uint16              // MediaWarp开放端口
-	MediaServer  MediaServerSetting  // 上游媒体服务器设置
-	Logger       LoggerSetting       // 日志设置
-	Web          WebSetting          // Web服务器设置
-	ClientFilter ClientFilterSetting // 客户端过滤设置
-	HTTPStrm     HTTPStrmSetting     // HTTPSTRM设置
-	AlistStrm    AlistStrmSetting    // AlistStrm设置
-	Subtitle     SubtitleSetting     // 字幕设置
+	active atomic.Pointer[Setting] // 当前生效的配置，重新加载时整体替换
+
+	configFilePath string     // 当前使用的配置文件路径
+	reloadMutex    sync.Mutex // 保证同一时刻只有一次重新加载
 )
 
+func init() {
+	active.Store(&Setting{})
+}
+
+// 获取当前生效的配置
+//
+// 返回的配置是只读快照，重新加载时会整体替换为新的配置而不会修改已有快照；
+// 处理同一个请求时应只获取一次，避免前后读取到不同版本的配置
+func Get() *Setting {
+	return active.Load()
+}
+
+// 替换当前生效的配置
+//
+// 替换后不应再修改 s，主要用于测试
+func Set(s *Setting) {
+	active.Store(s)
+}
+
 // 获取版本信息
 func Version() *VersionInfo {
 	return &version
@@ -82,7 +101,7 @@ func CostomDir() string {
 //
 // 监听所有网卡
 func ListenAddr() string {
-	return fmt.Sprintf(":%d", Port)
+	return fmt.Sprintf(":%d", Get().Port)
 }
 
 // 初始化configManager
@@ -98,24 +117,65 @@ func Init(path string) error {
 
 // 读取并解析配置文件
 func loadConfig(path string) error {
-	var s Setting
-	data, err := os.ReadFile(path)
+	s, err := readConfig(path)
+	if err != nil {
+		return err
+	}
+	configFilePath = path
+	Set(s)
+	return nil
+}
+
+// 读取、解析并校验配置文件，不修改当前配置
+func readConfig(path string) (*Setting, error) {
+	s, _, err := decodeConfig(path)
 	if err != nil {
-		return fmt.Errorf("读取配置文件失败: %v", err)
+		return nil, err
+	}
+	if err = validateSetting(s); err != nil {
+		return nil, fmt.Errorf("配置文件校验失败: %v", err)
 	}
-	err = yaml.Unmarshal(data, &s)
+	return s, nil
+}
+
+// 校验配置
+func validateSetting(s *Setting) error {
+	if s.Port == 0 {
+		return errors.New("port 不能为空")
+	}
+	if s.MediaServer.ADDR == "" {
+		return errors.New("server.addr 不能为空")
+	}
+	for i, alist := range s.AlistStrm.List {
+		if alist.ADDR == "" {
+			return fmt.Errorf("alist_strm.list[%d].addr 不能为空", i)
+		}
+	}
+	return nil
+}
+
+// 重新加载配置文件
+//
+// 新配置校验通过后调用 prepare 根据新配置创建依赖配置的组件，此时不影响当前使用的配置和组件
+// prepare 成功后替换当前配置并调用其返回的函数启用新组件；配置文件无效或 prepare 返回错误时继续使用原配置
+func Reload(prepare func(s *Setting) (func(), error)) error {
+	reloadMutex.Lock()
+	defer reloadMutex.Unlock()
+
+	s, err := readConfig(configFilePath)
 	if err != nil {
-		return fmt.Errorf("解析配置文件失败: %v", err)
+		return err
+	}
+	if s.Port != Get().Port {
+		return fmt.Errorf("port 修改为 %d 需要重启 MediaWarp 后生效", s.Port)
 	}
 
-	Port = s.Port
-	MediaServer = s.MediaServer
-	Logger = s.Logger
-	Web = s.Web
-	ClientFilter = s.ClientFilter
-	HTTPStrm = s.HTTPStrm
-	AlistStrm = s.AlistStrm
-	Subtitle = s.Subtitle
+	publish, err := prepare(s)
+	if err != nil {
+		return err
+	}
+	Set(s)
+	publish()
 	return nil
 }
 
@@ -132,3 +192,51 @@ func createDir() error {
 	}
 	return nil
 }
+
+var secretKeys = []string{"auth", "password", "token", "secret"} // 需要脱敏的配置项名称关键字
+
+// 获取当前生效的配置
+//
+// 以 YAML 字段名组织，认证密钥、密码等敏感信息已脱敏
+func Effective() (map[string]any, error) {
+	data, err := yaml.Marshal(Get())
+	if err != nil {
+		return nil, err
+	}
+	var effective map[string]any
+	if err = yaml.Unmarshal(data, &effective); err != nil {
+		return nil, err
+	}
+	redact(effective)
+	return effective, nil
+}
+
+// 脱敏敏感信息
+func redact(value any) {
+	switch v := value.(type) {
+	case map[string]any:
+		for key, item := range v {
+			if isSecretKey(key) {
+				if item != nil && item != "" {
+					v[key] = "******"
+				}
+				continue
+			}
+			redact(item)
+		}
+	case []any:
+		for _, item := range v {
+			redact(item)
+		}
+	}
+}
+
+func isSecretKey(key string) bool {
+	key = strings.ToLower(key)
+	for _, secretKey := range secretKeys {
+		if strings.Contains(key, secretKey) {
+			return true
+		}
+	}
+	return false
+}
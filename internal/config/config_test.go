@@ -0,0 +1,91 @@
+package config_test
+
+import (
+	"MediaWarp/internal/config"
+	"errors"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+)
+
+const reloadConfig = `
+port: 9000
+server:
+  type: Emby
+  addr: http://localhost:8096
+  auth: key
+cache:
+  image_ttl: `
+
+func TestReload(t *testing.T) {
+	dir := t.TempDir()
+	t.Chdir(dir)
+	path := filepath.Join(dir, "config.yaml")
+	write := func(ttl string) {
+		if err := os.WriteFile(path, []byte(reloadConfig+ttl+"\n"), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	write("10m")
+	if err := config.Init(path); err != nil {
+		t.Fatal(err)
+	}
+
+	old := config.Get()
+	write("20m")
+	done := make(chan struct{})
+	var wg sync.WaitGroup
+	wg.Add(1)
+	go func() { // 重新加载时并发读取配置
+		defer wg.Done()
+		for {
+			select {
+			case <-done:
+				return
+			default:
+				if ttl := config.Get().Cache.ImageTTL; ttl != 10*time.Minute && ttl != 20*time.Minute {
+					t.Errorf("读取到无效的配置：%s", ttl)
+					return
+				}
+			}
+		}
+	}()
+	var prepared *config.Setting
+	err := config.Reload(func(s *config.Setting) (func(), error) {
+		prepared = s
+		if config.Get() != old {
+			t.Error("prepare 期间不应替换当前配置")
+		}
+		return func() {
+			if config.Get() != s {
+				t.Error("启用新组件时期望已替换为新配置")
+			}
+		}, nil
+	})
+	close(done)
+	wg.Wait()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if config.Get() != prepared {
+		t.Error("重新加载后期望使用 prepare 收到的配置")
+	}
+	if ttl := config.Get().Cache.ImageTTL; ttl != 20*time.Minute {
+		t.Errorf("重新加载后期望 20m，实际: %s", ttl)
+	}
+	if ttl := old.Cache.ImageTTL; ttl != 10*time.Minute {
+		t.Errorf("已获取的配置快照不应被修改，实际: %s", ttl)
+	}
+
+	current := config.Get()
+	write("30m")
+	err = config.Reload(func(*config.Setting) (func(), error) { return nil, errors.New("prepare failed") })
+	if err == nil {
+		t.Fatal("prepare 返回错误时期望重新加载失败")
+	}
+	if config.Get() != current {
+		t.Errorf("重新加载失败时期望继续使用原配置，实际: %+v", config.Get().Cache)
+	}
+}
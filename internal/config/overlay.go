@@ -0,0 +1,266 @@
+package config
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"reflect"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+)
+
+const (
+	envPrefix     = "MEDIAWARP" // 环境变量前缀
+	envFileSuffix = "_FILE"     // 从文件中读取环境变量值的后缀
+	confDir       = "conf.d"    // 配置片段目录，位于主配置文件所在目录下
+)
+
+// 获取配置涉及的所有文件
+//
+// 依次为主配置文件、include 中匹配的文件、conf.d 目录下的 YAML 文件
+// 后面的文件覆盖前面的文件
+func configFiles(path string) ([]string, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("读取配置文件失败: %v", err)
+	}
+	var main struct {
+		Include []string `yaml:"include"`
+	}
+	if err = yaml.Unmarshal(data, &main); err != nil {
+		return nil, fmt.Errorf("解析配置文件 %s 失败: %v", path, err)
+	}
+
+	dir := filepath.Dir(path)
+	files := []string{path}
+	seen := map[string]struct{}{filepath.Clean(path): {}}
+	addFiles := func(pattern string) error {
+		matches, err := filepath.Glob(pattern)
+		if err != nil {
+			return fmt.Errorf("include 规则 %s 无效: %v", pattern, err)
+		}
+		sort.Strings(matches)
+		for _, match := range matches {
+			if info, err := os.Stat(match); err != nil || info.IsDir() {
+				continue
+			}
+			if _, ok := seen[filepath.Clean(match)]; ok {
+				continue
+			}
+			seen[filepath.Clean(match)] = struct{}{}
+			files = append(files, match)
+		}
+		return nil
+	}
+
+	for _, pattern := range main.Include {
+		if !filepath.IsAbs(pattern) {
+			pattern = filepath.Join(dir, pattern)
+		}
+		if err = addFiles(pattern); err != nil {
+			return nil, err
+		}
+	}
+	for _, ext := range []string{"*.yaml", "*.yml"} {
+		if err = addFiles(filepath.Join(dir, confDir, ext)); err != nil {
+			return nil, err
+		}
+	}
+	return files, nil
+}
+
+// 解析配置
+//
+// 合并所有配置文件后应用环境变量，返回配置和已使用的环境变量名
+func decodeConfig(path string) (*Setting, []string, error) {
+	files, err := configFiles(path)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	merged := make(map[string]any)
+	for _, file := range files {
+		data, err := os.ReadFile(file)
+		if err != nil {
+			return nil, nil, fmt.Errorf("读取配置文件 %s 失败: %v", file, err)
+		}
+		var fragment map[string]any
+		if err = yaml.Unmarshal(data, &fragment); err != nil {
+			return nil, nil, fmt.Errorf("解析配置文件 %s 失败: %v", file, err)
+		}
+		mergeMap(merged, fragment)
+	}
+
+	data, err := yaml.Marshal(merged)
+	if err != nil {
+		return nil, nil, fmt.Errorf("合并配置文件失败: %v", err)
+	}
+	var s Setting
+	if err = yaml.Unmarshal(data, &s); err != nil {
+		return nil, nil, fmt.Errorf("解析配置文件失败: %v", err)
+	}
+
+	used, err := applyEnv(&s, environ())
+	if err != nil {
+		return nil, nil, err
+	}
+	return &s, used, nil
+}
+
+// 深度合并配置
+//
+// 映射递归合并，其他类型（包括列表）直接覆盖
+func mergeMap(dst map[string]any, src map[string]any) {
+	for key, value := range src {
+		srcMap, ok := value.(map[string]any)
+		if !ok {
+			dst[key] = value
+			continue
+		}
+		dstMap, ok := dst[key].(map[string]any)
+		if !ok {
+			dstMap = make(map[string]any)
+			dst[key] = dstMap
+		}
+		mergeMap(dstMap, srcMap)
+	}
+}
+
+// 获取所有 MEDIAWARP_ 前缀的环境变量
+func environ() map[string]string {
+	env := make(map[string]string)
+	for _, kv := range os.Environ() {
+		key, value, _ := strings.Cut(kv, "=")
+		if strings.HasPrefix(key, envPrefix+"_") {
+			env[key] = value
+		}
+	}
+	return env
+}
+
+// 未被任何配置项使用的环境变量
+func unusedEnv(used []string) []string {
+	usedSet := make(map[string]struct{}, len(used))
+	for _, name := range used {
+		usedSet[name] = struct{}{}
+	}
+	var unused []string
+	for name := range environ() {
+		if _, ok := usedSet[strings.TrimSuffix(name, envFileSuffix)]; !ok {
+			unused = append(unused, name)
+		}
+	}
+	sort.Strings(unused)
+	return unused
+}
+
+// 使用环境变量覆盖配置
+//
+// 环境变量名为 MEDIAWARP_ 加上大写的 YAML 路径，以 _ 连接，如：
+//
+//	MEDIAWARP_SERVER_AUTH
+//	MEDIAWARP_ALIST_STRM_LIST_0_PASSWORD
+//
+// 字符串列表使用逗号分隔，元素中含有逗号时（如 ASS 样式）使用 YAML 列表写法，如 ["a, b", "c"]；
+// 以 _FILE 结尾的环境变量从对应文件中读取值
+func applyEnv(s *Setting, env map[string]string) ([]string, error) {
+	var used []string
+	err := applyEnvValue(reflect.ValueOf(s).Elem(), envPrefix, env, &used)
+	return used, err
+}
+
+func applyEnvValue(v reflect.Value, name string, env map[string]string, used *[]string) error {
+	switch {
+	case v.Kind() == reflect.Struct:
+		t := v.Type()
+		for i := 0; i < t.NumField(); i++ {
+			tag, _, _ := strings.Cut(t.Field(i).Tag.Get("yaml"), ",")
+			if tag == "" || tag == "-" || tag == "include" {
+				continue
+			}
+			if err := applyEnvValue(v.Field(i), name+"_"+strings.ToUpper(tag), env, used); err != nil {
+				return err
+			}
+		}
+		return nil
+
+	case v.Kind() == reflect.Slice && v.Type().Elem().Kind() == reflect.Struct:
+		indexRegexp := regexp.MustCompile("^" + regexp.QuoteMeta(name) + `_(\d+)_`)
+		length := v.Len()
+		for key := range env {
+			if match := indexRegexp.FindStringSubmatch(key); match != nil {
+				if index, err := strconv.Atoi(match[1]); err == nil && index+1 > length {
+					length = index + 1
+				}
+			}
+		}
+		if length > v.Len() {
+			grown := reflect.MakeSlice(v.Type(), length, length)
+			reflect.Copy(grown, v)
+			v.Set(grown)
+		}
+		for i := 0; i < v.Len(); i++ {
+			if err := applyEnvValue(v.Index(i), name+"_"+strconv.Itoa(i), env, used); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+
+	value, ok, err := lookupEnv(name, env)
+	if err != nil || !ok {
+		return err
+	}
+	*used = append(*used, name)
+
+	switch {
+	case v.Kind() == reflect.String:
+		v.SetString(value)
+	case v.Kind() == reflect.Pointer && v.Type().Elem().Kind() == reflect.String:
+		v.Set(reflect.ValueOf(&value))
+	case v.Kind() == reflect.Slice && v.Type().Elem().Kind() == reflect.String:
+		var list []string
+		if strings.HasPrefix(strings.TrimSpace(value), "[") {
+			if err := yaml.Unmarshal([]byte(value), &list); err != nil {
+				return fmt.Errorf("环境变量 %s 的值 %q 不是有效的列表: %v", name, value, err)
+			}
+			v.Set(reflect.ValueOf(list))
+			return nil
+		}
+		for _, item := range strings.Split(value, ",") {
+			if item = strings.TrimSpace(item); item != "" {
+				list = append(list, item)
+			}
+		}
+		v.Set(reflect.ValueOf(list))
+	default: // 数字、布尔值、时间间隔以及自定义类型交由 YAML 解析
+		if err := yaml.Unmarshal([]byte(value), v.Addr().Interface()); err != nil {
+			return fmt.Errorf("环境变量 %s 的值 %q 无效: %v", name, value, err)
+		}
+	}
+	return nil
+}
+
+// 查找环境变量
+//
+// 同时设置 NAME 和 NAME_FILE 时返回错误
+func lookupEnv(name string, env map[string]string) (string, bool, error) {
+	value, ok := env[name]
+	file, fileOK := env[name+envFileSuffix]
+	switch {
+	case ok && fileOK:
+		return "", false, fmt.Errorf("环境变量 %s 和 %s 不能同时设置", name, name+envFileSuffix)
+	case fileOK:
+		data, err := os.ReadFile(file)
+		if err != nil {
+			return "", false, fmt.Errorf("读取环境变量 %s 指定的文件失败: %v", name+envFileSuffix, err)
+		}
+		return strings.TrimRight(string(data), "\r\n"), true, nil
+	default:
+		return value, ok, nil
+	}
+}
@@ -0,0 +1,87 @@
+package config_test
+
+import (
+	"MediaWarp/internal/config"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func writeFile(t *testing.T, path string, content string) {
+	t.Helper()
+	if err := os.MkdirAll(filepath.Dir(path), os.ModePerm); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(path, []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestConfigOverlay(t *testing.T) {
+	dir := t.TempDir()
+	path := filepath.Join(dir, "config.yaml")
+	writeFile(t, path, baseConfig+`
+include:
+  - extra/*.yaml
+cache:
+  enable: true
+  image_ttl: 10m
+alist_strm:
+  enable: true
+  list:
+    - addr: http://localhost:5244
+      username: admin
+      password: file
+`)
+	writeFile(t, filepath.Join(dir, "extra", "cache.yaml"), "cache:\n  subtitle_ttl: 1h\n")
+	writeFile(t, filepath.Join(dir, "conf.d", "server.yaml"), "server:\n  auth: fromconfd\n")
+	secret := filepath.Join(dir, "secret")
+	writeFile(t, secret, "fromfile\n")
+
+	t.Setenv("MEDIAWARP_SERVER_AUTH", "fromenv")
+	t.Setenv("MEDIAWARP_ALIST_STRM_LIST_0_PASSWORD_FILE", secret)
+	t.Setenv("MEDIAWARP_ALIST_STRM_LIST_1_ADDR", "http://localhost:5245")
+	t.Setenv("MEDIAWARP_HTTP_STRM_PREFIX_LIST", "/media/http, /media/https")
+	t.Setenv("MEDIAWARP_CACHE_IMAGE_TTL", "30m")
+	t.Setenv("MEDIAWARP_SUBTITLE_ASS_STYLE", `["Format: Name, Fontname", "Style: Default, Arial"]`)
+
+	setting, report := config.Check(path)
+	if setting == nil || report.Failed() {
+		t.Fatalf("解析配置失败：%+v", report.Items)
+	}
+
+	tests := map[string]struct {
+		got  any
+		want any
+	}{
+		"环境变量覆盖 conf.d":    {setting.MediaServer.AUTH, "fromenv"},
+		"_FILE 环境变量":       {setting.AlistStrm.List[0].Password, "fromfile"},
+		"列表索引扩展":           {len(setting.AlistStrm.List), 2},
+		"新增列表项":            {setting.AlistStrm.List[1].ADDR, "http://localhost:5245"},
+		"逗号分隔的字符串列表":       {len(setting.HTTPStrm.PrefixList), 2},
+		"YAML 列表写法":        {len(setting.Subtitle.ASSStyle), 2},
+		"列表元素中的逗号":         {setting.Subtitle.ASSStyle[1], "Style: Default, Arial"},
+		"时间间隔":             {setting.Cache.ImageTTL, 30 * time.Minute},
+		"include 合并":       {setting.Cache.SubtitleTTL, time.Hour},
+		"合并时保留主配置文件中的其他字段": {setting.Cache.Enable, true},
+	}
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			if test.got != test.want {
+				t.Errorf("期望: %v，实际: %v", test.want, test.got)
+			}
+		})
+	}
+}
+
+func TestConfigOverlayEnvConflict(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "config.yaml")
+	writeFile(t, path, baseConfig)
+	t.Setenv("MEDIAWARP_SERVER_AUTH", "a")
+	t.Setenv("MEDIAWARP_SERVER_AUTH_FILE", path)
+
+	if _, report := config.Check(path); !report.Failed() {
+		t.Errorf("同时设置 NAME 和 NAME_FILE 时期望检查失败")
+	}
+}
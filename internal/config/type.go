@@ -2,6 +2,7 @@ package config
 
 import (
 	"MediaWarp/constants"
+	"time"
 )
 
 // 程序版本信息
@@ -33,7 +34,19 @@ type BaseLoggerSetting struct {
 	File    bool `yaml:"file"`    // 是否将日志输出到文件中
 }
 
-
+// 缓存设置
+type CacheSetting struct {
+	Enable             bool          `yaml:"enable"`
+	HTTPStrmTTL        time.Duration `yaml:"http_strm_ttl"`         // HTTPStrm 最终 URL 缓存有效期
+	AlistAPITTL        time.Duration `yaml:"alist_api_ttl"`         // Alist API 缓存有效期
+	ImageTTL           time.Duration `yaml:"image_ttl"`             // 图片缓存有效期
+	SubtitleTTL        time.Duration `yaml:"subtitle_ttl"`          // 字幕缓存有效期
+	MaxMemoryMB        int           `yaml:"max_memory_mb"`         // 每个缓存实例的最大内存占用（MB），0 表示不限制
+	Shards             int           `yaml:"shards"`                // 内存缓存分片数
+	MaxEntriesPerShard int           `yaml:"max_entries_per_shard"` // 每个分片最大条目数
+	DiskDir            string        `yaml:"disk_dir"`              // 磁盘缓存目录，为空表示不启用磁盘缓存
+	MaxDiskMB          int           `yaml:"max_disk_mb"`           // 每个缓存实例的最大磁盘占用（MB），0 表示不限制
+}
 
 // Web前端自定义设置
 type WebSetting struct {
@@ -51,10 +64,53 @@ type WebSetting struct {
 }
 
 // 客户端User-Agent过滤设置
+//
+// 先按顺序匹配 rules，没有规则放行或拦截时再按 mode 和 list 过滤 User-Agent
 type ClientFilterSetting struct {
-	Enable     bool                 `yaml:"enable"`
-	Mode       constants.FliterMode `yaml:"mode"`
-	ClientList []string             `yaml:"list"`
+	Enable         bool                      `yaml:"enable"`
+	Mode           constants.FliterMode      `yaml:"mode"`
+	ClientList     []string                  `yaml:"list"`
+	AllowEmptyUA   bool                      `yaml:"allow_empty_ua"`  // 是否放行未提供 User-Agent 的请求
+	TrustedProxies []string                  `yaml:"trusted_proxies"` // 可信代理的 IP 或 CIDR，仅信任这些代理设置的 X-Forwarded-For、X-Real-IP
+	Rules          []ClientFilterRuleSetting `yaml:"rules"`
+}
+
+// 客户端过滤规则
+//
+// 所有设置的条件都满足时规则匹配
+type ClientFilterRuleSetting struct {
+	Name      string                 `yaml:"name"`
+	UserAgent string                 `yaml:"user_agent"` // User-Agent 正则表达式
+	Headers   map[string]string      `yaml:"headers"`    // 请求头名称 -> 正则表达式，如 X-Emby-Client、X-Emby-Device-Name
+	IPs       []string               `yaml:"ips"`        // 客户端 IP 或 CIDR
+	Times     []string               `yaml:"times"`      // 生效时间段，如 22:00-06:00
+	Weekdays  []string               `yaml:"weekdays"`   // 生效的星期，如 Sat、Sun，为空时每天生效
+	Action    constants.FilterAction `yaml:"action"`     // allow、deny、log
+	Status    int                    `yaml:"status"`     // 拦截时的状态码，默认 403
+	Body      string                 `yaml:"body"`       // 拦截时的响应内容
+}
+
+// 访问策略设置
+//
+// 按 Emby、Jellyfin 用户、设备、客户端限制访问，按顺序使用第一条匹配的规则
+type PolicySetting struct {
+	Enable   bool                `yaml:"enable"`
+	CacheTTL time.Duration       `yaml:"cache_ttl"` // token 对应用户信息的缓存有效期，默认 10 分钟
+	Rules    []PolicyRuleSetting `yaml:"rules"`
+}
+
+// 访问策略规则
+//
+// 所有设置的条件都满足时规则匹配，条件均为空时匹配所有请求
+type PolicyRuleSetting struct {
+	Name           string   `yaml:"name"`
+	Users          []string `yaml:"users"`           // 用户名或用户 ID
+	Devices        []string `yaml:"devices"`         // 设备 ID 或设备名称
+	Clients        []string `yaml:"clients"`         // 客户端名称，如 Emby Web、Infuse
+	Deny           bool     `yaml:"deny"`            // 禁止访问
+	DisableStrm    bool     `yaml:"disable_strm"`    // 不重定向 Strm 文件，交由媒体服务器处理
+	ForceTranscode bool     `yaml:"force_transcode"` // 禁止直链播放，强制转码
+	Libraries      []string `yaml:"libraries"`       // 仅允许播放这些媒体库中的项目，为空时不限制
 }
 
 // HTTPStrm播放设置
@@ -63,16 +119,40 @@ type HTTPStrmSetting struct {
 	Proxy             bool     `yaml:"proxy"`              // 开启后支持媒体服务器串流、转码播放
 	FinalURL          bool     `yaml:"final_url"`          // 对 URL 进行重定向判断，找到非重定向地址再重定向给客户端，减少客户端重定向次数
 	CompatibilityMode bool     `yaml:"compatibility_mode"` // 兼容模式，开启后将使用更兼容但效率较低的方式获取最终 URL
+	Stream            bool     `yaml:"stream"`             // 由 MediaWarp 代理串流，而不是重定向
 	PrefixList        []string `yaml:"prefix_list"`
 }
 
+// 路径映射规则
+//
+// prefix 与 regex 二选一，url_decode 可单独使用
+type PathMappingSetting struct {
+	Prefix    string `yaml:"prefix"`     // 路径前缀，替换为 replace
+	Regex     string `yaml:"regex"`      // 正则表达式，替换为 replace（支持 $1 等分组引用）
+	Replace   string `yaml:"replace"`    // 替换内容
+	URLDecode bool   `yaml:"url_decode"` // 对路径进行 URL 解码
+}
+
 // AlistStrm具体设置
 type AlistSetting struct {
-	ADDR       string   `yaml:"addr"`
-	Username   string   `yaml:"username"`
-	Password   string   `yaml:"password"`
-	Token      *string  `yaml:"token"`
-	PrefixList []string `yaml:"prefix_list"`
+	ADDR        string                `yaml:"addr"`
+	Username    string                `yaml:"username"`
+	Password    string                `yaml:"password"`
+	Token       *string               `yaml:"token"`
+	PrefixList  []string              `yaml:"prefix_list"`
+	PathMapping []PathMappingSetting  `yaml:"path_mapping"` // 请求 Alist 前对路径进行映射，按顺序依次应用
+	Backends    []AlistBackendSetting `yaml:"backends"`     // 与 addr 提供相同目录结构的备用 Alist 服务器
+	Passwords   map[string]string     `yaml:"passwords"`    // 目录访问密码（目录路径: 密码），对子目录同样生效
+}
+
+// 备用 Alist 服务器设置
+//
+// 未设置用户名、密码和令牌时使用所属 Alist 的账号
+type AlistBackendSetting struct {
+	ADDR     string  `yaml:"addr"`
+	Username string  `yaml:"username"`
+	Password string  `yaml:"password"`
+	Token    *string `yaml:"token"`
 }
 
 // AlistStrm播放设置
@@ -80,24 +160,170 @@ type AlistStrmSetting struct {
 	Enable bool           `yaml:"enable"`
 	Proxy  bool           `yaml:"proxy"`   // 开启后支持媒体服务器串流、转码播放
 	RawURL bool           `yaml:"raw_url"` // 是否使用原始 URL
+	Stream bool           `yaml:"stream"`  // 由 MediaWarp 代理串流，而不是重定向
 	List   []AlistSetting `yaml:"list"`
+
+	HealthCheckInterval time.Duration `yaml:"health_check_interval"` // 健康检查间隔，默认 1 分钟
+}
+
+// 网盘挂载文件重定向设置
+//
+// 媒体服务器中非 Strm 文件的路径符合前缀时，通过 alist_strm.list[].path_mapping 找到对应的 Alist 路径并重定向至直链
+type MountSetting struct {
+	Enable     bool     `yaml:"enable"`
+	Proxy      bool     `yaml:"proxy"`       // 开启后支持媒体服务器串流、转码播放
+	RawURL     bool     `yaml:"raw_url"`     // 是否使用原始 URL
+	Stream     bool     `yaml:"stream"`      // 由 MediaWarp 代理串流，而不是重定向
+	PrefixList []string `yaml:"prefix_list"` // 媒体服务器中网盘挂载目录的前缀
+}
+
+// Strm 文件生成设置
+type StrmGenerateSetting struct {
+	Enable   bool                      `yaml:"enable"`
+	Interval time.Duration             `yaml:"interval"` // 定时同步间隔，为 0 时仅通过接口触发
+	Tasks    []StrmGenerateTaskSetting `yaml:"tasks"`
+}
+
+// Strm 文件生成任务
+//
+// 遍历 Alist 中的源目录，为视频文件生成 Strm 文件，并下载字幕、NFO、海报等附属文件
+type StrmGenerateTaskSetting struct {
+	Name          string                 `yaml:"name"`           // 任务名称，用于接口触发和日志
+	Alist         string                 `yaml:"alist"`          // 使用的 Alist 地址，需要在 alist_strm.list 中配置
+	Source        string                 `yaml:"source"`         // Alist 中的源目录
+	Output        string                 `yaml:"output"`         // 本地输出目录
+	Type          constants.StrmFileType `yaml:"type"`           // 生成的 Strm 类型：AlistStrm（内容为 Alist 路径）、HTTPStrm（内容为 Alist 直链）
+	VideoExts     []string               `yaml:"video_exts"`     // 视频文件扩展名，为空时使用默认列表
+	SidecarExts   []string               `yaml:"sidecar_exts"`   // 附属文件扩展名，为空时使用默认列表
+	DeleteOrphans bool                   `yaml:"delete_orphans"` // 删除源目录中已不存在的 Strm 文件
+	Refresh       bool                   `yaml:"refresh"`        // 遍历时强制刷新 Alist 目录缓存
+}
+
+// 媒体库刷新设置
+//
+// 文件发生变化时通知媒体服务器刷新对应目录（仅 Emby、Jellyfin 支持）
+type LibraryRefreshSetting struct {
+	Enable      bool                 `yaml:"enable"`
+	Debounce    time.Duration        `yaml:"debounce"`     // 收到变化后等待的时间，期间的变化合并为一次刷新，默认 10 秒
+	PathMapping []PathMappingSetting `yaml:"path_mapping"` // 将 MediaWarp 中的路径映射为媒体服务器中的路径
+}
+
+// 下一集预取设置
+//
+// 请求剧集的 PlaybackInfo 时在后台解析之后几集的 Strm 直链，播放下一集时直接重定向（仅 Emby、Jellyfin 支持）
+type PrefetchSetting struct {
+	Enable      bool          `yaml:"enable"`
+	Count       int           `yaml:"count"`       // 预取的集数，默认 1
+	Concurrency int           `yaml:"concurrency"` // 同时解析的最大数量，默认 2
+	TTL         time.Duration `yaml:"ttl"`         // 预取直链的有效期，默认 10 分钟
+}
+
+// Webhook 设置
+//
+// 接收 Emby、Jellyfin 发送至 /MediaWarp/webhook 的通知
+type WebhookSetting struct {
+	Enable       bool                         `yaml:"enable"`
+	StrmGenerate []WebhookStrmGenerateSetting `yaml:"strm_generate"` // 收到通知后运行 Strm 生成任务
+	Forward      []WebhookForwardSetting      `yaml:"forward"`       // 将通知转发至其他地址
+}
+
+type WebhookStrmGenerateSetting struct {
+	Events []string      `yaml:"events"` // 触发的事件，为空时为 item.added
+	Tasks  []string      `yaml:"tasks"`  // 运行的任务名称，为空时运行所有任务
+	Delay  time.Duration `yaml:"delay"`  // 收到第一个事件后等待的时间，期间的事件合并为一次运行，默认 1 分钟
+}
+
+type WebhookForwardSetting struct {
+	URL     string            `yaml:"url"`
+	Events  []string          `yaml:"events"`  // 转发的事件，为空时转发所有事件
+	Headers map[string]string `yaml:"headers"` // 额外的请求头
+}
+
+// 签名播放链接设置
+//
+// 启用后 PlaybackInfo 中的直链播放链接替换为 MediaWarp 签发的 /MediaWarp/play/{token}，过期或被吊销后无法播放
+type PlayURLSetting struct {
+	Enable bool          `yaml:"enable"`
+	Secret string        `yaml:"secret"` // 签名密钥，为空时启动时随机生成（重启后已签发的链接失效）
+	TTL    time.Duration `yaml:"ttl"`    // 有效期，默认 6 小时
+}
+
+// 串流代理设置
+//
+// 对启用了 stream 的 Strm 文件，MediaWarp 请求实际地址并将数据转发给客户端
+type StreamSetting struct {
+	Headers []StreamHeaderSetting `yaml:"headers"` // 按上游主机名设置请求头，按顺序匹配，第一个命中的规则生效
+}
+
+// 串流代理请求头规则
+type StreamHeaderSetting struct {
+	Hosts     []string          `yaml:"hosts"`      // 上游主机名，支持通配符
+	UserAgent string            `yaml:"user_agent"` // 请求上游使用的 User-Agent，为空时使用客户端的 User-Agent
+	Referer   string            `yaml:"referer"`    // 请求上游使用的 Referer，为空时不发送
+	Headers   map[string]string `yaml:"headers"`    // 其他请求头
+}
+
+// Strm 匹配规则
+//
+// 规则按顺序匹配，第一个满足全部条件的规则生效，未设置的条件视为满足
+type StrmRuleSetting struct {
+	Name      string                 `yaml:"name"`       // 规则名称，用于日志
+	Type      constants.StrmFileType `yaml:"type"`       // Strm 类型：HTTPStrm、AlistStrm
+	Prefix    string                 `yaml:"prefix"`     // Strm 文件路径前缀
+	Glob      string                 `yaml:"glob"`       // Strm 文件路径通配符，支持 *、** 和 ?
+	Regex     string                 `yaml:"regex"`      // Strm 文件路径正则表达式
+	Hosts     []string               `yaml:"hosts"`      // Strm 文件内容（URL）的主机名，支持通配符
+	Schemes   []string               `yaml:"schemes"`    // Strm 文件内容（URL）的协议
+	UserAgent string                 `yaml:"user_agent"` // 客户端 User-Agent 正则表达式
+	Libraries []string               `yaml:"libraries"`  // 媒体库名称
+	Alist     string                 `yaml:"alist"`      // AlistStrm 使用的 Alist 地址，需要在 alist_strm.list 中配置
+	Proxy     *bool                  `yaml:"proxy"`      // 覆盖 http_strm.proxy / alist_strm.proxy
+	RawURL    *bool                  `yaml:"raw_url"`    // 覆盖 alist_strm.raw_url
+	FinalURL  *bool                  `yaml:"final_url"`  // 覆盖 http_strm.final_url
+	Stream    *bool                  `yaml:"stream"`     // 覆盖 http_strm.stream / alist_strm.stream
 }
 
 // 字幕设置
 type SubtitleSetting struct {
-	Enable   bool     `yaml:"enable"`
-	SRT2ASS  bool     `yaml:"srt2ass"` // SRT 字幕转 ASS 字幕
-	ASSStyle []string `yaml:"ass_style"`
-	SubSet   bool     `yaml:"subset"` // ASS 字幕字体子集化
+	Enable    bool                    `yaml:"enable"`
+	SRT2ASS   bool                    `yaml:"srt2ass"` // SRT 字幕转 ASS 字幕
+	ASSStyle  []string                `yaml:"ass_style"`
+	SubSet    bool                    `yaml:"subset"`    // ASS 字幕字体子集化
+	FontDir   string                  `yaml:"font_dir"`  // 字体子集化使用的字体目录
+	Transcode bool                    `yaml:"transcode"` // 检测字幕的字符编码并转换为 UTF-8
+	Adjust    []SubtitleAdjustSetting `yaml:"adjust"`    // 按媒体库调整字幕
+	External  bool                    `yaml:"external"`  // 为 AlistStrm 添加 Alist 中与视频同名的外挂字幕
+}
+
+// 字幕调整规则
+//
+// 规则按顺序匹配，第一个匹配的规则生效，请求参数 mw_offset、mw_fps、mw_chinese、mw_bilingual 可覆盖对应设置
+type SubtitleAdjustSetting struct {
+	Libraries []string                    `yaml:"libraries"` // 媒体库名称，为空时匹配所有媒体库
+	Offset    time.Duration               `yaml:"offset"`    // 时间偏移，正数表示延后显示
+	FPS       string                      `yaml:"fps"`       // 帧率转换，格式为 原帧率:目标帧率，如 25:23.976
+	Chinese   constants.ChineseConversion `yaml:"chinese"`   // 简繁转换：s2t（简转繁）、t2s（繁转简）
+	Bilingual bool                        `yaml:"bilingual"` // 简繁转换时保留原文，转换后的文本显示在原文下方
 }
 
 type Setting struct {
-	Port         uint16              `yaml:"port"`
-	MediaServer  MediaServerSetting  `yaml:"server"`
-	Logger       LoggerSetting       `yaml:"log"`
-	Web          WebSetting          `yaml:"web"`
-	ClientFilter ClientFilterSetting `yaml:"client"`
-	HTTPStrm     HTTPStrmSetting     `yaml:"http_strm"`
-	AlistStrm    AlistStrmSetting    `yaml:"alist_strm"`
-	Subtitle     SubtitleSetting     `yaml:"subtitle"`
+	Include      []string              `yaml:"include,omitempty"` // 需要合并的其他配置文件，支持通配符，相对路径基于主配置文件所在目录
+	Port         uint16                `yaml:"port"`
+	MediaServer  MediaServerSetting    `yaml:"server"`
+	Logger       LoggerSetting         `yaml:"log"`
+	Cache        CacheSetting          `yaml:"cache"`
+	Web          WebSetting            `yaml:"web"`
+	ClientFilter ClientFilterSetting   `yaml:"client"`
+	Policy       PolicySetting         `yaml:"policy"`
+	HTTPStrm     HTTPStrmSetting       `yaml:"http_strm"`
+	AlistStrm    AlistStrmSetting      `yaml:"alist_strm"`
+	Mount        MountSetting          `yaml:"mount"`
+	Stream       StreamSetting         `yaml:"stream"`
+	PlayURL      PlayURLSetting        `yaml:"play_url"`
+	StrmGenerate StrmGenerateSetting   `yaml:"strm_generate"`
+	Refresh      LibraryRefreshSetting `yaml:"library_refresh"`
+	Prefetch     PrefetchSetting       `yaml:"prefetch"`
+	Webhook      WebhookSetting        `yaml:"webhook"`
+	StrmRules    []StrmRuleSetting     `yaml:"strm_rules"`
+	Subtitle     SubtitleSetting       `yaml:"subtitle"`
 }
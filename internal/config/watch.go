@@ -0,0 +1,77 @@
+package config
+
+import (
+	"fmt"
+	"os"
+	"strings"
+	"time"
+)
+
+// 监听配置文件变化
+//
+// 按 interval 轮询主配置文件及 include、conf.d 中文件的修改时间和大小，发生变化时调用 onChange
+// 编辑器保存文件时可能分多次写入，检测到变化后等待文件稳定再触发
+func Watch(interval time.Duration, onChange func()) (stop func()) {
+	done := make(chan struct{})
+	go func() {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+
+		last := configFingerprint()
+		for {
+			select {
+			case <-done:
+				return
+			case <-ticker.C:
+				current := configFingerprint()
+				if current == last {
+					continue
+				}
+				last = current
+				if !waitFileStable(&last, interval/2, done) {
+					return
+				}
+				onChange()
+			}
+		}
+	}()
+	return func() { close(done) }
+}
+
+// 配置文件指纹
+//
+// 由所有配置文件的路径、修改时间和大小组成
+func configFingerprint() string {
+	files, err := configFiles(configFilePath)
+	if err != nil {
+		files = []string{configFilePath}
+	}
+	var builder strings.Builder
+	for _, file := range files {
+		info, err := os.Stat(file)
+		if err != nil {
+			fmt.Fprintf(&builder, "%s:-;", file)
+			continue
+		}
+		fmt.Fprintf(&builder, "%s:%d:%d;", file, info.ModTime().UnixNano(), info.Size())
+	}
+	return builder.String()
+}
+
+// 等待配置文件在 delay 时间内不再变化
+//
+// 返回 false 表示监听已停止
+func waitFileStable(last *string, delay time.Duration, done <-chan struct{}) bool {
+	for {
+		select {
+		case <-done:
+			return false
+		case <-time.After(delay):
+		}
+		current := configFingerprint()
+		if current == *last {
+			return true
+		}
+		*last = current
+	}
+}
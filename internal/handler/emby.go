@@ -4,7 +4,9 @@ import (
 	"MediaWarp/constants"
 	"MediaWarp/internal/config"
 	"MediaWarp/internal/logging"
+	"MediaWarp/internal/policy"
 	"MediaWarp/internal/service/emby"
+	"MediaWarp/internal/strm"
 	"MediaWarp/utils"
 	"bytes"
 	"encoding/json"
@@ -19,6 +21,7 @@ import (
 	"regexp"
 	"strconv"
 	"strings"
+	"sync"
 	"time"
 
 	"github.com/gin-gonic/gin"
@@ -40,7 +43,7 @@ type EmbyHandler struct {
 }
 
 // 初始化
-func NewEmbyServerHandler(addr string, apiKey string) (*EmbyHandler, error) {
+func NewEmbyServerHandler(cfg *config.Setting, addr string, apiKey string) (*EmbyHandler, error) {
 	var handler = EmbyHandler{}
 	handler.client = emby.New(addr, apiKey)
 	target, err := url.Parse(handler.client.GetEndpoint())
@@ -93,8 +96,8 @@ func NewEmbyServerHandler(addr string, apiKey string) (*EmbyHandler, error) {
 			},
 		}
 
-		if config.Web.Enable {
-			if config.Web.Index || config.Web.Head != "" || config.Web.ExternalPlayerUrl || config.Web.VideoTogether {
+		if cfg.Web.Enable {
+			if cfg.Web.Index || cfg.Web.Head != "" || cfg.Web.ExternalPlayerUrl || cfg.Web.VideoTogether {
 				handler.routerRules = append(handler.routerRules,
 					RegexpRouteRule{
 						Regexp: constants.EmbyRegexp.Router.ModifyIndex,
@@ -106,7 +109,7 @@ func NewEmbyServerHandler(addr string, apiKey string) (*EmbyHandler, error) {
 				)
 			}
 		}
-		if config.Subtitle.Enable && config.Subtitle.SRT2ASS {
+		if cfg.Subtitle.Enable {
 			handler.routerRules = append(handler.routerRules,
 				RegexpRouteRule{
 					Regexp: constants.EmbyRegexp.Router.ModifySubtitles,
@@ -148,6 +151,7 @@ func (handler *EmbyHandler) GetSubtitleCacheRegexp() *regexp.Regexp {
 // /Items/:itemId/PlaybackInfo
 // 强制将 HTTPStrm 设置为支持直链播放和转码、AlistStrm 设置为支持直链播放并且禁止转码
 func (handler *EmbyHandler) ModifyPlaybackInfo(rw *http.Response) error {
+	cfg := config.Get()
 	startTime := time.Now()
 	defer func() {
 		logging.Debugf("处理 ModifyPlaybackInfo 耗时：%s", time.Since(startTime))
@@ -168,6 +172,8 @@ func (handler *EmbyHandler) ModifyPlaybackInfo(rw *http.Response) error {
 		return err
 	}
 
+	userID := getQueryValueCaseInsensitive(rw.Request.URL.Query(), "UserId")
+	accessPolicy := policy.FromContext(rw.Request.Context())
 	for index, mediasource := range playbackInfoResponse.MediaSources {
 		startTime := time.Now()
 
@@ -180,31 +186,64 @@ func (handler *EmbyHandler) ModifyPlaybackInfo(rw *http.Response) error {
 
 		bsePath := "MediaSources." + strconv.Itoa(index) + "."
 		item := itemResponse.Items[0]
-		strmFileType, opt := recgonizeStrmFileType(*item.Path)
-		switch strmFileType {
+		library := handler.getLibraryName(*mediasource.ItemID)
+		if !accessPolicy.AllowLibrary(library) {
+			forbidPlaybackInfo(jsonChain, accessPolicy, *item.Path)
+			break
+		}
+		if accessPolicy.Transcode() {
+			forceTranscodePlaybackInfo(jsonChain, bsePath, *mediasource.ID, accessPolicy)
+			continue
+		}
+		if accessPolicy.StrmDisabled() {
+			logging.Infof("访问策略 %s 禁止重定向 Strm，%s 交由媒体服务器处理", accessPolicy.Rule, *item.Path)
+			continue
+		}
+		var route strm.Result
+		if strings.HasSuffix(strings.ToLower(*item.Path), ".strm") { // 仅 Strm 文件匹配 Strm 规则
+			route = strm.Match(&strm.Input{
+				Path:      *item.Path,
+				Content:   stringValue(mediasource.Path),
+				UserAgent: rw.Request.UserAgent(),
+				Library:   library,
+			})
+		} else {
+			route = strm.MatchMount(*item.Path) // 网盘挂载文件按 AlistStrm 处理
+		}
+		switch route.Type {
 		case constants.HTTPStrm: // HTTPStrm 设置支持直链播放并且禁止转码
 			processHTTPStrmPlaybackInfo(
 				jsonChain,
 				bsePath,
 				*mediasource.ItemID,
 				*mediasource.ID,
+				userID,
+				route,
 				mediasource.DirectStreamURL,
 			)
 
-		case constants.AlistStrm: // AlistStm 设置支持直链播放并且禁止转码
+		case constants.AlistStrm: // AlistStm、网盘挂载文件设置支持直链播放并且禁止转码
 			processAlistStrmPlaybackInfo(
 				jsonChain,
 				bsePath,
 				*mediasource.ItemID,
 				*mediasource.ID,
-				opt.(string),
+				userID,
+				route,
 				mediasource.DirectStreamURL,
-				*item.Path,
+				stringValue(mediasource.Path),
 				mediasource.Size,
 			)
+			if cfg.Subtitle.Enable && cfg.Subtitle.External && strings.HasSuffix(strings.ToLower(*item.Path), ".strm") { // 网盘挂载文件的外挂字幕由媒体服务器识别
+				processExternalSubtitles(jsonChain, bsePath, *mediasource.ID, route, stringValue(mediasource.Path), library())
+			}
 		}
 
 		logging.Debugf("处理 %s 的 MediaSource %s 耗时：%s", *item.Path, *mediasource.ID, time.Since(startTime))
+
+		if index == 0 && cfg.Prefetch.Enable && stringValue(item.Type) == "Episode" && item.SeriesID != nil {
+			go handler.prefetchNextEpisodes(*item.SeriesID, *item.ID, userID, rw.Request.UserAgent())
+		}
 	}
 
 	body, err = jsonChain.Result()
@@ -253,33 +292,52 @@ func (handler *EmbyHandler) VideosHandler(ctx *gin.Context) {
 
 	item := itemResponse.Items[0]
 
+	accessPolicy := policy.FromContext(ctx.Request.Context())
+	if !accessPolicy.AllowLibrary(handler.getLibraryName(*item.ID)) {
+		logging.AccessWarningf(ctx, "访问策略 %s 禁止播放 %s", accessPolicy.Rule, *item.Path)
+		ctx.AbortWithStatus(http.StatusForbidden)
+		return
+	}
+	if accessPolicy.StrmDisabled() {
+		logging.Debugf("访问策略 %s 禁止重定向 Strm，%s 交由媒体服务器处理", accessPolicy.Rule, *item.Path)
+		handler.ReverseProxy(ctx.Writer, ctx.Request)
+		return
+	}
+
 	if !strings.HasSuffix(strings.ToLower(*item.Path), ".strm") { // 不是 Strm 文件
+		if redirectMountFile(ctx, *item.Path) {
+			return
+		}
 		logging.Debug("播放本地视频：" + *item.Path + "，不进行处理")
 		handler.ReverseProxy(ctx.Writer, ctx.Request)
 		return
 	}
 
-	strmFileType, opt := recgonizeStrmFileType(*item.Path)
-
 	for _, mediasource := range item.MediaSources {
 		logging.Debugf("mediasource.ID: %s ; mediaSourceID: %s ; mediaSourceID_without_prefix: %s", *mediasource.ID, mediaSourceID, mediaSourceID_without_prefix)
 		// EmbyServer >= 4.9 返回的ID带有前缀mediasource_
 		if strings.Replace(*mediasource.ID, "mediasource_", "", 1) == mediaSourceID_without_prefix {
-			switch strmFileType {
+			route := strm.Match(&strm.Input{
+				Path:      *item.Path,
+				Content:   stringValue(mediasource.Path),
+				UserAgent: ctx.Request.UserAgent(),
+				Library:   handler.getLibraryName(*item.ID),
+			})
+			switch route.Type {
 			case constants.HTTPStrm:
 				if *mediasource.Protocol == emby.HTTP {
-					ctx.Redirect(http.StatusFound, handler.httpStrmHandler(*mediasource.Path, ctx.Request.UserAgent()))
+					serveStrmURL(ctx, handler.httpStrmHandler(*mediasource.Path, ctx.Request.UserAgent(), route.FinalURL), route)
 					return
 				}
 
 			case constants.AlistStrm: // 无需判断 *mediasource.Container 是否以Strm结尾，当 AlistStrm 存储的位置有对应的文件时，*mediasource.Container 会被设置为文件后缀
-				res, err := alistStrmHandler(*mediasource.Path, opt.(string), false)
+				redirectURL, err := getAlistStrmURL(*mediasource.Path, route)
 				if err != nil {
 					logging.Warningf("获取 AlistStrm 重定向 URL 失败: %#v", err)
 					handler.ReverseProxy(ctx.Writer, ctx.Request)
 					return
 				}
-				ctx.Redirect(http.StatusFound, res.url)
+				serveStrmURL(ctx, redirectURL, route)
 				return
 
 			case constants.UnknownStrm:
@@ -290,28 +348,62 @@ func (handler *EmbyHandler) VideosHandler(ctx *gin.Context) {
 	}
 }
 
-// 修改字幕
+// 获取 Item 所在媒体库名称
 //
-// 将 SRT 字幕转 ASS
-func (handler *EmbyHandler) ModifySubtitles(rw *http.Response) error {
-	defer rw.Body.Close()
-	subtitile, err := io.ReadAll(rw.Body) // 读取字幕文件
+// 仅在 Strm 匹配规则包含媒体库条件时才会请求媒体服务器
+func (handler *EmbyHandler) getLibraryName(itemID string) func() string {
+	return sync.OnceValue(func() string {
+		ancestors, err := handler.client.ItemsServiceGetAncestors(itemID)
+		if err != nil {
+			logging.Warning("请求 ItemsServiceGetAncestors 失败：", err)
+			return ""
+		}
+		for _, ancestor := range ancestors {
+			if ancestor.Type != nil && *ancestor.Type == "CollectionFolder" && ancestor.Name != nil {
+				return *ancestor.Name
+			}
+		}
+		return ""
+	})
+}
+
+// 预取之后几集的 Strm 直链
+//
+// ua 为空时按空 User-Agent 匹配 Strm 规则
+func (handler *EmbyHandler) prefetchNextEpisodes(seriesID string, itemID string, userID string, ua string) {
+	count := prefetchCount()
+	episodes, err := handler.client.TvShowsServiceGetEpisodes(seriesID, itemID, userID, count+1, "Path,MediaSources")
 	if err != nil {
-		logging.Warning("读取原始字幕 Body 出错：", err)
-		return err
+		logging.Warning("请求 TvShowsServiceGetEpisodes 失败：", err)
+		return
 	}
 
-	if utils.IsSRT(subtitile) { // 判断是否为 SRT 格式
-		logging.Info("字幕文件为 SRT 格式")
-		if config.Subtitle.SRT2ASS {
-			logging.Info("已将 SRT 字幕已转为 ASS 格式")
-			assSubtitle := utils.SRT2ASS(subtitile, config.Subtitle.ASSStyle)
-			rw.Header.Set("Content-Length", strconv.Itoa(len(assSubtitle)))
-			rw.Body = io.NopCloser(bytes.NewReader(assSubtitle))
-			return nil
+	var targets []prefetchTarget
+	for _, episode := range episodes.Items {
+		if episode.ID == nil || *episode.ID == itemID || episode.Path == nil {
+			continue
+		}
+		if count == 0 {
+			break
+		}
+		count--
+		for _, mediasource := range episode.MediaSources {
+			if target, ok := matchPrefetchTarget(*episode.Path, stringValue(mediasource.Path), ua, handler.getLibraryName(*episode.ID)); ok {
+				targets = append(targets, target)
+			}
 		}
 	}
-	return nil
+	if len(targets) > 0 {
+		logging.Debugf("预取剧集 %s 中 %s 之后的 %d 个 Strm 直链", seriesID, itemID, len(targets))
+		prefetchLinks(targets, handler.httpStrmHandler, ua)
+	}
+}
+
+// 修改字幕
+//
+// 按客户端请求的格式转换字幕，调整字幕时间和简繁，并将 ASS 字幕使用的字体子集化后嵌入字幕
+func (handler *EmbyHandler) ModifySubtitles(rw *http.Response) error {
+	return modifySubtitle(rw, subtitleLibrary(rw.Request.URL.Path, handler.getLibraryName))
 }
 
 // 修改 basehtmlplayer.js
@@ -332,6 +424,7 @@ func (handler *EmbyHandler) ModifyBaseHtmlPlayer(rw *http.Response) error {
 
 // 修改首页函数
 func (handler *EmbyHandler) ModifyIndex(rw *http.Response) error {
+	cfg := config.Get()
 	var (
 		htmlFilePath string = path.Join(config.CostomDir(), "index.html")
 		htmlContent  []byte
@@ -339,8 +432,8 @@ func (handler *EmbyHandler) ModifyIndex(rw *http.Response) error {
 		err          error
 	)
 
-	defer rw.Body.Close()  // 无论哪种情况，最终都要确保原 Body 被关闭，避免内存泄漏
-	if !config.Web.Index { // 从上游获取响应体
+	defer rw.Body.Close() // 无论哪种情况，最终都要确保原 Body 被关闭，避免内存泄漏
+	if !cfg.Web.Index {   // 从上游获取响应体
 		if htmlContent, err = io.ReadAll(rw.Body); err != nil {
 			return err
 		}
@@ -351,29 +444,29 @@ func (handler *EmbyHandler) ModifyIndex(rw *http.Response) error {
 		}
 	}
 
-	if config.Web.Head != "" { // 用户自定义HEAD
-		addHEAD.WriteString(config.Web.Head + "\n")
+	if cfg.Web.Head != "" { // 用户自定义HEAD
+		addHEAD.WriteString(cfg.Web.Head + "\n")
 	}
-	if config.Web.ExternalPlayerUrl { // 外部播放器
+	if cfg.Web.ExternalPlayerUrl { // 外部播放器
 		addHEAD.WriteString(`<script src="/MediaWarp/static/embyExternalUrl/embyWebAddExternalUrl/embyLaunchPotplayer.js"></script>` + "\n")
 	}
-	if config.Web.Crx { // crx 美化
+	if cfg.Web.Crx { // crx 美化
 		addHEAD.WriteString(`<link rel="stylesheet" id="theme-css" href="/MediaWarp/static/emby-crx/static/css/style.css" type="text/css" media="all" />
     <script src="/MediaWarp/static/emby-crx/static/js/common-utils.js"></script>
     <script src="/MediaWarp/static/emby-crx/static/js/jquery-3.6.0.min.js"></script>
     <script src="/MediaWarp/static/emby-crx/static/js/md5.min.js"></script>
     <script src="/MediaWarp/static/emby-crx/content/main.js"></script>` + "\n")
 	}
-	if config.Web.ActorPlus { // 过滤没有头像的演员和制作人员
+	if cfg.Web.ActorPlus { // 过滤没有头像的演员和制作人员
 		addHEAD.WriteString(`<script src="/MediaWarp/static/emby-web-mod/actorPlus/actorPlus.js"></script>` + "\n")
 	}
-	if config.Web.FanartShow { // 显示同人图（fanart图）
+	if cfg.Web.FanartShow { // 显示同人图（fanart图）
 		addHEAD.WriteString(`<script src="/MediaWarp/static/emby-web-mod/fanart_show/fanart_show.js"></script>` + "\n")
 	}
-	if config.Web.Danmaku { // 弹幕
+	if cfg.Web.Danmaku { // 弹幕
 		addHEAD.WriteString(`<script src="/MediaWarp/static/dd-danmaku/ede.js" defer></script>` + "\n")
 	}
-	if config.Web.VideoTogether { // VideoTogether
+	if cfg.Web.VideoTogether { // VideoTogether
 		addHEAD.WriteString(`<script src="https://2gether.video/release/extension.website.user.js"></script>` + "\n")
 	}
 	addHEAD.WriteString(`<!-- MediaWarp Web 页面修改功能 -->` + "\n" + "</head>")
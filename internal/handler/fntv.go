@@ -2,7 +2,9 @@ package handler
 
 import (
 	"MediaWarp/constants"
+	"MediaWarp/internal/config"
 	"MediaWarp/internal/logging"
+	"MediaWarp/internal/strm"
 	"MediaWarp/utils"
 	"bytes"
 	"fmt"
@@ -13,6 +15,7 @@ import (
 	"net/url"
 	"regexp"
 	"strconv"
+	"strings"
 	"time"
 
 	"github.com/tidwall/gjson"
@@ -24,7 +27,7 @@ type FNTVHandler struct {
 	httpStrmHandler StrmHandlerFunc
 }
 
-func NewFNTVHandler(addr string) (*FNTVHandler, error) {
+func NewFNTVHandler(cfg *config.Setting, addr string) (*FNTVHandler, error) {
 	hanler := FNTVHandler{}
 	target, err := url.Parse(addr)
 	if err != nil {
@@ -63,6 +66,17 @@ func NewFNTVHandler(addr string) (*FNTVHandler, error) {
 			),
 		},
 	}
+	if cfg.Subtitle.Enable {
+		hanler.routerRules = append(hanler.routerRules,
+			RegexpRouteRule{
+				Regexp: constants.FNTVRegexp.ModifySubtitles,
+				Handler: responseModifyCreater(
+					&httputil.ReverseProxy{Director: hanler.proxy.Director},
+					hanler.ModifySubtitles,
+				),
+			},
+		)
+	}
 
 	hanler.httpStrmHandler, err = getHTTPStrmHandler()
 	if err != nil {
@@ -92,6 +106,13 @@ func (hanler *FNTVHandler) GetSubtitleCacheRegexp() *regexp.Regexp {
 	return constants.FNTVRegexp.Cache.Subtitle
 }
 
+// 修改字幕
+//
+// 字幕下载接口不包含扩展名，不会转换为其他格式；无法获取字幕所属媒体库，仅匹配未设置 libraries 的字幕调整规则
+func (hanler *FNTVHandler) ModifySubtitles(rw *http.Response) error {
+	return modifySubtitle(rw, func() string { return "" })
+}
+
 func (hanler *FNTVHandler) ModifyStream(rw *http.Response) error {
 	startTime := time.Now()
 	defer func() {
@@ -127,9 +148,16 @@ func (hanler *FNTVHandler) ModifyStream(rw *http.Response) error {
 
 	filePath := filePathRes.String()
 
-	strmFileType, opt := recgonizeStrmFileType(filePath)
+	route := strm.Result{Type: constants.UnknownStrm}
+	if strings.HasSuffix(strings.ToLower(filePath), ".strm") { // 仅 Strm 文件匹配 Strm 规则，本地视频保持原有播放链接
+		route = strm.Match(&strm.Input{
+			Path:      filePath,
+			Content:   jsonChain.Get("data.direct_link_qualities.0.url").String(),
+			UserAgent: rw.Request.UserAgent(),
+		})
+	}
 
-	switch strmFileType {
+	switch route.Type {
 	case constants.HTTPStrm: // HTTPStrm 设置支持直链播放并且支持转码
 		urlRes := jsonChain.Get("data.direct_link_qualities.0.url")
 		if urlRes.Type != gjson.String {
@@ -138,7 +166,7 @@ func (hanler *FNTVHandler) ModifyStream(rw *http.Response) error {
 			return nil
 		}
 
-		redirectURL := hanler.httpStrmHandler(urlRes.String(), rw.Request.Header.Get("User-Agent"))
+		redirectURL := hanler.httpStrmHandler(urlRes.String(), rw.Request.Header.Get("User-Agent"), route.FinalURL)
 		jsonChain.Set(
 			"data.direct_link_qualities.0.resolution",
 			"HTTPStrm 直链",
@@ -155,7 +183,7 @@ func (hanler *FNTVHandler) ModifyStream(rw *http.Response) error {
 			return nil
 		}
 
-		res, err := alistStrmHandler(remoteFilepathRes.String(), opt.(string), true)
+		res, err := alistStrmHandler(remoteFilepathRes.String(), route, true)
 		if err != nil {
 			logging.Warningf("获取 AlistStrm 重定向 URL 失败: %#v", err)
 			rw.Body = io.NopCloser(bytes.NewReader(data))
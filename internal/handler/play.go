@@ -0,0 +1,93 @@
+package handler
+
+import (
+	"MediaWarp/internal/config"
+	"MediaWarp/internal/logging"
+	"MediaWarp/internal/playurl"
+	"MediaWarp/internal/policy"
+	"fmt"
+	"net/http"
+	"net/url"
+
+	"github.com/gin-gonic/gin"
+)
+
+// 支持签名播放链接的媒体服务器处理器
+type videosHandler interface {
+	VideosHandler(ctx *gin.Context)
+}
+
+// 签名播放链接处理器
+//
+// /MediaWarp/play/:token、/emby/MediaWarp/play/:token
+// 校验令牌后改写为 /Videos/{itemId}/stream 请求，交由媒体服务器的 VideosHandler 解析并重定向
+//
+// 回源至媒体服务器时使用 MediaWarp 的密钥（server.auth，通常为管理员密钥），媒体服务器不会再校验用户权限，
+// 因此在此按令牌中的用户重新计算访问策略，媒体库限制、禁止重定向 Strm 由 VideosHandler 按该策略处理
+func PlayHandler(ctx *gin.Context) {
+	claims, err := playurl.Verify(ctx.Param("token"))
+	if err != nil {
+		logging.AccessWarningf(ctx, "拒绝播放链接：%s", err)
+		ctx.String(http.StatusForbidden, err.Error())
+		return
+	}
+
+	server, ok := GetMediaServer().(videosHandler)
+	if !ok {
+		ctx.String(http.StatusNotFound, "当前媒体服务器不支持签名播放链接")
+		return
+	}
+	logging.AccessDebugf(ctx, "播放链接校验通过，Item：%s，MediaSource：%s，用户：%s", claims.ItemID, claims.MediaSourceID, claims.UserID)
+
+	accessPolicy := policy.EvaluateUser(ctx.Request, claims.UserID)
+	if accessPolicy.Denied() || accessPolicy.Transcode() { // 强制转码时同样不允许直链播放
+		logging.AccessWarningf(ctx, "访问策略 %s 禁止用户 %s 使用播放链接", accessPolicy.Rule, claims.UserID)
+		ctx.String(http.StatusForbidden, "访问策略禁止播放")
+		return
+	}
+	ctx.Request = ctx.Request.WithContext(policy.NewContext(ctx.Request.Context(), accessPolicy))
+
+	query := make(url.Values)
+	query.Set("mediasourceid", claims.MediaSourceID)
+	query.Set("static", "true")
+	query.Set("api_key", config.Get().MediaServer.AUTH) // 回源至媒体服务器时使用 MediaWarp 的密钥
+	ctx.Request.URL.Path = fmt.Sprintf("/Videos/%s/stream", claims.ItemID)
+	ctx.Request.URL.RawQuery = query.Encode()
+	server.VideosHandler(ctx)
+}
+
+// 吊销签名播放链接
+//
+// 请求体为 JSON 或表单（兼容查询参数），三个参数任选其一：
+//
+//	token: 吊销单个链接
+//	user:  吊销该用户此前签发的所有链接
+//	all:   为 true 时吊销此前签发的所有链接
+//
+// 吊销记录仅保存在内存中，重启后失效；如需使此前签发的链接全部失效，请更换 play_url.secret
+func RevokePlayHandler(ctx *gin.Context) {
+	var req struct {
+		Token string `json:"token" form:"token"`
+		User  string `json:"user" form:"user"`
+		All   bool   `json:"all" form:"all"`
+	}
+	if err := ctx.ShouldBind(&req); err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	switch {
+	case req.Token != "":
+		if err := playurl.Revoke(req.Token); err != nil {
+			ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+	case req.User != "":
+		playurl.RevokeUser(req.User)
+	case req.All:
+		playurl.RevokeAll()
+	default:
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": "需要提供 token、user 或 all 参数"})
+		return
+	}
+	ctx.JSON(http.StatusOK, gin.H{"message": "吊销成功"})
+}
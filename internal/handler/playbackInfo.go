@@ -3,8 +3,13 @@ package handler
 import (
 	"MediaWarp/internal/config"
 	"MediaWarp/internal/logging"
+	"MediaWarp/internal/pathmap"
+	"MediaWarp/internal/playurl"
+	"MediaWarp/internal/policy"
 	"MediaWarp/internal/service"
 	"MediaWarp/internal/service/alist"
+	"MediaWarp/internal/strm"
+	"MediaWarp/internal/subtitle"
 	"MediaWarp/utils"
 	"fmt"
 	"path"
@@ -12,7 +17,7 @@ import (
 	"time"
 )
 
-func processHTTPStrmPlaybackInfo(jsonChain *utils.JsonChain, bsePath string, itemId string, id string, directStreamURL *string) {
+func processHTTPStrmPlaybackInfo(jsonChain *utils.JsonChain, bsePath string, itemId string, id string, userID string, route strm.Result, directStreamURL *string) {
 	startTime := time.Now()
 	defer func() {
 		logging.Debugf("处理 HTTPStrm %s PlaybackInfo 耗时：%s", id, time.Since(startTime))
@@ -25,7 +30,7 @@ func processHTTPStrmPlaybackInfo(jsonChain *utils.JsonChain, bsePath string, ite
 		true,
 	)
 
-	if !config.HTTPStrm.Proxy {
+	if !route.Proxy {
 		jsonChain.Set(
 			bsePath+"SupportsDirectStream",
 			false,
@@ -48,11 +53,7 @@ func processHTTPStrmPlaybackInfo(jsonChain *utils.JsonChain, bsePath string, ite
 
 	if directStreamURL != nil {
 		msgs = append(msgs, fmt.Sprintf("原直链播放链接: %s", *directStreamURL))
-		apikeypair, err := utils.ResolveEmbyAPIKVPairs(directStreamURL)
-		if err != nil {
-			logging.Warning("解析API键值对失败：", err)
-		}
-		directStreamURL := fmt.Sprintf("/Videos/%s/stream?MediaSourceId=%s&Static=true&%s", itemId, id, apikeypair)
+		directStreamURL := buildDirectStreamURL(itemId, id, userID, directStreamURL)
 		jsonChain.Set(
 			bsePath+"DirectStreamUrl",
 			directStreamURL,
@@ -62,7 +63,10 @@ func processHTTPStrmPlaybackInfo(jsonChain *utils.JsonChain, bsePath string, ite
 	logging.Infof("Media(id: %s) %s", id, strings.Join(msgs, ", "))
 }
 
-func processAlistStrmPlaybackInfo(jsonChain *utils.JsonChain, bsePath string, itemId string, id string, alistAddr string, directStreamURL *string, filepath string, size *int64) {
+// 处理 AlistStrm、网盘挂载文件的 PlaybackInfo
+//
+// content 为 Strm 文件内容（网盘挂载文件为本地路径），用于确定容器格式和查询文件大小
+func processAlistStrmPlaybackInfo(jsonChain *utils.JsonChain, bsePath string, itemId string, id string, userID string, route strm.Result, directStreamURL *string, content string, size *int64) {
 	startTime := time.Now()
 	defer func() {
 		logging.Debugf("处理 AlistStrm %s PlaybackInfo 耗时：%s", id, time.Since(startTime))
@@ -75,7 +79,7 @@ func processAlistStrmPlaybackInfo(jsonChain *utils.JsonChain, bsePath string, it
 
 	var msgs []string
 
-	container := strings.TrimPrefix(path.Ext(filepath), ".")
+	container := strings.TrimPrefix(path.Ext(content), ".")
 	jsonChain.Set(
 		bsePath+"Container",
 		container,
@@ -86,7 +90,7 @@ func processAlistStrmPlaybackInfo(jsonChain *utils.JsonChain, bsePath string, it
 
 	msgs = append(msgs, fmt.Sprintf("容器为： %s", container))
 
-	if !config.AlistStrm.Proxy {
+	if !route.Proxy {
 		jsonChain.Set(
 			bsePath+"SupportsTranscoding",
 			false,
@@ -106,12 +110,7 @@ func processAlistStrmPlaybackInfo(jsonChain *utils.JsonChain, bsePath string, it
 
 	if directStreamURL != nil {
 		msgs = append(msgs, fmt.Sprintf("原直链播放链接: %s", *directStreamURL))
-
-		apikeypair, err := utils.ResolveEmbyAPIKVPairs(directStreamURL)
-		if err != nil {
-			logging.Warning("解析API键值对失败：", err)
-		}
-		directStreamURL := fmt.Sprintf("/Videos/%s/stream?MediaSourceId=%s&Static=true&%s", itemId, id, apikeypair)
+		directStreamURL := buildDirectStreamURL(itemId, id, userID, directStreamURL)
 		jsonChain.Set(
 			bsePath+"DirectStreamUrl",
 			directStreamURL,
@@ -120,22 +119,148 @@ func processAlistStrmPlaybackInfo(jsonChain *utils.JsonChain, bsePath string, it
 	}
 
 	if size == nil {
-		alistClient, err := service.GetAlistClient(alistAddr)
+		var fsGetData *alist.FsGetData
+		err := service.WithAlistClient(route.AlistAddr, func(client *alist.AlistClient) error {
+			var err error
+			fsGetData, err = client.FsGet(&alist.FsGetRequest{Path: pathmap.MapAlistPath(route.AlistAddr, content), Page: 1})
+			return err
+		})
 		if err != nil {
-			logging.Warning("获取 AlistClient 失败：", err)
+			logging.Warning("请求 FsGet 失败：", err)
 		} else {
-			fsGetData, err := alistClient.FsGet(&alist.FsGetRequest{Path: filepath, Page: 1})
-			if err != nil {
-				logging.Warning("请求 FsGet 失败：", err)
-			} else {
-				jsonChain.Set(
-					bsePath+"Size",
-					fsGetData.Size,
-				)
-				msgs = append(msgs, fmt.Sprintf("设置文件大小为： %d", fsGetData.Size))
-			}
+			jsonChain.Set(
+				bsePath+"Size",
+				fsGetData.Size,
+			)
+			msgs = append(msgs, fmt.Sprintf("设置文件大小为： %d", fsGetData.Size))
 		}
 	}
 
 	logging.Infof("Media(id: %s) %s", id, strings.Join(msgs, ", "))
 }
+
+// 为 AlistStrm 添加外挂字幕
+//
+// 在 Alist 中查找与 Strm 指向的文件同名的字幕，添加到 MediaStreams，
+// 字幕通过 /MediaWarp/subtitle/external/{token} 从 Alist 获取；
+// 允许媒体服务器转码（proxy）时不添加，媒体服务器中不存在这些字幕，客户端选择烧录字幕时转码会失败
+func processExternalSubtitles(jsonChain *utils.JsonChain, bsePath string, id string, route strm.Result, content string, library string) {
+	if route.Proxy {
+		logging.Debugf("Media(id: %s) 允许转码，不添加 Alist 外挂字幕", id)
+		return
+	}
+	startTime := time.Now()
+	defer func() {
+		logging.Debugf("查找 %s 的外挂字幕耗时：%s", id, time.Since(startTime))
+	}()
+
+	alistPath := pathmap.MapAlistPath(route.AlistAddr, content)
+	dir, video := path.Split(alistPath)
+	var objects []alist.FsObject
+	err := service.WithAlistClient(route.AlistAddr, func(client *alist.AlistClient) error {
+		var err error
+		objects, err = client.FsListAll(dir, "", 0, false)
+		return err
+	})
+	if err != nil {
+		logging.Warning("列出外挂字幕目录失败：", err)
+		return
+	}
+	names := make([]string, 0, len(objects))
+	for _, object := range objects {
+		if !object.IsDir {
+			names = append(names, object.Name)
+		}
+	}
+	externals := subtitle.MatchExternal(video, names)
+	if len(externals) == 0 {
+		return
+	}
+
+	index := 0
+	for _, value := range jsonChain.Get(bsePath + "MediaStreams.#.Index").Array() {
+		index = max(index, int(value.Int())+1)
+	}
+	var titles []string
+	for _, external := range externals {
+		u, err := playurl.SignSubtitle(route.AlistAddr, path.Join(dir, external.Name), library)
+		if err != nil {
+			logging.Warning("签发外挂字幕链接失败：", err)
+			return
+		}
+		codec := external.Format
+		if codec == subtitle.FormatSRT && config.Get().Subtitle.SRT2ASS {
+			codec = subtitle.FormatASS
+		}
+		displayTitle := strings.ToUpper(string(codec))
+		if external.Title != "" {
+			displayTitle = external.Title + " (" + displayTitle + ")"
+		}
+		jsonChain.Set(bsePath+"MediaStreams.-1", map[string]any{
+			"Codec":                  codec,
+			"Language":               external.Language,
+			"Title":                  external.Title,
+			"DisplayTitle":           displayTitle,
+			"Type":                   "Subtitle",
+			"Index":                  index,
+			"IsDefault":              external.Default,
+			"IsForced":               external.Forced,
+			"IsExternal":             true,
+			"IsTextSubtitleStream":   true,
+			"SupportsExternalStream": true,
+			"DeliveryMethod":         "External",
+			"DeliveryUrl":            u + "/Stream." + string(codec),
+			"IsExternalUrl":          false,
+			"Path":                   path.Join(dir, external.Name),
+		})
+		index++
+		titles = append(titles, external.Name)
+	}
+	logging.Infof("Media(id: %s) 添加 Alist 外挂字幕：%s", id, strings.Join(titles, ", "))
+}
+
+// 生成直链播放链接
+//
+// 启用 play_url 时签发 /MediaWarp/play/{token}，否则沿用原链接中的 API 密钥
+func buildDirectStreamURL(itemId string, id string, userID string, directStreamURL *string) string {
+	if playurl.Enabled() {
+		signed, err := playurl.Sign(itemId, id, userID)
+		if err == nil {
+			return signed
+		}
+		logging.Warning("签发播放链接失败：", err)
+	}
+
+	apikeypair, err := utils.ResolveEmbyAPIKVPairs(directStreamURL)
+	if err != nil {
+		logging.Warning("解析API键值对失败：", err)
+	}
+	return fmt.Sprintf("/Videos/%s/stream?MediaSourceId=%s&Static=true&%s", itemId, id, apikeypair)
+}
+
+// 访问策略要求强制转码
+//
+// 禁止直链播放和直接串流，由媒体服务器转码
+func forceTranscodePlaybackInfo(jsonChain *utils.JsonChain, bsePath string, id string, p *policy.Policy) {
+	jsonChain.Set(
+		bsePath+"SupportsDirectPlay",
+		false,
+	).Set(
+		bsePath+"SupportsDirectStream",
+		false,
+	).Set(
+		bsePath+"SupportsTranscoding",
+		true,
+	).Delete(
+		bsePath + "DirectStreamUrl",
+	)
+	logging.Infof("Media(id: %s) 访问策略 %s 要求强制转码，禁止直链播放", id, p.Rule)
+}
+
+// 访问策略禁止播放
+//
+// 返回 NotAllowed 错误码并清空 MediaSources，客户端会提示无权播放
+func forbidPlaybackInfo(jsonChain *utils.JsonChain, p *policy.Policy, itemPath string) {
+	jsonChain.Set("ErrorCode", "NotAllowed").Set("MediaSources", []any{})
+	logging.Infof("访问策略 %s 禁止播放 %s", p.Rule, itemPath)
+}
@@ -0,0 +1,519 @@
+package handler
+
+import (
+	"MediaWarp/constants"
+	"MediaWarp/internal/logging"
+	"MediaWarp/internal/pathmap"
+	"MediaWarp/internal/service"
+	"MediaWarp/internal/service/alist"
+	"MediaWarp/internal/service/plex"
+	"MediaWarp/internal/strm"
+	"MediaWarp/utils"
+	"bytes"
+	"encoding/json"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httputil"
+	"net/url"
+	"path"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+const (
+	plexStrmContentLimit = 4 * 1024 // Strm 文件内容最大读取长度
+	maxPlexStrmParts     = 4096     // 最多记住的 Strm Part 数量，超出后清空重新记录
+)
+
+var plexMetadataPathRegexp = regexp.MustCompile(`^/library/metadata/(\d+)$`)
+
+// Plex 中 Strm 文件对应的 Part 信息
+type plexStrmPart struct {
+	file    string      // Strm 文件在媒体服务器中的路径
+	content string      // Strm 文件内容
+	library string      // 所在媒体库名称
+	route   strm.Result // Strm 匹配结果
+}
+
+// Plex 服务器处理器
+type PlexHandler struct {
+	client          *plex.Client           // Plex 客户端
+	routerRules     []RegexpRouteRule      // 正则路由规则
+	proxy           *httputil.ReverseProxy // 反向代理
+	httpStrmHandler StrmHandlerFunc
+	strmParts       map[string]*plexStrmPart // partID => Part 信息，在获取元数据时记录，播放时根据当前请求重新匹配
+	strmPartsMutex  sync.RWMutex
+}
+
+// 初始化
+func NewPlexHandler(addr string, token string) (*PlexHandler, error) {
+	handler := PlexHandler{strmParts: make(map[string]*plexStrmPart)}
+	handler.client = plex.New(addr, token)
+	target, err := url.Parse(handler.client.GetEndpoint())
+	if err != nil {
+		return nil, err
+	}
+	handler.proxy = httputil.NewSingleHostReverseProxy(target)
+
+	// 配置自定义 Transport，增加超时时间以避免临时性超时
+	handler.proxy.Transport = &http.Transport{
+		Proxy: http.ProxyFromEnvironment,
+		DialContext: (&net.Dialer{
+			Timeout:   30 * time.Second, // 连接超时
+			KeepAlive: 30 * time.Second,
+		}).DialContext,
+		MaxIdleConns:          100,
+		IdleConnTimeout:       90 * time.Second,
+		TLSHandshakeTimeout:   10 * time.Second,
+		ExpectContinueTimeout: 1 * time.Second,
+		ResponseHeaderTimeout: 60 * time.Second, // 响应头超时
+	}
+
+	// 设置自定义错误处理器，提供更友好的错误信息
+	handler.proxy.ErrorHandler = func(w http.ResponseWriter, r *http.Request, err error) {
+		logging.Errorf("代理请求失败: %s %s - %v", r.Method, r.URL.Path, err)
+		// 返回 502 Bad Gateway 错误，附带详细错误信息
+		w.WriteHeader(http.StatusBadGateway)
+		w.Write([]byte(`{"error": "无法连接到上游服务器，请稍后重试"}`))
+	}
+
+	handler.routerRules = []RegexpRouteRule{
+		{
+			Regexp: constants.PlexRegexp.ModifyMetadata,
+			Handler: responseModifyCreater(
+				&httputil.ReverseProxy{Director: handler.proxy.Director},
+				handler.ModifyMetadata,
+			),
+		},
+		{
+			Regexp:  constants.PlexRegexp.PartsHandler,
+			Handler: handler.PartsHandler,
+		},
+		{
+			Regexp:  constants.PlexRegexp.TranscodeHandler,
+			Handler: handler.TranscodeHandler,
+		},
+	}
+
+	handler.httpStrmHandler, err = getHTTPStrmHandler()
+	if err != nil {
+		return nil, fmt.Errorf("创建 HTTPStrm 处理器失败: %w", err)
+	}
+	return &handler, nil
+}
+
+// 转发请求至上游服务器
+func (handler *PlexHandler) ReverseProxy(rw http.ResponseWriter, req *http.Request) {
+	handler.proxy.ServeHTTP(rw, req)
+}
+
+// 使用原始查询参数转发请求至上游服务器
+//
+// Plex 查询参数区分大小写（如 X-Plex-Token），需要撤销 QueryKeyCaseInsensitive 的修改
+func (handler *PlexHandler) reverseProxy(ctx *gin.Context) {
+	if rawQuery, ok := ctx.Get(constants.RawQueryKey); ok {
+		ctx.Request.URL.RawQuery = rawQuery.(string)
+	}
+	handler.ReverseProxy(ctx.Writer, ctx.Request)
+}
+
+// 正则路由表
+func (handler *PlexHandler) GetRegexpRouteRules() []RegexpRouteRule {
+	return handler.routerRules
+}
+
+// 获取图片缓存正则表达式
+func (handler *PlexHandler) GetImageCacheRegexp() *regexp.Regexp {
+	return constants.PlexRegexp.Cache.Image
+}
+
+// 获取字幕缓存正则表达式
+func (handler *PlexHandler) GetSubtitleCacheRegexp() *regexp.Regexp {
+	return constants.PlexRegexp.Cache.Subtitle
+}
+
+// 识别 Part 是否为 Strm 文件
+//
+// 是 Strm 文件时读取其内容并记录到 strmParts 中，同一文件不会重复读取
+// 返回的 Part 包含根据当前请求得到的匹配结果
+func (handler *PlexHandler) resolveStrmPart(partID string, key string, file string, library string, ua string) (*plexStrmPart, bool) {
+	if !strings.HasSuffix(strings.ToLower(file), ".strm") {
+		return nil, false
+	}
+
+	var part plexStrmPart
+	if stored, ok := handler.loadStrmPart(partID); ok && stored.file == file {
+		part = *stored
+		if library != "" {
+			part.library = library
+		}
+	} else {
+		content, err := handler.client.ReadPart(key, plexStrmContentLimit)
+		if err != nil {
+			logging.Warningf("读取 Strm 文件 %s 内容失败：%v", file, err)
+			return nil, false
+		}
+		part = plexStrmPart{
+			file:    file,
+			content: strings.TrimSpace(string(content)),
+			library: library,
+		}
+	}
+
+	handler.storeStrmPart(partID, part)
+	if !part.match(ua) {
+		return nil, false
+	}
+	return &part, true
+}
+
+// 获取记录的 Strm Part 信息
+func (handler *PlexHandler) loadStrmPart(partID string) (*plexStrmPart, bool) {
+	handler.strmPartsMutex.RLock()
+	defer handler.strmPartsMutex.RUnlock()
+	part, ok := handler.strmParts[partID]
+	return part, ok
+}
+
+// 记录 Strm Part 信息
+//
+// 记录数量达到上限时清空已有记录，避免占用内存无限增长
+func (handler *PlexHandler) storeStrmPart(partID string, part plexStrmPart) {
+	handler.strmPartsMutex.Lock()
+	defer handler.strmPartsMutex.Unlock()
+	if _, ok := handler.strmParts[partID]; !ok && len(handler.strmParts) >= maxPlexStrmParts {
+		clear(handler.strmParts)
+	}
+	handler.strmParts[partID] = &part
+}
+
+// 按需读取未记录的 Part
+//
+// Part 记录为空（服务重启、记录被清空）时客户端仍可能直接请求 Part
+// Part 的 key 保留了原文件扩展名，扩展名为 .strm 时读取其内容并记录
+// 此时无法得知文件在媒体服务器中的路径和所在媒体库，路径条件按 key 匹配
+func (handler *PlexHandler) lookupStrmPart(partID string, key string) (*plexStrmPart, bool) {
+	if !strings.HasSuffix(strings.ToLower(key), ".strm") {
+		return nil, false
+	}
+	content, err := handler.client.ReadPart(key, plexStrmContentLimit)
+	if err != nil {
+		logging.Warningf("读取 Part(id: %s) 内容失败：%v", partID, err)
+		return nil, false
+	}
+	part := plexStrmPart{
+		file:    key,
+		content: strings.TrimSpace(string(content)),
+	}
+	handler.storeStrmPart(partID, part)
+	return &part, true
+}
+
+// 根据当前请求匹配 Strm 规则
+//
+// 返回是否匹配到已知的 Strm 类型
+func (part *plexStrmPart) match(ua string) bool {
+	part.route = strm.Match(&strm.Input{
+		Path:      part.file,
+		Content:   part.content,
+		UserAgent: ua,
+		Library:   func() string { return part.library },
+	})
+	return part.route.Type != constants.UnknownStrm
+}
+
+// 计算 Strm 文件实际指向的容器格式和文件大小
+func (handler *PlexHandler) processStrmPart(part *plexStrmPart, size int64) (string, int64) {
+	var container string
+	switch part.route.Type {
+	case constants.HTTPStrm:
+		if u, err := url.Parse(part.content); err == nil {
+			container = strings.TrimPrefix(path.Ext(u.Path), ".")
+		}
+
+	case constants.AlistStrm:
+		container = strings.TrimPrefix(path.Ext(part.content), ".")
+		if size == 0 {
+			var fsGetData *alist.FsGetData
+			err := service.WithAlistClient(part.route.AlistAddr, func(client *alist.AlistClient) error {
+				var err error
+				fsGetData, err = client.FsGet(&alist.FsGetRequest{Path: pathmap.MapAlistPath(part.route.AlistAddr, part.content), Page: 1})
+				return err
+			})
+			if err != nil {
+				logging.Warning("请求 FsGet 失败：", err)
+				break
+			}
+			size = fsGetData.Size
+		}
+	}
+	return container, size
+}
+
+// 修改媒体元数据
+//
+// /library/metadata/:ratingKey
+// 将 Strm 文件的容器格式、文件大小修改为实际指向文件的值，并记录 Strm 文件对应的 Part
+func (handler *PlexHandler) ModifyMetadata(rw *http.Response) error {
+	startTime := time.Now()
+	defer func() {
+		logging.Debugf("处理 ModifyMetadata 耗时：%s", time.Since(startTime))
+	}()
+
+	defer rw.Body.Close()
+	body, err := io.ReadAll(rw.Body)
+	if err != nil {
+		logging.Warning("读取 Body 出错：", err)
+		return err
+	}
+
+	contentType := rw.Header.Get("Content-Type")
+	switch {
+	case strings.Contains(contentType, "json"):
+		body, err = handler.modifyMetadataJSON(body, rw.Request.UserAgent())
+	case strings.Contains(contentType, "xml"):
+		body, err = handler.modifyMetadataXML(body, rw.Request.UserAgent())
+	default:
+		logging.Debugf("未知的元数据响应类型：%s，不进行处理", contentType)
+	}
+	if err != nil {
+		logging.Warning("修改 Plex 元数据出错：", err)
+		return err
+	}
+
+	rw.Header.Set("Content-Length", strconv.Itoa(len(body)))
+	rw.Body = io.NopCloser(bytes.NewReader(body))
+	return nil
+}
+
+func (handler *PlexHandler) modifyMetadataJSON(body []byte, ua string) ([]byte, error) {
+	var metadataResponse plex.Response
+	if err := json.Unmarshal(body, &metadataResponse); err != nil {
+		return nil, fmt.Errorf("解析 plex.Response Json 错误：%w", err)
+	}
+
+	jsonChain := utils.NewJsonChainFromBytesWithCopy(body, jsonChainOption)
+	for i, metadata := range metadataResponse.MediaContainer.Metadata {
+		library := stringValue(metadata.LibrarySectionTitle)
+		if library == "" {
+			library = stringValue(metadataResponse.MediaContainer.LibrarySectionTitle)
+		}
+		for j, media := range metadata.Media {
+			for k, part := range media.Part {
+				if part.ID == nil || part.Key == nil || part.File == nil {
+					continue
+				}
+				strmPart, ok := handler.resolveStrmPart(strconv.FormatInt(*part.ID, 10), *part.Key, *part.File, library, ua)
+				if !ok {
+					continue
+				}
+
+				var size int64
+				if part.Size != nil {
+					size = *part.Size
+				}
+				container, size := handler.processStrmPart(strmPart, size)
+
+				mediaPath := fmt.Sprintf("MediaContainer.Metadata.%d.Media.%d.", i, j)
+				partPath := mediaPath + fmt.Sprintf("Part.%d.", k)
+				if container != "" {
+					jsonChain.Set(mediaPath+"container", container).Set(partPath+"container", container)
+				}
+				jsonChain.Set(partPath+"size", size)
+				logging.Infof("Part(id: %d) %s 容器为：%s，文件大小为：%d", *part.ID, strmPart.route.Type, container, size)
+			}
+		}
+	}
+	return jsonChain.Result()
+}
+
+func (handler *PlexHandler) modifyMetadataXML(body []byte, ua string) ([]byte, error) {
+	var (
+		library string // 当前所在媒体库名称
+		buf     bytes.Buffer
+		decoder = xml.NewDecoder(bytes.NewReader(body))
+		encoder = xml.NewEncoder(&buf)
+	)
+
+	for {
+		token, err := decoder.Token()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return nil, fmt.Errorf("解析 Plex 元数据 XML 错误：%w", err)
+		}
+
+		if start, ok := token.(xml.StartElement); ok {
+			if start.Name.Local == "Part" {
+				token = handler.modifyPartElement(start, library, ua)
+			} else {
+				for _, attr := range start.Attr {
+					if attr.Name.Local == "librarySectionTitle" { // MediaContainer 和 Video 元素均可能带有媒体库名称
+						library = attr.Value
+					}
+				}
+			}
+		}
+		if err = encoder.EncodeToken(xml.CopyToken(token)); err != nil {
+			return nil, fmt.Errorf("生成 Plex 元数据 XML 错误：%w", err)
+		}
+	}
+
+	if err := encoder.Flush(); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+func (handler *PlexHandler) modifyPartElement(start xml.StartElement, library string, ua string) xml.StartElement {
+	var partID, key, file string
+	var size int64
+	for _, attr := range start.Attr {
+		switch attr.Name.Local {
+		case "id":
+			partID = attr.Value
+		case "key":
+			key = attr.Value
+		case "file":
+			file = attr.Value
+		case "size":
+			size, _ = strconv.ParseInt(attr.Value, 10, 64)
+		}
+	}
+
+	strmPart, ok := handler.resolveStrmPart(partID, key, file, library, ua)
+	if !ok {
+		return start
+	}
+
+	container, size := handler.processStrmPart(strmPart, size)
+	for i, attr := range start.Attr {
+		switch attr.Name.Local {
+		case "container":
+			if container != "" {
+				start.Attr[i].Value = container
+			}
+		case "size":
+			start.Attr[i].Value = strconv.FormatInt(size, 10)
+		}
+	}
+	logging.Infof("Part(id: %s) %s 容器为：%s，文件大小为：%d", partID, strmPart.route.Type, container, size)
+	return start
+}
+
+// 重定向 Strm 文件（启用 stream 时代理串流）
+func (handler *PlexHandler) redirectStrmPart(ctx *gin.Context, part *plexStrmPart) {
+	switch part.route.Type {
+	case constants.HTTPStrm:
+		serveStrmURL(ctx, handler.httpStrmHandler(part.content, ctx.Request.UserAgent(), part.route.FinalURL), part.route)
+		return
+
+	case constants.AlistStrm:
+		res, err := alistStrmHandler(part.content, part.route, false)
+		if err != nil {
+			logging.Warningf("获取 AlistStrm 重定向 URL 失败: %#v", err)
+			handler.reverseProxy(ctx)
+			return
+		}
+		serveStrmURL(ctx, res.url, part.route)
+		return
+
+	default:
+		handler.reverseProxy(ctx)
+	}
+}
+
+// 媒体文件直接播放处理器
+//
+// /library/parts/:partId/:updatedAt/file.:ext
+// 未记录的 Part（如服务重启后）按需读取
+func (handler *PlexHandler) PartsHandler(ctx *gin.Context) {
+	if ctx.Request.Method == http.MethodHead { // 不额外处理 HEAD 请求
+		handler.reverseProxy(ctx)
+		logging.Debug("PartsHandler 不处理 HEAD 请求，转发至上游服务器")
+		return
+	}
+
+	partID := constants.PlexRegexp.PartsHandler.FindStringSubmatch(ctx.Request.URL.Path)[1]
+	stored, ok := handler.loadStrmPart(partID)
+	if !ok {
+		stored, ok = handler.lookupStrmPart(partID, ctx.Request.URL.Path)
+	}
+	if !ok {
+		logging.Debugf("Part(id: %s) 不是 Strm 文件，不进行处理", partID)
+		handler.reverseProxy(ctx)
+		return
+	}
+
+	strmPart := *stored
+	if !strmPart.match(ctx.Request.UserAgent()) {
+		logging.Debugf("%s 未匹配任何 Strm 规则，不进行处理", strmPart.file)
+		handler.reverseProxy(ctx)
+		return
+	}
+	handler.redirectStrmPart(ctx, &strmPart)
+}
+
+// 转码播放处理器
+//
+// /video/:/transcode/universal/start?path=/library/metadata/:ratingKey&mediaIndex=0&partIndex=0
+// Strm 文件未允许流量经过媒体服务器时，直接重定向至实际文件
+func (handler *PlexHandler) TranscodeHandler(ctx *gin.Context) {
+	matches := plexMetadataPathRegexp.FindStringSubmatch(ctx.Query("path"))
+	if len(matches) != 2 {
+		handler.reverseProxy(ctx)
+		return
+	}
+	mediaIndex, _ := strconv.Atoi(ctx.Query("mediaindex"))
+	partIndex, _ := strconv.Atoi(ctx.Query("partindex"))
+
+	logging.Debugf("请求 LibraryMetadata：%s", matches[1])
+	metadataResponse, err := handler.client.LibraryMetadata(matches[1])
+	if err != nil {
+		logging.Warning("请求 LibraryMetadata 失败：", err)
+		handler.reverseProxy(ctx)
+		return
+	}
+
+	metadatas := metadataResponse.MediaContainer.Metadata
+	if len(metadatas) == 0 || mediaIndex < 0 || mediaIndex >= len(metadatas[0].Media) || partIndex < 0 || partIndex >= len(metadatas[0].Media[mediaIndex].Part) {
+		handler.reverseProxy(ctx)
+		return
+	}
+	part := metadatas[0].Media[mediaIndex].Part[partIndex]
+	if part.ID == nil || part.Key == nil || part.File == nil {
+		handler.reverseProxy(ctx)
+		return
+	}
+
+	library := stringValue(metadatas[0].LibrarySectionTitle)
+	if library == "" {
+		library = stringValue(metadataResponse.MediaContainer.LibrarySectionTitle)
+	}
+	strmPart, ok := handler.resolveStrmPart(strconv.FormatInt(*part.ID, 10), *part.Key, *part.File, library, ctx.Request.UserAgent())
+	if !ok {
+		logging.Debugf("播放本地视频：%s，不进行处理", *part.File)
+		handler.reverseProxy(ctx)
+		return
+	}
+
+	if strmPart.route.Proxy {
+		logging.Debugf("%s 允许流量经过媒体服务器，转发至上游服务器转码", strmPart.file)
+		handler.reverseProxy(ctx)
+		return
+	}
+
+	handler.redirectStrmPart(ctx, strmPart)
+}
+
+var _ MediaServerHandler = (*PlexHandler)(nil) // 确保 PlexHandler 实现 MediaServerHandler 接口
@@ -0,0 +1,184 @@
+package handler
+
+import (
+	"MediaWarp/constants"
+	"MediaWarp/internal/cache"
+	"MediaWarp/internal/config"
+	"MediaWarp/internal/logging"
+	"MediaWarp/internal/strm"
+	"MediaWarp/internal/webhook"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	defaultPrefetchCount       = 1
+	defaultPrefetchConcurrency = 2
+	defaultPrefetchTTL         = 10 * time.Minute
+	prefetchCacheShards        = 16
+	prefetchCacheEntries       = 64 // 每个分片最多缓存的直链数
+)
+
+// 需要预取的 Strm
+type prefetchTarget struct {
+	content string // Strm 文件内容，网盘挂载文件为文件路径
+	route   strm.Result
+}
+
+var (
+	prefetchStore     *cache.MemoryCache // 预取的 AlistStrm 直链，未启用时为 nil
+	prefetchTTL       time.Duration
+	prefetchSemaphore chan struct{} // 限制同时解析的数量
+	prefetchMutex     sync.RWMutex
+	prefetchFlight    cache.Group
+	prefetchSubscribe sync.Once
+)
+
+// 初始化下一集预取
+//
+// 重新加载配置时会再次调用，已预取的直链会被清空
+func initPrefetch() {
+	cfg := config.Get()
+	var (
+		store     *cache.MemoryCache
+		ttl       = cfg.Prefetch.TTL
+		semaphore chan struct{}
+	)
+	if cfg.Prefetch.Enable {
+		if ttl <= 0 {
+			ttl = defaultPrefetchTTL
+		}
+		concurrency := cfg.Prefetch.Concurrency
+		if concurrency <= 0 {
+			concurrency = defaultPrefetchConcurrency
+		}
+		store = cache.NewMemoryCache(0, prefetchCacheShards, prefetchCacheEntries)
+		semaphore = make(chan struct{}, concurrency)
+	}
+
+	prefetchMutex.Lock()
+	prefetchStore, prefetchTTL, prefetchSemaphore = store, ttl, semaphore
+	prefetchMutex.Unlock()
+
+	prefetchSubscribe.Do(func() { // 收到开始播放通知时同样预取下一集
+		webhook.Subscribe("prefetch", func(event webhook.Event) {
+			if !config.Get().Prefetch.Enable || event.ItemType != "Episode" || event.SeriesID == "" {
+				return
+			}
+			if prefetcher, ok := GetMediaServer().(nextEpisodePrefetcher); ok {
+				prefetcher.prefetchNextEpisodes(event.SeriesID, event.ItemID, event.UserID, "")
+			}
+		}, webhook.EventPlaybackStart)
+	})
+}
+
+// 支持预取下一集的媒体服务器处理器
+type nextEpisodePrefetcher interface {
+	prefetchNextEpisodes(seriesID string, itemID string, userID string, ua string)
+}
+
+func getPrefetchStore() (*cache.MemoryCache, time.Duration, chan struct{}) {
+	prefetchMutex.RLock()
+	defer prefetchMutex.RUnlock()
+	return prefetchStore, prefetchTTL, prefetchSemaphore
+}
+
+// 需要预取的集数
+func prefetchCount() int {
+	cfg := config.Get()
+	if cfg.Prefetch.Count <= 0 {
+		return defaultPrefetchCount
+	}
+	return cfg.Prefetch.Count
+}
+
+func prefetchKey(content string, route strm.Result) string {
+	return route.AlistAddr + "\x00" + strconv.FormatBool(route.RawURL) + "\x00" + content
+}
+
+// 获取 AlistStrm 重定向 URL
+//
+// 优先使用预取的直链
+func getAlistStrmURL(content string, route strm.Result) (string, error) {
+	if store, _, _ := getPrefetchStore(); store != nil {
+		if u, ok := store.Get(prefetchKey(content, route)); ok {
+			logging.Infof("AlistStrm 命中预取直链，重定向至：%s", u)
+			return string(u), nil
+		}
+	}
+	res, err := alistStrmHandler(content, route, false)
+	if err != nil {
+		return "", err
+	}
+	return res.url, nil
+}
+
+// 在后台解析 Strm 直链
+//
+// AlistStrm 的直链写入预取缓存；HTTPStrm 启用 final_url 时写入 HTTPStrm 最终 URL 缓存
+func prefetchLinks(targets []prefetchTarget, httpStrmHandler StrmHandlerFunc, ua string) {
+	store, ttl, semaphore := getPrefetchStore()
+	if store == nil {
+		return
+	}
+	for _, target := range targets {
+		switch target.route.Type {
+		case constants.AlistStrm:
+			key := prefetchKey(target.content, target.route)
+			if _, ok := store.Get(key); ok {
+				continue
+			}
+			go func() {
+				semaphore <- struct{}{}
+				defer func() { <-semaphore }()
+				prefetchFlight.Do(key, func() ([]byte, error) {
+					if u, ok := store.Get(key); ok {
+						return u, nil
+					}
+					res, err := alistStrmHandler(target.content, target.route, false)
+					if err != nil {
+						logging.Warningf("预取 %s 的 AlistStrm 直链失败：%v", target.content, err)
+						return nil, err
+					}
+					store.Set(key, []byte(res.url), ttl)
+					logging.Debugf("已预取 %s 的 AlistStrm 直链", target.content)
+					return []byte(res.url), nil
+				})
+			}()
+
+		case constants.HTTPStrm:
+			if !target.route.FinalURL || cache.GetHTTPStrmCache() == nil {
+				continue
+			}
+			go func() {
+				semaphore <- struct{}{}
+				defer func() { <-semaphore }()
+				httpStrmHandler(target.content, ua, true)
+			}()
+		}
+	}
+}
+
+// 匹配分集的 Strm 规则
+//
+// 非 Strm 文件按网盘挂载文件处理，不需要处理时返回 false
+func matchPrefetchTarget(itemPath string, content string, ua string, library func() string) (prefetchTarget, bool) {
+	var route strm.Result
+	if strings.HasSuffix(strings.ToLower(itemPath), ".strm") {
+		route = strm.Match(&strm.Input{
+			Path:      itemPath,
+			Content:   content,
+			UserAgent: ua,
+			Library:   library,
+		})
+	} else {
+		route = strm.MatchMount(itemPath)
+		content = itemPath
+	}
+	if route.Type == constants.UnknownStrm {
+		return prefetchTarget{}, false
+	}
+	return prefetchTarget{content: content, route: route}, true
+}
@@ -6,6 +6,7 @@ import (
 	"errors"
 	"net/http"
 	"regexp"
+	"sync"
 )
 
 // 媒体服务器处理接口
@@ -16,27 +17,56 @@ type MediaServerHandler interface {
 	GetSubtitleCacheRegexp() *regexp.Regexp          // 字幕缓存正则表达式
 }
 
-var mediaServerHandler MediaServerHandler
+var (
+	mediaServerHandler MediaServerHandler
+	mediaServerMutex   sync.RWMutex
+)
 var ErrInvalidMediaServerType = errors.New("错误的媒体服务器类型")
 
 // 初始化媒体服务器处理器
 func Init() error {
-	var err error
-	switch config.MediaServer.Type {
+	publish, err := Prepare(config.Get())
+	if err != nil {
+		return err
+	}
+	publish()
+	return nil
+}
+
+// 根据配置创建媒体服务器处理器，返回的函数用于替换当前使用的处理器
+func Prepare(cfg *config.Setting) (func(), error) {
+	var (
+		serverHandler MediaServerHandler
+		err           error
+	)
+	switch cfg.MediaServer.Type {
 	case constants.EMBY:
-		mediaServerHandler, err = NewEmbyServerHandler(config.MediaServer.ADDR, config.MediaServer.AUTH)
+		serverHandler, err = NewEmbyServerHandler(cfg, cfg.MediaServer.ADDR, cfg.MediaServer.AUTH)
 	case constants.JELLYFIN:
-		mediaServerHandler, err = NewJellyfinHandler(config.MediaServer.ADDR, config.MediaServer.AUTH)
+		serverHandler, err = NewJellyfinHandler(cfg, cfg.MediaServer.ADDR, cfg.MediaServer.AUTH)
+	case constants.PLEX:
+		serverHandler, err = NewPlexHandler(cfg.MediaServer.ADDR, cfg.MediaServer.AUTH)
 	case constants.FNTV:
-		mediaServerHandler, err = NewFNTVHandler(config.MediaServer.ADDR)
+		serverHandler, err = NewFNTVHandler(cfg, cfg.MediaServer.ADDR)
 
 	default:
 		err = ErrInvalidMediaServerType
 	}
-	return err
+	if err != nil {
+		return nil, err
+	}
+
+	return func() {
+		mediaServerMutex.Lock()
+		mediaServerHandler = serverHandler
+		mediaServerMutex.Unlock()
+		initPrefetch()
+	}, nil
 }
 
 // 获取媒体服务器接口
 func GetMediaServer() MediaServerHandler {
+	mediaServerMutex.RLock()
+	defer mediaServerMutex.RUnlock()
 	return mediaServerHandler
 }
@@ -1,20 +1,26 @@
 package handler
 
 import (
+	"MediaWarp/constants"
+	"MediaWarp/internal/cache"
 	"MediaWarp/internal/config"
 	"MediaWarp/internal/logging"
+	"MediaWarp/internal/pathmap"
 	"MediaWarp/internal/service"
 	"MediaWarp/internal/service/alist"
+	"MediaWarp/internal/stream"
+	"MediaWarp/internal/strm"
 	"fmt"
 	"net/http"
 	"net/url"
-	"path"
 	"strconv"
 	"strings"
 	"time"
+
+	"github.com/gin-gonic/gin"
 )
 
-type StrmHandlerFunc func(content string, ua string) string
+type StrmHandlerFunc func(content string, ua string, finalURL bool) string
 
 func getHTTPStrmHandler() (StrmHandlerFunc, error) {
 	client := &http.Client{ // 创建自定义HTTP客户端配置
@@ -24,16 +30,35 @@ func getHTTPStrmHandler() (StrmHandlerFunc, error) {
 			return http.ErrUseLastResponse
 		},
 	}
-	return func(content string, ua string) string {
-		if config.HTTPStrm.FinalURL {
+	var flight cache.Group
+	return func(content string, ua string, finalURL bool) string {
+		if finalURL {
 			logging.Debug("HTTPStrm 启用获取最终 URL，开始尝试获取最终 URL")
-			finalURL, err := getFinalURL(client, content, ua)
+			store := cache.GetHTTPStrmCache()
+			cacheKey := content + "\x00" + ua
+			if store != nil {
+				if finalURL, ok := store.Get(cacheKey); ok {
+					logging.Info("HTTPStrm 命中缓存，重定向至: ", string(finalURL))
+					return string(finalURL)
+				}
+			}
+
+			finalURL, err := flight.Do(cacheKey, func() ([]byte, error) {
+				finalURL, err := getFinalURL(client, content, ua)
+				if err != nil {
+					return nil, err
+				}
+				if store != nil {
+					store.Set(cacheKey, []byte(finalURL), config.Get().Cache.HTTPStrmTTL)
+				}
+				return []byte(finalURL), nil
+			})
 			if err != nil {
 				logging.Warning("获取最终 URL 失败，使用原始 URL: ", err)
-			} else {
-				logging.Info("HTTPStrm 重定向至: ", finalURL)
+				return content
 			}
-			return finalURL
+			logging.Info("HTTPStrm 重定向至: ", string(finalURL))
+			return string(finalURL)
 		} else {
 			logging.Debug("HTTPStrm 未启用获取最终 URL，直接使用原始 URL: ", content)
 			return content
@@ -41,6 +66,37 @@ func getHTTPStrmHandler() (StrmHandlerFunc, error) {
 	}, nil
 }
 
+// 重定向网盘挂载文件
+//
+// 非 Strm 文件符合 mount 设置时重定向至 Alist 直链，返回是否已处理请求
+func redirectMountFile(ctx *gin.Context, localPath string) bool {
+	route := strm.MatchMount(localPath)
+	if route.Type == constants.UnknownStrm {
+		return false
+	}
+	redirectURL, err := getAlistStrmURL(localPath, route)
+	if err != nil {
+		logging.Warningf("获取挂载文件 %s 的 Alist 直链失败，转发至上游服务器：%v", localPath, err)
+		return false
+	}
+	serveStrmURL(ctx, redirectURL, route)
+	return true
+}
+
+// 响应 Strm 文件实际指向的 URL
+//
+// route.Stream 为 true 时由 MediaWarp 代理串流，否则重定向至该 URL
+func serveStrmURL(ctx *gin.Context, target string, route strm.Result) {
+	if !route.Stream {
+		ctx.Redirect(http.StatusFound, target)
+		return
+	}
+	logging.Infof("%s 由 MediaWarp 代理串流：%s", route.Type, target)
+	if err := stream.Proxy(ctx.Writer, ctx.Request, target); err != nil {
+		logging.Warningf("代理串流 %s 失败：%v", target, err)
+	}
+}
+
 type resolutionInfo struct {
 	width  uint
 	height uint
@@ -59,18 +115,29 @@ type alistStrmResult struct {
 	transcodeResources []TranscodeResourceInfo // 转码资源列表
 }
 
-func alistStrmHandler(content string, alistAddr string, needTranscodeResourceInfo bool) (*alistStrmResult, error) {
+func alistStrmHandler(content string, route strm.Result, needTranscodeResourceInfo bool) (*alistStrmResult, error) {
 	startTime := time.Now()
 	defer func() {
 		logging.Debugf("获取 AlistStrm 重定向 URL 耗时：%s", time.Since(startTime))
 	}()
 
-	client, err := service.GetAlistClient(alistAddr)
-	if err != nil {
-		return nil, fmt.Errorf("获取 AlistClient 失败：%w", err)
+	alistPath := pathmap.MapAlistPath(route.AlistAddr, content)
+	if alistPath != content {
+		logging.Debugf("%s 映射为 Alist 路径：%s", content, alistPath)
 	}
 
-	fileData, err := client.FsGet(&alist.FsGetRequest{Path: content, Page: 1})
+	var (
+		client   *alist.AlistClient
+		fileData *alist.FsGetData
+	)
+	err := service.WithAlistClient(route.AlistAddr, func(c *alist.AlistClient) error {
+		data, err := c.FsGet(&alist.FsGetRequest{Path: alistPath, Page: 1, Link: true})
+		if err != nil {
+			return err
+		}
+		client, fileData = c, data
+		return nil
+	})
 	if err != nil {
 		return nil, fmt.Errorf("获取文件信息失败：%w", err)
 	}
@@ -79,23 +146,17 @@ func alistStrmHandler(content string, alistAddr string, needTranscodeResourceInf
 		transcodeResources: make([]TranscodeResourceInfo, 0),
 	}
 
-	if config.AlistStrm.RawURL {
+	if route.RawURL {
 		res.url = fileData.RawURL
 	} else {
-		var u strings.Builder
-		u.WriteString(client.GetEndpoint())
-		if fileData.Sign != "" {
-			u.WriteString("?sign=" + fileData.Sign)
-		}
-		u.WriteString(path.Join("/d", client.GetUserInfo().BasePath, content))
-		res.url = u.String()
+		res.url = client.GetDownloadURL(alistPath, fileData.Sign)
 	}
 	logging.Infof("AlistStrm 重定向至：%s", res.url)
 
 	res.fileSize = fileData.Size
 
 	if needTranscodeResourceInfo {
-		previewData, err := client.GetVideoPreviewData(content, "")
+		previewData, err := client.GetVideoPreviewData(alistPath, "")
 		if err != nil {
 			logging.Warningf("%#v 获取视频预览信息失败：%+v", fileData, err)
 			return &res, nil // 即使获取预览信息失败，也返回基本的重定向 URL 和文件大小
@@ -0,0 +1,320 @@
+package handler
+
+import (
+	"MediaWarp/constants"
+	"MediaWarp/internal/config"
+	"MediaWarp/internal/logging"
+	"MediaWarp/internal/playurl"
+	"MediaWarp/internal/service"
+	"MediaWarp/internal/service/alist"
+	"MediaWarp/internal/subtitle"
+	"MediaWarp/utils"
+	"bytes"
+	"io"
+	"mime"
+	"net/http"
+	"net/url"
+	"path"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+var subtitleItemIDRegexp = regexp.MustCompile(`(?i)/Videos/([^/]+)/`) // 字幕请求路径中的 Item ID
+
+const maxExternalSubtitleSize = 32 * 1024 * 1024 // 外挂字幕的最大大小，内嵌字体的 ASS 字幕可能较大
+
+// 字幕调整
+type subtitleAdjust struct {
+	offset    time.Duration
+	fpsFrom   float64
+	fpsTo     float64
+	chinese   constants.ChineseConversion
+	bilingual bool
+}
+
+func (adjust subtitleAdjust) empty() bool {
+	return adjust.offset == 0 && adjust.fpsFrom == adjust.fpsTo && adjust.chinese == ""
+}
+
+func (adjust subtitleAdjust) apply(s *subtitle.Subtitle) {
+	s.Rescale(adjust.fpsFrom, adjust.fpsTo)
+	s.Shift(adjust.offset)
+	s.ConvertChinese(adjust.chinese, adjust.bilingual)
+}
+
+// 获取字幕调整设置
+//
+// 先按媒体库匹配 subtitle.adjust 规则，再使用请求参数覆盖，无效的请求参数会被忽略
+func getSubtitleAdjust(query url.Values, library func() string) subtitleAdjust {
+	var adjust subtitleAdjust
+	for _, rule := range config.Get().Subtitle.Adjust {
+		if len(rule.Libraries) > 0 && !slices.Contains(rule.Libraries, library()) {
+			continue
+		}
+		adjust.offset, adjust.chinese, adjust.bilingual = rule.Offset, rule.Chinese, rule.Bilingual
+		if from, to, err := subtitle.ParseFPS(rule.FPS); err != nil {
+			logging.Warning("字幕调整规则的 fps 无效：", err)
+		} else {
+			adjust.fpsFrom, adjust.fpsTo = from, to
+		}
+		break
+	}
+
+	if value := getQueryValueCaseInsensitive(query, "mw_offset"); value != "" {
+		if offset, err := subtitle.ParseOffset(value); err != nil {
+			logging.Warning("请求参数 mw_offset 无效：", err)
+		} else {
+			adjust.offset = offset
+		}
+	}
+	if value := getQueryValueCaseInsensitive(query, "mw_fps"); value != "" {
+		if from, to, err := subtitle.ParseFPS(value); err != nil {
+			logging.Warning("请求参数 mw_fps 无效：", err)
+		} else {
+			adjust.fpsFrom, adjust.fpsTo = from, to
+		}
+	}
+	if value := getQueryValueCaseInsensitive(query, "mw_chinese"); value != "" {
+		switch conversion := constants.ChineseConversion(strings.ToLower(value)); conversion {
+		case constants.ChineseS2T, constants.ChineseT2S:
+			adjust.chinese = conversion
+		case "none":
+			adjust.chinese = ""
+		default:
+			logging.Warningf("请求参数 mw_chinese 无效：%s，仅支持 s2t、t2s、none", value)
+		}
+	}
+	if value := getQueryValueCaseInsensitive(query, "mw_bilingual"); value != "" {
+		if bilingual, err := strconv.ParseBool(value); err != nil {
+			logging.Warning("请求参数 mw_bilingual 无效：", err)
+		} else {
+			adjust.bilingual = bilingual
+		}
+	}
+	return adjust
+}
+
+// 获取字幕请求所属 Item 的媒体库名称
+func subtitleLibrary(p string, getLibraryName func(itemID string) func() string) func() string {
+	matches := subtitleItemIDRegexp.FindStringSubmatch(p)
+	if matches == nil {
+		return func() string { return "" }
+	}
+	return getLibraryName(matches[1])
+}
+
+// 修改字幕响应
+//
+// library 获取字幕所属媒体库名称，仅在字幕调整规则包含媒体库条件时调用
+func modifySubtitle(rw *http.Response, library func() string) error {
+	if rw.StatusCode != http.StatusOK {
+		return nil
+	}
+	defer rw.Body.Close()
+	content, err := io.ReadAll(rw.Body) // 读取字幕文件
+	if err != nil {
+		logging.Warning("读取原始字幕 Body 出错：", err)
+		return err
+	}
+
+	target := subtitle.FormatFromExt(rw.Request.URL.Path)
+	content, contentType := processSubtitle(rw.Request.URL.Path, subtitleKey(rw.Request.URL.Path), content, rw.Header.Get("Content-Type"), target, rw.Request.URL.Query(), library)
+	rw.Header.Set("Content-Type", contentType)
+	rw.Header.Set("Content-Length", strconv.Itoa(len(content)))
+	rw.Body = io.NopCloser(bytes.NewReader(content))
+	return nil
+}
+
+// 处理字幕内容
+//
+// 启用编码转换时先将字幕转换为 UTF-8，再转换为 target 格式并应用字幕调整，
+// 启用 SRT 转 ASS 时 SRT 字幕转换为 ASS，启用字体子集化时将 ASS 字幕使用的字体嵌入字幕
+//
+// p 用于日志，key 用于查找手动指定的字符编码，返回处理后的字幕和 Content-Type
+func processSubtitle(p string, key string, content []byte, contentType string, target subtitle.Format, query url.Values, library func() string) ([]byte, string) {
+	cfg := config.Get()
+	if cfg.Subtitle.Transcode {
+		decoded, charset, err := subtitle.ToUTF8(key, content)
+		if err != nil {
+			logging.Warningf("将字幕 %s 从 %s 转换为 UTF-8 失败：%v", p, charset, err)
+		} else {
+			if charset != subtitle.CharsetUTF8 {
+				logging.Infof("已将字幕 %s 从 %s 转换为 UTF-8", p, charset)
+			}
+			content = decoded
+			contentType = utf8ContentType(contentType, content)
+		}
+	}
+
+	adjust := getSubtitleAdjust(query, library)
+	if converted, format, err := convertSubtitle(content, target, adjust); err != nil {
+		logging.Warningf("转换字幕 %s 失败：%v", p, err)
+	} else if converted != nil {
+		content = converted
+		contentType = format.ContentType()
+	}
+	if cfg.Subtitle.SubSet {
+		content = subtitle.SubsetFonts(content)
+	}
+	return content, contentType
+}
+
+// Alist 外挂字幕处理器
+//
+// /MediaWarp/subtitle/external/:token/:name，Emby 客户端请求时带有 /emby 前缀
+// 校验令牌后从 Alist 获取字幕，按 name 的扩展名转换格式，处理方式与媒体服务器的字幕相同
+// 获取失败时返回 502，错误详情只记录在日志中
+func ExternalSubtitleHandler(ctx *gin.Context) {
+	claims, err := playurl.VerifySubtitle(ctx.Param("token"))
+	if err != nil {
+		logging.AccessWarningf(ctx, "拒绝外挂字幕链接：%s", err)
+		ctx.String(http.StatusForbidden, err.Error())
+		return
+	}
+
+	var u string
+	err = service.WithAlistClient(claims.AlistAddr, func(client *alist.AlistClient) error {
+		var err error
+		u, err = client.GetFileURL(claims.Path, false)
+		return err
+	})
+	if err != nil {
+		logging.AccessWarningf(ctx, "获取外挂字幕 %s 链接失败：%v", claims.Path, err)
+		ctx.String(http.StatusBadGateway, "获取外挂字幕失败")
+		return
+	}
+	resp, err := utils.GetHTTPClient().Get(u)
+	if err != nil {
+		logging.AccessWarningf(ctx, "下载外挂字幕 %s 失败：%v", claims.Path, err)
+		ctx.String(http.StatusBadGateway, "获取外挂字幕失败")
+		return
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		logging.AccessWarningf(ctx, "下载外挂字幕 %s 失败，状态码：%d", claims.Path, resp.StatusCode)
+		ctx.String(http.StatusBadGateway, "获取外挂字幕失败")
+		return
+	}
+	content, err := io.ReadAll(io.LimitReader(resp.Body, maxExternalSubtitleSize+1))
+	if err != nil {
+		logging.AccessWarningf(ctx, "读取外挂字幕 %s 失败：%v", claims.Path, err)
+		ctx.String(http.StatusBadGateway, "获取外挂字幕失败")
+		return
+	}
+	if len(content) > maxExternalSubtitleSize {
+		logging.AccessWarningf(ctx, "外挂字幕 %s 超过 %d 字节，不进行处理", claims.Path, maxExternalSubtitleSize)
+		ctx.String(http.StatusBadGateway, "获取外挂字幕失败")
+		return
+	}
+
+	// 使用字幕在 Alist 中的路径查找手动指定的字符编码，令牌会随签发时间变化
+	target := subtitle.FormatFromExt(ctx.Param("name"))
+	content, contentType := processSubtitle(claims.Path, subtitleKey(claims.Path), content, resp.Header.Get("Content-Type"), target, ctx.Request.URL.Query(), func() string { return claims.Library })
+	ctx.Data(http.StatusOK, contentType, content)
+}
+
+// 将字幕转换为目标格式并应用字幕调整
+//
+// 无需转换时返回 nil
+func convertSubtitle(content []byte, target subtitle.Format, adjust subtitleAdjust) ([]byte, subtitle.Format, error) {
+	cfg := config.Get()
+	source := subtitle.Detect(content)
+	if source == "" {
+		return nil, "", nil
+	}
+	if source == subtitle.FormatSRT && cfg.Subtitle.SRT2ASS && (target == "" || target == subtitle.FormatSRT || target == subtitle.FormatASS) {
+		target = subtitle.FormatASS
+	}
+	if target == "" {
+		target = source
+	}
+	if target == source && adjust.empty() {
+		return nil, "", nil
+	}
+	if target == source && (source == subtitle.FormatASS || source == subtitle.FormatSSA) {
+		result, err := subtitle.AdjustSSA(content, adjust.apply) // 保留字体、注释等无法转换的内容
+		return result, target, err
+	}
+
+	s, err := subtitle.ParseFormat(content, source)
+	if err != nil {
+		return nil, "", err
+	}
+	adjust.apply(s)
+	if (target == subtitle.FormatASS || target == subtitle.FormatSSA) && len(s.Styles) == 0 && len(cfg.Subtitle.ASSStyle) > 0 {
+		if s.Styles, err = subtitle.ParseStyles(cfg.Subtitle.ASSStyle); err != nil {
+			logging.Warning("解析 ASS 样式失败，使用默认样式：", err)
+		}
+	}
+	result, err := s.Marshal(target)
+	if err != nil {
+		return nil, "", err
+	}
+	if target != source {
+		logging.Infof("已将 %s 字幕转换为 %s 格式", source, target)
+	}
+	return result, target, nil
+}
+
+// 将 Content-Type 的 charset 设置为 utf-8
+func utf8ContentType(contentType string, content []byte) string {
+	mediaType, params, err := mime.ParseMediaType(contentType)
+	if err != nil || !strings.HasPrefix(mediaType, "text/") && !strings.HasPrefix(mediaType, "application/") {
+		return subtitle.Detect(content).ContentType()
+	}
+	params["charset"] = "utf-8"
+	return mime.FormatMediaType(mediaType, params)
+}
+
+// 字幕文件的标识，用于查找手动指定的字符编码
+//
+// 去除 /emby 前缀和扩展名，同一字幕转换为不同格式时使用相同的字符编码
+func subtitleKey(p string) string {
+	p = strings.TrimPrefix(p, "/emby")
+	return strings.ToLower(strings.TrimSuffix(p, path.Ext(p)))
+}
+
+// 查看手动指定的字幕字符编码
+func SubtitleCharsetHandler(ctx *gin.Context) {
+	ctx.JSON(http.StatusOK, subtitle.CharsetOverrides())
+}
+
+// 手动指定字幕的字符编码
+//
+// path 为客户端请求字幕的路径，charset 为空时恢复自动检测；
+// 已经缓存的字幕需要等待字幕缓存过期后才会使用新的编码。
+// 手动指定的编码仅保存在内存中，重新加载配置后保留，重启后丢失
+func SetSubtitleCharsetHandler(ctx *gin.Context) {
+	var req struct {
+		Path    string `json:"path"`
+		Charset string `json:"charset"`
+	}
+	if err := ctx.ShouldBindJSON(&req); err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	req.Path, _, _ = strings.Cut(req.Path, "?")
+	if req.Path == "" {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": "path 不能为空"})
+		return
+	}
+	var charset subtitle.Charset
+	if req.Charset != "" {
+		var err error
+		if charset, err = subtitle.ParseCharset(req.Charset); err != nil {
+			ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+	}
+	key := subtitleKey(req.Path)
+	if err := subtitle.SetCharset(key, charset); err != nil {
+		ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	ctx.JSON(http.StatusOK, gin.H{"path": key, "charset": charset})
+}
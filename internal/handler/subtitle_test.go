@@ -0,0 +1,94 @@
+package handler_test
+
+import (
+	"MediaWarp/internal/config"
+	"MediaWarp/internal/handler"
+	"MediaWarp/internal/playurl"
+	"MediaWarp/internal/service"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+)
+
+// 模拟 Alist 服务器，/d/ 返回 SRT 字幕，missing.srt 不存在
+func newAlistServer(t *testing.T) *httptest.Server {
+	t.Helper()
+	reply := func(w http.ResponseWriter, data any) {
+		json.NewEncoder(w).Encode(map[string]any{"code": 200, "message": "success", "data": data})
+	}
+	mux := http.NewServeMux()
+	mux.HandleFunc("/api/auth/login", func(w http.ResponseWriter, r *http.Request) {
+		reply(w, map[string]any{"token": "token"})
+	})
+	mux.HandleFunc("/api/me", func(w http.ResponseWriter, r *http.Request) {
+		reply(w, map[string]any{"username": "guest", "base_path": "/"})
+	})
+	mux.HandleFunc("/api/fs/get", func(w http.ResponseWriter, r *http.Request) {
+		var req struct {
+			Path string `json:"path"`
+		}
+		json.NewDecoder(r.Body).Decode(&req)
+		if strings.HasSuffix(req.Path, "missing.srt") {
+			json.NewEncoder(w).Encode(map[string]any{"code": 500, "message": "object not found: " + req.Path})
+			return
+		}
+		reply(w, map[string]any{"name": "movie.chs.srt"})
+	})
+	mux.HandleFunc("/d/", func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("1\n00:00:01,000 --> 00:00:02,000\n你好\n"))
+	})
+	server := httptest.NewServer(mux)
+	t.Cleanup(server.Close)
+	return server
+}
+
+func TestExternalSubtitleHandler(t *testing.T) {
+	server := newAlistServer(t)
+	config.Set(&config.Setting{
+		AlistStrm: config.AlistStrmSetting{Enable: true, List: []config.AlistSetting{{ADDR: server.URL}}},
+		PlayURL:   config.PlayURLSetting{Secret: "secret"},
+		Subtitle:  config.SubtitleSetting{Enable: true, External: true},
+	})
+	service.InitAlistClient()
+	if err := playurl.Init(); err != nil {
+		t.Fatal(err)
+	}
+	gin.SetMode(gin.TestMode)
+	router := gin.New()
+	router.GET("/MediaWarp/subtitle/external/:token/:name", handler.ExternalSubtitleHandler)
+
+	u, err := playurl.SignSubtitle(server.URL, "/movies/movie.chs.srt", "电影")
+	if err != nil {
+		t.Fatal(err)
+	}
+	missing, err := playurl.SignSubtitle(server.URL, "/movies/missing.srt", "电影")
+	if err != nil {
+		t.Fatal(err)
+	}
+	tests := map[string]struct {
+		url    string
+		status int
+		prefix string // 响应内容的开头
+	}{
+		"字幕不存在":      {url: missing + "/Stream.srt", status: http.StatusBadGateway, prefix: "获取外挂字幕失败"},
+		"令牌无效":       {url: "/MediaWarp/subtitle/external/invalid.token/Stream.srt", status: http.StatusForbidden},
+		"转换为 WebVTT": {url: u + "/Stream.vtt", status: http.StatusOK, prefix: "WEBVTT"},
+		"平移时间":       {url: u + "/Stream.srt?mw_offset=1s", status: http.StatusOK, prefix: "1\n00:00:02,000 --> 00:00:03,000"},
+	}
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			router.ServeHTTP(w, httptest.NewRequest(http.MethodGet, test.url, nil))
+			if w.Code != test.status {
+				t.Fatalf("期望状态码: %d，实际: %d，%s", test.status, w.Code, w.Body)
+			}
+			if !strings.HasPrefix(w.Body.String(), test.prefix) {
+				t.Errorf("响应内容错误：%q", w.Body)
+			}
+		})
+	}
+}
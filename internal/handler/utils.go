@@ -1,7 +1,6 @@
 package handler
 
 import (
-	"MediaWarp/constants"
 	"MediaWarp/internal/config"
 	"MediaWarp/internal/logging"
 	"errors"
@@ -44,43 +43,25 @@ func responseModifyCreater(proxy *httputil.ReverseProxy, modifyResponseFN func(r
 // 不区分大小写地获取查询参数值
 //
 // 从 url.Values 中查找指定键名的值，忽略大小写
-// func getQueryValueCaseInsensitive(query url.Values, key string) string {
-// 	keyLower := strings.ToLower(key)
-// 	for k, v := range query {
-// 		if strings.ToLower(k) == keyLower {
-// 			if len(v) > 0 {
-// 				return v[0]
-// 			}
-// 			return ""
-// 		}
-// 	}
-// 	return ""
-// }
-
-// 根据 Strm 文件路径识别 Strm 文件类型
-//
-// 返回 Strm 文件类型和一个可选配置
-func recgonizeStrmFileType(strmFilePath string) (constants.StrmFileType, any) {
-	if config.HTTPStrm.Enable {
-		for _, prefix := range config.HTTPStrm.PrefixList {
-			if strings.HasPrefix(strmFilePath, prefix) {
-				logging.Debugf("%s 成功匹配路径：%s，Strm 类型：%s", strmFilePath, prefix, constants.HTTPStrm)
-				return constants.HTTPStrm, nil
+func getQueryValueCaseInsensitive(query url.Values, key string) string {
+	keyLower := strings.ToLower(key)
+	for k, v := range query {
+		if strings.ToLower(k) == keyLower {
+			if len(v) > 0 {
+				return v[0]
 			}
+			return ""
 		}
 	}
-	if config.AlistStrm.Enable {
-		for _, alistStrmConfig := range config.AlistStrm.List {
-			for _, prefix := range alistStrmConfig.PrefixList {
-				if strings.HasPrefix(strmFilePath, prefix) {
-					logging.Debugf("%s 成功匹配路径：%s，Strm 类型：%s，AlistServer 地址：%s", strmFilePath, prefix, constants.AlistStrm, alistStrmConfig.ADDR)
-					return constants.AlistStrm, alistStrmConfig.ADDR
-				}
-			}
-		}
+	return ""
+}
+
+// 获取字符串指针的值，指针为 nil 时返回空字符串
+func stringValue(s *string) string {
+	if s == nil {
+		return ""
 	}
-	logging.Debugf("%s 未匹配任何路径，Strm 类型：%s", strmFilePath, constants.UnknownStrm)
-	return constants.UnknownStrm, nil
+	return *s
 }
 
 const (
@@ -114,7 +95,7 @@ func getFinalURL(client *http.Client, rawURL string, ua string) (string, error)
 	redirectChain := make([]string, 0, MaxRedirectAttempts+1)
 
 	var method string
-	if config.HTTPStrm.CompatibilityMode {
+	if config.Get().HTTPStrm.CompatibilityMode {
 		method = http.MethodGet
 	} else {
 		method = http.MethodHead
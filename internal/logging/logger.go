@@ -20,21 +20,22 @@ func init() {
 }
 
 func Init() {
+	cfg := config.Get()
 	serviceLogger.SetReportCaller(false) // 关闭报告调用方
 
-	if !config.Logger.AccessLogger.Console { // 访问日志不输出到终端
+	if !cfg.Logger.AccessLogger.Console { // 访问日志不输出到终端
 		accessLogger.Out = io.Discard
 	}
 
-	if !config.Logger.ServiceLogger.Console { // 服务日志不输出到终端
+	if !cfg.Logger.ServiceLogger.Console { // 服务日志不输出到终端
 		serviceLogger.Out = io.Discard
 	}
 
-	if config.Logger.AccessLogger.File {
+	if cfg.Logger.AccessLogger.File {
 		accessLogger.AddHook(NewLoggerFileHook(false))
 	}
 
-	if config.Logger.ServiceLogger.File {
+	if cfg.Logger.ServiceLogger.File {
 		serviceLogger.AddHook(NewLoggerFileHook(true))
 	}
 }
@@ -0,0 +1,41 @@
+package middleware
+
+import (
+	"MediaWarp/internal/config"
+	"MediaWarp/internal/logging"
+	"crypto/subtle"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+)
+
+// MediaWarp 管理接口认证
+//
+// 请求需要携带与 server.auth 一致的密钥，支持以下方式：
+// 查询参数 api_key、X-Plex-Token，请求头 X-Emby-Token、X-MediaBrowser-Token、X-Plex-Token
+// 未设置 server.auth 时拒绝所有请求
+func MediaWarpAuth() gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		auth := config.Get().MediaServer.AUTH
+		if auth == "" {
+			logging.AccessWarningf(ctx, "未设置 server.auth，拒绝访问 MediaWarp 管理接口")
+			ctx.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "unauthorized"})
+			return
+		}
+
+		for _, key := range []string{
+			ctx.Query("api_key"),
+			ctx.Query("X-Plex-Token"),
+			ctx.GetHeader("X-Emby-Token"),
+			ctx.GetHeader("X-MediaBrowser-Token"),
+			ctx.GetHeader("X-Plex-Token"),
+		} {
+			if key != "" && subtle.ConstantTimeCompare([]byte(key), []byte(auth)) == 1 {
+				ctx.Next()
+				return
+			}
+		}
+		logging.AccessWarningf(ctx, "MediaWarp 管理接口认证失败")
+		ctx.AbortWithStatusJSON(http.StatusUnauthorized, gin.H{"error": "unauthorized"})
+	}
+}
@@ -0,0 +1,48 @@
+package middleware_test
+
+import (
+	"MediaWarp/internal/config"
+	"MediaWarp/internal/middleware"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+)
+
+func TestMediaWarpAuth(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	tests := map[string]struct {
+		auth   string
+		url    string
+		header map[string]string
+		status int
+	}{
+		"未设置密钥":         {auth: "", url: "/MediaWarp/config", status: http.StatusUnauthorized},
+		"未设置密钥时携带空密钥":   {auth: "", url: "/MediaWarp/config?api_key=", status: http.StatusUnauthorized},
+		"未携带密钥":         {auth: "key", url: "/MediaWarp/config", status: http.StatusUnauthorized},
+		"密钥错误":          {auth: "key", url: "/MediaWarp/config?api_key=wrong", status: http.StatusUnauthorized},
+		"查询参数 api_key":  {auth: "key", url: "/MediaWarp/config?api_key=key", status: http.StatusOK},
+		"查询参数 Plex 令牌":  {auth: "key", url: "/MediaWarp/config?X-Plex-Token=key", status: http.StatusOK},
+		"请求头 Emby 令牌":   {auth: "key", url: "/MediaWarp/config", header: map[string]string{"X-Emby-Token": "key"}, status: http.StatusOK},
+		"请求头 Plex 令牌错误": {auth: "key", url: "/MediaWarp/config", header: map[string]string{"X-Plex-Token": "wrong"}, status: http.StatusUnauthorized},
+	}
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			config.Set(&config.Setting{MediaServer: config.MediaServerSetting{AUTH: test.auth}})
+			r := gin.New()
+			r.GET("/MediaWarp/config", middleware.MediaWarpAuth(), func(ctx *gin.Context) {
+				ctx.Status(http.StatusOK)
+			})
+			req := httptest.NewRequest(http.MethodGet, test.url, nil)
+			for key, value := range test.header {
+				req.Header.Set(key, value)
+			}
+			w := httptest.NewRecorder()
+			r.ServeHTTP(w, req)
+			if w.Code != test.status {
+				t.Errorf("期望状态码: %d，实际: %d", test.status, w.Code)
+			}
+		})
+	}
+}
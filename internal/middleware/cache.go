@@ -0,0 +1,200 @@
+package middleware
+
+import (
+	"MediaWarp/internal/cache"
+	"MediaWarp/internal/logging"
+	"bytes"
+	"encoding/gob"
+	"net/http"
+	"regexp"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+const (
+	maxMemoryCacheSize = cache.MaxMemoryEntrySize // 仅使用内存缓存时，超过该大小的响应体不缓存
+	maxDiskCacheSize   = 8 * 1024 * 1024          // 启用磁盘缓存时，超过该大小的响应体不缓存
+	cacheDataOverhead  = 2 * 1024                 // 为序列化后的响应头预留的空间
+)
+
+// 缓存需要保留的响应头
+var cacheHeaders = []string{
+	"Content-Type",
+	"Cache-Control",
+	"ETag",
+	"Last-Modified",
+	"Content-Disposition",
+}
+
+// 缓存数据
+type CacheData struct {
+	StatusCode int
+	Header     map[string]string // 仅保留 cacheHeaders 中的响应头
+	Body       []byte
+}
+
+// 记录响应体的 ResponseWriter
+type cacheWriter struct {
+	gin.ResponseWriter
+	body     bytes.Buffer
+	limit    int
+	overflow bool // 响应体超出大小限制，不进行缓存
+}
+
+func (w *cacheWriter) Write(data []byte) (int, error) {
+	if !w.overflow {
+		if w.body.Len()+len(data) > w.limit {
+			w.overflow = true
+			w.body = bytes.Buffer{}
+		} else {
+			w.body.Write(data)
+		}
+	}
+	return w.ResponseWriter.Write(data)
+}
+
+func (w *cacheWriter) WriteString(s string) (int, error) {
+	return w.Write([]byte(s))
+}
+
+// 从 Cache-Control 中获取指令
+//
+// 返回指令是否存在以及指令的值
+func getCacheControlDirective(cacheControl string, directive string) (string, bool) {
+	for _, part := range strings.Split(cacheControl, ",") {
+		name, value, _ := strings.Cut(strings.TrimSpace(part), "=")
+		if strings.EqualFold(name, directive) {
+			return strings.Trim(value, `"`), true
+		}
+	}
+	return "", false
+}
+
+// 请求是否要求跳过缓存
+func requestNoCache(req *http.Request) bool {
+	cacheControl := req.Header.Get("Cache-Control")
+	if _, ok := getCacheControlDirective(cacheControl, "no-cache"); ok {
+		return true
+	}
+	if _, ok := getCacheControlDirective(cacheControl, "no-store"); ok {
+		return true
+	}
+	return req.Header.Get("Pragma") == "no-cache"
+}
+
+// 根据上游响应头计算缓存有效期
+//
+// 返回值不大于 0 表示不允许缓存
+func responseCacheTTL(header http.Header, ttl time.Duration) time.Duration {
+	if header.Get("Set-Cookie") != "" {
+		return 0
+	}
+	cacheControl := header.Get("Cache-Control")
+	for _, directive := range []string{"no-store", "private", "no-cache"} {
+		if _, ok := getCacheControlDirective(cacheControl, directive); ok {
+			return 0
+		}
+	}
+	if value, ok := getCacheControlDirective(cacheControl, "max-age"); ok {
+		if maxAge, err := strconv.Atoi(value); err == nil {
+			if maxAgeTTL := time.Duration(maxAge) * time.Second; maxAgeTTL < ttl {
+				return maxAgeTTL
+			}
+		}
+	}
+	return ttl
+}
+
+// 响应缓存中间件
+//
+// 缓存匹配正则表达式的 GET 请求响应，缓存键为路径 + 查询参数
+// 支持 If-None-Match 协商缓存，遵循上游响应的 Cache-Control
+func getCacheHandler(name string, store cache.Cache, ttl time.Duration, reg *regexp.Regexp, maxSize int) gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		if ctx.Request.Method != http.MethodGet || !reg.MatchString(ctx.Request.URL.Path) {
+			ctx.Next()
+			return
+		}
+
+		cacheKey := ctx.Request.URL.Path + "?" + ctx.Request.URL.RawQuery
+		if !requestNoCache(ctx.Request) {
+			if data, ok := store.Get(cacheKey); ok {
+				var cacheData CacheData
+				if err := gob.NewDecoder(bytes.NewReader(data)).Decode(&cacheData); err == nil {
+					logging.AccessDebugf(ctx, "命中%s缓存", name)
+					for key, value := range cacheData.Header {
+						ctx.Header(key, value)
+					}
+					ctx.Header("X-MediaWarp-Cache", "HIT")
+
+					if etag := cacheData.Header["ETag"]; etag != "" && ctx.GetHeader("If-None-Match") == etag {
+						ctx.AbortWithStatus(http.StatusNotModified)
+						return
+					}
+					ctx.Header("Content-Length", strconv.Itoa(len(cacheData.Body)))
+					ctx.Status(cacheData.StatusCode)
+					ctx.Writer.Write(cacheData.Body)
+					ctx.Abort()
+					return
+				}
+				store.Delete(cacheKey)
+			}
+		}
+
+		ctx.Request.Header.Del("Accept-Encoding")   // 缓存未压缩的响应体，避免返回客户端不支持的编码
+		ctx.Request.Header.Del("If-None-Match")     // 确保上游返回完整响应体以便缓存
+		ctx.Request.Header.Del("If-Modified-Since") // 同上
+		writer := &cacheWriter{ResponseWriter: ctx.Writer, limit: maxSize - cacheDataOverhead}
+		ctx.Writer = writer
+		ctx.Header("X-MediaWarp-Cache", "MISS")
+		ctx.Next()
+
+		if writer.Status() != http.StatusOK {
+			return
+		}
+		if writer.overflow {
+			logging.AccessDebugf(ctx, "响应体大小超过缓存限制，跳过缓存")
+			return
+		}
+		header := writer.Header()
+		cacheTTL := responseCacheTTL(header, ttl)
+		if cacheTTL <= 0 {
+			logging.AccessDebugf(ctx, "上游响应不允许缓存，Cache-Control: %s", header.Get("Cache-Control"))
+			return
+		}
+
+		cacheData := CacheData{
+			StatusCode: writer.Status(),
+			Header:     make(map[string]string, len(cacheHeaders)),
+			Body:       writer.body.Bytes(),
+		}
+		for _, key := range cacheHeaders {
+			if value := header.Get(key); value != "" {
+				cacheData.Header[key] = value
+			}
+		}
+
+		var buf bytes.Buffer
+		if err := gob.NewEncoder(&buf).Encode(&cacheData); err != nil {
+			logging.AccessWarningf(ctx, "序列化%s缓存失败：%v", name, err)
+			return
+		}
+		store.Set(cacheKey, buf.Bytes(), cacheTTL)
+		logging.AccessDebugf(ctx, "写入%s缓存，有效期：%s", name, cacheTTL)
+	}
+}
+
+// 获取缓存响应体大小限制
+func getMaxCacheSize(store cache.Cache) int {
+	switch c := store.(type) {
+	case *cache.TieredCache:
+		return maxDiskCacheSize
+	case *cache.MemoryCache:
+		return c.MaxEntrySize()
+	default:
+		return maxMemoryCacheSize
+	}
+}
@@ -1,49 +1,35 @@
 package middleware
 
 import (
-	"MediaWarp/constants"
-	"MediaWarp/internal/config"
+	"MediaWarp/internal/clientfilter"
 	"MediaWarp/internal/logging"
-	"net/http"
 	"strings"
+	"time"
 
 	"github.com/gin-gonic/gin"
 )
 
 // 客户端过滤器
+//
+// 客户端 IP 通过 ctx.ClientIP() 获取，只信任 trusted_proxies 中代理设置的 X-Forwarded-For、X-Real-IP
 func ClientFilter() gin.HandlerFunc {
 	return func(ctx *gin.Context) {
 		userAgent := ctx.Request.UserAgent()
-		var allowed bool
-		if userAgent == "" { // 开启了客户端过滤器后禁止所有未提供User-Agent的链接
-			allowed = false
-		} else {
-			switch config.ClientFilter.Mode {
-			case constants.WHITELIST: // 白名单模式
-				allowed = false
-				for _, ua := range config.ClientFilter.ClientList {
-					if strings.Contains(userAgent, ua) {
-						allowed = true
-						break
-					}
-				}
-			case constants.BLACKLIST: // 黑名单模式
-				allowed = true
-				for _, ua := range config.ClientFilter.ClientList {
-					if strings.Contains(userAgent, ua) {
-						allowed = false
-						break
-					}
-				}
-			}
+		result := clientfilter.Evaluate(ctx.Request, ctx.ClientIP(), time.Now())
+		if len(result.Logged) > 0 {
+			logging.Infof("客户端过滤规则 %s 匹配了请求，IP: %s，User-Agent: %s", strings.Join(result.Logged, "、"), ctx.ClientIP(), userAgent)
 		}
-		if !allowed {
-			ctx.AbortWithStatus(http.StatusForbidden) // 禁止访问
-			logging.Info("客户端过滤器拦截了请求，User-Agent: ", userAgent)
+		if !result.Allowed {
+			logging.Infof("客户端过滤器 %s 拦截了请求，IP: %s，User-Agent: %s", result.Rule, ctx.ClientIP(), userAgent)
+			if result.Body != "" {
+				ctx.Data(result.Status, "text/plain; charset=utf-8", []byte(result.Body))
+				ctx.Abort()
+			} else {
+				ctx.AbortWithStatus(result.Status) // 禁止访问
+			}
 			return
 		}
 		logging.Debug("客户端过滤器放行了请求，User-Agent: ", userAgent)
 		ctx.Next()
-
 	}
 }
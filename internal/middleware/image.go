@@ -0,0 +1,15 @@
+package middleware
+
+import (
+	"MediaWarp/internal/cache"
+	"regexp"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// 图片缓存中间件
+func ImageCache(ttl time.Duration, reg *regexp.Regexp) gin.HandlerFunc {
+	store := cache.GetImageCache()
+	return getCacheHandler("图片", store, ttl, reg, getMaxCacheSize(store))
+}
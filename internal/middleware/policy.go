@@ -0,0 +1,49 @@
+package middleware
+
+import (
+	"MediaWarp/internal/logging"
+	"MediaWarp/internal/policy"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+)
+
+// 访问策略
+//
+// 禁止访问时返回 403，其余策略写入请求的 Context，由媒体服务器处理器读取
+// 强制转码时在 PlaybackInfo 请求中禁用直链播放和直接串流
+func AccessPolicy() gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		p, identity := policy.Evaluate(ctx.Request)
+		if p == nil {
+			ctx.Next()
+			return
+		}
+		if p.Denied() {
+			logging.AccessWarningf(ctx, "访问策略 %s 拒绝了请求，用户：%s，设备：%s，客户端：%s", p.Rule, identity.UserName, identity.Device, identity.Client)
+			ctx.AbortWithStatusJSON(http.StatusForbidden, gin.H{"error": "forbidden"})
+			return
+		}
+		logging.AccessDebugf(ctx, "适用访问策略 %s，用户：%s，设备：%s，客户端：%s", p.Rule, identity.UserName, identity.Device, identity.Client)
+		if p.Transcode() && strings.HasSuffix(strings.ToLower(ctx.Request.URL.Path), "/playbackinfo") {
+			disableDirectPlay(ctx.Request.URL)
+		}
+		ctx.Request = ctx.Request.WithContext(policy.NewContext(ctx.Request.Context(), p))
+		ctx.Next()
+	}
+}
+
+// 要求媒体服务器在 PlaybackInfo 中返回转码地址
+func disableDirectPlay(u *url.URL) {
+	query := u.Query()
+	for key := range query {
+		if strings.EqualFold(key, "EnableDirectPlay") || strings.EqualFold(key, "EnableDirectStream") {
+			query.Del(key)
+		}
+	}
+	query.Set("EnableDirectPlay", "false")
+	query.Set("EnableDirectStream", "false")
+	u.RawQuery = query.Encode()
+}
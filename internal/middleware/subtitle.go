@@ -0,0 +1,15 @@
+package middleware
+
+import (
+	"MediaWarp/internal/cache"
+	"regexp"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// 字幕缓存中间件
+func SubtitleCache(ttl time.Duration, reg *regexp.Regexp) gin.HandlerFunc {
+	store := cache.GetSubtitleCache()
+	return getCacheHandler("字幕", store, ttl, reg, getMaxCacheSize(store))
+}
@@ -0,0 +1,165 @@
+package pathmap
+
+import (
+	"MediaWarp/internal/config"
+	"MediaWarp/utils"
+	"fmt"
+	"net/url"
+	"regexp"
+	"strings"
+	"sync"
+)
+
+// 单条映射规则
+type rule struct {
+	prefix    string
+	regex     *regexp.Regexp
+	replace   string
+	urlDecode bool
+}
+
+// 路径映射器
+//
+// 将媒体服务器中的路径（或 Strm 文件内容）映射为 Alist 中的路径
+type Mapper struct {
+	rules []rule
+}
+
+// 创建路径映射器
+func New(settings []config.PathMappingSetting) (*Mapper, error) {
+	var mapper Mapper
+	for i, setting := range settings {
+		if setting.Prefix != "" && setting.Regex != "" {
+			return nil, fmt.Errorf("path_mapping[%d] 不能同时设置 prefix 和 regex", i)
+		}
+		r := rule{
+			prefix:    setting.Prefix,
+			replace:   setting.Replace,
+			urlDecode: setting.URLDecode,
+		}
+		if setting.Regex != "" {
+			reg, err := regexp.Compile(setting.Regex)
+			if err != nil {
+				return nil, fmt.Errorf("path_mapping[%d] 的 regex 无效: %w", i, err)
+			}
+			r.regex = reg
+		}
+		mapper.rules = append(mapper.rules, r)
+	}
+	return &mapper, nil
+}
+
+// 映射路径
+//
+// 按顺序依次应用所有规则，返回映射后的路径以及是否有 prefix / regex 规则命中
+func (mapper *Mapper) Map(p string) (string, bool) {
+	if mapper == nil {
+		return p, false
+	}
+	matched := false
+	for _, r := range mapper.rules {
+		if r.urlDecode {
+			if decoded, err := url.PathUnescape(p); err == nil {
+				p = decoded
+			}
+		}
+		switch {
+		case r.prefix != "":
+			if utils.HasPathPrefix(p, r.prefix) {
+				p = r.replace + strings.TrimPrefix(p, r.prefix)
+				matched = true
+			}
+		case r.regex != nil:
+			if r.regex.MatchString(p) {
+				p = r.regex.ReplaceAllString(p, r.replace)
+				matched = true
+			}
+		}
+	}
+	return p, matched
+}
+
+// 是否包含 prefix / regex 规则
+func (mapper *Mapper) hasPathRule() bool {
+	for _, r := range mapper.rules {
+		if r.prefix != "" || r.regex != nil {
+			return true
+		}
+	}
+	return false
+}
+
+type alistMapper struct {
+	addr   string
+	mapper *Mapper
+}
+
+var (
+	mappers      []alistMapper // 按 alist_strm.list 的顺序排列
+	mappersMutex sync.RWMutex
+)
+
+// 初始化路径映射
+func Init() error {
+	publish, err := Prepare(config.Get())
+	if err != nil {
+		return err
+	}
+	publish()
+	return nil
+}
+
+// 根据 alist_strm.list[].path_mapping 为每个 Alist 创建路径映射器，返回的函数用于替换当前使用的映射器
+func Prepare(cfg *config.Setting) (func(), error) {
+	var list []alistMapper
+	if cfg.AlistStrm.Enable {
+		for i, alist := range cfg.AlistStrm.List {
+			mapper, err := New(alist.PathMapping)
+			if err != nil {
+				return nil, fmt.Errorf("alist_strm.list[%d].%w", i, err)
+			}
+			list = append(list, alistMapper{addr: alist.ADDR, mapper: mapper})
+		}
+	}
+
+	return func() {
+		mappersMutex.Lock()
+		mappers = list
+		mappersMutex.Unlock()
+	}, nil
+}
+
+// 将路径映射为指定 Alist 中的路径
+//
+// 未配置映射规则时返回原路径
+func MapAlistPath(addr string, p string) string {
+	endpoint := utils.GetEndpoint(addr)
+
+	mappersMutex.RLock()
+	defer mappersMutex.RUnlock()
+	for _, m := range mappers {
+		if utils.GetEndpoint(m.addr) == endpoint {
+			mapped, _ := m.mapper.Map(p)
+			return mapped
+		}
+	}
+	return p
+}
+
+// 查找本地文件对应的 Alist 路径
+//
+// 依次尝试每个 Alist 的映射规则，返回第一个有 prefix / regex 规则命中的 Alist 地址和映射后的路径
+// 用于非 Strm 文件（如网盘挂载）直接从 Alist 获取直链
+func Resolve(localPath string) (addr string, alistPath string, ok bool) {
+	mappersMutex.RLock()
+	defer mappersMutex.RUnlock()
+	for _, m := range mappers {
+		if !m.mapper.hasPathRule() {
+			continue
+		}
+		if mapped, matched := m.mapper.Map(localPath); matched {
+			return m.addr, mapped, true
+		}
+	}
+	return "", "", false
+}
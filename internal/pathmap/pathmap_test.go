@@ -0,0 +1,63 @@
+package pathmap_test
+
+import (
+	"MediaWarp/internal/config"
+	"MediaWarp/internal/pathmap"
+	"testing"
+)
+
+func TestMapper(t *testing.T) {
+	mapper, err := pathmap.New([]config.PathMappingSetting{
+		{URLDecode: true},
+		{Prefix: "/mnt/media", Replace: "/115"},
+		{Regex: `^/local/(\w+)/(.+)$`, Replace: "/$1/$2"},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := map[string]struct {
+		input   string
+		want    string
+		matched bool
+	}{
+		"前缀替换":     {"/mnt/media/Movies/x.mkv", "/115/Movies/x.mkv", true},
+		"正则替换":     {"/local/aliyun/Movies/x.mkv", "/aliyun/Movies/x.mkv", true},
+		"URL 解码":   {"/mnt/media/%E7%94%B5%E5%BD%B1/x.mkv", "/115/电影/x.mkv", true},
+		"未命中任何规则":  {"/other/x.mkv", "/other/x.mkv", false},
+		"前缀不在路径边界": {"/mnt/media2/x.mkv", "/mnt/media2/x.mkv", false},
+		"仅 URL 解码": {"/other/a%20b.mkv", "/other/a b.mkv", false},
+	}
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			got, matched := mapper.Map(test.input)
+			if got != test.want || matched != test.matched {
+				t.Errorf("映射结果错误。期望: %s (%v)，实际: %s (%v)", test.want, test.matched, got, matched)
+			}
+		})
+	}
+}
+
+func TestResolve(t *testing.T) {
+	config.Set(&config.Setting{AlistStrm: config.AlistStrmSetting{
+		Enable: true,
+		List: []config.AlistSetting{
+			{ADDR: "http://a:5244"},
+			{ADDR: "http://b:5244", PathMapping: []config.PathMappingSetting{{Prefix: "/mnt/b", Replace: "/b"}}},
+		},
+	}})
+	if err := pathmap.Init(); err != nil {
+		t.Fatal(err)
+	}
+
+	addr, alistPath, ok := pathmap.Resolve("/mnt/b/x.mkv")
+	if !ok || addr != "http://b:5244" || alistPath != "/b/x.mkv" {
+		t.Errorf("解析结果错误：%s %s %v", addr, alistPath, ok)
+	}
+	if _, _, ok = pathmap.Resolve("/mnt/c/x.mkv"); ok {
+		t.Errorf("期望未匹配任何 Alist")
+	}
+	if got := pathmap.MapAlistPath("http://a:5244/", "/a/x.mkv"); got != "/a/x.mkv" {
+		t.Errorf("未配置映射规则时期望返回原路径，实际: %s", got)
+	}
+}
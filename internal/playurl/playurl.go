@@ -0,0 +1,299 @@
+package playurl
+
+import (
+	"MediaWarp/internal/config"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	defaultTTL     = 6 * time.Hour                   // 默认有效期
+	pathPrefix     = "/MediaWarp/play/"              // 播放链接路径前缀
+	subtitlePrefix = "/MediaWarp/subtitle/external/" // 外挂字幕链接路径前缀
+	subtitleScope  = "subtitle."                     // 外挂字幕令牌的签名范围
+)
+
+var (
+	ErrInvalidToken = errors.New("播放链接无效")
+	ErrExpiredToken = errors.New("播放链接已过期")
+	ErrRevokedToken = errors.New("播放链接已被吊销")
+)
+
+// 播放链接中携带的信息
+type Claims struct {
+	ID            string `json:"j"` // 链接 ID，用于吊销单个链接
+	ItemID        string `json:"i"`
+	MediaSourceID string `json:"m"`
+	UserID        string `json:"u"`
+	IssuedAt      int64  `json:"t"` // 签发时间（Unix 时间戳）
+	ExpiresAt     int64  `json:"e"` // 过期时间（Unix 时间戳）
+}
+
+// 外挂字幕链接中携带的信息
+type SubtitleClaims struct {
+	AlistAddr string `json:"a"`
+	Path      string `json:"p"` // 字幕在 Alist 中的路径
+	Library   string `json:"l"` // 所属媒体库名称，用于匹配字幕调整规则
+	ExpiresAt int64  `json:"e"` // 过期时间（Unix 时间戳）
+}
+
+var (
+	mutex     sync.RWMutex
+	key       []byte
+	keySecret string // 生成 key 使用的 play_url.secret，用于重新加载配置时判断是否需要更换密钥
+
+	revokedIDs    = make(map[string]int64) // 链接 ID -> 过期时间
+	revokedUsers  = make(map[string]int64) // 用户 ID -> 吊销时间，此前签发的链接均失效
+	revokedBefore int64                    // 此前签发的链接均失效
+)
+
+// 初始化签名密钥
+func Init() error {
+	publish, err := Prepare(config.Get())
+	if err != nil {
+		return err
+	}
+	publish()
+	return nil
+}
+
+// 根据配置生成签名密钥，返回的函数用于替换当前使用的密钥
+//
+// play_url.secret 未变化时保留原密钥，已签发的链接继续有效
+func Prepare(cfg *config.Setting) (func(), error) {
+	secret := cfg.PlayURL.Secret
+	mutex.RLock()
+	unchanged := key != nil && secret == keySecret
+	mutex.RUnlock()
+	if unchanged {
+		return func() {}, nil
+	}
+
+	var newKey []byte
+	if secret != "" {
+		sum := sha256.Sum256([]byte(secret))
+		newKey = sum[:]
+	} else {
+		newKey = make([]byte, 32)
+		if _, err := rand.Read(newKey); err != nil {
+			return nil, fmt.Errorf("生成签名密钥失败: %w", err)
+		}
+	}
+	return func() {
+		mutex.Lock()
+		key, keySecret = newKey, secret
+		mutex.Unlock()
+	}, nil
+}
+
+// 是否启用签名播放链接
+func Enabled() bool {
+	return config.Get().PlayURL.Enable
+}
+
+// 签发播放链接
+//
+// 返回 /MediaWarp/play/{token}
+func Sign(itemID string, mediaSourceID string, userID string) (string, error) {
+	id := make([]byte, 8)
+	if _, err := rand.Read(id); err != nil {
+		return "", fmt.Errorf("生成链接 ID 失败: %w", err)
+	}
+	ttl := config.Get().PlayURL.TTL
+	if ttl <= 0 {
+		ttl = defaultTTL
+	}
+	now := time.Now()
+	claims := Claims{
+		ID:            hex.EncodeToString(id),
+		ItemID:        itemID,
+		MediaSourceID: mediaSourceID,
+		UserID:        userID,
+		IssuedAt:      now.Unix(),
+		ExpiresAt:     now.Add(ttl).Unix(),
+	}
+	token, err := encode(claims, "")
+	if err != nil {
+		return "", fmt.Errorf("序列化播放链接信息失败: %w", err)
+	}
+	return pathPrefix + token, nil
+}
+
+// 签发外挂字幕链接
+//
+// 返回 /MediaWarp/subtitle/external/{token}，有效期与播放链接相同，不支持吊销
+func SignSubtitle(alistAddr string, p string, library string) (string, error) {
+	ttl := config.Get().PlayURL.TTL
+	if ttl <= 0 {
+		ttl = defaultTTL
+	}
+	token, err := encode(SubtitleClaims{
+		AlistAddr: alistAddr,
+		Path:      p,
+		Library:   library,
+		ExpiresAt: time.Now().Add(ttl).Unix(),
+	}, subtitleScope)
+	if err != nil {
+		return "", fmt.Errorf("序列化外挂字幕链接信息失败: %w", err)
+	}
+	return subtitlePrefix + token, nil
+}
+
+// 校验外挂字幕链接令牌
+func VerifySubtitle(token string) (*SubtitleClaims, error) {
+	var claims SubtitleClaims
+	if err := decode(strings.TrimPrefix(token, subtitlePrefix), subtitleScope, &claims); err != nil {
+		return nil, err
+	}
+	if time.Now().Unix() > claims.ExpiresAt {
+		return nil, ErrExpiredToken
+	}
+	return &claims, nil
+}
+
+// 校验播放链接令牌
+//
+// 签名错误、已过期或已被吊销时返回错误
+func Verify(token string) (*Claims, error) {
+	claims, err := parse(token)
+	if err != nil {
+		return nil, err
+	}
+	if time.Now().Unix() > claims.ExpiresAt {
+		return nil, ErrExpiredToken
+	}
+
+	mutex.RLock()
+	defer mutex.RUnlock()
+	if _, ok := revokedIDs[claims.ID]; ok {
+		return nil, ErrRevokedToken
+	}
+	if revokedAt, ok := revokedUsers[claims.UserID]; ok && claims.IssuedAt <= revokedAt {
+		return nil, ErrRevokedToken
+	}
+	if claims.IssuedAt <= revokedBefore {
+		return nil, ErrRevokedToken
+	}
+	return claims, nil
+}
+
+// 吊销单个播放链接
+func Revoke(token string) error {
+	claims, err := parse(token)
+	if err != nil {
+		return err
+	}
+
+	mutex.Lock()
+	defer mutex.Unlock()
+	revokedIDs[claims.ID] = claims.ExpiresAt
+	pruneRevoked()
+	return nil
+}
+
+// 吊销用户此前签发的所有播放链接
+func RevokeUser(userID string) {
+	mutex.Lock()
+	defer mutex.Unlock()
+	revokedUsers[userID] = time.Now().Unix()
+	pruneRevoked()
+}
+
+// 吊销此前签发的所有播放链接
+func RevokeAll() {
+	mutex.Lock()
+	defer mutex.Unlock()
+	revokedBefore = time.Now().Unix()
+	pruneRevoked()
+}
+
+// 清理已过期的吊销记录
+//
+// 需要持有写锁
+func pruneRevoked() {
+	now := time.Now().Unix()
+	for id, expiresAt := range revokedIDs {
+		if now > expiresAt {
+			delete(revokedIDs, id)
+		}
+	}
+
+	ttl := config.Get().PlayURL.TTL
+	if ttl <= 0 {
+		ttl = defaultTTL
+	}
+	for userID, revokedAt := range revokedUsers {
+		if now-revokedAt > int64(ttl.Seconds()) || revokedAt <= revokedBefore {
+			delete(revokedUsers, userID)
+		}
+	}
+}
+
+// 解析令牌并校验签名
+func parse(token string) (*Claims, error) {
+	var claims Claims
+	if err := decode(strings.TrimPrefix(token, pathPrefix), "", &claims); err != nil {
+		return nil, err
+	}
+	return &claims, nil
+}
+
+// 序列化并签名，返回 {payload}.{signature}
+//
+// scope 参与签名，避免不同用途的令牌混用
+func encode(v any, scope string) (string, error) {
+	payload, err := json.Marshal(v)
+	if err != nil {
+		return "", err
+	}
+	encoded := base64.RawURLEncoding.EncodeToString(payload)
+	signature, err := sign(scope + encoded)
+	if err != nil {
+		return "", err
+	}
+	return encoded + "." + signature, nil
+}
+
+// 校验签名并反序列化到 v
+func decode(token string, scope string, v any) error {
+	encoded, signature, ok := strings.Cut(token, ".")
+	if !ok {
+		return ErrInvalidToken
+	}
+	expected, err := sign(scope + encoded)
+	if err != nil {
+		return err
+	}
+	if !hmac.Equal([]byte(signature), []byte(expected)) {
+		return ErrInvalidToken
+	}
+
+	payload, err := base64.RawURLEncoding.DecodeString(encoded)
+	if err != nil {
+		return ErrInvalidToken
+	}
+	if err = json.Unmarshal(payload, v); err != nil {
+		return ErrInvalidToken
+	}
+	return nil
+}
+
+func sign(encoded string) (string, error) {
+	mutex.RLock()
+	defer mutex.RUnlock()
+	if key == nil {
+		return "", errors.New("签名密钥未初始化")
+	}
+	mac := hmac.New(sha256.New, key)
+	mac.Write([]byte(encoded))
+	return base64.RawURLEncoding.EncodeToString(mac.Sum(nil)), nil
+}
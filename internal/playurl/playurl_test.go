@@ -0,0 +1,109 @@
+package playurl_test
+
+import (
+	"MediaWarp/internal/config"
+	"MediaWarp/internal/playurl"
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestPlayURL(t *testing.T) {
+	config.Set(&config.Setting{PlayURL: config.PlayURLSetting{Enable: true, Secret: "secret"}})
+	if err := playurl.Init(); err != nil {
+		t.Fatal(err)
+	}
+
+	sign := func(t *testing.T, userID string) string {
+		token, err := playurl.Sign("54", "mediasource_54", userID)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if !strings.HasPrefix(token, "/MediaWarp/play/") {
+			t.Fatalf("播放链接格式错误：%s", token)
+		}
+		return strings.TrimPrefix(token, "/MediaWarp/play/")
+	}
+
+	t.Run("校验通过", func(t *testing.T) {
+		claims, err := playurl.Verify(sign(t, "user1"))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if claims.ItemID != "54" || claims.MediaSourceID != "mediasource_54" || claims.UserID != "user1" {
+			t.Errorf("播放链接信息错误：%+v", claims)
+		}
+	})
+
+	t.Run("篡改令牌", func(t *testing.T) {
+		token := sign(t, "user1")
+		payload, signature, _ := strings.Cut(token, ".")
+		tampered := payload[:len(payload)-1] + "A." + signature
+		if _, err := playurl.Verify(tampered); !errors.Is(err, playurl.ErrInvalidToken) {
+			t.Errorf("期望 ErrInvalidToken，实际: %v", err)
+		}
+	})
+
+	t.Run("更换密钥", func(t *testing.T) {
+		token := sign(t, "user1")
+		config.Set(&config.Setting{PlayURL: config.PlayURLSetting{Enable: true, Secret: "another"}})
+		if err := playurl.Init(); err != nil {
+			t.Fatal(err)
+		}
+		defer func() {
+			config.Set(&config.Setting{PlayURL: config.PlayURLSetting{Enable: true, Secret: "secret"}})
+			playurl.Init()
+		}()
+		if _, err := playurl.Verify(token); !errors.Is(err, playurl.ErrInvalidToken) {
+			t.Errorf("期望 ErrInvalidToken，实际: %v", err)
+		}
+	})
+
+	t.Run("吊销单个链接", func(t *testing.T) {
+		revoked, kept := sign(t, "user2"), sign(t, "user2")
+		if err := playurl.Revoke(revoked); err != nil {
+			t.Fatal(err)
+		}
+		if _, err := playurl.Verify(revoked); !errors.Is(err, playurl.ErrRevokedToken) {
+			t.Errorf("期望 ErrRevokedToken，实际: %v", err)
+		}
+		if _, err := playurl.Verify(kept); err != nil {
+			t.Errorf("未吊销的链接校验失败: %v", err)
+		}
+	})
+
+	t.Run("吊销用户", func(t *testing.T) {
+		revoked, kept := sign(t, "user3"), sign(t, "user4")
+		playurl.RevokeUser("user3")
+		if _, err := playurl.Verify(revoked); !errors.Is(err, playurl.ErrRevokedToken) {
+			t.Errorf("期望 ErrRevokedToken，实际: %v", err)
+		}
+		if _, err := playurl.Verify(kept); err != nil {
+			t.Errorf("其他用户的链接校验失败: %v", err)
+		}
+	})
+
+	t.Run("外挂字幕链接", func(t *testing.T) {
+		u, err := playurl.SignSubtitle("http://alist:5244", "/movies/movie.chs.srt", "电影")
+		if err != nil {
+			t.Fatal(err)
+		}
+		token, ok := strings.CutPrefix(u, "/MediaWarp/subtitle/external/")
+		if !ok {
+			t.Fatalf("外挂字幕链接格式错误：%s", u)
+		}
+		claims, err := playurl.VerifySubtitle(token)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if claims.AlistAddr != "http://alist:5244" || claims.Path != "/movies/movie.chs.srt" || claims.Library != "电影" {
+			t.Errorf("外挂字幕链接信息错误：%+v", claims)
+		}
+		if _, err := playurl.Verify(token); !errors.Is(err, playurl.ErrInvalidToken) {
+			t.Errorf("外挂字幕令牌不应作为播放链接使用，实际: %v", err)
+		}
+		if _, err := playurl.VerifySubtitle(sign(t, "user1")); !errors.Is(err, playurl.ErrInvalidToken) {
+			t.Errorf("播放链接令牌不应作为外挂字幕链接使用，实际: %v", err)
+		}
+	})
+}
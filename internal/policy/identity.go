@@ -0,0 +1,144 @@
+package policy
+
+import (
+	"MediaWarp/constants"
+	"MediaWarp/internal/config"
+	"MediaWarp/internal/service/emby"
+	"MediaWarp/internal/service/jellyfin"
+	"net/http"
+	"strings"
+)
+
+// 客户端身份
+type Identity struct {
+	Token    string `json:"-"`
+	UserID   string `json:"user_id,omitempty"`
+	UserName string `json:"user_name,omitempty"`
+	DeviceID string `json:"device_id,omitempty"`
+	Device   string `json:"device,omitempty"`
+	Client   string `json:"client,omitempty"`
+}
+
+// 从请求中解析客户端身份
+//
+// 依次读取 Authorization / X-Emby-Authorization 请求头（MediaBrowser Client="", Device="", DeviceId="", Token=""）、
+// X-Emby-Token、X-MediaBrowser-Token 请求头以及 api_key、X-Emby-* 查询参数，不会请求媒体服务器
+// 客户端提供的 UserId 可以伪造，用户只通过 token 向媒体服务器获取
+func ParseIdentity(req *http.Request) Identity {
+	var identity Identity
+	for _, header := range []string{"X-Emby-Authorization", "Authorization"} {
+		fields := parseAuthorization(req.Header.Get(header))
+		identity.Token = first(identity.Token, fields["token"])
+		identity.DeviceID = first(identity.DeviceID, fields["deviceid"])
+		identity.Device = first(identity.Device, fields["device"])
+		identity.Client = first(identity.Client, fields["client"])
+	}
+
+	query := make(map[string]string)
+	for key, values := range req.URL.Query() {
+		if len(values) > 0 {
+			query[strings.ToLower(key)] = values[0]
+		}
+	}
+	identity.Token = first(identity.Token, req.Header.Get("X-Emby-Token"), req.Header.Get("X-MediaBrowser-Token"), query["api_key"], query["x-emby-token"])
+	identity.DeviceID = first(identity.DeviceID, req.Header.Get("X-Emby-Device-Id"), query["x-emby-device-id"], query["deviceid"])
+	identity.Device = first(identity.Device, req.Header.Get("X-Emby-Device-Name"), query["x-emby-device-name"])
+	identity.Client = first(identity.Client, req.Header.Get("X-Emby-Client"), query["x-emby-client"])
+	return identity
+}
+
+// 解析 MediaBrowser 认证请求头
+//
+// 返回的键名均为小写
+func parseAuthorization(header string) map[string]string {
+	scheme, params, ok := strings.Cut(strings.TrimSpace(header), " ")
+	if !ok || (!strings.EqualFold(scheme, "MediaBrowser") && !strings.EqualFold(scheme, "Emby")) {
+		return nil
+	}
+	fields := make(map[string]string)
+	for _, param := range strings.Split(params, ",") {
+		key, value, ok := strings.Cut(param, "=")
+		if !ok {
+			continue
+		}
+		fields[strings.ToLower(strings.TrimSpace(key))] = strings.Trim(strings.TrimSpace(value), `"`)
+	}
+	return fields
+}
+
+func first(values ...string) string {
+	for _, value := range values {
+		if value != "" {
+			return value
+		}
+	}
+	return ""
+}
+
+// 通过媒体服务器的会话列表获取 token 对应的用户
+//
+// 非管理员用户只能获取自己的会话；管理员可以获取所有会话，需要按设备 ID 区分
+func lookupUser(identity Identity) (userID string, userName string, err error) {
+	cfg := config.Get()
+	type session struct{ userID, userName, deviceID string }
+	var sessions []session
+	switch cfg.MediaServer.Type {
+	case constants.EMBY:
+		list, err := emby.New(cfg.MediaServer.ADDR, cfg.MediaServer.AUTH).SessionsServiceGetSessions(identity.Token, identity.DeviceID)
+		if err != nil {
+			return "", "", err
+		}
+		for _, s := range list {
+			sessions = append(sessions, session{value(s.UserID), value(s.UserName), value(s.DeviceID)})
+		}
+	case constants.JELLYFIN:
+		list, err := jellyfin.New(cfg.MediaServer.ADDR, cfg.MediaServer.AUTH).SessionsServiceGetSessions(identity.Token, identity.DeviceID)
+		if err != nil {
+			return "", "", err
+		}
+		for _, s := range list {
+			sessions = append(sessions, session{value(s.UserID), value(s.UserName), value(s.DeviceID)})
+		}
+	default:
+		return "", "", ErrUnsupported
+	}
+
+	for _, s := range sessions {
+		if s.userID == "" || (identity.DeviceID != "" && s.deviceID != identity.DeviceID) {
+			continue
+		}
+		if userID != "" && userID != s.userID {
+			return "", "", ErrAmbiguousUser
+		}
+		userID, userName = s.userID, s.userName
+	}
+	return userID, userName, nil
+}
+
+// 通过媒体服务器获取用户 ID 对应的用户名
+func lookupUserName(userID string) (string, error) {
+	cfg := config.Get()
+	switch cfg.MediaServer.Type {
+	case constants.EMBY:
+		user, err := emby.New(cfg.MediaServer.ADDR, cfg.MediaServer.AUTH).UserServiceGetUser(userID)
+		if err != nil {
+			return "", err
+		}
+		return value(user.Name), nil
+	case constants.JELLYFIN:
+		user, err := jellyfin.New(cfg.MediaServer.ADDR, cfg.MediaServer.AUTH).UserServiceGetUser(userID)
+		if err != nil {
+			return "", err
+		}
+		return value(user.Name), nil
+	default:
+		return "", ErrUnsupported
+	}
+}
+
+func value(p *string) string {
+	if p == nil {
+		return ""
+	}
+	return *p
+}
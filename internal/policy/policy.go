@@ -0,0 +1,274 @@
+package policy
+
+import (
+	"MediaWarp/internal/cache"
+	"MediaWarp/internal/config"
+	"MediaWarp/internal/logging"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	defaultCacheTTL  = 10 * time.Minute
+	failedCacheTTL   = time.Minute // 获取用户失败时的缓存有效期，避免频繁请求媒体服务器
+	userCacheShards  = 16
+	userCacheEntries = 256
+)
+
+var (
+	ErrUnsupported   = errors.New("当前媒体服务器不支持访问策略")
+	ErrAmbiguousUser = errors.New("无法确定 token 对应的用户")
+)
+
+// 访问策略
+//
+// 方法均可在 nil 上调用，nil 表示不限制
+type Policy struct {
+	Rule           string   `json:"rule"` // 匹配的规则名称
+	Deny           bool     `json:"deny"`
+	DisableStrm    bool     `json:"disable_strm"`
+	ForceTranscode bool     `json:"force_transcode"`
+	Libraries      []string `json:"libraries,omitempty"`
+}
+
+// 是否禁止访问
+func (p *Policy) Denied() bool {
+	return p != nil && p.Deny
+}
+
+// 是否需要交由媒体服务器处理 Strm 文件
+//
+// 强制转码时同样不重定向 Strm 文件
+func (p *Policy) StrmDisabled() bool {
+	return p != nil && (p.DisableStrm || p.ForceTranscode)
+}
+
+// 是否强制转码
+func (p *Policy) Transcode() bool {
+	return p != nil && p.ForceTranscode
+}
+
+// 是否允许播放媒体库中的项目
+//
+// 未限制媒体库时不会调用 library
+func (p *Policy) AllowLibrary(library func() string) bool {
+	if p == nil || len(p.Libraries) == 0 {
+		return true
+	}
+	return slices.Contains(p.Libraries, library())
+}
+
+var (
+	mutex    sync.RWMutex
+	users    *cache.MemoryCache // token + 设备 ID -> 用户
+	flight   cache.Group
+	rules    []config.PolicyRuleSetting
+	needUser bool // 是否有规则需要按用户匹配
+	cacheTTL time.Duration
+)
+
+// 初始化访问策略
+//
+// 重新加载配置时会再次调用，已缓存的用户信息会被清空
+func Init() {
+	cfg := config.Get()
+	mutex.Lock()
+	defer mutex.Unlock()
+	rules = cfg.Policy.Rules
+	needUser = false
+	for _, rule := range rules {
+		if len(rule.Users) > 0 {
+			needUser = true
+		}
+	}
+	cacheTTL = cfg.Policy.CacheTTL
+	if cacheTTL <= 0 {
+		cacheTTL = defaultCacheTTL
+	}
+	users = cache.NewMemoryCache(0, userCacheShards, userCacheEntries)
+}
+
+// 计算请求适用的访问策略
+//
+// 没有匹配的规则时返回 nil
+func Evaluate(req *http.Request) (*Policy, Identity) {
+	identity := ParseIdentity(req)
+	if !config.Get().Policy.Enable {
+		return nil, identity
+	}
+
+	mutex.RLock()
+	currentNeedUser := needUser
+	mutex.RUnlock()
+	if currentNeedUser && identity.Token != "" {
+		resolveUser(&identity)
+	}
+	return evaluate(identity), identity
+}
+
+// 计算指定用户适用的访问策略
+//
+// 用于签名播放链接等不携带用户 token 的请求，用户 ID 由调用方确定
+// 按用户 ID 向媒体服务器获取用户名，设备和客户端条件按请求匹配
+func EvaluateUser(req *http.Request, userID string) *Policy {
+	if !config.Get().Policy.Enable {
+		return nil
+	}
+	identity := ParseIdentity(req)
+	identity.Token = ""
+	identity.UserID, identity.UserName = userID, ""
+
+	mutex.RLock()
+	currentNeedUser := needUser
+	mutex.RUnlock()
+	if currentNeedUser && userID != "" {
+		resolveUserName(&identity)
+	}
+	return evaluate(identity)
+}
+
+// 按顺序匹配规则，没有匹配的规则时返回 nil
+func evaluate(identity Identity) *Policy {
+	mutex.RLock()
+	currentRules := rules
+	mutex.RUnlock()
+
+	for i, rule := range currentRules {
+		if !match(rule, identity) {
+			continue
+		}
+		name := rule.Name
+		if name == "" {
+			name = fmt.Sprintf("rules[%d]", i)
+		}
+		return &Policy{
+			Rule:           name,
+			Deny:           rule.Deny,
+			DisableStrm:    rule.DisableStrm,
+			ForceTranscode: rule.ForceTranscode,
+			Libraries:      rule.Libraries,
+		}
+	}
+	return nil
+}
+
+// 规则是否匹配
+func match(rule config.PolicyRuleSetting, identity Identity) bool {
+	if len(rule.Users) > 0 && !containsFold(rule.Users, identity.UserName, identity.UserID) {
+		return false
+	}
+	if len(rule.Devices) > 0 && !containsFold(rule.Devices, identity.Device, identity.DeviceID) {
+		return false
+	}
+	if len(rule.Clients) > 0 && !containsFold(rule.Clients, identity.Client) {
+		return false
+	}
+	return true
+}
+
+// list 中是否有与 values 中任意非空值相同的项（不区分大小写）
+func containsFold(list []string, values ...string) bool {
+	for _, value := range values {
+		if value == "" {
+			continue
+		}
+		for _, item := range list {
+			if strings.EqualFold(item, value) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+// 获取 token 对应的用户
+//
+// 按 token 和设备 ID 缓存，获取失败时缓存空结果
+func resolveUser(identity *Identity) {
+	key := identity.Token + "\x00" + identity.DeviceID
+	userID, userName := cachedUser(key, func() (string, string, error) {
+		userID, userName, err := lookupUser(*identity)
+		if err != nil {
+			logging.Warningf("获取 token 对应的用户失败（设备：%s，客户端：%s）：%v", identity.DeviceID, identity.Client, err)
+		}
+		return userID, userName, err
+	})
+	if userID != "" {
+		identity.UserID, identity.UserName = userID, userName
+	}
+}
+
+// 获取用户 ID 对应的用户名
+//
+// 按用户 ID 缓存，获取失败时缓存空结果
+func resolveUserName(identity *Identity) {
+	userID := identity.UserID
+	key := "\x00user\x00" + userID // 与 token 的缓存键区分
+	_, userName := cachedUser(key, func() (string, string, error) {
+		userName, err := lookupUserName(userID)
+		if err != nil {
+			logging.Warningf("获取用户 %s 的用户名失败：%v", userID, err)
+		}
+		return userID, userName, err
+	})
+	if userName != "" {
+		identity.UserName = userName
+	}
+}
+
+// 从缓存中获取用户，未缓存时调用 lookup 获取
+//
+// 获取失败时缓存空结果，避免频繁请求媒体服务器
+func cachedUser(key string, lookup func() (userID string, userName string, err error)) (string, string) {
+	mutex.RLock()
+	store, ttl := users, cacheTTL
+	mutex.RUnlock()
+
+	type user struct {
+		ID   string `json:"id"`
+		Name string `json:"name"`
+	}
+	data, ok := store.Get(key)
+	if !ok {
+		data, _ = flight.Do(key, func() ([]byte, error) {
+			var u user
+			userID, userName, err := lookup()
+			expire := ttl
+			if err != nil {
+				expire = failedCacheTTL
+			} else {
+				u.ID = userID
+				u.Name = userName
+			}
+			data, _ := json.Marshal(u)
+			store.Set(key, data, expire)
+			return data, nil
+		})
+	}
+
+	var u user
+	if json.Unmarshal(data, &u) != nil {
+		return "", ""
+	}
+	return u.ID, u.Name
+}
+
+type contextKey struct{}
+
+// 将访问策略写入 Context
+func NewContext(ctx context.Context, p *Policy) context.Context {
+	return context.WithValue(ctx, contextKey{}, p)
+}
+
+// 从 Context 中获取访问策略，未设置时返回 nil
+func FromContext(ctx context.Context) *Policy {
+	p, _ := ctx.Value(contextKey{}).(*Policy)
+	return p
+}
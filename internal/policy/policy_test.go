@@ -0,0 +1,123 @@
+package policy_test
+
+import (
+	"MediaWarp/constants"
+	"MediaWarp/internal/config"
+	"MediaWarp/internal/policy"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+)
+
+func TestParseIdentity(t *testing.T) {
+	tests := map[string]struct {
+		req  func() *http.Request
+		want policy.Identity
+	}{
+		"Authorization 请求头": {
+			req: func() *http.Request {
+				req := httptest.NewRequest(http.MethodGet, "/Items", nil)
+				req.Header.Set("Authorization", `MediaBrowser Client="Jellyfin Web", Device="Chrome", DeviceId="d1", Version="10.10.0", Token="t1"`)
+				return req
+			},
+			want: policy.Identity{Token: "t1", DeviceID: "d1", Device: "Chrome", Client: "Jellyfin Web"},
+		},
+		"X-Emby-Authorization 不信任 UserId": {
+			req: func() *http.Request {
+				req := httptest.NewRequest(http.MethodGet, "/Items", nil)
+				req.Header.Set("X-Emby-Authorization", `Emby UserId="admin", Client="Infuse", DeviceId="d2", Token="t2"`)
+				return req
+			},
+			want: policy.Identity{Token: "t2", DeviceID: "d2", Client: "Infuse"},
+		},
+		"查询参数": {
+			req: func() *http.Request {
+				return httptest.NewRequest(http.MethodGet, "/Videos/1/stream?X-Emby-Client=Emby+Web&X-Emby-Device-Id=d3&X-Emby-Device-Name=Edge&api_key=t3", nil)
+			},
+			want: policy.Identity{Token: "t3", DeviceID: "d3", Device: "Edge", Client: "Emby Web"},
+		},
+	}
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			if got := policy.ParseIdentity(test.req()); got != test.want {
+				t.Errorf("解析结果错误。\n期望: %+v\n实际: %+v", test.want, got)
+			}
+		})
+	}
+}
+
+func TestEvaluate(t *testing.T) {
+	var requests atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests.Add(1)
+		sessions := map[string][]map[string]string{
+			"kid":   {{"UserId": "u1", "UserName": "kid", "DeviceId": "ipad"}},
+			"admin": {{"UserId": "u0", "UserName": "admin", "DeviceId": "pc"}, {"UserId": "u1", "UserName": "kid", "DeviceId": "ipad"}},
+		}[r.URL.Query().Get("api_key")]
+		if sessions == nil {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		var list []map[string]string
+		for _, session := range sessions {
+			if deviceID := r.URL.Query().Get("DeviceId"); deviceID == "" || session["DeviceId"] == deviceID {
+				list = append(list, session)
+			}
+		}
+		json.NewEncoder(w).Encode(list)
+	}))
+	defer server.Close()
+
+	config.Set(&config.Setting{
+		MediaServer: config.MediaServerSetting{Type: constants.EMBY, ADDR: server.URL, AUTH: "key"},
+		Policy: config.PolicySetting{
+			Enable: true,
+			Rules: []config.PolicyRuleSetting{
+				{Name: "kid", Users: []string{"kid"}, Libraries: []string{"动画"}},
+				{Name: "tv", Clients: []string{"Emby for Android TV"}, ForceTranscode: true},
+				{Name: "browser", Devices: []string{"Edge"}, Deny: true},
+			},
+		},
+	})
+	policy.Init()
+
+	newRequest := func(authorization string) *http.Request {
+		req := httptest.NewRequest(http.MethodGet, "/Items/1/PlaybackInfo", nil)
+		req.Header.Set("X-Emby-Authorization", authorization)
+		return req
+	}
+	tests := map[string]struct {
+		authorization string
+		rule          string // 为空表示没有匹配的规则
+	}{
+		"按用户匹配":      {`MediaBrowser Client="Infuse", DeviceId="ipad", Token="kid"`, "kid"},
+		"管理员按设备区分用户": {`MediaBrowser Client="Infuse", DeviceId="pc", Token="admin"`, ""},
+		"按客户端匹配":     {`MediaBrowser Client="Emby for Android TV", DeviceId="tv", Token="admin"`, "tv"},
+		"按设备名称匹配":    {`MediaBrowser Client="Emby Web", Device="Edge", DeviceId="edge", Token="invalid"`, "browser"},
+	}
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			p, identity := policy.Evaluate(newRequest(test.authorization))
+			rule := ""
+			if p != nil {
+				rule = p.Rule
+			}
+			if rule != test.rule {
+				t.Errorf("匹配规则错误。期望: %q，实际: %q（%+v）", test.rule, rule, identity)
+			}
+		})
+	}
+
+	t.Run("缓存用户", func(t *testing.T) {
+		before := requests.Load()
+		p, _ := policy.Evaluate(newRequest(`MediaBrowser Client="Infuse", DeviceId="ipad", Token="kid"`))
+		if requests.Load() != before {
+			t.Error("相同 token 再次请求了媒体服务器")
+		}
+		if p.AllowLibrary(func() string { return "电影" }) || !p.AllowLibrary(func() string { return "动画" }) {
+			t.Error("媒体库限制错误")
+		}
+	})
+}
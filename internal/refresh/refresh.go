@@ -0,0 +1,205 @@
+package refresh
+
+import (
+	"MediaWarp/constants"
+	"MediaWarp/internal/config"
+	"MediaWarp/internal/logging"
+	"MediaWarp/internal/pathmap"
+	"MediaWarp/internal/service/emby"
+	"MediaWarp/internal/service/jellyfin"
+	"errors"
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+)
+
+const (
+	defaultDebounce = 10 * time.Second // 默认等待时间
+	maxDelayFactor  = 6                // 持续收到变化时，最多等待 debounce 的倍数后刷新
+)
+
+// 文件变化类型
+type UpdateType string
+
+const (
+	Created  UpdateType = "Created"
+	Modified UpdateType = "Modified"
+	Deleted  UpdateType = "Deleted"
+)
+
+// 文件变化
+type Update struct {
+	Path string     `json:"path"`
+	Type UpdateType `json:"type"` // 为空时视为 Modified
+}
+
+var ErrUnsupported = errors.New("当前媒体服务器不支持刷新媒体库")
+
+var (
+	mutex        sync.Mutex
+	mapper       *pathmap.Mapper
+	pendingPaths = make(map[string]UpdateType) // 媒体服务器中的路径 -> 变化类型
+	pendingItems = make(map[string]struct{})   // 需要刷新元数据的 Item ID
+	firstAt      time.Time                     // 第一个未刷新变化的时间
+	timer        *time.Timer
+)
+
+// 初始化媒体库刷新
+func Init() error {
+	publish, err := Prepare(config.Get())
+	if err != nil {
+		return err
+	}
+	publish()
+	return nil
+}
+
+// 根据配置创建路径映射器，返回的函数用于替换当前使用的映射器
+func Prepare(cfg *config.Setting) (func(), error) {
+	m, err := pathmap.New(cfg.Refresh.PathMapping)
+	if err != nil {
+		return nil, fmt.Errorf("library_refresh.%w", err)
+	}
+	return func() {
+		mutex.Lock()
+		mapper = m
+		mutex.Unlock()
+	}, nil
+}
+
+// 是否启用媒体库刷新
+func Enabled() bool {
+	return config.Get().Refresh.Enable
+}
+
+// 通知文件发生变化
+//
+// 变化会在 debounce 时间内合并，之后一次性通知媒体服务器
+func Notify(updates ...Update) {
+	if !Enabled() || len(updates) == 0 {
+		return
+	}
+	mutex.Lock()
+	defer mutex.Unlock()
+	for _, update := range updates {
+		p, _ := mapper.Map(update.Path)
+		if update.Type == "" {
+			update.Type = Modified
+		}
+		pendingPaths[p] = update.Type
+	}
+	schedule()
+}
+
+// 刷新 Item 的元数据
+//
+// 与文件变化一起合并刷新
+func RefreshItems(ids ...string) {
+	if !Enabled() || len(ids) == 0 {
+		return
+	}
+	mutex.Lock()
+	defer mutex.Unlock()
+	for _, id := range ids {
+		pendingItems[id] = struct{}{}
+	}
+	schedule()
+}
+
+// 安排刷新
+//
+// 每次收到变化都会重新等待 debounce，但距第一个变化最多等待 debounce * maxDelayFactor
+// 需要持有锁
+func schedule() {
+	debounce := config.Get().Refresh.Debounce
+	if debounce <= 0 {
+		debounce = defaultDebounce
+	}
+	now := time.Now()
+	if firstAt.IsZero() {
+		firstAt = now
+	}
+	delay := debounce
+	if deadline := firstAt.Add(debounce * maxDelayFactor); now.Add(delay).After(deadline) {
+		delay = deadline.Sub(now)
+	}
+
+	if timer != nil {
+		timer.Stop()
+	}
+	timer = time.AfterFunc(delay, Flush)
+}
+
+// 立即通知媒体服务器所有未刷新的变化
+func Flush() {
+	mutex.Lock()
+	paths, items := pendingPaths, pendingItems
+	pendingPaths = make(map[string]UpdateType)
+	pendingItems = make(map[string]struct{})
+	firstAt = time.Time{}
+	if timer != nil {
+		timer.Stop()
+		timer = nil
+	}
+	mutex.Unlock()
+
+	if len(paths) == 0 && len(items) == 0 {
+		return
+	}
+	if err := refresh(paths, items); err != nil {
+		logging.Warning("刷新媒体库失败：", err)
+	}
+}
+
+func refresh(paths map[string]UpdateType, items map[string]struct{}) error {
+	cfg := config.Get()
+	sortedPaths := make([]string, 0, len(paths))
+	for p := range paths {
+		sortedPaths = append(sortedPaths, p)
+	}
+	sort.Strings(sortedPaths)
+
+	var (
+		errs          []error
+		mediaUpdated  func() error
+		refreshItemFn func(id string) error
+	)
+	switch cfg.MediaServer.Type {
+	case constants.EMBY:
+		client := emby.New(cfg.MediaServer.ADDR, cfg.MediaServer.AUTH)
+		updates := make([]emby.MediaUpdateInfo, 0, len(sortedPaths))
+		for _, p := range sortedPaths {
+			updates = append(updates, emby.MediaUpdateInfo{Path: p, UpdateType: emby.MediaUpdateType(paths[p])})
+		}
+		mediaUpdated = func() error { return client.LibraryServicePostMediaUpdated(updates) }
+		refreshItemFn = func(id string) error { return client.ItemRefreshServiceRefresh(id, true) }
+	case constants.JELLYFIN:
+		client := jellyfin.New(cfg.MediaServer.ADDR, cfg.MediaServer.AUTH)
+		updates := make([]jellyfin.MediaUpdateInfo, 0, len(sortedPaths))
+		for _, p := range sortedPaths {
+			updates = append(updates, jellyfin.MediaUpdateInfo{Path: p, UpdateType: jellyfin.MediaUpdateType(paths[p])})
+		}
+		mediaUpdated = func() error { return client.LibraryServicePostMediaUpdated(updates) }
+		refreshItemFn = func(id string) error { return client.ItemRefreshServiceRefresh(id, true) }
+	default:
+		return ErrUnsupported
+	}
+
+	if len(sortedPaths) > 0 {
+		if err := mediaUpdated(); err != nil {
+			errs = append(errs, err)
+		} else {
+			logging.Infof("已通知媒体服务器 %d 个文件发生变化", len(sortedPaths))
+			logging.Debugf("发生变化的文件：%v", sortedPaths)
+		}
+	}
+	for id := range items {
+		if err := refreshItemFn(id); err != nil {
+			errs = append(errs, fmt.Errorf("刷新 Item %s 失败：%w", id, err))
+		} else {
+			logging.Infof("已刷新 Item %s 的元数据", id)
+		}
+	}
+	return errors.Join(errs...)
+}
@@ -0,0 +1,81 @@
+package refresh_test
+
+import (
+	"MediaWarp/constants"
+	"MediaWarp/internal/config"
+	"MediaWarp/internal/refresh"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestNotify(t *testing.T) {
+	var (
+		mutex    sync.Mutex
+		requests [][]map[string]string
+		items    []string
+	)
+	mux := http.NewServeMux()
+	mux.HandleFunc("/Library/Media/Updated", func(w http.ResponseWriter, r *http.Request) {
+		var req struct {
+			Updates []map[string]string
+		}
+		json.NewDecoder(r.Body).Decode(&req)
+		mutex.Lock()
+		requests = append(requests, req.Updates)
+		mutex.Unlock()
+		w.WriteHeader(http.StatusNoContent)
+	})
+	mux.HandleFunc("/Items/{id}/Refresh", func(w http.ResponseWriter, r *http.Request) {
+		mutex.Lock()
+		items = append(items, r.PathValue("id"))
+		mutex.Unlock()
+		w.WriteHeader(http.StatusNoContent)
+	})
+	server := httptest.NewServer(mux)
+	defer server.Close()
+
+	config.Set(&config.Setting{
+		MediaServer: config.MediaServerSetting{Type: constants.EMBY, ADDR: server.URL, AUTH: "key"},
+		Refresh: config.LibraryRefreshSetting{
+			Enable:      true,
+			Debounce:    50 * time.Millisecond,
+			PathMapping: []config.PathMappingSetting{{Prefix: "/media/strm", Replace: "/mnt/strm"}},
+		},
+	})
+	if err := refresh.Init(); err != nil {
+		t.Fatal(err)
+	}
+
+	refresh.Notify(refresh.Update{Path: "/media/strm/电影/阿凡达.strm", Type: refresh.Created})
+	refresh.Notify(
+		refresh.Update{Path: "/media/strm/电影/阿凡达.strm", Type: refresh.Modified},
+		refresh.Update{Path: "/media/strm/电影/泰坦尼克号.strm", Type: refresh.Deleted},
+	)
+	refresh.RefreshItems("100")
+	time.Sleep(300 * time.Millisecond)
+
+	mutex.Lock()
+	defer mutex.Unlock()
+	if len(requests) != 1 {
+		t.Fatalf("期望合并为 1 次请求，实际: %d", len(requests))
+	}
+	want := []map[string]string{ // 按路径排序
+		{"Path": "/mnt/strm/电影/泰坦尼克号.strm", "UpdateType": "Deleted"},
+		{"Path": "/mnt/strm/电影/阿凡达.strm", "UpdateType": "Modified"},
+	}
+	if len(requests[0]) != len(want) {
+		t.Fatalf("变化数量错误。期望: %d，实际: %d", len(want), len(requests[0]))
+	}
+	for i := range want {
+		if requests[0][i]["Path"] != want[i]["Path"] || requests[0][i]["UpdateType"] != want[i]["UpdateType"] {
+			t.Errorf("第 %d 个变化错误。期望: %v，实际: %v", i, want[i], requests[0][i])
+		}
+	}
+	if len(items) != 1 || items[0] != "100" {
+		t.Errorf("刷新的 Item 错误：%v", items)
+	}
+}
@@ -1,14 +1,19 @@
 package router
 
 import (
+	"MediaWarp/constants"
 	"net/url"
 	"strings"
 
 	"github.com/gin-gonic/gin"
 )
 
+// 将查询参数名转为小写
+//
+// 原始查询参数保存在 constants.RawQueryKey 中，查询参数区分大小写的上游服务器（如 Plex）转发前需要恢复
 func QueryKeyCaseInsensitive(internalFunc gin.HandlerFunc) gin.HandlerFunc {
 	return func(ctx *gin.Context) {
+		ctx.Set(constants.RawQueryKey, ctx.Request.URL.RawQuery)
 		queryParams := make(url.Values)
 		for key, values := range ctx.Request.URL.Query() {
 			queryParams.Add(strings.ToLower(key), strings.Join(values, ","))
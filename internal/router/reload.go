@@ -0,0 +1,31 @@
+package router
+
+import (
+	"net/http"
+	"sync/atomic"
+
+	"github.com/gin-gonic/gin"
+)
+
+// 支持热重载的路由
+//
+// 重新加载配置后重建 gin.Engine 并原子替换，进行中的请求继续由原路由处理
+type ReloadableRouter struct {
+	engine atomic.Pointer[gin.Engine]
+}
+
+// 创建支持热重载的路由
+func NewReloadableRouter() *ReloadableRouter {
+	var r ReloadableRouter
+	r.Reload()
+	return &r
+}
+
+// 根据当前配置重建路由
+func (r *ReloadableRouter) Reload() {
+	r.engine.Store(InitRouter())
+}
+
+func (r *ReloadableRouter) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	r.engine.Load().ServeHTTP(w, req)
+}
@@ -2,16 +2,25 @@ package router
 
 import (
 	"MediaWarp/constants"
+	"MediaWarp/internal/cache"
+	"MediaWarp/internal/clientfilter"
 	"MediaWarp/internal/config"
 	"MediaWarp/internal/handler"
 	"MediaWarp/internal/logging"
 	"MediaWarp/internal/middleware"
+	"MediaWarp/internal/refresh"
+	"MediaWarp/internal/service"
+	"MediaWarp/internal/stream"
+	"MediaWarp/internal/strmgen"
+	"MediaWarp/internal/webhook"
+	"errors"
 	"net/http"
 
 	"github.com/gin-gonic/gin"
 )
 
 func InitRouter() *gin.Engine {
+	cfg := config.Get() // 重新加载配置时会重建路由，路由始终与配置快照一致
 	ginR := gin.New()
 	ginR.Use(
 		middleware.Logger(),
@@ -19,7 +28,10 @@ func InitRouter() *gin.Engine {
 		middleware.SetRefererPolicy(constants.SameOrigin),
 	)
 
-	if config.ClientFilter.Enable {
+	if cfg.ClientFilter.Enable {
+		if err := ginR.SetTrustedProxies(cfg.ClientFilter.TrustedProxies); err != nil { // 未设置时不信任任何代理
+			logging.Warning("设置可信代理失败：", err)
+		}
 		ginR.Use(middleware.ClientFilter())
 		logging.Info("客户端过滤中间件已启用")
 	} else {
@@ -31,8 +43,89 @@ func InitRouter() *gin.Engine {
 		mediawarpRouter.Any("/version", func(ctx *gin.Context) {
 			ctx.JSON(http.StatusOK, config.Version())
 		})
-		if config.Web.Enable { // 启用 Web 页面修改相关设置
-			if config.Web.Custom { // 用户自定义静态资源目录
+		mediawarpRouter.GET("/config", middleware.MediaWarpAuth(), func(ctx *gin.Context) {
+			effective, err := config.Effective()
+			if err != nil {
+				ctx.JSON(http.StatusInternalServerError, gin.H{"error": err.Error()})
+				return
+			}
+			ctx.JSON(http.StatusOK, effective)
+		})
+		mediawarpRouter.GET("/stream/status", middleware.MediaWarpAuth(), func(ctx *gin.Context) {
+			ctx.JSON(http.StatusOK, stream.GetStats())
+		})
+		if cfg.ClientFilter.Enable {
+			mediawarpRouter.GET("/client/status", middleware.MediaWarpAuth(), func(ctx *gin.Context) {
+				ctx.JSON(http.StatusOK, clientfilter.GetStats())
+			})
+		}
+		if cfg.PlayURL.Enable {
+			mediawarpRouter.POST("/play/revoke", middleware.MediaWarpAuth(), handler.RevokePlayHandler)
+		}
+		if cfg.StrmGenerate.Enable {
+			mediawarpRouter.GET("/strm/generate", middleware.MediaWarpAuth(), func(ctx *gin.Context) {
+				ctx.JSON(http.StatusOK, strmgen.Status())
+			})
+			mediawarpRouter.POST("/strm/generate", middleware.MediaWarpAuth(), func(ctx *gin.Context) {
+				err := strmgen.Run(ctx.Query("task"))
+				switch {
+				case errors.Is(err, strmgen.ErrTaskNotFound):
+					ctx.JSON(http.StatusNotFound, gin.H{"error": err.Error()})
+				case err != nil:
+					ctx.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+				default:
+					ctx.JSON(http.StatusAccepted, gin.H{"message": "Strm 生成任务已开始运行"})
+				}
+			})
+		}
+		if cfg.Refresh.Enable {
+			mediawarpRouter.POST("/library/refresh", middleware.MediaWarpAuth(), func(ctx *gin.Context) {
+				var req struct {
+					Paths   []string           `json:"paths"`
+					Type    refresh.UpdateType `json:"type"` // paths 的变化类型
+					Updates []refresh.Update   `json:"updates"`
+					ItemIDs []string           `json:"item_ids"`
+				}
+				if err := ctx.ShouldBindJSON(&req); err != nil {
+					ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+					return
+				}
+				updates := req.Updates
+				for _, p := range req.Paths {
+					updates = append(updates, refresh.Update{Path: p, Type: req.Type})
+				}
+				if len(updates) == 0 && len(req.ItemIDs) == 0 {
+					ctx.JSON(http.StatusBadRequest, gin.H{"error": "paths、updates、item_ids 不能同时为空"})
+					return
+				}
+				refresh.Notify(updates...)
+				refresh.RefreshItems(req.ItemIDs...)
+				ctx.JSON(http.StatusAccepted, gin.H{"message": "已加入媒体库刷新队列"})
+			})
+		}
+		if cfg.Webhook.Enable {
+			mediawarpRouter.POST("/webhook", middleware.MediaWarpAuth(), func(ctx *gin.Context) {
+				event, err := webhook.Parse(ctx.Request)
+				if err != nil {
+					logging.AccessWarningf(ctx, "解析 Webhook 请求失败：%v", err)
+					ctx.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+					return
+				}
+				webhook.Dispatch(*event)
+				ctx.JSON(http.StatusOK, gin.H{"message": "ok"})
+			})
+		}
+		if cfg.Subtitle.Enable && cfg.Subtitle.Transcode {
+			mediawarpRouter.GET("/subtitle/charset", middleware.MediaWarpAuth(), handler.SubtitleCharsetHandler)
+			mediawarpRouter.POST("/subtitle/charset", middleware.MediaWarpAuth(), handler.SetSubtitleCharsetHandler)
+		}
+		if cfg.AlistStrm.Enable {
+			mediawarpRouter.GET("/alist/status", middleware.MediaWarpAuth(), func(ctx *gin.Context) {
+				ctx.JSON(http.StatusOK, service.AlistStatus())
+			})
+		}
+		if cfg.Web.Enable { // 启用 Web 页面修改相关设置
+			if cfg.Web.Custom { // 用户自定义静态资源目录
 				mediawarpRouter.Static("/custom", config.CostomDir())
 				logging.Info("使用自定义静态资源目录: ", config.CostomDir())
 			} else {
@@ -40,19 +133,43 @@ func InitRouter() *gin.Engine {
 				logging.Info("Web功能已启用，但未配置自定义静态资源目录。")
 				logging.Info("如需使用Web美化功能（如actor-plus、emby-swiper等），请在config.yaml中设置 web.custom: true 并提供自定义资源目录。")
 			}
-			if config.Web.Robots != "" { // 自定义 robots.txt
+			if cfg.Web.Robots != "" { // 自定义 robots.txt
 				ginR.GET(
 					"/robots.txt",
 					func(ctx *gin.Context) {
-						ctx.String(http.StatusOK, config.Web.Robots)
+						ctx.String(http.StatusOK, cfg.Web.Robots)
 					},
 				)
 			}
 		}
 	}
+	if cfg.PlayURL.Enable {
+		// Emby 客户端请求 DirectStreamUrl 时会添加 /emby 前缀
+		for _, prefix := range []string{"/MediaWarp", "/emby/MediaWarp"} {
+			ginR.GET(prefix+"/play/:token", handler.PlayHandler)
+			ginR.HEAD(prefix+"/play/:token", handler.PlayHandler)
+		}
+	}
+	if cfg.Subtitle.Enable && cfg.Subtitle.External {
+		// Emby 客户端请求非外部链接的 DeliveryUrl 时会添加 /emby 前缀
+		for _, prefix := range []string{"/MediaWarp", "/emby/MediaWarp"} {
+			ginR.GET(prefix+"/subtitle/external/:token/:name", handler.ExternalSubtitleHandler)
+		}
+	}
 
-	handlers := make(gin.HandlersChain, 0, 3)
-
+	handlers := make(gin.HandlersChain, 0, 4)
+	if cfg.Policy.Enable {
+		handlers = append(handlers, middleware.AccessPolicy())
+		logging.Info("访问策略中间件已启用")
+	}
+	if cache.GetImageCache() != nil {
+		handlers = append(handlers, middleware.ImageCache(cfg.Cache.ImageTTL, handler.GetMediaServer().GetImageCacheRegexp()))
+		logging.Info("图片缓存中间件已启用，有效期：", cfg.Cache.ImageTTL)
+	}
+	if cache.GetSubtitleCache() != nil {
+		handlers = append(handlers, middleware.SubtitleCache(cfg.Cache.SubtitleTTL, handler.GetMediaServer().GetSubtitleCacheRegexp()))
+		logging.Info("字幕缓存中间件已启用，有效期：", cfg.Cache.SubtitleTTL)
+	}
 	handlers = append(handlers, getRegexpRouterHandler())
 	ginR.NoRoute(handlers...)
 	return ginR
@@ -0,0 +1,192 @@
+package router_test
+
+import (
+	"MediaWarp/constants"
+	"MediaWarp/internal/config"
+	"MediaWarp/internal/handler"
+	"MediaWarp/internal/playurl"
+	"MediaWarp/internal/policy"
+	"MediaWarp/internal/router"
+	"MediaWarp/internal/strm"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/gin-gonic/gin"
+)
+
+// 模拟 Plex 服务器，记录收到的查询参数
+func newPlexServer(t *testing.T) (*httptest.Server, func(string) string) {
+	t.Helper()
+	var (
+		mutex   sync.Mutex
+		queries = make(map[string]string)
+	)
+	mux := http.NewServeMux()
+	mux.HandleFunc("/", func(w http.ResponseWriter, r *http.Request) {
+		mutex.Lock()
+		queries[r.URL.Path] = r.URL.RawQuery
+		mutex.Unlock()
+		switch r.URL.Path {
+		case "/library/metadata/1":
+			w.Header().Set("Content-Type", "application/json")
+			w.Write([]byte(`{"MediaContainer":{"Metadata":[{"Media":[{"Part":[{"id":1,"key":"/library/parts/1/1700000000/file.mkv","file":"/media/movie.mkv"}]}]}]}}`))
+		case "/library/parts/2/1700000000/file.strm":
+			w.Write([]byte("http://example.com/movie.mkv\n"))
+		}
+	})
+	server := httptest.NewServer(mux)
+	t.Cleanup(server.Close)
+	return server, func(p string) string {
+		mutex.Lock()
+		defer mutex.Unlock()
+		return queries[p]
+	}
+}
+
+func TestPlexRouter(t *testing.T) {
+	server, query := newPlexServer(t)
+	config.Set(&config.Setting{
+		MediaServer: config.MediaServerSetting{Type: constants.PLEX, ADDR: server.URL, AUTH: "token"},
+		HTTPStrm:    config.HTTPStrmSetting{Enable: true},
+		StrmRules:   []config.StrmRuleSetting{{Name: "http", Type: constants.HTTPStrm, Schemes: []string{"http"}}},
+	})
+	if err := strm.Init(); err != nil {
+		t.Fatal(err)
+	}
+	if err := handler.Init(); err != nil {
+		t.Fatal(err)
+	}
+	gin.SetMode(gin.TestMode)
+	mediawarp := httptest.NewServer(router.InitRouter()) // ReverseProxy 需要支持 CloseNotify 的 ResponseWriter
+	t.Cleanup(mediawarp.Close)
+	client := &http.Client{CheckRedirect: func(*http.Request, []*http.Request) error { return http.ErrUseLastResponse }}
+
+	tests := map[string]struct {
+		url      string
+		status   int
+		location string // 期望的重定向地址，为空时期望转发至上游并保留原始查询参数
+	}{
+		"转码本地视频":      {url: "/video/:/transcode/universal/start.m3u8?path=/library/metadata/1&mediaIndex=0&X-Plex-Token=abc", status: http.StatusOK},
+		"转码决策":        {url: "/video/:/transcode/universal/decision?path=/library/metadata/1&X-Plex-Token=abc", status: http.StatusOK},
+		"播放本地视频":      {url: "/library/parts/1/1700000000/file.mkv?X-Plex-Token=abc", status: http.StatusOK},
+		"播放未记录的 Strm": {url: "/library/parts/2/1700000000/file.strm?X-Plex-Token=abc", status: http.StatusFound, location: "http://example.com/movie.mkv"},
+	}
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			resp, err := client.Get(mediawarp.URL + test.url)
+			if err != nil {
+				t.Fatal(err)
+			}
+			resp.Body.Close()
+			if resp.StatusCode != test.status {
+				t.Fatalf("期望状态码: %d，实际: %d", test.status, resp.StatusCode)
+			}
+			if test.location != "" {
+				if location := resp.Header.Get("Location"); location != test.location {
+					t.Errorf("期望重定向至: %s，实际: %s", test.location, location)
+				}
+				return
+			}
+			if got := query(resp.Request.URL.Path); got != resp.Request.URL.RawQuery {
+				t.Errorf("期望上游收到查询参数: %s，实际: %s", resp.Request.URL.RawQuery, got)
+			}
+		})
+	}
+}
+
+// 模拟 Emby 服务器，Item 1 为电影媒体库中的 HTTPStrm 文件
+func newEmbyServer(t *testing.T) *httptest.Server {
+	t.Helper()
+	mux := http.NewServeMux()
+	mux.HandleFunc("/Items", func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`{"Items":[{"Id":"1","Path":"/media/strm/movie.strm","MediaSources":[{"Id":"1","Path":"http://example.com/movie.mkv","Protocol":"Http"}]}]}`))
+	})
+	mux.HandleFunc("/Items/1/Ancestors", func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`[{"Name":"电影","Type":"CollectionFolder"}]`))
+	})
+	mux.HandleFunc("/Users/{id}", func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte(`{"Id":"` + r.PathValue("id") + `","Name":"` + map[string]string{"u1": "adult", "u2": "kid", "u3": "guest"}[r.PathValue("id")] + `"}`))
+	})
+	mux.HandleFunc("/Videos/1/stream", func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("upstream"))
+	})
+	server := httptest.NewServer(mux)
+	t.Cleanup(server.Close)
+	return server
+}
+
+func TestEmbyPlayRouter(t *testing.T) {
+	server := newEmbyServer(t)
+	config.Set(&config.Setting{
+		MediaServer: config.MediaServerSetting{Type: constants.EMBY, ADDR: server.URL, AUTH: "key"},
+		HTTPStrm:    config.HTTPStrmSetting{Enable: true},
+		StrmRules:   []config.StrmRuleSetting{{Name: "http", Type: constants.HTTPStrm, Schemes: []string{"http"}}},
+		PlayURL:     config.PlayURLSetting{Enable: true, Secret: "secret"},
+		Policy: config.PolicySetting{
+			Enable: true,
+			Rules: []config.PolicyRuleSetting{
+				{Name: "kid", Users: []string{"kid"}, Libraries: []string{"动画"}},
+				{Name: "guest", Users: []string{"guest"}, DisableStrm: true},
+			},
+		},
+	})
+	for _, init := range []func() error{strm.Init, playurl.Init, handler.Init} {
+		if err := init(); err != nil {
+			t.Fatal(err)
+		}
+	}
+	policy.Init()
+	gin.SetMode(gin.TestMode)
+	mediawarp := httptest.NewServer(router.InitRouter())
+	t.Cleanup(mediawarp.Close)
+	client := &http.Client{CheckRedirect: func(*http.Request, []*http.Request) error { return http.ErrUseLastResponse }}
+
+	sign := func(userID string) string {
+		u, err := playurl.Sign("1", "1", userID)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return u
+	}
+	tests := map[string]struct {
+		method string
+		url    string
+		status int
+		body   string // 期望的响应内容，为空时不检查
+	}{
+		"播放链接":                {method: http.MethodGet, url: sign("u1"), status: http.StatusFound},
+		"带 /emby 前缀的播放链接":     {method: http.MethodGet, url: "/emby" + sign("u1"), status: http.StatusFound},
+		"带 /emby 前缀的 HEAD 请求": {method: http.MethodHead, url: "/emby" + sign("u1"), status: http.StatusOK},
+		"无效的令牌":               {method: http.MethodGet, url: "/emby/MediaWarp/play/invalid", status: http.StatusForbidden},
+		"访问策略限制媒体库":           {method: http.MethodGet, url: "/emby" + sign("u2"), status: http.StatusForbidden},
+		"访问策略禁止重定向 Strm":      {method: http.MethodGet, url: "/emby" + sign("u3"), status: http.StatusOK, body: "upstream"},
+	}
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			req, err := http.NewRequest(test.method, mediawarp.URL+test.url, nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+			resp, err := client.Do(req)
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer resp.Body.Close()
+			if resp.StatusCode != test.status {
+				t.Fatalf("期望状态码: %d，实际: %d", test.status, resp.StatusCode)
+			}
+			if test.status == http.StatusFound {
+				if location := resp.Header.Get("Location"); location != "http://example.com/movie.mkv" {
+					t.Errorf("期望重定向至: http://example.com/movie.mkv，实际: %s", location)
+				}
+			}
+			if body, _ := io.ReadAll(resp.Body); test.body != "" && !strings.Contains(string(body), test.body) {
+				t.Errorf("期望响应内容: %s，实际: %s", test.body, body)
+			}
+		})
+	}
+}
@@ -1,46 +1,184 @@
 package service
 
 import (
+	"MediaWarp/internal/cache"
 	"MediaWarp/internal/config"
 	"MediaWarp/internal/logging"
 	"MediaWarp/internal/service/alist"
 	"MediaWarp/utils"
+	"errors"
 	"fmt"
 	"sync"
+	"time"
 )
 
+const defaultHealthCheckInterval = time.Minute // 默认健康检查间隔
+
 var (
-	alistClientMap sync.Map
+	alistClientMap sync.Map // endpoint -> *alistGroup
+
+	healthCheckStop  chan struct{}
+	healthCheckMutex sync.Mutex
 )
 
 // 初始化 Alist 客户端
 func InitAlistClient() {
-	if config.AlistStrm.Enable {
-		for _, alist := range config.AlistStrm.List {
-			registerAlistClient(alist.ADDR, alist.Username, alist.Password, alist.Token)
+	PrepareAlistClient(config.Get(), cache.GetAlistAPICache())()
+}
+
+// 根据配置创建 Alist 客户端
+//
+// 创建时登录并获取用户信息，不影响当前使用的客户端
+// 返回的函数用于替换当前使用的客户端，移除已不在配置中的客户端，并重新启动健康检查
+func PrepareAlistClient(cfg *config.Setting, apiCache cache.Cache) func() {
+	groups := make(map[string]*alistGroup)
+	if cfg.AlistStrm.Enable {
+		for _, setting := range cfg.AlistStrm.List {
+			group := newAlistGroup(setting, apiCache, cfg.Cache.AlistAPITTL)
+			groups[group.endpoint] = group
+		}
+	}
+	interval := cfg.AlistStrm.HealthCheckInterval
+	if interval <= 0 {
+		interval = defaultHealthCheckInterval
+	}
+
+	return func() {
+		for endpoint, group := range groups {
+			alistClientMap.Store(endpoint, group)
+		}
+		alistClientMap.Range(func(key, _ any) bool {
+			if _, ok := groups[key.(string)]; !ok {
+				alistClientMap.Delete(key)
+			}
+			return true
+		})
+
+		healthCheckMutex.Lock()
+		defer healthCheckMutex.Unlock()
+		if healthCheckStop != nil {
+			close(healthCheckStop)
+			healthCheckStop = nil
+		}
+		if len(groups) > 0 {
+			healthCheckStop = make(chan struct{})
+			go runHealthCheck(interval, healthCheckStop)
 		}
 	}
 }
 
-// 注册Alist客户端
+// 获取Alist客户端
 //
-// 将Alist客户端注册到全局Map中
-func registerAlistClient(addr string, username string, password string, token *string) {
-	alistClient, err := alist.NewAlistClient(addr, username, password, token)
+// 返回 addr 所属分组中当前最优的 Alist 客户端
+func GetAlistClient(addr string) (*alist.AlistClient, error) {
+	group, err := getAlistGroup(addr)
 	if err != nil {
-		logging.Warningf("注册 Alist 客户端 %s 失败：%s", addr, err)
-		return
+		return nil, err
 	}
-	alistClientMap.Store(alistClient.GetEndpoint(), alistClient)
+	for _, backend := range group.sorted() {
+		if client := backend.getClient(); client != nil {
+			return client, nil
+		}
+	}
+	return nil, fmt.Errorf("%s 没有可用的 Alist 服务器", group.endpoint)
 }
 
-// 获取Alist客户端
+// 使用 Alist 客户端执行操作
 //
-// 从全局Map中获取Alist客户端
-func GetAlistClient(addr string) (*alist.AlistClient, error) {
+// 按健康状态和延迟依次尝试 addr 所属分组中的服务器，直到 fn 执行成功
+// fn 返回的错误与请求本身有关（如文件不存在）时不再尝试其他服务器
+func WithAlistClient(addr string, fn func(client *alist.AlistClient) error) error {
+	group, err := getAlistGroup(addr)
+	if err != nil {
+		return err
+	}
+
+	var errs []error
+	for _, backend := range group.sorted() {
+		client := backend.getClient()
+		if client == nil {
+			continue
+		}
+		err := fn(client)
+		if err == nil {
+			return nil
+		}
+
+		var apiErr *alist.APIError
+		if errors.As(err, &apiErr) {
+			if !apiErr.Retryable() {
+				return err
+			}
+		} else {
+			backend.markFailed(err) // 网络错误，视为服务器不可用
+		}
+		errs = append(errs, fmt.Errorf("%s: %w", client.GetEndpoint(), err))
+		logging.Warningf("Alist 服务器 %s 请求失败，尝试下一个服务器：%s", client.GetEndpoint(), err)
+	}
+	if len(errs) == 0 {
+		return fmt.Errorf("%s 没有可用的 Alist 服务器", group.endpoint)
+	}
+	return errors.Join(errs...)
+}
+
+func getAlistGroup(addr string) (*alistGroup, error) {
 	endpoint := utils.GetEndpoint(addr)
-	if client, ok := alistClientMap.Load(endpoint); ok {
-		return client.(*alist.AlistClient), nil
+	if group, ok := alistClientMap.Load(endpoint); ok {
+		return group.(*alistGroup), nil
 	}
 	return nil, fmt.Errorf("%s 未注册到 Alist 客户端列表中", endpoint)
 }
+
+// 定期检查所有 Alist 服务器
+func runHealthCheck(interval time.Duration, stop chan struct{}) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-stop:
+			return
+		case <-ticker.C:
+			alistClientMap.Range(func(_, value any) bool {
+				for _, backend := range value.(*alistGroup).backends {
+					backend.check()
+				}
+				return true
+			})
+		}
+	}
+}
+
+// Alist 服务器状态
+type AlistBackendStatus struct {
+	ADDR      string    `json:"addr"`
+	Healthy   bool      `json:"healthy"`
+	Latency   string    `json:"latency"`
+	Failures  int       `json:"failures"`
+	LastCheck time.Time `json:"last_check"`
+	LastError string    `json:"last_error,omitempty"`
+}
+
+// Alist 分组状态
+//
+// Backends 按当前选择顺序排列
+type AlistGroupStatus struct {
+	ADDR     string               `json:"addr"`
+	Backends []AlistBackendStatus `json:"backends"`
+}
+
+// 获取所有 Alist 分组的状态
+func AlistStatus() []AlistGroupStatus {
+	var status []AlistGroupStatus
+	for _, setting := range config.Get().AlistStrm.List {
+		group, err := getAlistGroup(setting.ADDR)
+		if err != nil {
+			continue
+		}
+		groupStatus := AlistGroupStatus{ADDR: group.endpoint}
+		for _, backend := range group.sorted() {
+			groupStatus.Backends = append(groupStatus.Backends, backend.status())
+		}
+		status = append(status, groupStatus)
+	}
+	return status
+}
@@ -1,6 +1,7 @@
 package alist
 
 import (
+	"MediaWarp/internal/cache"
 	"MediaWarp/utils"
 	"encoding/json"
 	"fmt"
@@ -12,6 +13,8 @@ import (
 	"time"
 )
 
+const defaultPerPage = 100 // 分页请求的默认每页数量
+
 type alistToken struct {
 	value    string       // 令牌 Token
 	expireAt time.Time    // 令牌过期时间
@@ -26,6 +29,12 @@ type AlistClient struct {
 
 	token  alistToken
 	client *http.Client
+
+	cache    cache.Cache   // API 响应缓存，为 nil 表示不缓存
+	cacheTTL time.Duration // API 响应缓存有效期
+	flight   cache.Group   // 合并同一时刻的相同请求
+
+	passwords map[string]string // 目录路径 -> 访问密码
 }
 
 // 获得AlistClient实例
@@ -70,6 +79,42 @@ func (client *AlistClient) GetUserInfo() UserInfoData {
 	return client.userInfo
 }
 
+// 设置 API 响应缓存
+//
+// store 为 nil 或 ttl 不大于 0 时不缓存
+func (client *AlistClient) SetCache(store cache.Cache, ttl time.Duration) {
+	if ttl <= 0 {
+		store = nil
+	}
+	client.cache = store
+	client.cacheTTL = ttl
+}
+
+// 设置目录访问密码
+//
+// 请求未指定密码时，使用与路径最接近的上级目录的密码
+func (client *AlistClient) SetPasswords(passwords map[string]string) {
+	client.passwords = make(map[string]string, len(passwords))
+	for p, password := range passwords {
+		client.passwords[path.Clean("/"+p)] = password
+	}
+}
+
+// 获取路径对应的访问密码
+func (client *AlistClient) getPassword(p string) string {
+	if len(client.passwords) == 0 {
+		return ""
+	}
+	for dir := path.Clean("/" + p); ; dir = path.Dir(dir) {
+		if password, ok := client.passwords[dir]; ok {
+			return password
+		}
+		if dir == "/" {
+			return ""
+		}
+	}
+}
+
 // 得到一个可用的 Token
 //
 // 先从缓存池中读取，若过期或者未找到则重新生成
@@ -97,8 +142,9 @@ func (client *AlistClient) getToken() (string, error) {
 	return loginData.Token, nil
 }
 
-func doRequest[T any](client *AlistClient, r Request) (*T, error) {
-	var resp AlistResponse[T]
+// 发送请求并返回响应中的 data 字段
+func (client *AlistClient) fetch(r Request) ([]byte, error) {
+	var resp AlistResponse[json.RawMessage]
 
 	req := newHTTPReq(client.GetEndpoint(), r)
 	req.Header.Set("Accept", "application/json")
@@ -128,10 +174,54 @@ func doRequest[T any](client *AlistClient, r Request) (*T, error) {
 	}
 
 	if resp.Code != http.StatusOK {
-		return nil, fmt.Errorf("请求失败，HTTP 状态码: %d, 响应状态码: %d, 响应信息: %s", res.StatusCode, resp.Code, resp.Message)
+		return nil, &APIError{StatusCode: res.StatusCode, Code: resp.Code, Message: resp.Message}
+	}
+
+	return resp.Data, nil
+}
+
+// 发送请求
+//
+// 设置了缓存且请求的 GetCacheKey() 不为空时，优先从缓存中读取，并合并同一时刻的相同请求
+// 请求实现 cacheFilterRequest 时，写入缓存的是处理后的响应数据
+func doRequest[T any](client *AlistClient, r Request) (*T, error) {
+	var (
+		data     []byte
+		err      error
+		cacheKey = r.GetCacheKey()
+	)
+
+	if client.cache == nil || cacheKey == "" {
+		data, err = client.fetch(r)
+	} else {
+		cacheKey = client.GetEndpoint() + "|" + client.GetUsername() + "|" + cacheKey
+		if cacheData, ok := client.cache.Get(cacheKey); ok {
+			data = cacheData
+		} else {
+			data, err = client.flight.Do(cacheKey, func() ([]byte, error) {
+				data, err := client.fetch(r)
+				if err == nil {
+					cacheData := data
+					if f, ok := r.(cacheFilterRequest); ok {
+						cacheData = f.FilterCacheData(data)
+					}
+					if cacheData != nil {
+						client.cache.Set(cacheKey, cacheData, client.cacheTTL)
+					}
+				}
+				return data, err
+			})
+		}
+	}
+	if err != nil {
+		return nil, err
 	}
 
-	return &resp.Data, nil
+	var result T
+	if err = json.Unmarshal(data, &result); err != nil {
+		return nil, fmt.Errorf("解析响应数据失败: %w", err)
+	}
+	return &result, nil
 }
 
 // ==========Alist API(v3) 相关操作==========
@@ -152,6 +242,9 @@ func (client *AlistClient) authLogin() (*AuthLoginData, error) {
 
 // 获取某个文件/目录信息
 func (client *AlistClient) FsGet(req *FsGetRequest) (*FsGetData, error) {
+	if req.Password == "" {
+		req.Password = client.getPassword(req.Path)
+	}
 	respData, err := doRequest[FsGetData](client, req)
 	if err != nil {
 		return nil, fmt.Errorf("获取文件/目录信息失败: %w", err)
@@ -159,6 +252,120 @@ func (client *AlistClient) FsGet(req *FsGetRequest) (*FsGetData, error) {
 	return respData, nil
 }
 
+// 列出目录内容
+//
+// 仅返回 req 指定的一页，PerPage 为 0 时返回全部内容
+func (client *AlistClient) FsList(req *FsListRequest) (*FsListData, error) {
+	if req.Password == "" {
+		req.Password = client.getPassword(req.Path)
+	}
+	respData, err := doRequest[FsListData](client, req)
+	if err != nil {
+		return nil, fmt.Errorf("列出目录 %s 失败: %w", req.Path, err)
+	}
+	return respData, nil
+}
+
+// 分页列出目录的全部内容
+//
+// perPage 为每页数量，为 0 时使用默认值
+func (client *AlistClient) FsListAll(p string, password string, perPage uint32, refresh bool) ([]FsObject, error) {
+	if perPage == 0 {
+		perPage = defaultPerPage
+	}
+	var content []FsObject
+	for page := uint32(1); ; page++ {
+		data, err := client.FsList(&FsListRequest{
+			Path:     p,
+			Password: password,
+			Page:     page,
+			PerPage:  perPage,
+			Refresh:  refresh && page == 1, // 仅第一页需要刷新
+		})
+		if err != nil {
+			return nil, err
+		}
+		content = append(content, data.Content...)
+		if len(data.Content) < int(perPage) || int64(len(content)) >= data.Total {
+			return content, nil
+		}
+	}
+}
+
+// 搜索文件/目录
+//
+// 需要 Alist 开启搜索索引，仅返回 req 指定的一页
+func (client *AlistClient) FsSearch(req *FsSearchRequest) (*FsSearchData, error) {
+	if req.Password == "" {
+		req.Password = client.getPassword(req.Parent)
+	}
+	if req.Page == 0 {
+		req.Page = 1
+	}
+	if req.PerPage == 0 {
+		req.PerPage = defaultPerPage
+	}
+	respData, err := doRequest[FsSearchData](client, req)
+	if err != nil {
+		return nil, fmt.Errorf("搜索 %s 失败: %w", req.Keywords, err)
+	}
+	return respData, nil
+}
+
+// 获取目录下的子目录
+func (client *AlistClient) FsDirs(req *FsDirsRequest) ([]FsDir, error) {
+	if req.Password == "" {
+		req.Password = client.getPassword(req.Path)
+	}
+	respData, err := doRequest[[]FsDir](client, req)
+	if err != nil {
+		return nil, fmt.Errorf("获取 %s 的子目录失败: %w", req.Path, err)
+	}
+	return *respData, nil
+}
+
+// 获取文件的上游真实链接
+//
+// 需要管理员权限
+func (client *AlistClient) FsLink(req *FsLinkRequest) (*FsLinkData, error) {
+	if req.Password == "" {
+		req.Password = client.getPassword(req.Path)
+	}
+	respData, err := doRequest[FsLinkData](client, req)
+	if err != nil {
+		return nil, fmt.Errorf("获取 %s 的链接失败: %w", req.Path, err)
+	}
+	return respData, nil
+}
+
+// 列出存储
+//
+// 需要管理员权限，仅返回 req 指定的一页，PerPage 为 0 时返回全部存储
+func (client *AlistClient) AdminStorageList(req *AdminStorageListRequest) (*StorageListData, error) {
+	respData, err := doRequest[StorageListData](client, req)
+	if err != nil {
+		return nil, fmt.Errorf("获取存储列表失败: %w", err)
+	}
+	return respData, nil
+}
+
+// 分页列出全部存储
+//
+// 需要管理员权限
+func (client *AlistClient) AdminStorageListAll() ([]StorageData, error) {
+	var content []StorageData
+	for page := uint32(1); ; page++ {
+		data, err := client.AdminStorageList(&AdminStorageListRequest{Page: page, PerPage: defaultPerPage})
+		if err != nil {
+			return nil, err
+		}
+		content = append(content, data.Content...)
+		if len(data.Content) < defaultPerPage || int64(len(content)) >= data.Total {
+			return content, nil
+		}
+	}
+}
+
 func (client *AlistClient) Me() (*UserInfoData, error) {
 	data, err := doRequest[UserInfoData](client, &MeRequest{})
 	if err != nil {
@@ -167,25 +374,46 @@ func (client *AlistClient) Me() (*UserInfoData, error) {
 	return data, nil
 }
 
+// 检测服务器是否可用
+//
+// 不使用缓存请求 /api/me，返回请求耗时
+func (client *AlistClient) Ping() (time.Duration, error) {
+	startTime := time.Now()
+	if _, err := client.fetch(&MeRequest{}); err != nil {
+		return 0, fmt.Errorf("获取用户信息失败: %w", err)
+	}
+	return time.Since(startTime), nil
+}
+
 // GetFileURL 获取文件的可访问 URL
 func (client *AlistClient) GetFileURL(p string, isRawURL bool) (string, error) {
-	fileData, err := client.FsGet(&FsGetRequest{Path: p, Page: 1})
+	fileData, err := client.FsGet(&FsGetRequest{Path: p, Page: 1, Link: true})
 	if err != nil {
 		return "", fmt.Errorf("获取文件信息失败：%w", err)
 	}
 	if isRawURL {
 		return fileData.RawURL, nil
 	}
+	return client.GetDownloadURL(p, fileData.Sign), nil
+}
+
+// GetDownloadURL 拼接文件的 /d 下载 URL
+//
+// sign 为 fs/get 返回的签名，为空时不添加签名参数
+func (client *AlistClient) GetDownloadURL(p string, sign string) string {
 	var url strings.Builder
 	url.WriteString(client.GetEndpoint())
-	if fileData.Sign != "" {
-		url.WriteString("?sign=" + fileData.Sign)
-	}
 	url.WriteString(path.Join("/d", client.userInfo.BasePath, p))
-	return url.String(), nil
+	if sign != "" {
+		url.WriteString("?sign=" + sign)
+	}
+	return url.String()
 }
 
 func (client *AlistClient) GetFsOther(req *FsOtherRequest) (any, error) {
+	if req.Password == "" {
+		req.Password = client.getPassword(req.Path)
+	}
 	respData, err := doRequest[any](client, req)
 	if err != nil {
 		return nil, fmt.Errorf("请求失败: %w", err)
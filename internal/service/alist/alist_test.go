@@ -0,0 +1,262 @@
+package alist_test
+
+import (
+	"MediaWarp/internal/cache"
+	"MediaWarp/internal/service/alist"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"path"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+const testToken = "test-token"
+
+// 模拟 Alist 服务器
+//
+// /movies 目录下有 5 个文件，/private 目录需要密码 123456
+func newTestServer(t *testing.T) *httptest.Server {
+	t.Helper()
+
+	reply := func(w http.ResponseWriter, code int, message string, data any) {
+		json.NewEncoder(w).Encode(map[string]any{"code": code, "message": message, "data": data})
+	}
+	files := func(dir string) []map[string]any {
+		var content []map[string]any
+		for i := 1; i <= 5; i++ {
+			content = append(content, map[string]any{"name": fmt.Sprintf("%s-%d.mkv", dir, i), "size": i * 100, "is_dir": false})
+		}
+		return content
+	}
+	page := func(content []map[string]any, page int, perPage int) []map[string]any {
+		if perPage <= 0 {
+			return content
+		}
+		start := min((page-1)*perPage, len(content))
+		end := min(start+perPage, len(content))
+		return content[start:end]
+	}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/api/me", func(w http.ResponseWriter, r *http.Request) {
+		reply(w, 200, "success", map[string]any{"username": "admin", "base_path": "/", "role": 2})
+	})
+	mux.HandleFunc("/api/fs/list", func(w http.ResponseWriter, r *http.Request) {
+		var req alist.FsListRequest
+		json.NewDecoder(r.Body).Decode(&req)
+		if strings.HasPrefix(req.Path, "/private") && req.Password != "123456" {
+			reply(w, 403, "password is incorrect or you have no permission", nil)
+			return
+		}
+		content := files(strings.Trim(req.Path, "/"))
+		reply(w, 200, "success", map[string]any{"content": page(content, int(req.Page), int(req.PerPage)), "total": len(content)})
+	})
+	mux.HandleFunc("/api/fs/search", func(w http.ResponseWriter, r *http.Request) {
+		var req alist.FsSearchRequest
+		json.NewDecoder(r.Body).Decode(&req)
+		reply(w, 200, "success", map[string]any{
+			"content": []map[string]any{{"parent": req.Parent, "name": req.Keywords + ".mkv", "is_dir": req.Scope == alist.SearchScopeDir}},
+			"total":   1,
+		})
+	})
+	mux.HandleFunc("/api/fs/dirs", func(w http.ResponseWriter, r *http.Request) {
+		reply(w, 200, "success", []map[string]any{{"name": "电影"}, {"name": "剧集"}})
+	})
+	mux.HandleFunc("/api/fs/get", func(w http.ResponseWriter, r *http.Request) {
+		var req alist.FsGetRequest
+		json.NewDecoder(r.Body).Decode(&req)
+		sign := ""
+		if strings.HasPrefix(req.Path, "/movies") {
+			sign = "abc=:0"
+		}
+		reply(w, 200, "success", map[string]any{"name": path.Base(req.Path), "raw_url": "https://cdn.example.com" + req.Path, "sign": sign})
+	})
+	mux.HandleFunc("/api/fs/link", func(w http.ResponseWriter, r *http.Request) {
+		var req alist.FsLinkRequest
+		json.NewDecoder(r.Body).Decode(&req)
+		if req.Path == "/missing.mkv" {
+			reply(w, 404, "object not found", nil)
+			return
+		}
+		reply(w, 200, "success", map[string]any{"url": "https://cdn.example.com" + req.Path, "header": map[string][]string{"Referer": {"https://example.com/"}}})
+	})
+	mux.HandleFunc("/api/admin/storage/list", func(w http.ResponseWriter, r *http.Request) {
+		var content []map[string]any
+		for i := 1; i <= 250; i++ {
+			content = append(content, map[string]any{"id": i, "mount_path": "/storage" + strconv.Itoa(i), "driver": "Local", "status": "work"})
+		}
+		p, _ := strconv.Atoi(r.URL.Query().Get("page"))
+		perPage, _ := strconv.Atoi(r.URL.Query().Get("per_page"))
+		reply(w, 200, "success", map[string]any{"content": page(content, p, perPage), "total": len(content)})
+	})
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != testToken {
+			reply(w, 401, "token is invalidated", nil)
+			return
+		}
+		mux.ServeHTTP(w, r)
+	}))
+	t.Cleanup(server.Close)
+	return server
+}
+
+func newTestClient(t *testing.T) *alist.AlistClient {
+	t.Helper()
+	token := testToken
+	client, err := alist.NewAlistClient(newTestServer(t).URL, "", "", &token)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return client
+}
+
+func TestFsList(t *testing.T) {
+	client := newTestClient(t)
+
+	t.Run("单页", func(t *testing.T) {
+		data, err := client.FsList(&alist.FsListRequest{Path: "/movies", Page: 2, PerPage: 2})
+		if err != nil {
+			t.Fatal(err)
+		}
+		if data.Total != 5 || len(data.Content) != 2 || data.Content[0].Name != "movies-3.mkv" {
+			t.Errorf("目录内容错误：%+v", data)
+		}
+	})
+
+	t.Run("分页获取全部", func(t *testing.T) {
+		content, err := client.FsListAll("/movies", "", 2, false)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(content) != 5 || content[4].Name != "movies-5.mkv" || content[4].Size != 500 {
+			t.Errorf("目录内容错误：%+v", content)
+		}
+	})
+
+	t.Run("目录密码", func(t *testing.T) {
+		_, err := client.FsList(&alist.FsListRequest{Path: "/private/movies"})
+		var apiErr *alist.APIError
+		if !errors.As(err, &apiErr) || apiErr.Code != http.StatusForbidden {
+			t.Fatalf("期望返回 403 错误，实际: %v", err)
+		}
+
+		client.SetPasswords(map[string]string{"/private": "123456"})
+		defer client.SetPasswords(nil)
+		content, err := client.FsListAll("/private/movies", "", 0, false)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if len(content) != 5 {
+			t.Errorf("目录内容数量错误。期望: 5，实际: %d", len(content))
+		}
+	})
+}
+
+func TestFsSearch(t *testing.T) {
+	client := newTestClient(t)
+	data, err := client.FsSearch(&alist.FsSearchRequest{Parent: "/movies", Keywords: "阿凡达", Scope: alist.SearchScopeFile})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if data.Total != 1 || data.Content[0].Name != "阿凡达.mkv" || data.Content[0].Parent != "/movies" || data.Content[0].IsDir {
+		t.Errorf("搜索结果错误：%+v", data)
+	}
+}
+
+func TestFsDirs(t *testing.T) {
+	client := newTestClient(t)
+	dirs, err := client.FsDirs(&alist.FsDirsRequest{Path: "/"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(dirs) != 2 || dirs[0].Name != "电影" {
+		t.Errorf("子目录错误：%+v", dirs)
+	}
+}
+
+func TestFsLink(t *testing.T) {
+	client := newTestClient(t)
+	link, err := client.FsLink(&alist.FsLinkRequest{Path: "/movies/a.mkv"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if link.URL != "https://cdn.example.com/movies/a.mkv" || link.Header["Referer"][0] != "https://example.com/" {
+		t.Errorf("链接错误：%+v", link)
+	}
+
+	_, err = client.FsLink(&alist.FsLinkRequest{Path: "/missing.mkv"})
+	var apiErr *alist.APIError
+	if !errors.As(err, &apiErr) || apiErr.Retryable() {
+		t.Errorf("期望返回不可重试的 404 错误，实际: %v", err)
+	}
+}
+
+func TestGetFileURL(t *testing.T) {
+	client := newTestClient(t)
+	tests := map[string]struct {
+		path     string
+		isRawURL bool
+		want     string
+	}{
+		"签名位于路径之后": {path: "/movies/a.mkv", want: client.GetEndpoint() + "/d/movies/a.mkv?sign=abc=:0"},
+		"无签名":      {path: "/public/a.mkv", want: client.GetEndpoint() + "/d/public/a.mkv"},
+		"原始 URL":   {path: "/movies/a.mkv", isRawURL: true, want: "https://cdn.example.com/movies/a.mkv"},
+	}
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			got, err := client.GetFileURL(test.path, test.isRawURL)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got != test.want {
+				t.Errorf("期望: %s，实际: %s", test.want, got)
+			}
+		})
+	}
+}
+
+func TestFsGetCache(t *testing.T) {
+	client := newTestClient(t)
+	client.SetCache(cache.NewMemoryCache(0, 1, 16), time.Minute)
+
+	tests := []struct { // 按顺序执行，第二次请求命中缓存
+		name string
+		link bool
+		sign string
+	}{
+		{name: "首次请求", sign: "abc=:0"},
+		{name: "缓存中不包含签名", sign: ""},
+		{name: "需要链接时不使用缓存", link: true, sign: "abc=:0"},
+	}
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			data, err := client.FsGet(&alist.FsGetRequest{Path: "/movies/a.mkv", Page: 1, Link: test.link})
+			if err != nil {
+				t.Fatal(err)
+			}
+			if data.Sign != test.sign {
+				t.Errorf("期望签名: %q，实际: %q", test.sign, data.Sign)
+			}
+			if data.Name != "a.mkv" {
+				t.Errorf("期望文件名: a.mkv，实际: %s", data.Name)
+			}
+		})
+	}
+}
+
+func TestAdminStorageListAll(t *testing.T) {
+	client := newTestClient(t)
+	storages, err := client.AdminStorageListAll()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(storages) != 250 || storages[249].MountPath != "/storage250" || storages[0].Status != "work" {
+		t.Errorf("存储列表错误，共 %d 个", len(storages))
+	}
+}
@@ -0,0 +1,52 @@
+package alist
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+)
+
+// Alist 以 500 状态码返回的逻辑错误信息（小写），与服务器状态无关
+var logicalErrorMessages = []string{
+	"not found",             // object not found、storage not found 等
+	"password is incorrect", // 目录密码错误
+	"no permission",
+	"not a folder",
+	"not a file",
+	"file exists",
+}
+
+// Alist API 返回的错误
+//
+// 请求已到达 Alist 服务器，但响应中的状态码不为 200
+type APIError struct {
+	StatusCode int    // HTTP 状态码
+	Code       int64  // 响应状态码
+	Message    string // 响应信息
+}
+
+func (e *APIError) Error() string {
+	return fmt.Sprintf("请求失败，HTTP 状态码: %d, 响应状态码: %d, 响应信息: %s", e.StatusCode, e.Code, e.Message)
+}
+
+// 换用其他服务器重试是否可能成功
+//
+// 400、404 等错误与请求本身有关，换用其他服务器重试没有意义；
+// 401、403 可能由该服务器的账号配置引起，5xx 可能为服务器内部错误，均值得重试；
+// 但 Alist 对文件不存在等逻辑错误同样返回 500，需根据响应信息排除
+func (e *APIError) Retryable() bool {
+	switch {
+	case e.Code == http.StatusUnauthorized, e.Code == http.StatusForbidden:
+		return true
+	case e.Code >= http.StatusInternalServerError:
+		message := strings.ToLower(e.Message)
+		for _, logical := range logicalErrorMessages {
+			if strings.Contains(message, logical) {
+				return false
+			}
+		}
+		return true
+	default:
+		return false
+	}
+}
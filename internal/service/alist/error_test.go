@@ -0,0 +1,27 @@
+package alist_test
+
+import (
+	"MediaWarp/internal/service/alist"
+	"testing"
+)
+
+func TestAPIErrorRetryable(t *testing.T) {
+	tests := map[string]struct {
+		err  alist.APIError
+		want bool
+	}{
+		"请求参数错误":  {alist.APIError{Code: 400, Message: "bad request"}, false},
+		"认证失败":    {alist.APIError{Code: 401, Message: "token is expired"}, true},
+		"服务器内部错误": {alist.APIError{Code: 500, Message: "failed get objs: context deadline exceeded"}, true},
+		"文件不存在":   {alist.APIError{Code: 500, Message: "failed get link: failed get obj: object not found"}, false},
+		"存储不存在":   {alist.APIError{Code: 500, Message: "storage not found; please add a storage first"}, false},
+		"目录密码错误":  {alist.APIError{Code: 403, Message: "password is incorrect or you have no permission"}, true},
+	}
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			if got := test.err.Retryable(); got != test.want {
+				t.Errorf("期望: %t，实际: %t", test.want, got)
+			}
+		})
+	}
+}
@@ -6,6 +6,7 @@ import (
 	"fmt"
 	"io"
 	"net/http"
+	"net/url"
 	"strconv"
 )
 
@@ -16,6 +17,16 @@ type Request interface {
 	GetCacheKey() string
 }
 
+// 通过查询参数传递参数的 GET 请求
+type queryRequest interface {
+	GetQuery() url.Values
+}
+
+// 写入缓存前需要处理响应数据的请求
+type cacheFilterRequest interface {
+	FilterCacheData(data []byte) []byte // 返回 nil 表示不写入缓存
+}
+
 func getReqBody(r Request) io.Reader {
 	b, err := json.Marshal(r)
 	if err != nil {
@@ -30,7 +41,11 @@ func newHTTPReq(endpoint string, r Request) *http.Request {
 		err error
 	)
 	if r.GetMethod() == http.MethodGet {
-		req, err = http.NewRequest(r.GetMethod(), endpoint+r.GetAPIPath(), nil)
+		u := endpoint + r.GetAPIPath()
+		if q, ok := r.(queryRequest); ok {
+			u += "?" + q.GetQuery().Encode()
+		}
+		req, err = http.NewRequest(r.GetMethod(), u, nil)
 	} else {
 		req, err = http.NewRequest(r.GetMethod(), endpoint+r.GetAPIPath(), getReqBody(r))
 	}
@@ -46,6 +61,7 @@ type FsGetRequest struct {
 	Page     uint32 `json:"page"`
 	PerPage  uint32 `json:"per_page"`
 	Refresh  bool   `json:"refresh"`
+	Link     bool   `json:"-"` // 是否需要使用 raw_url、sign，链接有时效，为 true 时不使用缓存
 }
 
 func (FsGetRequest) GetMethod() string {
@@ -61,9 +77,165 @@ func (FsGetRequest) NeedAuth() bool {
 }
 
 func (req *FsGetRequest) GetCacheKey() string {
+	if req.Refresh || req.Link { // 强制刷新和需要链接的请求不使用缓存
+		return ""
+	}
 	return req.GetAPIPath() + req.Path + req.Password + strconv.Itoa(int(req.Page)) + strconv.Itoa(int(req.PerPage)) + strconv.FormatBool(req.Refresh)
 }
 
+// 缓存中不保存有时效的 raw_url 和 sign
+func (FsGetRequest) FilterCacheData(data []byte) []byte {
+	var fsGetData FsGetData
+	if err := json.Unmarshal(data, &fsGetData); err != nil {
+		return nil
+	}
+	fsGetData.RawURL, fsGetData.Sign = "", ""
+	filtered, err := json.Marshal(fsGetData)
+	if err != nil {
+		return nil
+	}
+	return filtered
+}
+
+type FsListRequest struct {
+	Path     string `json:"path"`
+	Password string `json:"password"`
+	Page     uint32 `json:"page"`
+	PerPage  uint32 `json:"per_page"` // 为 0 时返回全部内容
+	Refresh  bool   `json:"refresh"`
+}
+
+func (FsListRequest) GetMethod() string {
+	return http.MethodPost
+}
+
+func (FsListRequest) GetAPIPath() string {
+	return "/api/fs/list"
+}
+
+func (FsListRequest) NeedAuth() bool {
+	return true
+}
+
+func (req *FsListRequest) GetCacheKey() string {
+	if req.Refresh { // 强制刷新的请求不使用缓存
+		return ""
+	}
+	return req.GetAPIPath() + req.Path + req.Password + strconv.Itoa(int(req.Page)) + strconv.Itoa(int(req.PerPage))
+}
+
+// 搜索范围
+type SearchScope int
+
+const (
+	SearchScopeAll  SearchScope = iota // 文件和目录
+	SearchScopeDir                     // 仅目录
+	SearchScopeFile                    // 仅文件
+)
+
+// 需要 Alist 开启搜索索引
+type FsSearchRequest struct {
+	Parent   string      `json:"parent"` // 搜索的目录
+	Keywords string      `json:"keywords"`
+	Scope    SearchScope `json:"scope"`
+	Password string      `json:"password"`
+	Page     uint32      `json:"page"`
+	PerPage  uint32      `json:"per_page"`
+}
+
+func (FsSearchRequest) GetMethod() string {
+	return http.MethodPost
+}
+
+func (FsSearchRequest) GetAPIPath() string {
+	return "/api/fs/search"
+}
+
+func (FsSearchRequest) NeedAuth() bool {
+	return true
+}
+
+func (req *FsSearchRequest) GetCacheKey() string {
+	return req.GetAPIPath() + req.Parent + req.Keywords + strconv.Itoa(int(req.Scope)) + req.Password + strconv.Itoa(int(req.Page)) + strconv.Itoa(int(req.PerPage))
+}
+
+type FsDirsRequest struct {
+	Path      string `json:"path"`
+	Password  string `json:"password"`
+	ForceRoot bool   `json:"force_root"` // 以根目录而不是用户的 base_path 为起点，需要管理员权限
+}
+
+func (FsDirsRequest) GetMethod() string {
+	return http.MethodPost
+}
+
+func (FsDirsRequest) GetAPIPath() string {
+	return "/api/fs/dirs"
+}
+
+func (FsDirsRequest) NeedAuth() bool {
+	return true
+}
+
+func (req *FsDirsRequest) GetCacheKey() string {
+	return req.GetAPIPath() + req.Path + req.Password + strconv.FormatBool(req.ForceRoot)
+}
+
+// 需要管理员权限
+type FsLinkRequest struct {
+	Path     string `json:"path"`
+	Password string `json:"password"`
+}
+
+func (FsLinkRequest) GetMethod() string {
+	return http.MethodPost
+}
+
+func (FsLinkRequest) GetAPIPath() string {
+	return "/api/fs/link"
+}
+
+func (FsLinkRequest) NeedAuth() bool {
+	return true
+}
+
+func (req *FsLinkRequest) GetCacheKey() string {
+	return "" // 链接有时效，不使用缓存
+}
+
+// 需要管理员权限
+type AdminStorageListRequest struct {
+	Page    uint32 `json:"page"`
+	PerPage uint32 `json:"per_page"` // 为 0 时返回全部存储
+}
+
+func (AdminStorageListRequest) GetMethod() string {
+	return http.MethodGet
+}
+
+func (AdminStorageListRequest) GetAPIPath() string {
+	return "/api/admin/storage/list"
+}
+
+func (AdminStorageListRequest) NeedAuth() bool {
+	return true
+}
+
+func (req *AdminStorageListRequest) GetCacheKey() string {
+	return ""
+}
+
+func (req *AdminStorageListRequest) GetQuery() url.Values {
+	query := make(url.Values)
+	if req.Page > 0 {
+		query.Set("page", strconv.Itoa(int(req.Page)))
+	}
+	if req.PerPage > 0 {
+		query.Set("per_page", strconv.Itoa(int(req.PerPage)))
+	}
+	return query
+}
+
 type FsOtherRequest struct {
 	Path     string `json:"path"`
 	Method   string `json:"method"`
@@ -32,6 +32,79 @@ type FsGetData struct {
 	Type     int64  `json:"type"`  // 类型
 }
 
+// 目录中的文件/目录信息
+type FsObject struct {
+	Created  string `json:"created"` // 创建时间
+	HashInfo any    `json:"hash_info"`
+	Hashinfo string `json:"hashinfo"`
+	IsDir    bool   `json:"is_dir"`   // 是否是文件夹
+	Modified string `json:"modified"` // 修改时间
+	Name     string `json:"name"`     // 文件名
+	Sign     string `json:"sign"`     // 签名
+	Size     int64  `json:"size"`     // 大小
+	Thumb    string `json:"thumb"`    // 缩略图
+	Type     int64  `json:"type"`     // 类型
+}
+
+type FsListData struct {
+	Content  []FsObject `json:"content"` // 目录内容
+	Header   string     `json:"header"`
+	Provider string     `json:"provider"`
+	Readme   string     `json:"readme"` // 说明
+	Total    int64      `json:"total"`  // 总数
+	Write    bool       `json:"write"`  // 是否可写
+}
+
+type FsSearchObject struct {
+	IsDir  bool   `json:"is_dir"` // 是否是文件夹
+	Name   string `json:"name"`   // 文件名
+	Parent string `json:"parent"` // 所在目录
+	Size   int64  `json:"size"`   // 大小
+	Type   int64  `json:"type"`   // 类型
+}
+
+type FsSearchData struct {
+	Content []FsSearchObject `json:"content"` // 搜索结果
+	Total   int64            `json:"total"`   // 总数
+}
+
+type FsDir struct {
+	Modified string `json:"modified"` // 修改时间
+	Name     string `json:"name"`     // 目录名
+}
+
+type FsLinkData struct {
+	URL         string              `json:"url"`         // 上游真实链接
+	Header      map[string][]string `json:"header"`      // 请求上游链接时需要携带的请求头
+	Concurrency int64               `json:"concurrency"` // 最大并发数
+	PartSize    int64               `json:"part_size"`   // 分片大小
+}
+
+type StorageData struct {
+	ID              int64  `json:"id"`
+	MountPath       string `json:"mount_path"` // 挂载路径
+	Order           int64  `json:"order"`
+	Driver          string `json:"driver"` // 驱动
+	CacheExpiration int64  `json:"cache_expiration"`
+	Status          string `json:"status"` // 状态，正常时为 work
+	Addition        string `json:"addition"`
+	Remark          string `json:"remark"` // 备注
+	Modified        string `json:"modified"`
+	Disabled        bool   `json:"disabled"` // 是否禁用
+	EnableSign      bool   `json:"enable_sign"`
+	OrderBy         string `json:"order_by"`
+	OrderDirection  string `json:"order_direction"`
+	ExtractFolder   string `json:"extract_folder"`
+	WebProxy        bool   `json:"web_proxy"`
+	WebdavPolicy    string `json:"webdav_policy"`
+	DownProxyURL    string `json:"down_proxy_url"`
+}
+
+type StorageListData struct {
+	Content []StorageData `json:"content"` // 存储列表
+	Total   int64         `json:"total"`   // 总数
+}
+
 type UserInfoData struct {
 	BasePath   string   `json:"base_path"`  // 根目录
 	Disabled   bool     `json:"disabled"`   // 是否禁用
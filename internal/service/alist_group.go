@@ -0,0 +1,182 @@
+package service
+
+import (
+	"MediaWarp/internal/cache"
+	"MediaWarp/internal/config"
+	"MediaWarp/internal/logging"
+	"MediaWarp/internal/service/alist"
+	"MediaWarp/utils"
+	"sort"
+	"sync"
+	"time"
+)
+
+// 一组提供相同目录结构的 Alist 服务器
+//
+// 以 alist_strm.list[].addr 作为分组标识，backends[0] 为 addr 本身
+type alistGroup struct {
+	endpoint string
+	backends []*alistBackend
+}
+
+// apiCache、apiCacheTTL 为分组内客户端使用的 API 响应缓存
+func newAlistGroup(setting config.AlistSetting, apiCache cache.Cache, apiCacheTTL time.Duration) *alistGroup {
+	group := alistGroup{endpoint: utils.GetEndpoint(setting.ADDR)}
+	group.backends = append(group.backends, newAlistBackend(setting.ADDR, setting.Username, setting.Password, setting.Token, setting.Passwords, apiCache, apiCacheTTL))
+	for _, backend := range setting.Backends {
+		username, password, token := backend.Username, backend.Password, backend.Token
+		if username == "" && token == nil {
+			username, password, token = setting.Username, setting.Password, setting.Token
+		}
+		group.backends = append(group.backends, newAlistBackend(backend.ADDR, username, password, token, setting.Passwords, apiCache, apiCacheTTL))
+	}
+	return &group
+}
+
+// 按选择顺序排列的服务器
+//
+// 健康的服务器按延迟从低到高排列，不健康的服务器排在最后
+func (group *alistGroup) sorted() []*alistBackend {
+	type entry struct {
+		backend *alistBackend
+		healthy bool
+		latency time.Duration
+	}
+	entries := make([]entry, len(group.backends))
+	for i, backend := range group.backends {
+		backend.mutex.RLock()
+		entries[i] = entry{backend: backend, healthy: backend.healthy, latency: backend.latency}
+		backend.mutex.RUnlock()
+	}
+	sort.SliceStable(entries, func(i, j int) bool {
+		if entries[i].healthy != entries[j].healthy {
+			return entries[i].healthy
+		}
+		return entries[i].healthy && entries[i].latency < entries[j].latency
+	})
+
+	backends := make([]*alistBackend, len(entries))
+	for i, e := range entries {
+		backends[i] = e.backend
+	}
+	return backends
+}
+
+// 单个 Alist 服务器
+type alistBackend struct {
+	addr     string
+	username string
+	password string
+	token    *string
+	dirPass  map[string]string // 目录访问密码
+
+	apiCache    cache.Cache // API 响应缓存，为 nil 表示不缓存
+	apiCacheTTL time.Duration
+
+	mutex     sync.RWMutex
+	client    *alist.AlistClient // 注册失败时为 nil，健康检查时重试
+	healthy   bool
+	latency   time.Duration // 请求 /api/me 的平滑延迟
+	failures  int           // 连续失败次数
+	lastCheck time.Time
+	lastError string
+}
+
+func newAlistBackend(addr string, username string, password string, token *string, dirPass map[string]string, apiCache cache.Cache, apiCacheTTL time.Duration) *alistBackend {
+	backend := alistBackend{
+		addr:        addr,
+		username:    username,
+		password:    password,
+		token:       token,
+		dirPass:     dirPass,
+		apiCache:    apiCache,
+		apiCacheTTL: apiCacheTTL,
+	}
+	backend.register()
+	return &backend
+}
+
+// 注册 Alist 客户端
+//
+// 登录（如有需要）并获取当前用户信息，成功后视为健康
+func (backend *alistBackend) register() bool {
+	startTime := time.Now()
+	client, err := alist.NewAlistClient(backend.addr, backend.username, backend.password, backend.token)
+	if err != nil {
+		logging.Warningf("注册 Alist 客户端 %s 失败：%s", backend.addr, err)
+		backend.markFailed(err)
+		return false
+	}
+	client.SetCache(backend.apiCache, backend.apiCacheTTL)
+	client.SetPasswords(backend.dirPass)
+
+	backend.mutex.Lock()
+	backend.client = client
+	backend.mutex.Unlock()
+	backend.markHealthy(time.Since(startTime))
+	return true
+}
+
+func (backend *alistBackend) getClient() *alist.AlistClient {
+	backend.mutex.RLock()
+	defer backend.mutex.RUnlock()
+	return backend.client
+}
+
+// 检查服务器是否可用
+func (backend *alistBackend) check() {
+	client := backend.getClient()
+	if client == nil {
+		if backend.register() {
+			logging.Infof("Alist 服务器 %s 注册成功", backend.addr)
+		}
+		return
+	}
+
+	latency, err := client.Ping()
+	if err != nil {
+		logging.Warningf("Alist 服务器 %s 健康检查失败：%s", backend.addr, err)
+		backend.markFailed(err)
+		return
+	}
+	backend.markHealthy(latency)
+}
+
+func (backend *alistBackend) markHealthy(latency time.Duration) {
+	backend.mutex.Lock()
+	defer backend.mutex.Unlock()
+	if !backend.healthy && backend.failures > 0 {
+		logging.Infof("Alist 服务器 %s 恢复可用", backend.addr)
+	}
+	if backend.latency == 0 {
+		backend.latency = latency
+	} else {
+		backend.latency = (backend.latency*7 + latency*3) / 10
+	}
+	backend.healthy = true
+	backend.failures = 0
+	backend.lastCheck = time.Now()
+	backend.lastError = ""
+}
+
+func (backend *alistBackend) markFailed(err error) {
+	backend.mutex.Lock()
+	defer backend.mutex.Unlock()
+	backend.healthy = false
+	backend.failures++
+	backend.lastCheck = time.Now()
+	backend.lastError = err.Error()
+}
+
+func (backend *alistBackend) status() AlistBackendStatus {
+	backend.mutex.RLock()
+	defer backend.mutex.RUnlock()
+	return AlistBackendStatus{
+		ADDR:      backend.addr,
+		Healthy:   backend.healthy,
+		Latency:   backend.latency.String(),
+		Failures:  backend.failures,
+		LastCheck: backend.lastCheck,
+		LastError: backend.lastError,
+	}
+}
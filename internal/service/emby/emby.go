@@ -3,10 +3,14 @@ package emby
 import (
 	"MediaWarp/constants"
 	"MediaWarp/utils"
+	"bytes"
 	"encoding/json"
+	"fmt"
 	"io"
+	"net/http"
 	"net/url"
 	"strconv"
+	"strings"
 )
 
 type Client struct {
@@ -63,6 +67,171 @@ func (client *Client) ItemsServiceQueryItem(ids string, limit int, fields string
 	return itemResponse, nil
 }
 
+// 获取 Item 的所有祖先
+//
+// /Items/{Id}/Ancestors
+func (client *Client) ItemsServiceGetAncestors(id string) ([]BaseItemDto, error) {
+	var (
+		params    = url.Values{}
+		ancestors []BaseItemDto
+	)
+	params.Add("api_key", client.GetAPIKey())
+	api := client.GetEndpoint() + "/Items/" + url.PathEscape(id) + "/Ancestors?" + params.Encode()
+	resp, err := utils.GetHTTPClient().Get(api)
+	if err != nil {
+		return nil, err
+	}
+
+	defer resp.Body.Close()
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, err
+	}
+
+	if err = json.Unmarshal(body, &ancestors); err != nil {
+		return nil, err
+	}
+	return ancestors, nil
+}
+
+// 获取剧集的分集
+//
+// /Shows/{Id}/Episodes
+// startItemID 不为空时从该分集开始返回，userID 可以为空
+func (client *Client) TvShowsServiceGetEpisodes(seriesID string, startItemID string, userID string, limit int, fields string) (*EmbyResponse, error) {
+	var (
+		params   = url.Values{}
+		episodes = &EmbyResponse{}
+	)
+	if startItemID != "" {
+		params.Add("StartItemId", startItemID)
+	}
+	if userID != "" {
+		params.Add("UserId", userID)
+	}
+	params.Add("Limit", strconv.Itoa(limit))
+	params.Add("Fields", fields)
+	params.Add("api_key", client.GetAPIKey())
+	resp, err := utils.GetHTTPClient().Get(client.GetEndpoint() + "/Shows/" + url.PathEscape(seriesID) + "/Episodes?" + params.Encode())
+	if err != nil {
+		return nil, err
+	}
+
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("请求 /Shows/%s/Episodes 失败，状态码：%d", seriesID, resp.StatusCode)
+	}
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, err
+	}
+
+	if err = json.Unmarshal(body, episodes); err != nil {
+		return nil, err
+	}
+	return episodes, nil
+}
+
+// 获取会话列表
+//
+// /Sessions
+// 使用客户端的 token 请求，非管理员用户只能获取自己的会话；deviceID 不为空时只返回该设备的会话
+func (client *Client) SessionsServiceGetSessions(token string, deviceID string) ([]SessionInfo, error) {
+	var (
+		params   = url.Values{}
+		sessions []SessionInfo
+	)
+	if deviceID != "" {
+		params.Add("DeviceId", deviceID)
+	}
+	params.Add("api_key", token)
+	resp, err := utils.GetHTTPClient().Get(client.GetEndpoint() + "/Sessions?" + params.Encode())
+	if err != nil {
+		return nil, err
+	}
+
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("请求 /Sessions 失败，状态码：%d", resp.StatusCode)
+	}
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, err
+	}
+
+	if err = json.Unmarshal(body, &sessions); err != nil {
+		return nil, err
+	}
+	return sessions, nil
+}
+
+// 获取用户信息
+//
+// /Users/{Id}
+func (client *Client) UserServiceGetUser(id string) (*UserDto, error) {
+	params := url.Values{}
+	params.Add("api_key", client.GetAPIKey())
+	resp, err := utils.GetHTTPClient().Get(client.GetEndpoint() + "/Users/" + url.PathEscape(id) + "?" + params.Encode())
+	if err != nil {
+		return nil, err
+	}
+
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("请求 /Users/%s 失败，状态码：%d", id, resp.StatusCode)
+	}
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, err
+	}
+
+	var user UserDto
+	if err = json.Unmarshal(body, &user); err != nil {
+		return nil, err
+	}
+	return &user, nil
+}
+
+// 通知媒体服务器文件发生变化
+//
+// /Library/Media/Updated
+// Emby 会刷新包含这些路径的媒体库目录，比扫描整个媒体库更快
+func (client *Client) LibraryServicePostMediaUpdated(updates []MediaUpdateInfo) error {
+	params := url.Values{}
+	params.Add("api_key", client.GetAPIKey())
+	body, err := json.Marshal(MediaUpdateInfoRequest{Updates: updates})
+	if err != nil {
+		return err
+	}
+	return client.post("/Library/Media/Updated?"+params.Encode(), body)
+}
+
+// 刷新 Item 的元数据
+//
+// /Items/{Id}/Refresh
+func (client *Client) ItemRefreshServiceRefresh(id string, recursive bool) error {
+	params := url.Values{}
+	params.Add("Recursive", strconv.FormatBool(recursive))
+	params.Add("MetadataRefreshMode", "Default")
+	params.Add("ImageRefreshMode", "Default")
+	params.Add("api_key", client.GetAPIKey())
+	return client.post("/Items/"+url.PathEscape(id)+"/Refresh?"+params.Encode(), nil)
+}
+
+// 发送 POST 请求，响应状态码不为 2xx 时返回错误
+func (client *Client) post(api string, body []byte) error {
+	resp, err := utils.GetHTTPClient().Post(client.GetEndpoint()+api, "application/json", bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode < http.StatusOK || resp.StatusCode >= http.StatusMultipleChoices {
+		msg, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+		return fmt.Errorf("请求 %s 失败，状态码：%d，响应：%s", strings.SplitN(api, "?", 2)[0], resp.StatusCode, msg)
+	}
+	return nil
+}
+
 // 获取index.html内容 API：/web/index.html
 func (client *Client) GetIndexHtml() ([]byte, error) {
 	resp, err := utils.GetHTTPClient().Get(client.GetEndpoint() + "/web/index.html")
@@ -559,3 +559,41 @@ const (
 	Keyword  LiveTvTimerType = "Keyword"
 	Program  LiveTvTimerType = "Program"
 )
+
+// 媒体文件变化类型
+type MediaUpdateType string
+
+const (
+	MediaUpdateCreated  MediaUpdateType = "Created"
+	MediaUpdateModified MediaUpdateType = "Modified"
+	MediaUpdateDeleted  MediaUpdateType = "Deleted"
+)
+
+type MediaUpdateInfo struct {
+	Path       string          `json:"Path"`
+	UpdateType MediaUpdateType `json:"UpdateType"`
+}
+
+type MediaUpdateInfoRequest struct {
+	Updates []MediaUpdateInfo `json:"Updates"`
+}
+
+// 会话信息
+//
+// /Sessions 的响应
+type SessionInfo struct {
+	ID         *string `json:"Id,omitempty"`
+	UserID     *string `json:"UserId,omitempty"`
+	UserName   *string `json:"UserName,omitempty"`
+	Client     *string `json:"Client,omitempty"`
+	DeviceID   *string `json:"DeviceId,omitempty"`
+	DeviceName *string `json:"DeviceName,omitempty"`
+}
+
+// 用户信息
+//
+// /Users/{Id} 的响应
+type UserDto struct {
+	ID   *string `json:"Id,omitempty"`
+	Name *string `json:"Name,omitempty"`
+}
@@ -0,0 +1,84 @@
+package emby
+
+import (
+	"encoding/json"
+	"errors"
+	"io"
+	"mime"
+	"net/http"
+	"strings"
+)
+
+// Webhook 事件类型
+type WebhookEventType string
+
+const (
+	WebhookPlaybackStart   WebhookEventType = "playback.start"
+	WebhookPlaybackPause   WebhookEventType = "playback.pause"
+	WebhookPlaybackUnpause WebhookEventType = "playback.unpause"
+	WebhookPlaybackStop    WebhookEventType = "playback.stop"
+	WebhookLibraryNew      WebhookEventType = "library.new"
+	WebhookLibraryDeleted  WebhookEventType = "library.deleted"
+	WebhookItemMarkPlayed  WebhookEventType = "item.markplayed"
+)
+
+// Webhook 通知
+//
+// Emby 服务器 设置 -> 通知 -> Webhooks，请求体格式可为 application/json 或 multipart/form-data
+type WebhookEvent struct {
+	Title        *string              `json:"Title,omitempty"`
+	Description  *string              `json:"Description,omitempty"`
+	Date         *string              `json:"Date,omitempty"`
+	Event        WebhookEventType     `json:"Event"`
+	User         *NameIDPair          `json:"User,omitempty"`
+	Item         *BaseItemDto         `json:"Item,omitempty"`
+	Server       *WebhookServer       `json:"Server,omitempty"`
+	Session      *WebhookSession      `json:"Session,omitempty"`
+	PlaybackInfo *WebhookPlaybackInfo `json:"PlaybackInfo,omitempty"`
+}
+
+type WebhookServer struct {
+	ID      *string `json:"Id,omitempty"`
+	Name    *string `json:"Name,omitempty"`
+	Version *string `json:"Version,omitempty"`
+}
+
+type WebhookSession struct {
+	ID                 *string `json:"Id,omitempty"`
+	Client             *string `json:"Client,omitempty"`
+	DeviceName         *string `json:"DeviceName,omitempty"`
+	DeviceID           *string `json:"DeviceId,omitempty"`
+	ApplicationVersion *string `json:"ApplicationVersion,omitempty"`
+	RemoteEndPoint     *string `json:"RemoteEndPoint,omitempty"`
+}
+
+type WebhookPlaybackInfo struct {
+	PlayedToCompletion *bool   `json:"PlayedToCompletion,omitempty"`
+	PositionTicks      *int64  `json:"PositionTicks,omitempty"`
+	PlaylistIndex      *int64  `json:"PlaylistIndex,omitempty"`
+	PlaylistLength     *int64  `json:"PlaylistLength,omitempty"`
+	MediaSourceID      *string `json:"MediaSourceId,omitempty"`
+}
+
+// 解析 Webhook 请求
+//
+// multipart/form-data 格式时通知内容位于 data 字段
+func ParseWebhook(req *http.Request) (*WebhookEvent, error) {
+	var body io.Reader = req.Body
+	if mediaType, _, _ := mime.ParseMediaType(req.Header.Get("Content-Type")); strings.HasPrefix(mediaType, "multipart/") {
+		data := req.FormValue("data")
+		if data == "" {
+			return nil, errors.New("multipart/form-data 请求缺少 data 字段")
+		}
+		body = strings.NewReader(data)
+	}
+
+	var event WebhookEvent
+	if err := json.NewDecoder(body).Decode(&event); err != nil {
+		return nil, err
+	}
+	if event.Event == "" {
+		return nil, errors.New("缺少 Event 字段")
+	}
+	return &event, nil
+}
@@ -0,0 +1,96 @@
+package jellyfin
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+// Webhook 事件类型
+type WebhookEventType string
+
+const (
+	WebhookPlaybackStart    WebhookEventType = "PlaybackStart"
+	WebhookPlaybackProgress WebhookEventType = "PlaybackProgress"
+	WebhookPlaybackStop     WebhookEventType = "PlaybackStop"
+	WebhookItemAdded        WebhookEventType = "ItemAdded"
+	WebhookItemDeleted      WebhookEventType = "ItemDeleted"
+	WebhookUserDataSaved    WebhookEventType = "UserDataSaved"
+)
+
+// Webhook 通知
+//
+// 由 Jellyfin Webhook 插件的 Generic Destination 发送，字段名与插件模板变量名一致
+// 模板中的数字、布尔值可以带引号也可以不带
+type WebhookEvent struct {
+	NotificationType      WebhookEventType `json:"NotificationType"`
+	ServerID              string           `json:"ServerId"`
+	ServerName            string           `json:"ServerName"`
+	ServerVersion         string           `json:"ServerVersion"`
+	UtcTimestamp          string           `json:"UtcTimestamp"`
+	ItemID                string           `json:"ItemId"`
+	ItemType              string           `json:"ItemType"`
+	Name                  string           `json:"Name"`
+	Year                  WebhookInt       `json:"Year"`
+	SeriesID              string           `json:"SeriesId"`
+	SeriesName            string           `json:"SeriesName"`
+	SeasonID              string           `json:"SeasonId"`
+	SeasonNumber          WebhookInt       `json:"SeasonNumber"`
+	EpisodeNumber         WebhookInt       `json:"EpisodeNumber"`
+	UserID                string           `json:"UserId"`
+	NotificationUsername  string           `json:"NotificationUsername"`
+	DeviceID              string           `json:"DeviceId"`
+	DeviceName            string           `json:"DeviceName"`
+	ClientName            string           `json:"ClientName"`
+	MediaSourceID         string           `json:"MediaSourceId"`
+	PlaybackPositionTicks WebhookInt       `json:"PlaybackPositionTicks"`
+	PlayedToCompletion    WebhookBool      `json:"PlayedToCompletion"`
+}
+
+// 兼容字符串和数字的整数
+type WebhookInt int64
+
+func (i *WebhookInt) UnmarshalJSON(data []byte) error {
+	s := strings.Trim(string(data), `"`)
+	if s == "" || s == "null" {
+		*i = 0
+		return nil
+	}
+	v, err := strconv.ParseInt(s, 10, 64)
+	if err != nil {
+		return err
+	}
+	*i = WebhookInt(v)
+	return nil
+}
+
+// 兼容字符串和布尔值的布尔值
+type WebhookBool bool
+
+func (b *WebhookBool) UnmarshalJSON(data []byte) error {
+	s := strings.Trim(string(data), `"`)
+	if s == "" || s == "null" {
+		*b = false
+		return nil
+	}
+	v, err := strconv.ParseBool(s)
+	if err != nil {
+		return err
+	}
+	*b = WebhookBool(v)
+	return nil
+}
+
+// 解析 Webhook 请求
+func ParseWebhook(req *http.Request) (*WebhookEvent, error) {
+	var event WebhookEvent
+	if err := json.NewDecoder(req.Body).Decode(&event); err != nil {
+		return nil, err
+	}
+	if event.NotificationType == "" {
+		return nil, errors.New("缺少 NotificationType 字段")
+	}
+	return &event, nil
+}
@@ -0,0 +1,92 @@
+package plex
+
+import (
+	"MediaWarp/constants"
+	"MediaWarp/utils"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+)
+
+type Client struct {
+	endpoint string
+	token    string // 认证方式：X-Plex-Token；获取方式：https://support.plex.tv/articles/204059436
+}
+
+// 获取媒体服务器类型
+func (client *Client) GetType() constants.MediaServerType {
+	return constants.PLEX
+}
+
+// 获取 Plex 连接地址
+//
+// 包含协议、服务器域名（IP）、端口号
+// 示例：return "http://plex.example.com:32400"
+func (client *Client) GetEndpoint() string {
+	return client.endpoint
+}
+
+// 获取 Plex 的 Token
+func (client *Client) GetToken() string {
+	return client.token
+}
+
+// 获取媒体元数据
+//
+// /library/metadata/:ratingKey
+func (client *Client) LibraryMetadata(ratingKey string) (*Response, error) {
+	params := url.Values{}
+	params.Add("X-Plex-Token", client.GetToken())
+	req, err := http.NewRequest(http.MethodGet, client.GetEndpoint()+"/library/metadata/"+ratingKey+"?"+params.Encode(), nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Accept", "application/json")
+
+	resp, err := utils.GetHTTPClient().Do(req)
+	if err != nil {
+		return nil, err
+	}
+
+	defer resp.Body.Close()
+	body, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, err
+	}
+
+	var metadataResponse Response
+	if err = json.Unmarshal(body, &metadataResponse); err != nil {
+		return nil, err
+	}
+	return &metadataResponse, nil
+}
+
+// 读取媒体文件内容
+//
+// key: Part 的 key 属性，如 /library/parts/1234/1700000000/file.strm
+// limit: 最多读取的字节数，Strm 文件内容很小，避免误读取视频文件
+func (client *Client) ReadPart(key string, limit int64) ([]byte, error) {
+	params := url.Values{}
+	params.Add("X-Plex-Token", client.GetToken())
+	resp, err := utils.GetHTTPClient().Get(client.GetEndpoint() + key + "?" + params.Encode())
+	if err != nil {
+		return nil, err
+	}
+
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("读取 %s 失败，HTTP 状态码：%d", key, resp.StatusCode)
+	}
+	return io.ReadAll(io.LimitReader(resp.Body, limit))
+}
+
+// 获取 Plex 实例
+func New(addr string, token string) *Client {
+	client := &Client{
+		endpoint: utils.GetEndpoint(addr),
+		token:    token,
+	}
+	return client
+}
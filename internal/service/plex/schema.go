@@ -0,0 +1,41 @@
+package plex
+
+// /library/metadata/:ratingKey 的响应（JSON 格式）
+type Response struct {
+	MediaContainer MediaContainer `json:"MediaContainer"`
+}
+
+type MediaContainer struct {
+	Size                *int64     `json:"size,omitempty"`
+	LibrarySectionTitle *string    `json:"librarySectionTitle,omitempty"` // 媒体库名称
+	Metadata            []Metadata `json:"Metadata,omitempty"`
+}
+
+// 媒体元数据
+type Metadata struct {
+	RatingKey            *string `json:"ratingKey,omitempty"`
+	Key                  *string `json:"key,omitempty"`
+	Type                 *string `json:"type,omitempty"` // movie、episode...
+	Title                *string `json:"title,omitempty"`
+	GrandparentRatingKey *string `json:"grandparentRatingKey,omitempty"`
+	ParentIndex          *int64  `json:"parentIndex,omitempty"`
+	Index                *int64  `json:"index,omitempty"`
+	LibrarySectionTitle  *string `json:"librarySectionTitle,omitempty"` // 媒体库名称
+	Media                []Media `json:"Media,omitempty"`
+}
+
+// 媒体版本
+type Media struct {
+	ID        *int64  `json:"id,omitempty"`
+	Container *string `json:"container,omitempty"`
+	Part      []Part  `json:"Part,omitempty"`
+}
+
+// 媒体文件
+type Part struct {
+	ID        *int64  `json:"id,omitempty"`
+	Key       *string `json:"key,omitempty"`  // /library/parts/1234/1700000000/file.mkv
+	File      *string `json:"file,omitempty"` // 媒体服务器中的文件路径
+	Size      *int64  `json:"size,omitempty"`
+	Container *string `json:"container,omitempty"`
+}
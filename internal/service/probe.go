@@ -0,0 +1,66 @@
+package service
+
+import (
+	"MediaWarp/constants"
+	"MediaWarp/internal/config"
+	"MediaWarp/internal/service/alist"
+	"MediaWarp/utils"
+	"fmt"
+	"net/http"
+	"net/url"
+)
+
+// 检测媒体服务器连通性
+//
+// 使用 server.auth 请求媒体服务器需要认证的接口，同时检查密钥是否有效；飞牛影视不检查密钥
+func ProbeMediaServer(setting config.MediaServerSetting) (string, error) {
+	endpoint := utils.GetEndpoint(setting.ADDR)
+	var (
+		api   string
+		query = make(url.Values)
+	)
+	switch setting.Type {
+	case constants.EMBY:
+		api = endpoint + "/emby/System/Info"
+		query.Set("api_key", setting.AUTH)
+	case constants.JELLYFIN:
+		api = endpoint + "/System/Info"
+		query.Set("api_key", setting.AUTH)
+	case constants.PLEX:
+		api = endpoint + "/library/sections"
+		query.Set("X-Plex-Token", setting.AUTH)
+	default:
+		api = endpoint + "/"
+	}
+
+	u := api
+	if len(query) > 0 {
+		u += "?" + query.Encode()
+	}
+	resp, err := utils.GetHTTPClient().Get(u)
+	if err != nil {
+		if urlErr, ok := err.(*url.Error); ok { // 错误信息中的 URL 带有密钥
+			err = urlErr.Err
+		}
+		return "", fmt.Errorf("请求 %s 失败: %w", api, err)
+	}
+	defer resp.Body.Close()
+	switch {
+	case resp.StatusCode == http.StatusUnauthorized || resp.StatusCode == http.StatusForbidden:
+		return "", fmt.Errorf("请求 %s 认证失败，状态码: %d，请检查 server.auth 是否正确", api, resp.StatusCode)
+	case resp.StatusCode >= http.StatusInternalServerError:
+		return "", fmt.Errorf("请求 %s 失败，状态码: %d", api, resp.StatusCode)
+	}
+	return fmt.Sprintf("%s 响应状态码: %d", api, resp.StatusCode), nil
+}
+
+// 检测 Alist 服务器连通性
+//
+// 登录（如有需要）并获取当前用户信息
+func ProbeAlist(setting config.AlistSetting) (string, error) {
+	client, err := alist.NewAlistClient(setting.ADDR, setting.Username, setting.Password, setting.Token)
+	if err != nil {
+		return "", err
+	}
+	return fmt.Sprintf("%s 当前用户: %s", client.GetEndpoint(), client.GetUserInfo().Username), nil
+}
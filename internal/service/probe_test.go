@@ -0,0 +1,42 @@
+package service_test
+
+import (
+	"MediaWarp/constants"
+	"MediaWarp/internal/config"
+	"MediaWarp/internal/service"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestProbeMediaServer(t *testing.T) {
+	const apiKey = "test-key"
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/emby/System/Info" {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		if r.URL.Query().Get("api_key") != apiKey {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		w.Write([]byte("{}"))
+	}))
+	defer server.Close()
+
+	tests := map[string]struct {
+		auth    string
+		wantErr bool
+	}{
+		"密钥正确": {auth: apiKey},
+		"密钥错误": {auth: "wrong-key", wantErr: true},
+	}
+	for name, tt := range tests {
+		t.Run(name, func(t *testing.T) {
+			_, err := service.ProbeMediaServer(config.MediaServerSetting{Type: constants.EMBY, ADDR: server.URL, AUTH: tt.auth})
+			if (err != nil) != tt.wantErr {
+				t.Errorf("期望错误: %t，实际: %v", tt.wantErr, err)
+			}
+		})
+	}
+}
@@ -0,0 +1,109 @@
+package stream
+
+import (
+	"io"
+	"sort"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+// 单个上游主机的流量计数
+type hostCounter struct {
+	bytes   atomic.Int64 // 已转发的字节数
+	active  atomic.Int64 // 正在进行的串流数
+	streams atomic.Int64 // 累计串流数
+}
+
+type statistics struct {
+	startTime time.Time
+	mutex     sync.RWMutex
+	hosts     map[string]*hostCounter
+}
+
+var stats = statistics{
+	startTime: time.Now(),
+	hosts:     make(map[string]*hostCounter),
+}
+
+func (s *statistics) counter(host string) *hostCounter {
+	s.mutex.RLock()
+	counter, ok := s.hosts[host]
+	s.mutex.RUnlock()
+	if ok {
+		return counter
+	}
+
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	if counter, ok = s.hosts[host]; !ok {
+		counter = new(hostCounter)
+		s.hosts[host] = counter
+	}
+	return counter
+}
+
+func (s *statistics) start(host string) *hostCounter {
+	counter := s.counter(host)
+	counter.active.Add(1)
+	counter.streams.Add(1)
+	return counter
+}
+
+func (s *statistics) finish(host string) {
+	s.counter(host).active.Add(-1)
+}
+
+// 统计转发字节数
+type countingWriter struct {
+	writer  io.Writer
+	counter *hostCounter
+}
+
+func (w *countingWriter) Write(p []byte) (int, error) {
+	n, err := w.writer.Write(p)
+	w.counter.bytes.Add(int64(n))
+	return n, err
+}
+
+// 上游主机流量统计
+type HostStats struct {
+	Host    string `json:"host"`
+	Bytes   int64  `json:"bytes"`
+	Active  int64  `json:"active"`
+	Streams int64  `json:"streams"`
+}
+
+// 串流流量统计
+type Stats struct {
+	Since   time.Time   `json:"since"`
+	Bytes   int64       `json:"bytes"`
+	Active  int64       `json:"active"`
+	Streams int64       `json:"streams"`
+	Hosts   []HostStats `json:"hosts"` // 按字节数从高到低排列
+}
+
+// 获取串流流量统计
+func GetStats() Stats {
+	result := Stats{Since: stats.startTime, Hosts: make([]HostStats, 0)}
+
+	stats.mutex.RLock()
+	for host, counter := range stats.hosts {
+		hostStats := HostStats{
+			Host:    host,
+			Bytes:   counter.bytes.Load(),
+			Active:  counter.active.Load(),
+			Streams: counter.streams.Load(),
+		}
+		result.Bytes += hostStats.Bytes
+		result.Active += hostStats.Active
+		result.Streams += hostStats.Streams
+		result.Hosts = append(result.Hosts, hostStats)
+	}
+	stats.mutex.RUnlock()
+
+	sort.Slice(result.Hosts, func(i, j int) bool {
+		return result.Hosts[i].Bytes > result.Hosts[j].Bytes
+	})
+	return result
+}
@@ -0,0 +1,165 @@
+package stream
+
+import (
+	"MediaWarp/internal/config"
+	"MediaWarp/internal/logging"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/url"
+	"path"
+	"strings"
+	"time"
+)
+
+// 转发给上游的客户端请求头
+var forwardRequestHeaders = []string{
+	"Range",
+	"If-Range",
+	"If-Match",
+	"If-None-Match",
+	"If-Modified-Since",
+	"If-Unmodified-Since",
+}
+
+// 返回给客户端的上游响应头
+var forwardResponseHeaders = []string{
+	"Content-Type",
+	"Content-Length",
+	"Content-Range",
+	"Content-Disposition",
+	"Accept-Ranges",
+	"Last-Modified",
+	"ETag",
+	"Cache-Control",
+	"Expires",
+}
+
+// 请求上下文中保存未应用请求头规则的原始请求头
+type baseHeaderKey struct{}
+
+// 所有串流共享的 HTTP 客户端，复用与上游的连接
+var client = &http.Client{
+	Transport: &http.Transport{
+		Proxy: http.ProxyFromEnvironment,
+		DialContext: (&net.Dialer{
+			Timeout:   10 * time.Second,
+			KeepAlive: 30 * time.Second,
+		}).DialContext,
+		ForceAttemptHTTP2:     true,
+		MaxIdleConns:          100,
+		MaxIdleConnsPerHost:   16,
+		IdleConnTimeout:       90 * time.Second,
+		TLSHandshakeTimeout:   10 * time.Second,
+		ResponseHeaderTimeout: 30 * time.Second,
+		DisableCompression:    true, // 视频数据无需压缩，且需要保持 Content-Length、Content-Range 与实际数据一致
+	},
+	CheckRedirect: func(req *http.Request, via []*http.Request) error {
+		if len(via) >= 10 {
+			return errors.New("超过最大重定向次数限制（10）")
+		}
+		// 重定向请求的请求头复制自上一个请求，其中包含按上一个主机名设置的请求头，需要按新的主机名重新设置
+		if header, ok := req.Context().Value(baseHeaderKey{}).(http.Header); ok {
+			req.Header = header.Clone()
+			applyHeaderRule(req, req.URL.Hostname())
+		}
+		return nil
+	},
+}
+
+// 代理串流
+//
+// 请求 target 并将响应转发给客户端，支持 Range 请求
+// 请求上游失败时向客户端返回 502
+func Proxy(rw http.ResponseWriter, req *http.Request, target string) error {
+	u, err := url.Parse(target)
+	if err != nil {
+		http.Error(rw, "无效的串流地址", http.StatusBadGateway)
+		return fmt.Errorf("解析串流地址失败：%w", err)
+	}
+
+	reqHeader := make(http.Header)
+	for _, key := range forwardRequestHeaders {
+		if value := req.Header.Get(key); value != "" {
+			reqHeader.Set(key, value)
+		}
+	}
+	reqHeader.Set("User-Agent", req.UserAgent())
+
+	ctx := context.WithValue(req.Context(), baseHeaderKey{}, reqHeader)
+	upstreamReq, err := http.NewRequestWithContext(ctx, req.Method, target, nil)
+	if err != nil {
+		http.Error(rw, "创建串流请求失败", http.StatusBadGateway)
+		return fmt.Errorf("创建请求失败：%w", err)
+	}
+	upstreamReq.Header = reqHeader.Clone()
+	applyHeaderRule(upstreamReq, u.Hostname())
+
+	startTime := time.Now()
+	resp, err := client.Do(upstreamReq)
+	if err != nil {
+		http.Error(rw, "请求上游失败", http.StatusBadGateway)
+		return fmt.Errorf("请求上游失败：%w", err)
+	}
+	defer resp.Body.Close()
+
+	header := rw.Header()
+	for _, key := range forwardResponseHeaders {
+		if value := resp.Header.Get(key); value != "" {
+			header.Set(key, value)
+		}
+	}
+	if resp.StatusCode == http.StatusPartialContent && header.Get("Accept-Ranges") == "" {
+		header.Set("Accept-Ranges", "bytes")
+	}
+	rw.WriteHeader(resp.StatusCode)
+
+	host := u.Host
+	counter := stats.start(host)
+	defer stats.finish(host)
+	n, err := io.Copy(&countingWriter{writer: rw, counter: counter}, resp.Body)
+	logging.Debugf(
+		"串流 %s 结束，状态码：%d，Range：%s，传输 %d 字节，耗时 %s",
+		host, resp.StatusCode, req.Header.Get("Range"), n, time.Since(startTime),
+	)
+	if err != nil && req.Context().Err() == nil {
+		return fmt.Errorf("转发数据失败：%w", err)
+	}
+	return nil
+}
+
+// 根据上游主机名设置请求头
+func applyHeaderRule(req *http.Request, host string) {
+	host = strings.ToLower(host)
+	for _, rule := range config.Get().Stream.Headers {
+		if !matchHost(rule.Hosts, host) {
+			continue
+		}
+		if rule.UserAgent != "" {
+			req.Header.Set("User-Agent", rule.UserAgent)
+		}
+		if rule.Referer != "" {
+			req.Header.Set("Referer", rule.Referer)
+		}
+		for key, value := range rule.Headers {
+			req.Header.Set(key, value)
+		}
+		return
+	}
+}
+
+// 主机名是否匹配任一模式，未设置模式时匹配所有主机
+func matchHost(patterns []string, host string) bool {
+	if len(patterns) == 0 {
+		return true
+	}
+	for _, pattern := range patterns {
+		if ok, _ := path.Match(strings.ToLower(pattern), host); ok {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,124 @@
+package stream_test
+
+import (
+	"MediaWarp/internal/config"
+	"MediaWarp/internal/stream"
+	"bytes"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestProxy(t *testing.T) {
+	content := []byte("0123456789abcdefghijklmnopqrstuvwxyz")
+	var gotReferer, gotUA string
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		gotReferer, gotUA = r.Referer(), r.UserAgent()
+		http.ServeContent(w, r, "video.mkv", time.Time{}, bytes.NewReader(content))
+	}))
+	defer upstream.Close()
+
+	config.Set(&config.Setting{Stream: config.StreamSetting{
+		Headers: []config.StreamHeaderSetting{
+			{Hosts: []string{"example.com"}, Referer: "https://example.com/"},
+			{Hosts: []string{"127.0.0.*"}, Referer: "https://mediawarp/"},
+		},
+	}})
+
+	tests := map[string]struct {
+		rangeHeader  string
+		status       int
+		body         string
+		contentRange string
+	}{
+		"完整请求":     {"", http.StatusOK, string(content), ""},
+		"Range 请求": {"bytes=10-15", http.StatusPartialContent, "abcdef", "bytes 10-15/36"},
+		"后缀 Range": {"bytes=-3", http.StatusPartialContent, "xyz", "bytes 33-35/36"},
+		"无效 Range": {"bytes=100-", http.StatusRequestedRangeNotSatisfiable, "", "bytes */36"},
+	}
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			req := httptest.NewRequest(http.MethodGet, "/videos/1/stream", nil)
+			req.Header.Set("User-Agent", "TestPlayer/1.0")
+			if test.rangeHeader != "" {
+				req.Header.Set("Range", test.rangeHeader)
+			}
+			rec := httptest.NewRecorder()
+			if err := stream.Proxy(rec, req, upstream.URL+"/video.mkv"); err != nil {
+				t.Fatal(err)
+			}
+
+			if rec.Code != test.status {
+				t.Errorf("状态码错误。期望: %d，实际: %d", test.status, rec.Code)
+			}
+			if rec.Code != http.StatusRequestedRangeNotSatisfiable && rec.Body.String() != test.body {
+				t.Errorf("响应内容错误。期望: %q，实际: %q", test.body, rec.Body.String())
+			}
+			if got := rec.Header().Get("Content-Range"); got != test.contentRange {
+				t.Errorf("Content-Range 错误。期望: %q，实际: %q", test.contentRange, got)
+			}
+			if got := rec.Header().Get("Accept-Ranges"); test.status != http.StatusRequestedRangeNotSatisfiable && got != "bytes" {
+				t.Errorf("Accept-Ranges 错误。期望: bytes，实际: %q", got)
+			}
+			if gotReferer != "https://mediawarp/" || gotUA != "TestPlayer/1.0" {
+				t.Errorf("请求头错误。Referer: %q，User-Agent: %q", gotReferer, gotUA)
+			}
+		})
+	}
+
+	stats := stream.GetStats()
+	if stats.Streams < int64(len(tests)) || stats.Active != 0 {
+		t.Errorf("流量统计错误：%+v", stats)
+	}
+}
+
+func TestProxyUpstreamError(t *testing.T) {
+	req := httptest.NewRequest(http.MethodGet, "/videos/1/stream", nil)
+	rec := httptest.NewRecorder()
+	if err := stream.Proxy(rec, req, "http://127.0.0.1:1/video.mkv"); err == nil {
+		t.Error("期望返回错误")
+	}
+	if rec.Code != http.StatusBadGateway {
+		t.Errorf("状态码错误。期望: %d，实际: %d", http.StatusBadGateway, rec.Code)
+	}
+	if body, _ := io.ReadAll(rec.Body); !strings.Contains(string(body), "请求上游失败") {
+		t.Errorf("响应内容错误：%s", body)
+	}
+}
+
+func TestProxyRedirectHeaderRule(t *testing.T) {
+	var gotReferer, gotToken, gotUA string
+	target := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		gotReferer, gotToken, gotUA = r.Referer(), r.Header.Get("X-Token"), r.UserAgent()
+		w.Write([]byte("ok"))
+	}))
+	defer target.Close()
+	targetURL := strings.Replace(target.URL, "127.0.0.1", "localhost", 1) // 使用不同的主机名区分重定向前后的请求
+	redirector := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Redirect(w, r, targetURL+"/video.mkv", http.StatusFound)
+	}))
+	defer redirector.Close()
+
+	config.Set(&config.Setting{Stream: config.StreamSetting{
+		Headers: []config.StreamHeaderSetting{
+			{Hosts: []string{"127.0.0.1"}, Referer: "https://redirector/", Headers: map[string]string{"X-Token": "secret"}},
+			{Hosts: []string{"localhost"}, Referer: "https://target/"},
+		},
+	}})
+
+	req := httptest.NewRequest(http.MethodGet, "/videos/1/stream", nil)
+	req.Header.Set("User-Agent", "TestPlayer/1.0")
+	rec := httptest.NewRecorder()
+	if err := stream.Proxy(rec, req, redirector.URL+"/video.mkv"); err != nil {
+		t.Fatal(err)
+	}
+	if rec.Body.String() != "ok" {
+		t.Fatalf("响应内容错误：%q", rec.Body.String())
+	}
+	if gotReferer != "https://target/" || gotToken != "" || gotUA != "TestPlayer/1.0" {
+		t.Errorf("重定向后请求头错误。Referer: %q，X-Token: %q，User-Agent: %q", gotReferer, gotToken, gotUA)
+	}
+}
@@ -0,0 +1,48 @@
+package strm
+
+import (
+	"MediaWarp/constants"
+	"MediaWarp/internal/config"
+	"MediaWarp/internal/logging"
+	"MediaWarp/internal/pathmap"
+	"MediaWarp/utils"
+)
+
+const mountRuleName = "mount" // 网盘挂载文件重定向的规则名称
+
+// 匹配网盘挂载文件
+//
+// 路径符合 mount.prefix_list 且能通过路径映射找到对应的 Alist 时，按 AlistStrm 处理
+// 返回结果中的 Strm 内容即为 path 本身，由路径映射转换为 Alist 路径
+func MatchMount(path string) Result {
+	cfg := config.Get()
+	if !cfg.Mount.Enable || !cfg.AlistStrm.Enable {
+		return Result{Type: constants.UnknownStrm}
+	}
+
+	matched := false
+	for _, prefix := range cfg.Mount.PrefixList {
+		if utils.HasPathPrefix(path, prefix) {
+			matched = true
+			break
+		}
+	}
+	if !matched {
+		return Result{Type: constants.UnknownStrm}
+	}
+
+	addr, alistPath, ok := pathmap.Resolve(path)
+	if !ok {
+		logging.Debugf("%s 符合挂载目录前缀，但未找到对应的 Alist 路径映射", path)
+		return Result{Type: constants.UnknownStrm}
+	}
+	logging.Debugf("%s 为网盘挂载文件，对应 Alist：%s，路径：%s", path, addr, alistPath)
+	return Result{
+		Type:      constants.AlistStrm,
+		Rule:      mountRuleName,
+		AlistAddr: addr,
+		Proxy:     cfg.Mount.Proxy,
+		RawURL:    cfg.Mount.RawURL,
+		Stream:    cfg.Mount.Stream,
+	}
+}
@@ -0,0 +1,200 @@
+package strm
+
+import (
+	"MediaWarp/constants"
+	"MediaWarp/internal/config"
+	"MediaWarp/utils"
+	"fmt"
+	"net/url"
+	"path"
+	"regexp"
+	"strings"
+)
+
+// 匹配输入
+type Input struct {
+	Path      string        // Strm 文件路径
+	Content   string        // Strm 文件内容，HTTPStrm 为 URL，AlistStrm 为 Alist 中的路径
+	UserAgent string        // 客户端 User-Agent
+	Library   func() string // 获取媒体库名称，仅在规则包含媒体库条件时调用，可为 nil
+}
+
+// 匹配结果
+type Result struct {
+	Type      constants.StrmFileType // Strm 类型
+	Rule      string                 // 命中的规则名称
+	AlistAddr string                 // AlistStrm 使用的 Alist 地址
+	Proxy     bool                   // 是否支持媒体服务器串流、转码播放
+	RawURL    bool                   // AlistStrm 是否使用原始 URL
+	FinalURL  bool                   // HTTPStrm 是否获取最终 URL
+	Stream    bool                   // 是否由 MediaWarp 代理串流
+}
+
+// 编译后的匹配规则
+type Rule struct {
+	name      string
+	result    Result
+	prefix    string
+	glob      *regexp.Regexp
+	regex     *regexp.Regexp
+	hosts     []string
+	schemes   []string
+	userAgent *regexp.Regexp
+	libraries []string
+}
+
+// 编译匹配规则
+//
+// 未设置的选项使用 http_strm、alist_strm 中的全局设置
+func NewRule(cfg *config.Setting, setting config.StrmRuleSetting) (*Rule, error) {
+	rule := Rule{
+		name:      setting.Name,
+		prefix:    setting.Prefix,
+		hosts:     setting.Hosts,
+		libraries: setting.Libraries,
+		result:    Result{Type: setting.Type, Rule: setting.Name},
+	}
+	for _, scheme := range setting.Schemes {
+		rule.schemes = append(rule.schemes, strings.ToLower(scheme))
+	}
+
+	switch setting.Type {
+	case constants.HTTPStrm:
+		rule.result.Proxy = boolOr(setting.Proxy, cfg.HTTPStrm.Proxy)
+		rule.result.FinalURL = boolOr(setting.FinalURL, cfg.HTTPStrm.FinalURL)
+		rule.result.Stream = boolOr(setting.Stream, cfg.HTTPStrm.Stream)
+	case constants.AlistStrm:
+		if setting.Alist == "" {
+			return nil, fmt.Errorf("规则 %s 未设置 alist", setting.Name)
+		}
+		rule.result.AlistAddr = findAlistAddr(cfg, setting.Alist)
+		if rule.result.AlistAddr == "" {
+			return nil, fmt.Errorf("规则 %s 使用的 Alist %s 未在 alist_strm.list 中配置", setting.Name, setting.Alist)
+		}
+		rule.result.Proxy = boolOr(setting.Proxy, cfg.AlistStrm.Proxy)
+		rule.result.RawURL = boolOr(setting.RawURL, cfg.AlistStrm.RawURL)
+		rule.result.Stream = boolOr(setting.Stream, cfg.AlistStrm.Stream)
+	default:
+		return nil, fmt.Errorf("规则 %s 的类型无效", setting.Name)
+	}
+
+	var err error
+	if setting.Glob != "" {
+		if rule.glob, err = compileGlob(setting.Glob); err != nil {
+			return nil, fmt.Errorf("规则 %s 的 glob 无效: %w", setting.Name, err)
+		}
+	}
+	if setting.Regex != "" {
+		if rule.regex, err = regexp.Compile(setting.Regex); err != nil {
+			return nil, fmt.Errorf("规则 %s 的 regex 无效: %w", setting.Name, err)
+		}
+	}
+	if setting.UserAgent != "" {
+		if rule.userAgent, err = regexp.Compile(setting.UserAgent); err != nil {
+			return nil, fmt.Errorf("规则 %s 的 user_agent 无效: %w", setting.Name, err)
+		}
+	}
+	for _, host := range setting.Hosts {
+		if _, err = path.Match(host, ""); err != nil {
+			return nil, fmt.Errorf("规则 %s 的 hosts 无效: %w", setting.Name, err)
+		}
+	}
+	return &rule, nil
+}
+
+// 判断输入是否满足规则的全部条件
+func (rule *Rule) Match(input *Input) bool {
+	if rule.prefix != "" && !utils.HasPathPrefix(input.Path, rule.prefix) {
+		return false
+	}
+	if rule.glob != nil && !rule.glob.MatchString(input.Path) {
+		return false
+	}
+	if rule.regex != nil && !rule.regex.MatchString(input.Path) {
+		return false
+	}
+	if rule.userAgent != nil && !rule.userAgent.MatchString(input.UserAgent) {
+		return false
+	}
+	if len(rule.hosts) > 0 || len(rule.schemes) > 0 {
+		u, err := url.Parse(strings.TrimSpace(input.Content))
+		if err != nil {
+			return false
+		}
+		if len(rule.schemes) > 0 && !contains(rule.schemes, strings.ToLower(u.Scheme)) {
+			return false
+		}
+		if len(rule.hosts) > 0 && !matchHost(rule.hosts, u.Hostname()) {
+			return false
+		}
+	}
+	if len(rule.libraries) > 0 {
+		if input.Library == nil || !contains(rule.libraries, input.Library()) {
+			return false
+		}
+	}
+	return true
+}
+
+// 主机名是否匹配任一模式
+func matchHost(patterns []string, host string) bool {
+	host = strings.ToLower(host)
+	for _, pattern := range patterns {
+		if ok, _ := path.Match(strings.ToLower(pattern), host); ok {
+			return true
+		}
+	}
+	return false
+}
+
+func contains(list []string, s string) bool {
+	for _, item := range list {
+		if item == s {
+			return true
+		}
+	}
+	return false
+}
+
+func boolOr(value *bool, fallback bool) bool {
+	if value != nil {
+		return *value
+	}
+	return fallback
+}
+
+// 在 alist_strm.list 中查找 Alist 地址
+func findAlistAddr(cfg *config.Setting, addr string) string {
+	endpoint := utils.GetEndpoint(addr)
+	for _, alist := range cfg.AlistStrm.List {
+		if utils.GetEndpoint(alist.ADDR) == endpoint {
+			return alist.ADDR
+		}
+	}
+	return ""
+}
+
+// 将通配符转换为正则表达式
+//
+// ** 匹配任意字符（包括 /），* 匹配除 / 以外的任意字符，? 匹配除 / 以外的单个字符
+func compileGlob(glob string) (*regexp.Regexp, error) {
+	var builder strings.Builder
+	builder.WriteString("^")
+	for i := 0; i < len(glob); i++ {
+		switch c := glob[i]; c {
+		case '*':
+			if i+1 < len(glob) && glob[i+1] == '*' {
+				builder.WriteString(".*")
+				i++
+			} else {
+				builder.WriteString("[^/]*")
+			}
+		case '?':
+			builder.WriteString("[^/]")
+		default:
+			builder.WriteString(regexp.QuoteMeta(string(c)))
+		}
+	}
+	builder.WriteString("$")
+	return regexp.Compile(builder.String())
+}
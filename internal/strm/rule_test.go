@@ -0,0 +1,158 @@
+package strm_test
+
+import (
+	"MediaWarp/constants"
+	"MediaWarp/internal/config"
+	"MediaWarp/internal/pathmap"
+	"MediaWarp/internal/strm"
+	"testing"
+)
+
+func boolPtr(b bool) *bool {
+	return &b
+}
+
+func TestMatch(t *testing.T) {
+	config.Set(&config.Setting{
+		HTTPStrm: config.HTTPStrmSetting{
+			Enable:     true,
+			FinalURL:   true,
+			PrefixList: []string{"/media/http"},
+		},
+		AlistStrm: config.AlistStrmSetting{
+			Enable: true,
+			Proxy:  true,
+			List: []config.AlistSetting{
+				{ADDR: "http://alist:5244", PrefixList: []string{"/media"}},
+			},
+		},
+		StrmRules: []config.StrmRuleSetting{
+			{
+				Name:      "infuse",
+				Type:      constants.HTTPStrm,
+				Prefix:    "/media/http",
+				UserAgent: "(?i)infuse",
+				FinalURL:  boolPtr(false),
+			},
+			{
+				Name:  "anime",
+				Type:  constants.AlistStrm,
+				Glob:  "/media/**/anime/*.strm",
+				Alist: "http://alist:5244/",
+				Proxy: boolPtr(false),
+			},
+			{
+				Name:    "cdn",
+				Type:    constants.HTTPStrm,
+				Regex:   `\.strm$`,
+				Hosts:   []string{"*.example.com"},
+				Schemes: []string{"https"},
+			},
+			{
+				Name:      "kids",
+				Type:      constants.HTTPStrm,
+				Libraries: []string{"儿童"},
+			},
+		},
+	})
+	if err := strm.Init(); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := map[string]struct {
+		input strm.Input
+		want  strm.Result
+	}{
+		"User-Agent 条件": {
+			input: strm.Input{Path: "/media/http/a.strm", UserAgent: "Infuse/7.0"},
+			want:  strm.Result{Type: constants.HTTPStrm, Rule: "infuse", FinalURL: false},
+		},
+		"前缀列表转换的规则": {
+			input: strm.Input{Path: "/media/http/a.strm", UserAgent: "Emby"},
+			want:  strm.Result{Type: constants.HTTPStrm, Rule: "http_strm.prefix_list:/media/http", FinalURL: true},
+		},
+		"前缀不在路径边界": {
+			input: strm.Input{Path: "/media/https/a.strm", UserAgent: "Infuse/7.0"},
+			want:  strm.Result{Type: constants.AlistStrm, Rule: "alist_strm.prefix_list:/media", AlistAddr: "http://alist:5244", Proxy: true},
+		},
+		"通配符": {
+			input: strm.Input{Path: "/media/tv/anime/a.strm"},
+			want:  strm.Result{Type: constants.AlistStrm, Rule: "anime", AlistAddr: "http://alist:5244", Proxy: false},
+		},
+		"主机名和协议条件": {
+			input: strm.Input{Path: "/other/a.strm", Content: "https://cdn.example.com/a.mkv"},
+			want:  strm.Result{Type: constants.HTTPStrm, Rule: "cdn", FinalURL: true},
+		},
+		"协议不匹配": {
+			input: strm.Input{Path: "/other/a.strm", Content: "http://cdn.example.com/a.mkv"},
+			want:  strm.Result{Type: constants.UnknownStrm},
+		},
+		"媒体库条件": {
+			input: strm.Input{Path: "/other/a.strm", Library: func() string { return "儿童" }},
+			want:  strm.Result{Type: constants.HTTPStrm, Rule: "kids", FinalURL: true},
+		},
+		"未匹配": {
+			input: strm.Input{Path: "/other/a.strm"},
+			want:  strm.Result{Type: constants.UnknownStrm},
+		},
+	}
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			if got := strm.Match(&test.input); got != test.want {
+				t.Errorf("匹配结果错误。期望: %+v，实际: %+v", test.want, got)
+			}
+		})
+	}
+}
+
+func TestInitInvalidRule(t *testing.T) {
+	config.Set(&config.Setting{
+		AlistStrm: config.AlistStrmSetting{Enable: true},
+		StrmRules: []config.StrmRuleSetting{
+			{Name: "missing", Type: constants.AlistStrm, Alist: "http://unknown:5244"},
+		},
+	})
+	if err := strm.Init(); err == nil {
+		t.Errorf("期望 Alist 未配置时返回错误")
+	}
+}
+
+func TestMatchMount(t *testing.T) {
+	config.Set(&config.Setting{
+		AlistStrm: config.AlistStrmSetting{
+			Enable: true,
+			List: []config.AlistSetting{
+				{ADDR: "http://alist:5244", PathMapping: []config.PathMappingSetting{{Prefix: "/mnt/cd2", Replace: "/115"}}},
+			},
+		},
+		Mount: config.MountSetting{Enable: true, RawURL: true, PrefixList: []string{"/mnt"}},
+	})
+	if err := pathmap.Init(); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := map[string]struct {
+		path string
+		want strm.Result
+	}{
+		"挂载文件": {
+			path: "/mnt/cd2/Movies/x.mkv",
+			want: strm.Result{Type: constants.AlistStrm, Rule: "mount", AlistAddr: "http://alist:5244", RawURL: true},
+		},
+		"符合前缀但没有路径映射": {
+			path: "/mnt/local/x.mkv",
+			want: strm.Result{Type: constants.UnknownStrm},
+		},
+		"不符合前缀": {
+			path: "/media/x.mkv",
+			want: strm.Result{Type: constants.UnknownStrm},
+		},
+	}
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			if got := strm.MatchMount(test.path); got != test.want {
+				t.Errorf("匹配结果错误。期望: %+v，实际: %+v", test.want, got)
+			}
+		})
+	}
+}
@@ -0,0 +1,122 @@
+package strm
+
+import (
+	"MediaWarp/constants"
+	"MediaWarp/internal/config"
+	"MediaWarp/internal/logging"
+	"fmt"
+	"sync"
+)
+
+var (
+	rules      []*Rule
+	rulesMutex sync.RWMutex
+)
+
+// 初始化 Strm 匹配规则
+func Init() error {
+	publish, err := Prepare(config.Get())
+	if err != nil {
+		return err
+	}
+	publish()
+	return nil
+}
+
+// 根据配置编译 Strm 匹配规则，返回的函数用于替换当前使用的规则
+//
+// 依次为 strm_rules 中的规则、http_strm.prefix_list 和 alist_strm.list[].prefix_list 转换的规则
+// 对应类型未启用时跳过该类型的规则
+func Prepare(cfg *config.Setting) (func(), error) {
+	var compiled []*Rule
+	for i, setting := range cfg.StrmRules {
+		if setting.Name == "" {
+			setting.Name = fmt.Sprintf("strm_rules[%d]", i)
+		}
+		if !typeEnabled(cfg, setting.Type) {
+			logging.Debugf("Strm 规则 %s 的类型 %s 未启用，跳过", setting.Name, setting.Type)
+			continue
+		}
+		rule, err := NewRule(cfg, setting)
+		if err != nil {
+			return nil, err
+		}
+		compiled = append(compiled, rule)
+	}
+	legacy, err := legacyRules(cfg)
+	if err != nil {
+		return nil, err
+	}
+	compiled = append(compiled, legacy...)
+
+	return func() {
+		rulesMutex.Lock()
+		rules = compiled
+		rulesMutex.Unlock()
+	}, nil
+}
+
+// 将前缀列表转换为匹配规则
+func legacyRules(cfg *config.Setting) ([]*Rule, error) {
+	var settings []config.StrmRuleSetting
+	if cfg.HTTPStrm.Enable {
+		for _, prefix := range cfg.HTTPStrm.PrefixList {
+			settings = append(settings, config.StrmRuleSetting{
+				Name:   "http_strm.prefix_list:" + prefix,
+				Type:   constants.HTTPStrm,
+				Prefix: prefix,
+			})
+		}
+	}
+	if cfg.AlistStrm.Enable {
+		for _, alist := range cfg.AlistStrm.List {
+			for _, prefix := range alist.PrefixList {
+				settings = append(settings, config.StrmRuleSetting{
+					Name:   "alist_strm.prefix_list:" + prefix,
+					Type:   constants.AlistStrm,
+					Prefix: prefix,
+					Alist:  alist.ADDR,
+				})
+			}
+		}
+	}
+
+	compiled := make([]*Rule, 0, len(settings))
+	for _, setting := range settings {
+		rule, err := NewRule(cfg, setting)
+		if err != nil {
+			return nil, err
+		}
+		compiled = append(compiled, rule)
+	}
+	return compiled, nil
+}
+
+func typeEnabled(cfg *config.Setting, t constants.StrmFileType) bool {
+	switch t {
+	case constants.HTTPStrm:
+		return cfg.HTTPStrm.Enable
+	case constants.AlistStrm:
+		return cfg.AlistStrm.Enable
+	default:
+		return true // 交由 NewRule 报错
+	}
+}
+
+// 根据匹配规则识别 Strm 文件类型
+//
+// 未匹配任何规则时返回的 Type 为 constants.UnknownStrm
+func Match(input *Input) Result {
+	rulesMutex.RLock()
+	current := rules
+	rulesMutex.RUnlock()
+
+	for _, rule := range current {
+		if rule.Match(input) {
+			logging.Debugf("%s 成功匹配规则：%s，Strm 类型：%s", input.Path, rule.name, rule.result.Type)
+			return rule.result
+		}
+	}
+	logging.Debugf("%s 未匹配任何规则，Strm 类型：%s", input.Path, constants.UnknownStrm)
+	return Result{Type: constants.UnknownStrm}
+}
@@ -0,0 +1,159 @@
+package strmgen
+
+import (
+	"MediaWarp/internal/config"
+	"MediaWarp/internal/logging"
+	"MediaWarp/internal/refresh"
+	"errors"
+	"fmt"
+	"sync"
+	"time"
+)
+
+var (
+	ErrTaskNotFound = errors.New("Strm 生成任务不存在")
+	ErrTaskRunning  = errors.New("Strm 生成任务正在运行")
+)
+
+var (
+	scheduleStop  chan struct{}
+	scheduleMutex sync.Mutex
+
+	results      = make(map[string]*Result) // 任务名称 -> 最近一次同步结果
+	resultsMutex sync.RWMutex
+)
+
+// 初始化 Strm 文件生成
+//
+// 重新加载配置时会再次调用，重新启动定时同步
+func Init() {
+	cfg := config.Get()
+	scheduleMutex.Lock()
+	defer scheduleMutex.Unlock()
+	if scheduleStop != nil {
+		close(scheduleStop)
+		scheduleStop = nil
+	}
+	if !cfg.StrmGenerate.Enable || cfg.StrmGenerate.Interval <= 0 {
+		return
+	}
+
+	scheduleStop = make(chan struct{})
+	go func(interval time.Duration, stop chan struct{}) {
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+		for {
+			select {
+			case <-stop:
+				return
+			case <-ticker.C:
+				for _, task := range tasks() {
+					if err := runTask(task); err != nil {
+						logging.Warningf("定时同步 Strm 生成任务 %s 失败：%s", task.Name, err)
+					}
+				}
+			}
+		}
+	}(cfg.StrmGenerate.Interval, scheduleStop)
+	logging.Infof("Strm 生成任务每 %s 同步一次", cfg.StrmGenerate.Interval)
+}
+
+// 当前配置中的所有任务
+//
+// 未设置名称的任务使用 tasks[i] 作为名称
+func tasks() []config.StrmGenerateTaskSetting {
+	cfg := config.Get()
+	list := make([]config.StrmGenerateTaskSetting, len(cfg.StrmGenerate.Tasks))
+	for i, task := range cfg.StrmGenerate.Tasks {
+		if task.Name == "" {
+			task.Name = fmt.Sprintf("tasks[%d]", i)
+		}
+		list[i] = task
+	}
+	return list
+}
+
+// 在后台运行 Strm 生成任务
+//
+// name 为空时运行所有任务，任务正在运行时返回 ErrTaskRunning
+func Run(name string) error {
+	var selected []config.StrmGenerateTaskSetting
+	for _, task := range tasks() {
+		if name == "" || task.Name == name {
+			selected = append(selected, task)
+		}
+	}
+	if len(selected) == 0 {
+		return ErrTaskNotFound
+	}
+
+	var errs []error
+	for _, task := range selected {
+		result, err := start(task.Name)
+		if err != nil {
+			errs = append(errs, fmt.Errorf("%s: %w", task.Name, err))
+			continue
+		}
+		go execute(task, result)
+	}
+	return errors.Join(errs...)
+}
+
+// 运行 Strm 生成任务并等待完成
+func runTask(task config.StrmGenerateTaskSetting) error {
+	result, err := start(task.Name)
+	if err != nil {
+		return err
+	}
+	execute(task, result)
+	return nil
+}
+
+// 标记任务开始运行
+func start(name string) (*Result, error) {
+	resultsMutex.Lock()
+	defer resultsMutex.Unlock()
+	if last, ok := results[name]; ok && last.Running {
+		return nil, ErrTaskRunning
+	}
+	result := &Result{Task: name, Running: true, StartTime: time.Now()}
+	results[name] = result
+	return result, nil
+}
+
+func execute(task config.StrmGenerateTaskSetting, result *Result) {
+	logging.Infof("开始同步 Strm 生成任务 %s：%s => %s", task.Name, task.Source, task.Output)
+
+	// 同步过程中只修改 local，完成后再写回，避免读取状态时加锁等待
+	local := *result
+	s := newSyncer(task, &local)
+	s.run()
+	local.Running = false
+	local.EndTime = time.Now()
+
+	resultsMutex.Lock()
+	*result = local
+	resultsMutex.Unlock()
+	logging.Infof(
+		"Strm 生成任务 %s 同步完成，新建：%d，更新：%d，未变化：%d，附属文件：%d，删除：%d，错误：%d，耗时：%s",
+		task.Name, local.Created, local.Updated, local.Skipped, local.Sidecars, local.Deleted, len(local.Errors), local.EndTime.Sub(local.StartTime),
+	)
+
+	// 通知媒体服务器刷新发生变化的 Strm 文件
+	refresh.Notify(s.changes...)
+}
+
+// 获取所有任务最近一次的同步结果
+func Status() []Result {
+	resultsMutex.RLock()
+	defer resultsMutex.RUnlock()
+	status := make([]Result, 0, len(results))
+	for _, task := range tasks() {
+		if result, ok := results[task.Name]; ok {
+			status = append(status, *result)
+		} else {
+			status = append(status, Result{Task: task.Name})
+		}
+	}
+	return status
+}
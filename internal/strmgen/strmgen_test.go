@@ -0,0 +1,155 @@
+package strmgen_test
+
+import (
+	"MediaWarp/constants"
+	"MediaWarp/internal/config"
+	"MediaWarp/internal/service"
+	"MediaWarp/internal/strmgen"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+// 模拟 Alist 服务器
+//
+// 目录结构由 tree 指定，/d/ 返回的内容为 "sidecar:" + 请求路径
+func newTestServer(t *testing.T, tree map[string][]map[string]any) *httptest.Server {
+	t.Helper()
+	reply := func(w http.ResponseWriter, data any) {
+		json.NewEncoder(w).Encode(map[string]any{"code": 200, "message": "success", "data": data})
+	}
+	mux := http.NewServeMux()
+	mux.HandleFunc("/api/auth/login", func(w http.ResponseWriter, r *http.Request) {
+		reply(w, map[string]any{"token": "token"})
+	})
+	mux.HandleFunc("/api/me", func(w http.ResponseWriter, r *http.Request) {
+		reply(w, map[string]any{"username": "guest", "base_path": "/"})
+	})
+	mux.HandleFunc("/api/fs/list", func(w http.ResponseWriter, r *http.Request) {
+		var req struct {
+			Path string `json:"path"`
+		}
+		json.NewDecoder(r.Body).Decode(&req)
+		content := tree[req.Path]
+		reply(w, map[string]any{"content": content, "total": len(content)})
+	})
+	mux.HandleFunc("/d/", func(w http.ResponseWriter, r *http.Request) {
+		w.Write([]byte("sidecar:" + r.URL.Path))
+	})
+	server := httptest.NewServer(mux)
+	t.Cleanup(server.Close)
+	return server
+}
+
+// 运行任务并等待完成
+func run(t *testing.T, name string) strmgen.Result {
+	t.Helper()
+	if err := strmgen.Run(name); err != nil {
+		t.Fatal(err)
+	}
+	for range 100 {
+		for _, result := range strmgen.Status() {
+			if result.Task == name && !result.Running {
+				return result
+			}
+		}
+		time.Sleep(20 * time.Millisecond)
+	}
+	t.Fatal("等待任务完成超时")
+	return strmgen.Result{}
+}
+
+func TestGenerate(t *testing.T) {
+	tree := map[string][]map[string]any{
+		"/media": {
+			{"name": "电影", "is_dir": true},
+			{"name": "readme.txt", "size": 1},
+		},
+		"/media/电影": {
+			{"name": "阿凡达 (2009).mkv", "size": 100, "sign": "abc"},
+			{"name": "阿凡达 (2009).zh.srt", "size": len("sidecar:/d/media/电影/阿凡达 (2009).zh.srt")},
+			{"name": "阿凡达 (2009).nfo", "size": len("sidecar:/d/media/电影/阿凡达 (2009).nfo")},
+		},
+	}
+	server := newTestServer(t, tree)
+	output := t.TempDir()
+
+	config.Set(&config.Setting{
+		AlistStrm: config.AlistStrmSetting{Enable: true, List: []config.AlistSetting{{ADDR: server.URL}}},
+		StrmGenerate: config.StrmGenerateSetting{
+			Enable: true,
+			Tasks: []config.StrmGenerateTaskSetting{
+				{Name: "alist", Alist: server.URL, Source: "/media", Output: filepath.Join(output, "alist"), Type: constants.AlistStrm, DeleteOrphans: true},
+				{Name: "http", Alist: server.URL, Source: "/media", Output: filepath.Join(output, "http"), Type: constants.HTTPStrm},
+			},
+		},
+	})
+	service.InitAlistClient()
+
+	read := func(t *testing.T, p string) string {
+		data, err := os.ReadFile(p)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return string(data)
+	}
+
+	t.Run("首次同步", func(t *testing.T) {
+		result := run(t, "alist")
+		if result.Created != 1 || result.Sidecars != 2 || len(result.Errors) != 0 {
+			t.Errorf("同步结果错误：%+v", result)
+		}
+		if got := read(t, filepath.Join(output, "alist", "电影", "阿凡达 (2009).strm")); got != "/media/电影/阿凡达 (2009).mkv" {
+			t.Errorf("Strm 内容错误：%s", got)
+		}
+		if got := read(t, filepath.Join(output, "alist", "电影", "阿凡达 (2009).zh.srt")); got != "sidecar:/d/media/电影/阿凡达 (2009).zh.srt" {
+			t.Errorf("附属文件内容错误：%s", got)
+		}
+	})
+
+	t.Run("HTTPStrm", func(t *testing.T) {
+		result := run(t, "http")
+		if result.Created != 1 {
+			t.Errorf("同步结果错误：%+v", result)
+		}
+		want := server.URL + "/d/media/%E7%94%B5%E5%BD%B1/%E9%98%BF%E5%87%A1%E8%BE%BE%20%282009%29.mkv?sign=abc"
+		if got := read(t, filepath.Join(output, "http", "电影", "阿凡达 (2009).strm")); got != want {
+			t.Errorf("Strm 内容错误。期望: %s，实际: %s", want, got)
+		}
+	})
+
+	t.Run("增量同步", func(t *testing.T) {
+		result := run(t, "alist")
+		if result.Created != 0 || result.Updated != 0 || result.Skipped != 1 || result.Sidecars != 0 {
+			t.Errorf("同步结果错误：%+v", result)
+		}
+	})
+
+	t.Run("删除 Strm 文件", func(t *testing.T) {
+		manual := filepath.Join(output, "alist", "手动添加.strm")
+		if err := os.WriteFile(manual, []byte("/media/手动添加.mkv"), 0644); err != nil {
+			t.Fatal(err)
+		}
+		tree["/media/电影"] = tree["/media/电影"][1:] // 源目录中删除视频文件
+		result := run(t, "alist")
+		if result.Deleted != 1 {
+			t.Errorf("同步结果错误：%+v", result)
+		}
+		if _, err := os.Stat(filepath.Join(output, "alist", "电影", "阿凡达 (2009).strm")); !os.IsNotExist(err) {
+			t.Error("Strm 文件未被删除")
+		}
+		if _, err := os.Stat(manual); err != nil {
+			t.Errorf("不应删除非本任务生成的 Strm 文件：%v", err)
+		}
+	})
+
+	t.Run("任务不存在", func(t *testing.T) {
+		if err := strmgen.Run("unknown"); err != strmgen.ErrTaskNotFound {
+			t.Errorf("期望 ErrTaskNotFound，实际: %v", err)
+		}
+	})
+}
@@ -0,0 +1,304 @@
+package strmgen
+
+import (
+	"MediaWarp/constants"
+	"MediaWarp/internal/config"
+	"MediaWarp/internal/logging"
+	"MediaWarp/internal/refresh"
+	"MediaWarp/internal/service"
+	"MediaWarp/internal/service/alist"
+	"MediaWarp/utils"
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"net/http"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+)
+
+const (
+	maxErrors    = 50                     // 同步结果中最多记录的错误数
+	manifestName = ".mediawarp_strm.json" // 记录任务生成的 Strm 文件的清单，位于输出目录下
+)
+
+var (
+	defaultVideoExts   = []string{"mkv", "mp4", "avi", "ts", "m2ts", "iso", "rmvb", "wmv", "mov", "flv", "webm", "m4v", "mpg", "mpeg"}
+	defaultSidecarExts = []string{"srt", "ass", "ssa", "sub", "idx", "sup", "vtt", "nfo", "jpg", "jpeg", "png", "webp"}
+)
+
+// 同步结果
+type Result struct {
+	Task      string    `json:"task"`
+	Running   bool      `json:"running"`
+	StartTime time.Time `json:"start_time"`
+	EndTime   time.Time `json:"end_time"`
+	Created   int       `json:"created"`  // 新建的 Strm 文件数
+	Updated   int       `json:"updated"`  // 内容变化的 Strm 文件数
+	Skipped   int       `json:"skipped"`  // 内容未变化的 Strm 文件数
+	Sidecars  int       `json:"sidecars"` // 下载的附属文件数
+	Deleted   int       `json:"deleted"`  // 删除的 Strm 文件数
+	Errors    []string  `json:"errors,omitempty"`
+}
+
+func (result *Result) addError(format string, args ...any) {
+	msg := fmt.Sprintf(format, args...)
+	logging.Warningf("Strm 生成任务 %s：%s", result.Task, msg)
+	if len(result.Errors) < maxErrors {
+		result.Errors = append(result.Errors, msg)
+	}
+}
+
+// 单次同步
+type syncer struct {
+	setting     config.StrmGenerateTaskSetting
+	videoExts   map[string]struct{}
+	sidecarExts map[string]struct{}
+	result      *Result
+	expected    map[string]struct{} // 本次同步应存在的 Strm 文件
+	generated   map[string]struct{} // 此前同步生成的 Strm 文件（读取自清单）
+	listFailed  bool                // 是否有目录遍历失败，失败时不删除 Strm 文件
+	changes     []refresh.Update    // 本次同步新建、更新、删除的 Strm 文件
+}
+
+func newSyncer(setting config.StrmGenerateTaskSetting, result *Result) *syncer {
+	s := syncer{
+		setting:     setting,
+		videoExts:   extSet(setting.VideoExts, defaultVideoExts),
+		sidecarExts: extSet(setting.SidecarExts, defaultSidecarExts),
+		result:      result,
+		expected:    make(map[string]struct{}),
+		generated:   make(map[string]struct{}),
+	}
+	s.setting.Source = path.Clean("/" + setting.Source)
+	return &s
+}
+
+func extSet(exts []string, defaults []string) map[string]struct{} {
+	if len(exts) == 0 {
+		exts = defaults
+	}
+	set := make(map[string]struct{}, len(exts))
+	for _, ext := range exts {
+		set[strings.ToLower(strings.TrimPrefix(ext, "."))] = struct{}{}
+	}
+	return set
+}
+
+// 执行同步
+func (s *syncer) run() {
+	s.readManifest()
+	s.walk(s.setting.Source)
+	switch {
+	case !s.setting.DeleteOrphans:
+	case s.listFailed:
+		s.result.addError("部分目录遍历失败，跳过删除 Strm 文件")
+	default:
+		s.deleteOrphans()
+	}
+	s.writeManifest()
+}
+
+// 读取清单中此前同步生成的 Strm 文件
+func (s *syncer) readManifest() {
+	data, err := os.ReadFile(filepath.Join(s.setting.Output, manifestName))
+	if errors.Is(err, fs.ErrNotExist) {
+		return
+	}
+	var files []string
+	if err == nil {
+		err = json.Unmarshal(data, &files)
+	}
+	if err != nil {
+		s.result.addError("读取清单 %s 失败：%v", manifestName, err)
+		return
+	}
+	for _, file := range files {
+		if !filepath.IsLocal(filepath.FromSlash(file)) { // 防止删除输出目录之外的文件
+			continue
+		}
+		s.generated[filepath.Join(s.setting.Output, filepath.FromSlash(file))] = struct{}{}
+	}
+}
+
+// 写入清单
+//
+// 记录本次同步应存在的 Strm 文件，以及此前生成但本次未删除的 Strm 文件
+func (s *syncer) writeManifest() {
+	var files []string
+	for local := range s.generated {
+		s.expected[local] = struct{}{}
+	}
+	for local := range s.expected {
+		if rel, err := filepath.Rel(s.setting.Output, local); err == nil {
+			files = append(files, filepath.ToSlash(rel))
+		}
+	}
+	sort.Strings(files)
+	data, err := json.MarshalIndent(files, "", "  ")
+	if err == nil {
+		err = os.MkdirAll(s.setting.Output, 0755)
+	}
+	if err == nil {
+		err = os.WriteFile(filepath.Join(s.setting.Output, manifestName), data, 0644)
+	}
+	if err != nil {
+		s.result.addError("写入清单 %s 失败：%v", manifestName, err)
+	}
+}
+
+// 遍历 Alist 目录
+func (s *syncer) walk(dir string) {
+	var (
+		objects  []alist.FsObject
+		endpoint string
+		basePath string
+	)
+	err := service.WithAlistClient(s.setting.Alist, func(client *alist.AlistClient) error {
+		var err error
+		objects, err = client.FsListAll(dir, "", 0, s.setting.Refresh)
+		endpoint, basePath = client.GetEndpoint(), client.GetUserInfo().BasePath
+		return err
+	})
+	if err != nil {
+		s.listFailed = true
+		s.result.addError("遍历目录 %s 失败：%v", dir, err)
+		return
+	}
+
+	for _, object := range objects {
+		alistPath := path.Join(dir, object.Name)
+		if object.IsDir {
+			s.walk(alistPath)
+			continue
+		}
+
+		rel := strings.TrimPrefix(strings.TrimPrefix(alistPath, s.setting.Source), "/")
+		local := filepath.Join(s.setting.Output, filepath.FromSlash(rel))
+		ext := strings.ToLower(strings.TrimPrefix(path.Ext(object.Name), "."))
+		if _, ok := s.videoExts[ext]; ok {
+			content := alistPath
+			if s.setting.Type == constants.HTTPStrm {
+				content = alistURL(endpoint, basePath, alistPath, object.Sign)
+			}
+			s.writeStrm(strings.TrimSuffix(local, filepath.Ext(local))+".strm", content)
+		} else if _, ok := s.sidecarExts[ext]; ok {
+			s.downloadSidecar(local, alistURL(endpoint, basePath, alistPath, object.Sign), object.Size)
+		}
+	}
+}
+
+// 写入 Strm 文件，内容未变化时跳过
+func (s *syncer) writeStrm(local string, content string) {
+	if _, ok := s.expected[local]; ok {
+		s.result.addError("%s 对应多个视频文件，使用 %s", local, content)
+	}
+	s.expected[local] = struct{}{}
+
+	old, err := os.ReadFile(local)
+	switch {
+	case err == nil && bytes.Equal(old, []byte(content)):
+		s.result.Skipped++
+		return
+	case err != nil && !errors.Is(err, fs.ErrNotExist):
+		s.result.addError("读取 %s 失败：%v", local, err)
+		return
+	}
+
+	if err := os.MkdirAll(filepath.Dir(local), 0755); err != nil {
+		s.result.addError("创建目录 %s 失败：%v", filepath.Dir(local), err)
+		return
+	}
+	if err := os.WriteFile(local, []byte(content), 0644); err != nil {
+		s.result.addError("写入 %s 失败：%v", local, err)
+		return
+	}
+	if old == nil {
+		s.result.Created++
+		s.changes = append(s.changes, refresh.Update{Path: local, Type: refresh.Created})
+	} else {
+		s.result.Updated++
+		s.changes = append(s.changes, refresh.Update{Path: local, Type: refresh.Modified})
+	}
+	logging.Debugf("Strm 生成任务 %s：写入 %s", s.result.Task, local)
+}
+
+// 下载附属文件，本地文件大小一致时跳过
+func (s *syncer) downloadSidecar(local string, u string, size int64) {
+	if info, err := os.Stat(local); err == nil && info.Size() == size {
+		return
+	}
+
+	resp, err := utils.GetHTTPClient().Get(u)
+	if err != nil {
+		s.result.addError("下载 %s 失败：%v", u, err)
+		return
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		s.result.addError("下载 %s 失败，状态码：%d", u, resp.StatusCode)
+		return
+	}
+
+	if err := os.MkdirAll(filepath.Dir(local), 0755); err != nil {
+		s.result.addError("创建目录 %s 失败：%v", filepath.Dir(local), err)
+		return
+	}
+	tmp := local + ".tmp"
+	file, err := os.Create(tmp)
+	if err != nil {
+		s.result.addError("创建 %s 失败：%v", tmp, err)
+		return
+	}
+	_, err = io.Copy(file, resp.Body)
+	if closeErr := file.Close(); err == nil {
+		err = closeErr
+	}
+	if err == nil {
+		err = os.Rename(tmp, local)
+	}
+	if err != nil {
+		os.Remove(tmp)
+		s.result.addError("保存 %s 失败：%v", local, err)
+		return
+	}
+	s.result.Sidecars++
+}
+
+// 删除源目录中已不存在的 Strm 文件
+//
+// 仅删除清单中记录的由本任务生成的 Strm 文件，不会删除其他任务或用户创建的文件
+func (s *syncer) deleteOrphans() {
+	for local := range s.generated {
+		if _, ok := s.expected[local]; ok {
+			continue
+		}
+		if err := os.Remove(local); err != nil {
+			if !errors.Is(err, fs.ErrNotExist) {
+				s.result.addError("删除 %s 失败：%v", local, err)
+				continue // 保留在清单中，下次同步时重试
+			}
+		} else {
+			s.result.Deleted++
+			s.changes = append(s.changes, refresh.Update{Path: local, Type: refresh.Deleted})
+			logging.Debugf("Strm 生成任务 %s：删除 %s", s.result.Task, local)
+		}
+		delete(s.generated, local)
+	}
+}
+
+// Alist 直链
+func alistURL(endpoint string, basePath string, p string, sign string) string {
+	u := endpoint + (&url.URL{Path: path.Join("/d", basePath, p)}).EscapedPath()
+	if sign != "" {
+		u += "?sign=" + url.QueryEscape(sign)
+	}
+	return u
+}
@@ -0,0 +1,127 @@
+package subtitle
+
+import (
+	"MediaWarp/constants"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/siongui/gojianfan"
+)
+
+// 解析时间偏移
+//
+// 支持 1.5s、-500ms 等 Go 时间格式，纯数字时单位为秒
+func ParseOffset(value string) (time.Duration, error) {
+	value = strings.TrimSpace(value)
+	if value == "" {
+		return 0, nil
+	}
+	if seconds, err := strconv.ParseFloat(value, 64); err == nil {
+		return time.Duration(seconds * float64(time.Second)), nil
+	}
+	d, err := time.ParseDuration(value)
+	if err != nil {
+		return 0, fmt.Errorf("无效的时间偏移: %q", value)
+	}
+	return d, nil
+}
+
+// 解析帧率转换
+//
+// 格式为 原帧率:目标帧率，如 25:23.976 表示字幕按 25 帧的视频制作，需要用于 23.976 帧的视频
+func ParseFPS(value string) (from float64, to float64, err error) {
+	value = strings.TrimSpace(value)
+	if value == "" {
+		return 0, 0, nil
+	}
+	a, b, ok := strings.Cut(value, ":")
+	if ok {
+		from, err = strconv.ParseFloat(strings.TrimSpace(a), 64)
+		if err == nil {
+			to, err = strconv.ParseFloat(strings.TrimSpace(b), 64)
+		}
+	}
+	if !ok || err != nil || from <= 0 || to <= 0 {
+		return 0, 0, fmt.Errorf("无效的帧率转换: %q，格式为 原帧率:目标帧率", value)
+	}
+	return from, to, nil
+}
+
+// 平移所有字幕的时间
+//
+// 平移后开始时间小于 0 的字幕从 0 开始，结束时间不大于 0 的字幕被删除
+func (s *Subtitle) Shift(offset time.Duration) {
+	if offset == 0 {
+		return
+	}
+	cues := s.Cues[:0]
+	for _, cue := range s.Cues {
+		cue.Start, cue.End = max(cue.Start+offset, 0), cue.End+offset
+		if cue.End > 0 {
+			cues = append(cues, cue)
+		}
+	}
+	s.Cues = cues
+}
+
+// 将按 from 帧率制作的字幕转换为 to 帧率使用
+//
+// 字幕时间按 from / to 的比例缩放
+func (s *Subtitle) Rescale(from float64, to float64) {
+	if from <= 0 || to <= 0 || from == to {
+		return
+	}
+	ratio := from / to
+	for _, cue := range s.Cues {
+		cue.Start = time.Duration(float64(cue.Start) * ratio)
+		cue.End = time.Duration(float64(cue.End) * ratio)
+	}
+}
+
+// 简繁转换
+//
+// 按字转换，不处理词汇差异；覆盖标签（如 {\fn微软雅黑}）不会被转换。
+// bilingual 为 true 时保留原文，转换后的文本显示在原文下方
+func (s *Subtitle) ConvertChinese(conversion constants.ChineseConversion, bilingual bool) {
+	var convert func(string) string
+	switch conversion {
+	case constants.ChineseS2T:
+		convert = gojianfan.S2T
+	case constants.ChineseT2S:
+		convert = gojianfan.T2S
+	default:
+		return
+	}
+	for _, cue := range s.Cues {
+		converted := convertText(cue.Text, convert)
+		if bilingual && converted != cue.Text {
+			runs, _ := parseRuns(converted)
+			cue.Text += `\N{\r}` + runsToASS(runs, 0)
+		} else {
+			cue.Text = converted
+		}
+	}
+}
+
+// 转换对话文本中覆盖标签以外的部分
+func convertText(text string, convert func(string) string) string {
+	var b strings.Builder
+	for text != "" {
+		start := strings.IndexByte(text, '{')
+		if start < 0 {
+			b.WriteString(convert(text))
+			break
+		}
+		end := strings.IndexByte(text[start:], '}')
+		if end < 0 {
+			b.WriteString(convert(text))
+			break
+		}
+		b.WriteString(convert(text[:start]))
+		b.WriteString(text[start : start+end+1])
+		text = text[start+end+1:]
+	}
+	return b.String()
+}
@@ -0,0 +1,96 @@
+package subtitle_test
+
+import (
+	"MediaWarp/constants"
+	"MediaWarp/internal/subtitle"
+	"testing"
+	"time"
+)
+
+func TestAdjust(t *testing.T) {
+	parse := func(t *testing.T) *subtitle.Subtitle {
+		s, err := subtitle.ParseFormat([]byte("1\n00:00:01,000 --> 00:00:02,000\n第一句\n\n2\n00:00:10,000 --> 00:00:12,000\n{\\fn微软雅黑}这是简体\\N<i>后来</i>\n"), subtitle.FormatSRT)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return s
+	}
+
+	t.Run("时间偏移", func(t *testing.T) {
+		s := parse(t)
+		s.Shift(-1500 * time.Millisecond)
+		if len(s.Cues) != 2 || s.Cues[0].Start != 0 || s.Cues[0].End != 500*time.Millisecond || s.Cues[1].Start != 8500*time.Millisecond {
+			t.Errorf("偏移结果错误：%v", s.Cues)
+		}
+		s.Shift(-9 * time.Second)
+		if len(s.Cues) != 1 {
+			t.Errorf("结束时间不大于 0 的字幕应被删除，实际剩余 %d 条", len(s.Cues))
+		}
+	})
+
+	t.Run("帧率转换", func(t *testing.T) {
+		s := parse(t)
+		from, to, err := subtitle.ParseFPS("25:24")
+		if err != nil {
+			t.Fatal(err)
+		}
+		s.Rescale(from, to)
+		if s.Cues[1].Start != 10416666666 || s.Cues[1].End != 12500*time.Millisecond {
+			t.Errorf("转换结果错误：%s --> %s", s.Cues[1].Start, s.Cues[1].End)
+		}
+	})
+
+	t.Run("简繁转换", func(t *testing.T) {
+		s := parse(t)
+		s.ConvertChinese(constants.ChineseS2T, false)
+		if want := `{\fn微软雅黑}這是簡體\N{\i1}後來`; s.Cues[1].Text != want {
+			t.Errorf("转换结果错误。期望: %s, 实际: %s", want, s.Cues[1].Text)
+		}
+	})
+
+	t.Run("简繁双语", func(t *testing.T) {
+		s := parse(t)
+		s.ConvertChinese(constants.ChineseS2T, true)
+		if want := `{\fn微软雅黑}这是简体\N{\i1}后来\N{\r}這是簡體\N{\i1}後來`; s.Cues[1].Text != want {
+			t.Errorf("转换结果错误。期望: %s, 实际: %s", want, s.Cues[1].Text)
+		}
+	})
+
+	offsets := map[string]time.Duration{"1.5": 1500 * time.Millisecond, "-500ms": -500 * time.Millisecond, "2s": 2 * time.Second}
+	for value, want := range offsets {
+		if got, err := subtitle.ParseOffset(value); err != nil || got != want {
+			t.Errorf("解析时间偏移 %s 错误。期望: %s, 实际: %s, %v", value, want, got, err)
+		}
+	}
+	for _, value := range []string{"25", "a:b", "0:25"} {
+		if _, _, err := subtitle.ParseFPS(value); err == nil {
+			t.Errorf("帧率转换 %s 应解析失败", value)
+		}
+	}
+}
+
+func TestAdjustSSA(t *testing.T) {
+	const content = "[Script Info]\r\nScriptType: v4.00+\r\n\r\n" +
+		"[V4+ Styles]\r\nFormat: Name, Fontname, Fontsize\r\nStyle: Default,微软雅黑,52\r\n\r\n" +
+		"[Fonts]\r\nfontname: font_0.ttf\r\n!!!!\r\n\r\n" +
+		"[Events]\r\nFormat: Layer, Start, End, Style, Name, MarginL, MarginR, MarginV, Effect, Text\r\n" +
+		"Comment: 0,0:00:00.00,0:00:05.00,Default,,0,0,0,,注释，不显示\r\n" +
+		"Dialogue: 0,0:00:00.50,0:00:01.00,Default,,0,0,0,,第一句\r\n" +
+		"Dialogue: 1,0:00:02.00,0:00:03.50,Default,旁白,0,0,10,,{\\fn楷体}这是简体, 含逗号\r\n"
+	result, err := subtitle.AdjustSSA([]byte(content), func(s *subtitle.Subtitle) {
+		s.Shift(-time.Second)
+		s.ConvertChinese(constants.ChineseS2T, false)
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := "[Script Info]\r\nScriptType: v4.00+\r\n\r\n" +
+		"[V4+ Styles]\r\nFormat: Name, Fontname, Fontsize\r\nStyle: Default,微软雅黑,52\r\n\r\n" +
+		"[Fonts]\r\nfontname: font_0.ttf\r\n!!!!\r\n\r\n" +
+		"[Events]\r\nFormat: Layer, Start, End, Style, Name, MarginL, MarginR, MarginV, Effect, Text\r\n" +
+		"Comment: 0,0:00:00.00,0:00:05.00,Default,,0,0,0,,注释，不显示\r\n" +
+		"Dialogue: 1,0:00:01.00,0:00:02.50,Default,旁白,0,0,10,,{\\fn楷体}這是簡體, 含逗號\r\n"
+	if string(result) != want {
+		t.Errorf("调整结果错误。期望:\n%s\n实际:\n%s", want, result)
+	}
+}
@@ -0,0 +1,279 @@
+package subtitle
+
+import (
+	"MediaWarp/internal/logging"
+	"bytes"
+	"slices"
+	"strings"
+	"unicode/utf8"
+)
+
+const uuencodeLineLength = 80 // ASS 规范中 [Fonts] 每行的字符数
+
+// 字体使用的字符
+type fontUsage struct {
+	name  string // 字幕中的字体名称
+	runes map[rune]struct{}
+}
+
+// ASS 字幕中各字体使用的字符
+//
+// 字体来自 [V4+ Styles] 中样式的 Fontname 和对话中的 \fn、\r 覆盖标签，字体名称不区分大小写
+func collectGlyphs(content string) map[string]*fontUsage {
+	var (
+		section      string
+		styleFormat  []string
+		eventFormat  []string
+		styles       = make(map[string]string) // 样式名称 -> 字体
+		usages       = make(map[string]*fontUsage)
+		defaultStyle string
+	)
+	add := func(font string, r rune) {
+		font = strings.TrimPrefix(strings.TrimSpace(font), "@") // @ 表示竖排字体
+		if font == "" {
+			return
+		}
+		key := strings.ToLower(font)
+		usage, ok := usages[key]
+		if !ok {
+			usage = &fontUsage{name: font, runes: make(map[rune]struct{})}
+			usages[key] = usage
+		}
+		usage.runes[r] = struct{}{}
+	}
+
+	for _, line := range strings.Split(content, "\n") {
+		line = strings.TrimSpace(line)
+		if strings.HasPrefix(line, "[") && strings.HasSuffix(line, "]") {
+			section = strings.ToLower(line)
+			continue
+		}
+		key, value, ok := strings.Cut(line, ":")
+		if !ok {
+			continue
+		}
+		key, value = strings.ToLower(strings.TrimSpace(key)), strings.TrimSpace(value)
+
+		switch {
+		case strings.HasPrefix(section, "[v4") && strings.Contains(section, "styles"):
+			switch key {
+			case "format":
+				styleFormat = splitFormat(value)
+			case "style":
+				fields := splitFields(value, len(styleFormat))
+				name, font := field(fields, styleFormat, "name"), field(fields, styleFormat, "fontname")
+				styles[name] = font
+				if defaultStyle == "" || strings.EqualFold(name, "Default") {
+					defaultStyle = name
+				}
+			}
+
+		case section == "[events]":
+			switch key {
+			case "format":
+				eventFormat = splitFormat(value)
+			case "dialogue":
+				fields := splitFields(value, len(eventFormat))
+				styleFont := func(name string) string {
+					if font, ok := styles[strings.TrimPrefix(name, "*")]; ok {
+						return font
+					}
+					return styles[defaultStyle]
+				}
+				lineFont := styleFont(field(fields, eventFormat, "style"))
+				walkText(field(fields, eventFormat, "text"), lineFont, styleFont, add)
+			}
+		}
+	}
+	return usages
+}
+
+// 按 Format 拆分字段名称，字段名称均为小写
+func splitFormat(value string) []string {
+	var format []string
+	for _, name := range strings.Split(value, ",") {
+		format = append(format, strings.ToLower(strings.TrimSpace(name)))
+	}
+	return format
+}
+
+// 拆分字段，最后一个字段可以包含逗号
+func splitFields(value string, count int) []string {
+	if count <= 0 {
+		return nil
+	}
+	return strings.SplitN(value, ",", count)
+}
+
+func field(fields []string, format []string, name string) string {
+	i := slices.Index(format, name)
+	if i < 0 || i >= len(fields) {
+		return ""
+	}
+	return strings.TrimSpace(fields[i])
+}
+
+// 遍历对话文本中显示的字符
+//
+// 处理 {} 中的 \fn、\r、\p 覆盖标签，跳过 \N、\n 换行和绘图指令
+func walkText(text string, lineFont string, styleFont func(string) string, add func(font string, r rune)) {
+	font, drawing := lineFont, false
+	for i := 0; i < len(text); {
+		switch {
+		case text[i] == '{':
+			end := strings.IndexByte(text[i:], '}')
+			if end < 0 {
+				break
+			}
+			for _, tag := range strings.Split(text[i+1:i+end], `\`) {
+				tag = strings.TrimSpace(tag)
+				switch {
+				case strings.HasPrefix(tag, "fn"):
+					font = strings.TrimSpace(tag[2:])
+					if font == "" {
+						font = lineFont
+					}
+				case strings.HasPrefix(tag, "r"):
+					if style := strings.TrimSpace(tag[1:]); style != "" {
+						lineFont = styleFont(style)
+					}
+					font = lineFont
+				case len(tag) > 1 && tag[0] == 'p' && strings.Trim(tag[1:], "0123456789") == "":
+					drawing = strings.Trim(tag[1:], "0") != ""
+				}
+			}
+			i += end + 1
+			continue
+		case text[i] == '\\' && i+1 < len(text):
+			switch text[i+1] {
+			case 'N', 'n':
+				i += 2
+				continue
+			case 'h': // 不换行空格
+				if !drawing {
+					add(font, '\u00a0')
+				}
+				i += 2
+				continue
+			}
+		}
+		r, size := utf8.DecodeRuneInString(text[i:])
+		if !drawing {
+			add(font, r)
+		}
+		i += size
+	}
+}
+
+// 将 ASS 字幕中使用的字体子集化后嵌入 [Fonts]
+//
+// 字体从 subtitle.font_dir 中查找，未找到或无法子集化的字体会被跳过
+// 不是 ASS 字幕或没有嵌入任何字体时返回原内容
+func SubsetFonts(content []byte) []byte {
+	usages := collectGlyphs(string(content))
+	if len(usages) == 0 {
+		return content
+	}
+	keys := make([]string, 0, len(usages))
+	for key := range usages {
+		keys = append(keys, key)
+	}
+	slices.Sort(keys)
+
+	newLine := "\n"
+	if bytes.Contains(content, []byte("\r\n")) {
+		newLine = "\r\n"
+	}
+	var section bytes.Buffer
+	for _, key := range keys {
+		usage := usages[key]
+		runes := make([]rune, 0, len(usage.runes))
+		for r := range usage.runes {
+			runes = append(runes, r)
+		}
+		face, err := loadFont(usage.name)
+		if err != nil {
+			logging.Debugf("字体 %s 未嵌入字幕：%v", usage.name, err)
+			continue
+		}
+		data, err := face.subset(runes)
+		if err != nil {
+			logging.Warningf("字体 %s 子集化失败：%v", usage.name, err)
+			continue
+		}
+		section.WriteString("fontname: " + usage.name + "_0.ttf" + newLine)
+		for _, line := range uuencode(data) {
+			section.WriteString(line + newLine)
+		}
+		logging.Debugf("已嵌入字体 %s 的子集（%d 个字符，%d 字节）", usage.name, len(runes), len(data))
+	}
+	if section.Len() == 0 {
+		return content
+	}
+	return insertFonts(content, section.Bytes(), newLine)
+}
+
+// 将字体插入 [Fonts] 段
+//
+// 已有 [Fonts] 段时追加在段首，否则在 [Events] 之前新建
+func insertFonts(content []byte, fonts []byte, newLine string) []byte {
+	var (
+		result bytes.Buffer
+		offset int
+		done   bool
+	)
+	result.Grow(len(content) + len(fonts) + 16)
+	for _, line := range bytes.SplitAfter(content, []byte("\n")) {
+		offset += len(line)
+		switch strings.ToLower(string(bytes.TrimSpace(line))) {
+		case "[fonts]":
+			result.Write(line)
+			if !bytes.HasSuffix(line, []byte("\n")) {
+				result.WriteString(newLine)
+			}
+			result.Write(fonts)
+			done = true
+		case "[events]":
+			result.WriteString("[Fonts]" + newLine)
+			result.Write(fonts)
+			result.WriteString(newLine)
+			result.Write(line)
+			done = true
+		default:
+			result.Write(line)
+			continue
+		}
+		result.Write(content[offset:])
+		break
+	}
+	if !done {
+		if result.Len() > 0 && !bytes.HasSuffix(result.Bytes(), []byte("\n")) {
+			result.WriteString(newLine)
+		}
+		result.WriteString(newLine + "[Fonts]" + newLine)
+		result.Write(fonts)
+	}
+	return result.Bytes()
+}
+
+// 按 ASS 规范编码嵌入的文件
+//
+// 每 3 个字节编码为 4 个字符（每 6 位加 33），剩余 1、2 个字节分别编码为 2、3 个字符，每行 80 个字符
+func uuencode(data []byte) []string {
+	encoded := make([]byte, 0, (len(data)+2)/3*4)
+	for i := 0; i < len(data); i += 3 {
+		var chunk [3]byte
+		n := copy(chunk[:], data[i:])
+		value := uint32(chunk[0])<<16 | uint32(chunk[1])<<8 | uint32(chunk[2])
+		for j := range n + 1 {
+			encoded = append(encoded, byte(value>>(18-6*j)&0x3F)+33)
+		}
+	}
+	lines := make([]string, 0, (len(encoded)+uuencodeLineLength-1)/uuencodeLineLength)
+	for len(encoded) > 0 {
+		n := min(uuencodeLineLength, len(encoded))
+		lines = append(lines, string(encoded[:n]))
+		encoded = encoded[n:]
+	}
+	return lines
+}
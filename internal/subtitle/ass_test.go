@@ -0,0 +1,191 @@
+package subtitle_test
+
+import (
+	"MediaWarp/internal/config"
+	"MediaWarp/internal/subtitle"
+	"encoding/binary"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+	"unicode/utf16"
+)
+
+// 生成只包含 cmap、glyf、loca 等必要表的 TrueType 字体，第 i 个字符对应字形 i+1
+func buildFont(family string, runes []rune) []byte {
+	u16 := func(values ...int) []byte {
+		b := make([]byte, 2*len(values))
+		for i, v := range values {
+			binary.BigEndian.PutUint16(b[2*i:], uint16(v))
+		}
+		return b
+	}
+	cat := func(parts ...[]byte) []byte {
+		var b []byte
+		for _, part := range parts {
+			b = append(b, part...)
+		}
+		return b
+	}
+
+	numGlyphs := len(runes) + 1
+	var glyf, loca []byte
+	for gid := range numGlyphs {
+		loca = binary.BigEndian.AppendUint32(loca, uint32(len(glyf)))
+		glyf = append(glyf, u16(1, 0, 0, 100, 100, 0, 0, gid)...) // 1 个轮廓，最后两个字节标记字形编号
+	}
+	loca = binary.BigEndian.AppendUint32(loca, uint32(len(glyf)))
+
+	segCount := len(runes) + 1
+	var ends, starts, deltas []int
+	sorted := slices.Clone(runes) // 分段需要按字符升序排列
+	slices.Sort(sorted)
+	for _, r := range sorted {
+		gid := slices.Index(runes, r) + 1
+		ends, starts, deltas = append(ends, int(r)), append(starts, int(r)), append(deltas, gid-int(r))
+	}
+	ends, starts, deltas = append(ends, 0xFFFF), append(starts, 0xFFFF), append(deltas, 1)
+	subtable := cat(u16(4, 0, 0, 2*segCount, 0, 0, 0), u16(ends...), u16(0), u16(starts...), u16(deltas...), make([]byte, 2*segCount))
+	binary.BigEndian.PutUint16(subtable[2:], uint16(len(subtable)))
+	cmap := cat(u16(0, 1, 3, 1), binary.BigEndian.AppendUint32(nil, 12), subtable)
+
+	var familyName []int
+	for _, unit := range utf16.Encode([]rune(family)) {
+		familyName = append(familyName, int(unit))
+	}
+	name := cat(u16(0, 2, 30, 3, 1, 0x409, 1, 2*len(familyName), 0, 3, 1, 0x409, 2, 14, 2*len(familyName)), u16(familyName...), u16([]int{'R', 'e', 'g', 'u', 'l', 'a', 'r'}...))
+
+	head := make([]byte, 54)
+	binary.BigEndian.PutUint16(head[50:], 1)
+	tables := []struct {
+		tag  string
+		data []byte
+	}{{"cmap", cmap}, {"glyf", glyf}, {"head", head}, {"loca", loca}, {"maxp", u16(0, 0x5000, numGlyphs)}, {"name", name}}
+
+	font := cat(u16(1, 0, len(tables), 0, 0, 0))
+	offset := 12 + 16*len(tables)
+	var data []byte
+	for _, table := range tables {
+		font = append(font, table.tag...)
+		font = binary.BigEndian.AppendUint32(font, 0)
+		font = binary.BigEndian.AppendUint32(font, uint32(offset+len(data)))
+		font = binary.BigEndian.AppendUint32(font, uint32(len(table.data)))
+		data = append(data, table.data...)
+		for len(data)%4 != 0 {
+			data = append(data, 0)
+		}
+	}
+	return append(font, data...)
+}
+
+// 按 ASS 规范解码嵌入的字体
+func uudecode(lines []string) []byte {
+	encoded := []byte(strings.Join(lines, ""))
+	var data []byte
+	for i := 0; i < len(encoded); i += 4 {
+		chunk := encoded[i:min(i+4, len(encoded))]
+		var value uint32
+		for j := range 4 {
+			value <<= 6
+			if j < len(chunk) {
+				value |= uint32(chunk[j] - 33)
+			}
+		}
+		data = append(data, byte(value>>16), byte(value>>8), byte(value))[:len(data)+len(chunk)-1]
+	}
+	return data
+}
+
+// 读取字体中各字形的轮廓数据长度
+func glyphLengths(t *testing.T, font []byte) []int {
+	tables := make(map[string][]byte)
+	for i := range int(binary.BigEndian.Uint16(font[4:])) {
+		record := font[12+16*i:]
+		offset, length := binary.BigEndian.Uint32(record[8:]), binary.BigEndian.Uint32(record[12:])
+		tables[string(record[:4])] = font[offset : offset+length]
+	}
+	if binary.BigEndian.Uint16(tables["head"][50:]) != 1 {
+		t.Fatal("子集化后的字体应使用 32 位 loca")
+	}
+	var lengths []int
+	loca := tables["loca"]
+	for i := 0; i+8 <= len(loca); i += 4 {
+		lengths = append(lengths, int(binary.BigEndian.Uint32(loca[i+4:])-binary.BigEndian.Uint32(loca[i:])))
+	}
+	return lengths
+}
+
+func TestSubsetFonts(t *testing.T) {
+	dir := t.TempDir()
+	os.WriteFile(filepath.Join(dir, "kai.ttf"), buildFont("楷体", []rune("你好世界甲乙")), 0644)
+	os.WriteFile(filepath.Join(dir, "hei.ttf"), buildFont("黑体", []rune("你好世界")), 0644)
+	config.Set(&config.Setting{Subtitle: config.SubtitleSetting{Enable: true, SubSet: true, FontDir: dir}})
+	if err := subtitle.Init(); err != nil {
+		t.Fatal(err)
+	}
+
+	ass := strings.Join([]string{
+		"[Script Info]",
+		"ScriptType: v4.00+",
+		"",
+		"[V4+ Styles]",
+		"Format: Name, Fontname, Fontsize",
+		"Style: Default,楷体,20",
+		"Style: Sign,@黑体,20",
+		"",
+		"[Events]",
+		"Format: Layer, Start, End, Style, Name, MarginL, MarginR, MarginV, Effect, Text",
+		`Dialogue: 0,0:00:01.00,0:00:02.00,Default,,0,0,0,,你{\fn黑体}好\N{\r}乙{\p1}m 0 0 l 世 界{\p0}`,
+		`Dialogue: 0,0:00:01.00,0:00:02.00,Sign,,0,0,0,,世,{\rDefault}甲`,
+		`Comment: 0,0:00:01.00,0:00:02.00,Default,,0,0,0,,界`,
+		"",
+	}, "\r\n")
+	result := string(subtitle.SubsetFonts([]byte(ass)))
+	if !strings.Contains(result, "\r\n[Fonts]\r\n") || strings.Index(result, "[Fonts]") > strings.Index(result, "[Events]") {
+		t.Fatalf("[Fonts] 段位置错误：\n%s", result)
+	}
+
+	embedded := make(map[string][]string)
+	var current string
+	for _, line := range strings.Split(result[strings.Index(result, "[Fonts]"):strings.Index(result, "[Events]")], "\r\n")[1:] {
+		if name, ok := strings.CutPrefix(line, "fontname: "); ok {
+			current = name
+		} else if line != "" {
+			embedded[current] = append(embedded[current], line)
+		}
+	}
+	tests := map[string][]bool{ // 各字形是否保留，第 0 个为 .notdef
+		"楷体_0.ttf": {true, true, false, false, false, true, true}, // 你、甲、乙
+		"黑体_0.ttf": {true, false, true, true, false},              // 好、世
+	}
+	for name, want := range tests {
+		t.Run(name, func(t *testing.T) {
+			lines, ok := embedded[name]
+			if !ok {
+				t.Fatalf("未嵌入字体，已嵌入：%v", embedded)
+			}
+			for _, line := range lines[:len(lines)-1] {
+				if len(line) != 80 {
+					t.Fatalf("每行应为 80 个字符，实际为 %d", len(line))
+				}
+			}
+			lengths := glyphLengths(t, uudecode(lines))
+			if len(lengths) != len(want) {
+				t.Fatalf("字形数量错误。期望: %d，实际: %d", len(want), len(lengths))
+			}
+			for gid, keep := range want {
+				if (lengths[gid] > 0) != keep {
+					t.Errorf("字形 %d 保留状态错误。期望: %v", gid, keep)
+				}
+			}
+		})
+	}
+
+	t.Run("非 ASS 字幕", func(t *testing.T) {
+		srt := "1\n00:00:01,000 --> 00:00:02,000\n你好\n"
+		if got := string(subtitle.SubsetFonts([]byte(srt))); got != srt {
+			t.Errorf("非 ASS 字幕不应被修改：%q", got)
+		}
+	})
+}
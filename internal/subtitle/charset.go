@@ -0,0 +1,231 @@
+package subtitle
+
+import (
+	"bytes"
+	"errors"
+	"maps"
+	"strings"
+	"sync"
+	"unicode"
+	"unicode/utf8"
+
+	"golang.org/x/text/encoding"
+	"golang.org/x/text/encoding/japanese"
+	"golang.org/x/text/encoding/simplifiedchinese"
+	"golang.org/x/text/encoding/traditionalchinese"
+	xunicode "golang.org/x/text/encoding/unicode"
+)
+
+// 字符编码
+type Charset string
+
+const (
+	CharsetUTF8     Charset = "utf-8"
+	CharsetUTF16LE  Charset = "utf-16le"
+	CharsetUTF16BE  Charset = "utf-16be"
+	CharsetGB18030  Charset = "gb18030"
+	CharsetBig5     Charset = "big5"
+	CharsetShiftJIS Charset = "shift_jis"
+)
+
+var ErrUnknownCharset = errors.New("不支持的字符编码")
+
+var (
+	utf16LEBOM = []byte{0xFF, 0xFE}
+	utf16BEBOM = []byte{0xFE, 0xFF}
+
+	// 常用汉字，用于区分 GB18030 和 Big5 的解码结果
+	commonHans  = []rune("的一是不了人我在有他这中大来上个国们到说时要就出会也你对生能而子那得于着下自之年过发后作里用道行所然家种事成方多经么去法学如都同现当没动面起看定天分还进好小部其些主样理心她本前开但因只从想实吗呢吧啊哦嗯谁怎什别让给")
+	commonHant  = []rune("的一是不了人我在有他這中大來上個國們到說時要就出會也你對生能而子那得於著下自之年過發後作裡用道行所然家種事成方多經麼去法學如都同現當沒動面起看定天分還進好小部其些主樣理心她本前開但因只從想實嗎呢吧啊哦嗯誰怎什別讓給")
+	commonRunes = make(map[rune]bool)
+)
+
+func init() {
+	for _, r := range commonHans {
+		commonRunes[r] = true
+	}
+	for _, r := range commonHant {
+		commonRunes[r] = true
+	}
+}
+
+var (
+	charsetMutex sync.RWMutex
+	charsets     = make(map[string]Charset) // 文件 -> 手动指定的字符编码，仅保存在内存中，重新加载配置后保留
+)
+
+// 获取字符编码的解码器
+func (charset Charset) encoding() (encoding.Encoding, error) {
+	switch charset {
+	case CharsetUTF8:
+		return xunicode.UTF8, nil
+	case CharsetUTF16LE:
+		return xunicode.UTF16(xunicode.LittleEndian, xunicode.IgnoreBOM), nil
+	case CharsetUTF16BE:
+		return xunicode.UTF16(xunicode.BigEndian, xunicode.IgnoreBOM), nil
+	case CharsetGB18030:
+		return simplifiedchinese.GB18030, nil
+	case CharsetBig5:
+		return traditionalchinese.Big5, nil
+	case CharsetShiftJIS:
+		return japanese.ShiftJIS, nil
+	}
+	return nil, ErrUnknownCharset
+}
+
+// 解析字符编码名称
+//
+// 支持常见别名，如 gbk、gb2312、cp936 均视为 GB18030
+func ParseCharset(name string) (Charset, error) {
+	switch strings.ToLower(strings.TrimSpace(name)) {
+	case "utf-8", "utf8":
+		return CharsetUTF8, nil
+	case "utf-16le", "utf-16", "utf16":
+		return CharsetUTF16LE, nil
+	case "utf-16be":
+		return CharsetUTF16BE, nil
+	case "gb18030", "gbk", "gb2312", "cp936":
+		return CharsetGB18030, nil
+	case "big5", "big-5", "cp950":
+		return CharsetBig5, nil
+	case "shift_jis", "shift-jis", "sjis", "cp932":
+		return CharsetShiftJIS, nil
+	}
+	return "", ErrUnknownCharset
+}
+
+// 检测字幕的字符编码
+//
+// 优先根据 BOM 判断，其次判断 UTF-16 和 UTF-8，
+// 最后分别使用 GB18030、Big5、Shift-JIS 解码，选择解码结果中常用字符最多的编码
+func DetectCharset(data []byte) Charset {
+	switch {
+	case bytes.HasPrefix(data, utf8BOM):
+		return CharsetUTF8
+	case bytes.HasPrefix(data, utf16LEBOM):
+		return CharsetUTF16LE
+	case bytes.HasPrefix(data, utf16BEBOM):
+		return CharsetUTF16BE
+	}
+	if charset, ok := detectUTF16(data); ok {
+		return charset
+	}
+	if utf8.Valid(data) {
+		return CharsetUTF8
+	}
+
+	best, bestScore := CharsetGB18030, 0
+	for i, charset := range []Charset{CharsetGB18030, CharsetBig5, CharsetShiftJIS} {
+		enc, _ := charset.encoding()
+		decoded, err := enc.NewDecoder().Bytes(data)
+		if err != nil {
+			continue
+		}
+		if score := charsetScore(decoded); i == 0 || score > bestScore {
+			best, bestScore = charset, score
+		}
+	}
+	return best
+}
+
+// 没有 BOM 的 UTF-16 中 ASCII 字符的高位字节为 0
+func detectUTF16(data []byte) (Charset, bool) {
+	sample := data[:min(len(data), 4096)&^1]
+	if len(sample) < 4 {
+		return "", false
+	}
+	var even, odd int
+	for i := 0; i < len(sample); i += 2 {
+		if sample[i] == 0 {
+			even++
+		}
+		if sample[i+1] == 0 {
+			odd++
+		}
+	}
+	half := len(sample) / 2
+	switch {
+	case odd > half*3/10 && even < half/20:
+		return CharsetUTF16LE, true
+	case even > half*3/10 && odd < half/20:
+		return CharsetUTF16BE, true
+	}
+	return "", false
+}
+
+// 计算解码结果的可信程度
+func charsetScore(text []byte) int {
+	score := 0
+	for _, r := range string(text) {
+		switch {
+		case r == utf8.RuneError:
+			score -= 10
+		case commonRunes[r]:
+			score += 3
+		case r >= 0x3040 && r <= 0x30FF: // 平假名、片假名
+			score += 2
+		case r >= 0xFF61 && r <= 0xFF9F: // 半角片假名，字幕中很少使用
+			score -= 2
+		case unicode.Is(unicode.Han, r), r < utf8.RuneSelf && (r >= ' ' || r == '\n' || r == '\r' || r == '\t'):
+		case unicode.IsControl(r), unicode.Is(unicode.Co, r): // 控制字符、私用区字符
+			score -= 5
+		}
+	}
+	return score
+}
+
+// 将字幕转换为 UTF-8
+//
+// key 用于查找手动指定的编码，手动指定的编码优先于检测结果；
+// 但带有 BOM 或本身是有效 UTF-8 的内容（如媒体服务器转换后的字幕）始终按检测结果处理，
+// 检测结果不会被记住，同一 key 下的不同响应分别检测。
+// 返回原始的字符编码，已经是 UTF-8 时去除 BOM 后返回
+func ToUTF8(key string, data []byte) ([]byte, Charset, error) {
+	charset := DetectCharset(data)
+	hasBOM := bytes.HasPrefix(data, utf8BOM) || bytes.HasPrefix(data, utf16LEBOM) || bytes.HasPrefix(data, utf16BEBOM)
+	if key != "" && !hasBOM && !utf8.Valid(data) {
+		charsetMutex.RLock()
+		if override, ok := charsets[key]; ok {
+			charset = override
+		}
+		charsetMutex.RUnlock()
+	}
+
+	if charset == CharsetUTF8 {
+		return bytes.TrimPrefix(data, utf8BOM), charset, nil
+	}
+	enc, err := charset.encoding()
+	if err != nil {
+		return nil, charset, err
+	}
+	data = bytes.TrimPrefix(bytes.TrimPrefix(data, utf16LEBOM), utf16BEBOM)
+	decoded, err := enc.NewDecoder().Bytes(data)
+	if err != nil {
+		return nil, charset, err
+	}
+	return bytes.TrimPrefix(decoded, utf8BOM), charset, nil
+}
+
+// 手动指定文件的字符编码
+//
+// charset 为空时删除手动指定的编码，之后重新检测
+func SetCharset(key string, charset Charset) error {
+	charsetMutex.Lock()
+	defer charsetMutex.Unlock()
+	if charset == "" {
+		delete(charsets, key)
+		return nil
+	}
+	if _, err := charset.encoding(); err != nil {
+		return err
+	}
+	charsets[key] = charset
+	return nil
+}
+
+// 获取所有手动指定的字符编码
+func CharsetOverrides() map[string]Charset {
+	charsetMutex.RLock()
+	defer charsetMutex.RUnlock()
+	return maps.Clone(charsets)
+}
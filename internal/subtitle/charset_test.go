@@ -0,0 +1,85 @@
+package subtitle_test
+
+import (
+	"MediaWarp/internal/subtitle"
+	"testing"
+
+	"golang.org/x/text/encoding"
+	"golang.org/x/text/encoding/japanese"
+	"golang.org/x/text/encoding/simplifiedchinese"
+	"golang.org/x/text/encoding/traditionalchinese"
+	"golang.org/x/text/encoding/unicode"
+)
+
+func TestToUTF8(t *testing.T) {
+	var (
+		hans = "1\n00:00:01,000 --> 00:00:02,000\n你好，这是一个测试字幕\n\n2\n00:00:03,000 --> 00:00:04,000\n我们现在就出发吧\n"
+		hant = "1\n00:00:01,000 --> 00:00:02,000\n你好，這是一個測試字幕\n\n2\n00:00:03,000 --> 00:00:04,000\n我們現在就出發吧\n"
+		jpn  = "1\n00:00:01,000 --> 00:00:02,000\nこんにちは、これはテストです\n\n2\n00:00:03,000 --> 00:00:04,000\n今から出発しましょう\n"
+	)
+	tests := map[string]struct {
+		text     string
+		encoding encoding.Encoding
+		charset  subtitle.Charset
+	}{
+		"UTF-8":        {hans, unicode.UTF8, subtitle.CharsetUTF8},
+		"UTF-8 BOM":    {hans, unicode.UTF8BOM, subtitle.CharsetUTF8},
+		"UTF-16LE BOM": {hans, unicode.UTF16(unicode.LittleEndian, unicode.UseBOM), subtitle.CharsetUTF16LE},
+		"UTF-16BE BOM": {hant, unicode.UTF16(unicode.BigEndian, unicode.UseBOM), subtitle.CharsetUTF16BE},
+		"UTF-16LE":     {jpn, unicode.UTF16(unicode.LittleEndian, unicode.IgnoreBOM), subtitle.CharsetUTF16LE},
+		"GBK 简体":       {hans, simplifiedchinese.GBK, subtitle.CharsetGB18030},
+		"GB18030 繁体":   {hant, simplifiedchinese.GB18030, subtitle.CharsetGB18030},
+		"Big5":         {hant, traditionalchinese.Big5, subtitle.CharsetBig5},
+		"Shift-JIS":    {jpn, japanese.ShiftJIS, subtitle.CharsetShiftJIS},
+	}
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			data, err := test.encoding.NewEncoder().Bytes([]byte(test.text))
+			if err != nil {
+				t.Fatal(err)
+			}
+			result, charset, err := subtitle.ToUTF8("", data)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if charset != test.charset {
+				t.Errorf("编码检测错误。期望: %s, 实际: %s", test.charset, charset)
+			}
+			if string(result) != test.text {
+				t.Errorf("转换结果错误：%q", result)
+			}
+		})
+	}
+
+	t.Run("手动指定编码", func(t *testing.T) {
+		data, _ := traditionalchinese.Big5.NewEncoder().Bytes([]byte(hant))
+		const key = "/videos/1/subtitles/2/stream"
+		if err := subtitle.SetCharset(key, subtitle.CharsetGB18030); err != nil {
+			t.Fatal(err)
+		}
+		if _, charset, _ := subtitle.ToUTF8(key, data); charset != subtitle.CharsetGB18030 {
+			t.Errorf("应使用手动指定的编码，实际: %s", charset)
+		}
+		if got := subtitle.CharsetOverrides()[key]; got != subtitle.CharsetGB18030 {
+			t.Errorf("手动指定的编码未记录，实际: %q", got)
+		}
+		if result, charset, _ := subtitle.ToUTF8(key, []byte(hant)); charset != subtitle.CharsetUTF8 || string(result) != hant {
+			t.Errorf("有效的 UTF-8 内容不应使用手动指定的编码，实际: %s", charset)
+		}
+		subtitle.SetCharset(key, "")
+		if _, charset, _ := subtitle.ToUTF8(key, data); charset != subtitle.CharsetBig5 {
+			t.Errorf("删除手动指定的编码后应重新检测，实际: %s", charset)
+		}
+	})
+
+	t.Run("不记住检测结果", func(t *testing.T) {
+		const key = "/videos/1/subtitles/3/stream"
+		data, _ := simplifiedchinese.GBK.NewEncoder().Bytes([]byte(hans))
+		if _, charset, _ := subtitle.ToUTF8(key, data); charset != subtitle.CharsetGB18030 {
+			t.Fatalf("编码检测错误，实际: %s", charset)
+		}
+		if result, charset, _ := subtitle.ToUTF8(key, []byte(hans)); charset != subtitle.CharsetUTF8 || string(result) != hans {
+			t.Errorf("同一字幕的 UTF-8 响应应重新检测，实际: %s", charset)
+		}
+	})
+}
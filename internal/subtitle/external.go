@@ -0,0 +1,68 @@
+package subtitle
+
+import (
+	"path"
+	"slices"
+	"strings"
+)
+
+var externalFormats = []Format{FormatSRT, FormatASS, FormatSSA, FormatVTT} // 支持的外挂字幕格式
+
+// 字幕文件名中的语言标识 -> ISO 639-2 语言代码
+var externalLanguages = map[string]string{
+	"zh": "chi", "chi": "chi", "zho": "chi", "chs": "chi", "cht": "chi", "sc": "chi", "tc": "chi",
+	"zh-cn": "chi", "zh-tw": "chi", "zh-hk": "chi", "zh-hans": "chi", "zh-hant": "chi",
+	"简体": "chi", "繁体": "chi", "简中": "chi", "繁中": "chi", "中文": "chi",
+	"en": "eng", "eng": "eng", "english": "eng",
+	"ja": "jpn", "jp": "jpn", "jpn": "jpn", "japanese": "jpn",
+	"ko": "kor", "kor": "kor", "korean": "kor",
+}
+
+// 外挂字幕文件
+type External struct {
+	Name     string // 文件名
+	Format   Format
+	Language string // ISO 639-2 语言代码，无法识别时为空
+	Title    string // 视频文件名与扩展名之间的部分，如 movie.chs.forced.ass 为 chs.forced
+	Default  bool
+	Forced   bool
+}
+
+// 在同一目录的文件中查找视频的外挂字幕
+//
+// 字幕文件名需要与视频文件同名（不区分大小写），可以带有语言等后缀，如 movie.srt、movie.zh-CN.ass、movie.chs.forced.vtt
+func MatchExternal(video string, names []string) []External {
+	base := strings.TrimSuffix(video, path.Ext(video))
+	var externals []External
+	for _, name := range names {
+		format := FormatFromExt(name)
+		if !slices.Contains(externalFormats, format) {
+			continue
+		}
+		stem := strings.TrimSuffix(name, path.Ext(name))
+		if len(stem) < len(base) || !strings.EqualFold(stem[:len(base)], base) {
+			continue
+		}
+		suffix := stem[len(base):]
+		if suffix != "" && suffix[0] != '.' {
+			continue
+		}
+
+		external := External{Name: name, Format: format, Title: strings.TrimPrefix(suffix, ".")}
+		for tag := range strings.SplitSeq(strings.ToLower(external.Title), ".") {
+			switch tag {
+			case "default":
+				external.Default = true
+			case "forced":
+				external.Forced = true
+			default:
+				if language, ok := externalLanguages[tag]; ok && external.Language == "" {
+					external.Language = language
+				}
+			}
+		}
+		externals = append(externals, external)
+	}
+	slices.SortFunc(externals, func(a, b External) int { return strings.Compare(a.Name, b.Name) })
+	return externals
+}
@@ -0,0 +1,36 @@
+package subtitle_test
+
+import (
+	"MediaWarp/internal/subtitle"
+	"testing"
+)
+
+func TestMatchExternal(t *testing.T) {
+	names := []string{
+		"Movie.2024.mkv",
+		"movie.2024.srt",
+		"Movie.2024.zh-CN.ass",
+		"Movie.2024.chs.forced.vtt",
+		"Movie.2024.eng.default.ssa",
+		"Movie.2024.jpg",
+		"Movie.2024.idx",
+		"Movie.20245.srt",
+		"Other.srt",
+	}
+	want := []subtitle.External{
+		{Name: "Movie.2024.chs.forced.vtt", Format: subtitle.FormatVTT, Language: "chi", Title: "chs.forced", Forced: true},
+		{Name: "Movie.2024.eng.default.ssa", Format: subtitle.FormatSSA, Language: "eng", Title: "eng.default", Default: true},
+		{Name: "Movie.2024.zh-CN.ass", Format: subtitle.FormatASS, Language: "chi", Title: "zh-CN"},
+		{Name: "movie.2024.srt", Format: subtitle.FormatSRT},
+	}
+
+	got := subtitle.MatchExternal("Movie.2024.mkv", names)
+	if len(got) != len(want) {
+		t.Fatalf("期望匹配 %d 个字幕，实际: %+v", len(want), got)
+	}
+	for i := range want {
+		if got[i] != want[i] {
+			t.Errorf("第 %d 个字幕错误。期望: %+v, 实际: %+v", i, want[i], got[i])
+		}
+	}
+}
@@ -0,0 +1,181 @@
+package subtitle
+
+import (
+	"MediaWarp/internal/config"
+	"MediaWarp/internal/logging"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+)
+
+var ErrFontNotFound = errors.New("字体目录中没有该字体")
+
+// 字体文件中的一个字体
+type fontFile struct {
+	path    string
+	index   int  // TTC 字体集合中的序号
+	regular bool // 是否为常规字重
+}
+
+var (
+	fontMutex sync.RWMutex
+	fonts     map[string]fontFile // 小写的字体名称 -> 字体文件
+)
+
+// 初始化字幕字体
+func Init() error {
+	publish, err := Prepare(config.Get())
+	if err != nil {
+		return err
+	}
+	publish()
+	return nil
+}
+
+// 启用字体子集化时扫描字体目录，返回的函数用于替换当前使用的字体索引
+func Prepare(cfg *config.Setting) (func(), error) {
+	var index map[string]fontFile
+	if cfg.Subtitle.Enable && cfg.Subtitle.SubSet {
+		var err error
+		if index, err = scanFonts(cfg.Subtitle.FontDir); err != nil {
+			return nil, err
+		}
+		logging.Infof("字体目录 %s 中共有 %d 个字体名称", cfg.Subtitle.FontDir, len(index))
+	}
+
+	return func() {
+		fontMutex.Lock()
+		fonts = index
+		fontMutex.Unlock()
+	}, nil
+}
+
+// 扫描目录中的 TTF、OTF、TTC 字体
+//
+// 同名字体优先使用常规字重，其次使用最先找到的文件，无法解析的文件会被跳过
+func scanFonts(dir string) (map[string]fontFile, error) {
+	index := make(map[string]fontFile)
+	err := filepath.WalkDir(dir, func(path string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		switch strings.ToLower(filepath.Ext(path)) {
+		case ".ttf", ".otf", ".ttc":
+		default:
+			return nil
+		}
+		faces, err := readFontNames(path)
+		if err != nil {
+			logging.Warningf("读取字体 %s 失败：%v", path, err)
+			return nil
+		}
+		for i, face := range faces {
+			for _, name := range face.names {
+				key := strings.ToLower(name)
+				if file, ok := index[key]; !ok || (face.regular && !file.regular) {
+					index[key] = fontFile{path: path, index: i, regular: face.regular}
+				}
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("扫描字体目录失败: %w", err)
+	}
+	return index, nil
+}
+
+// 字体名称
+type fontNames struct {
+	names   []string
+	regular bool
+}
+
+// 读取字体文件中各个字体的名称
+//
+// 只读取表目录和 name 表，不会读取整个字体文件
+func readFontNames(path string) ([]fontNames, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer file.Close()
+
+	readAt := func(offset int64, size int) ([]byte, error) {
+		buf := make([]byte, size)
+		if _, err := file.ReadAt(buf, offset); err != nil {
+			if errors.Is(err, io.EOF) {
+				return nil, ErrInvalidFont
+			}
+			return nil, err
+		}
+		return buf, nil
+	}
+
+	header, err := readAt(0, 12)
+	if err != nil {
+		return nil, err
+	}
+	offsets := []int64{0}
+	if string(header[:4]) == "ttcf" {
+		numFonts := int(binary.BigEndian.Uint32(header[8:]))
+		data, err := readAt(12, 4*numFonts)
+		if err != nil {
+			return nil, err
+		}
+		offsets = offsets[:0]
+		for i := range numFonts {
+			offsets = append(offsets, int64(binary.BigEndian.Uint32(data[4*i:])))
+		}
+	}
+
+	faces := make([]fontNames, 0, len(offsets))
+	for _, offset := range offsets {
+		header, err := readAt(offset, 12)
+		if err != nil {
+			return nil, err
+		}
+		numTables := int(binary.BigEndian.Uint16(header[4:]))
+		records, err := readAt(offset+12, 16*numTables)
+		if err != nil {
+			return nil, err
+		}
+		var face fontNames
+		for i := range numTables {
+			record := records[16*i:]
+			if string(record[:4]) != "name" {
+				continue
+			}
+			data, err := readAt(int64(binary.BigEndian.Uint32(record[8:])), int(binary.BigEndian.Uint32(record[12:])))
+			if err != nil {
+				return nil, err
+			}
+			face.names, face.regular = parseNames(data)
+		}
+		faces = append(faces, face)
+	}
+	return faces, nil
+}
+
+// 加载字体
+//
+// 字体名称不区分大小写
+func loadFont(name string) (*sfntFace, error) {
+	fontMutex.RLock()
+	file, ok := fonts[strings.ToLower(name)]
+	fontMutex.RUnlock()
+	if !ok {
+		return nil, ErrFontNotFound
+	}
+	data, err := os.ReadFile(file.path)
+	if err != nil {
+		return nil, err
+	}
+	return parseFace(data, file.index)
+}
@@ -0,0 +1,177 @@
+package subtitle
+
+import (
+	"bytes"
+	"fmt"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// 解析 MicroDVD 字幕
+//
+// 第一条字幕为 {1}{1}23.976 时作为帧率，否则使用 DefaultFPS；| 表示换行，
+// 支持 {y:i}、{y:b}、{y:u}、{y:s} 和 {c:$BBGGRR} 控制码，小写控制码只作用于当前行
+func parseMicroDVD(text string) (*Subtitle, error) {
+	s := &Subtitle{FPS: DefaultFPS}
+	first := true
+	for _, line := range strings.Split(text, "\n") {
+		line = strings.TrimSpace(line)
+		loc := microDVDPattern.FindStringIndex(line)
+		if loc == nil {
+			continue
+		}
+		from, to, _ := strings.Cut(line[1:loc[1]-1], "}{")
+		start, err := parseUint(from)
+		if err != nil {
+			return nil, err
+		}
+		end := start
+		if to != "" {
+			if end, err = parseUint(to); err != nil {
+				return nil, err
+			}
+		}
+		body := line[loc[1]:]
+		if first {
+			first = false
+			if fps, err := strconv.ParseFloat(body, 64); err == nil && fps > 0 && start <= 1 && end <= 1 {
+				s.FPS = fps
+				continue
+			}
+		}
+		s.Cues = append(s.Cues, &Cue{
+			Start: frameTime(start, s.FPS),
+			End:   frameTime(end, s.FPS),
+			Text:  runsToASS(parseMicroDVDText(body), 0),
+		})
+	}
+	if len(s.Cues) == 0 {
+		return nil, ErrUnknownFormat
+	}
+	return s, nil
+}
+
+// 解析 MicroDVD 文本中的控制码
+func parseMicroDVDText(text string) []run {
+	var (
+		runs   []run
+		global run // 大写控制码作用于所有行
+	)
+	for i, line := range strings.Split(text, "|") {
+		current := global
+		for strings.HasPrefix(line, "{") {
+			end := strings.IndexByte(line, '}')
+			if end < 0 {
+				break
+			}
+			code, value, ok := strings.Cut(line[1:end], ":")
+			if !ok {
+				break
+			}
+			target := &current
+			if code == strings.ToUpper(code) {
+				target = &global
+			}
+			switch strings.ToLower(code) {
+			case "y":
+				for _, c := range strings.ToLower(value) {
+					switch c {
+					case 'i':
+						target.italic = true
+					case 'b':
+						target.bold = true
+					case 'u':
+						target.underline = true
+					case 's':
+						target.strike = true
+					}
+				}
+			case "c":
+				target.color = parseTagColor(strings.TrimPrefix(value, "$"))
+			}
+			if target == &global { // 大写控制码同时作用于当前行
+				current.italic, current.bold, current.underline, current.strike = current.italic || global.italic, current.bold || global.bold, current.underline || global.underline, current.strike || global.strike
+				if global.color != "" {
+					current.color = global.color
+				}
+			}
+			line = line[end+1:]
+		}
+		if i > 0 {
+			line = "\n" + line
+		}
+		current.text = line
+		if n := len(runs); n > 0 && runs[n-1].sameStyle(current) {
+			runs[n-1].text += current.text
+		} else {
+			runs = append(runs, current)
+		}
+	}
+	return runs
+}
+
+// 生成 MicroDVD 字幕
+//
+// 第一行写入帧率，每行字幕使用小写控制码设置整行的样式
+func (s *Subtitle) marshalMicroDVD() []byte {
+	fps := s.FPS
+	if fps <= 0 {
+		fps = DefaultFPS
+	}
+	var b bytes.Buffer
+	fmt.Fprintf(&b, "{1}{1}%s\n", formatFloat(fps))
+	for _, cue := range s.Cues {
+		runs, _ := parseRuns(cue.Text)
+		var lines []string
+		var line strings.Builder
+		var style run
+		flush := func() {
+			var codes strings.Builder
+			var y string
+			for _, c := range []struct {
+				on   bool
+				code string
+			}{{style.italic, "i"}, {style.bold, "b"}, {style.underline, "u"}, {style.strike, "s"}} {
+				if c.on {
+					y += c.code
+				}
+			}
+			if y != "" {
+				codes.WriteString("{y:" + y + "}")
+			}
+			if style.color != "" {
+				codes.WriteString("{c:$" + strings.Trim(assTagColor(style.color), "&H") + "}")
+			}
+			lines = append(lines, codes.String()+line.String())
+			line.Reset()
+			style = run{}
+		}
+		for _, r := range runs {
+			for i, part := range strings.Split(r.text, "\n") {
+				if i > 0 {
+					flush()
+				}
+				if part != "" && line.Len() == 0 { // 每行只能有一种样式，使用第一段文本的样式
+					style = r
+				}
+				line.WriteString(part)
+			}
+		}
+		flush()
+		fmt.Fprintf(&b, "{%d}{%d}%s\n", timeFrame(cue.Start, fps), timeFrame(cue.End, fps), strings.Join(lines, "|"))
+	}
+	return b.Bytes()
+}
+
+func frameTime(frame int, fps float64) time.Duration {
+	return time.Duration(float64(frame) / fps * float64(time.Second))
+}
+
+func timeFrame(d time.Duration, fps float64) int {
+	if d < 0 {
+		return 0
+	}
+	return int(math.Round(d.Seconds() * fps))
+}
@@ -0,0 +1,434 @@
+package subtitle
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
+	"unicode/utf16"
+)
+
+var (
+	ErrInvalidFont     = errors.New("无效的字体文件")
+	ErrUnsupportedFont = errors.New("仅支持 TrueType 轮廓（glyf）字体的子集化")
+)
+
+// 字体中的表
+type sfntTable struct {
+	tag  string
+	data []byte
+}
+
+// 字体文件中的一个字体（TTC 字体集合中包含多个字体）
+type sfntFace struct {
+	tables map[string][]byte
+}
+
+// 解析字体文件中的第 index 个字体
+//
+// 支持 TTF、OTF 和 TTC 字体集合
+func parseFace(data []byte, index int) (*sfntFace, error) {
+	offset := 0
+	if len(data) >= 12 && string(data[:4]) == "ttcf" {
+		numFonts := int(binary.BigEndian.Uint32(data[8:]))
+		if index >= numFonts || 12+4*numFonts > len(data) {
+			return nil, ErrInvalidFont
+		}
+		offset = int(binary.BigEndian.Uint32(data[12+4*index:]))
+	} else if index != 0 {
+		return nil, ErrInvalidFont
+	}
+	if offset+12 > len(data) {
+		return nil, ErrInvalidFont
+	}
+
+	numTables := int(binary.BigEndian.Uint16(data[offset+4:]))
+	if offset+12+16*numTables > len(data) {
+		return nil, ErrInvalidFont
+	}
+	face := sfntFace{tables: make(map[string][]byte, numTables)}
+	for i := range numTables {
+		record := data[offset+12+16*i:]
+		tableOffset := int(binary.BigEndian.Uint32(record[8:]))
+		tableLength := int(binary.BigEndian.Uint32(record[12:]))
+		if tableOffset < 0 || tableLength < 0 || tableOffset+tableLength > len(data) {
+			return nil, ErrInvalidFont
+		}
+		face.tables[string(record[:4])] = data[tableOffset : tableOffset+tableLength]
+	}
+	return &face, nil
+}
+
+// 解析 name 表中的字体名称
+//
+// 返回字体家族名称（nameID 1、16）和完整名称（nameID 4），以及是否为常规字重（nameID 2）
+func parseNames(data []byte) (names []string, regular bool) {
+	if len(data) < 6 {
+		return nil, false
+	}
+	count := int(binary.BigEndian.Uint16(data[2:]))
+	storage := int(binary.BigEndian.Uint16(data[4:]))
+	for i := range count {
+		if 6+12*(i+1) > len(data) {
+			break
+		}
+		record := data[6+12*i:]
+		platformID := binary.BigEndian.Uint16(record)
+		nameID := binary.BigEndian.Uint16(record[6:])
+		length := int(binary.BigEndian.Uint16(record[8:]))
+		offset := storage + int(binary.BigEndian.Uint16(record[10:]))
+		if nameID > 16 || offset+length > len(data) {
+			continue
+		}
+		raw := data[offset : offset+length]
+
+		var name string
+		switch platformID {
+		case 0, 3: // Unicode、Windows 平台使用 UTF-16BE 编码
+			units := make([]uint16, len(raw)/2)
+			for j := range units {
+				units[j] = binary.BigEndian.Uint16(raw[2*j:])
+			}
+			name = string(utf16.Decode(units))
+		case 1: // Macintosh 平台只使用 ASCII 名称
+			if slices.ContainsFunc(raw, func(b byte) bool { return b >= 0x80 }) {
+				continue
+			}
+			name = string(raw)
+		default:
+			continue
+		}
+
+		switch nameID {
+		case 1, 4, 16:
+			if name != "" && !slices.Contains(names, name) {
+				names = append(names, name)
+			}
+		case 2:
+			switch strings.ToLower(name) {
+			case "regular", "normal", "book", "roman", "standard":
+				regular = true
+			}
+		}
+	}
+	return names, regular
+}
+
+// 查找字符对应的字形
+//
+// 依次使用 Unicode 完整字符集（format 12）和 BMP（format 4）子表，未找到的字符不包含在结果中
+func (face *sfntFace) glyphIDs(runes []rune) (map[rune]uint16, error) {
+	cmap := face.tables["cmap"]
+	if len(cmap) < 4 {
+		return nil, ErrInvalidFont
+	}
+	var format4, format12 []byte
+	numTables := int(binary.BigEndian.Uint16(cmap[2:]))
+	for i := range numTables {
+		if 4+8*(i+1) > len(cmap) {
+			return nil, ErrInvalidFont
+		}
+		record := cmap[4+8*i:]
+		platformID, encodingID := binary.BigEndian.Uint16(record), binary.BigEndian.Uint16(record[2:])
+		offset := int(binary.BigEndian.Uint32(record[4:]))
+		if offset+2 > len(cmap) || !(platformID == 0 || (platformID == 3 && (encodingID == 1 || encodingID == 10))) {
+			continue
+		}
+		switch binary.BigEndian.Uint16(cmap[offset:]) {
+		case 4:
+			format4 = cmap[offset:]
+		case 12:
+			format12 = cmap[offset:]
+		}
+	}
+
+	glyphs := make(map[rune]uint16, len(runes))
+	for _, r := range runes {
+		var (
+			gid uint16
+			err error
+		)
+		if format12 != nil {
+			gid, err = lookupFormat12(format12, r)
+		} else if format4 != nil {
+			gid, err = lookupFormat4(format4, r)
+		} else {
+			return nil, fmt.Errorf("%w：缺少 Unicode 字符映射表", ErrInvalidFont)
+		}
+		if err != nil {
+			return nil, err
+		}
+		if gid != 0 {
+			glyphs[r] = gid
+		}
+	}
+	return glyphs, nil
+}
+
+func lookupFormat4(table []byte, r rune) (uint16, error) {
+	if r > 0xFFFF {
+		return 0, nil
+	}
+	if len(table) < 14 {
+		return 0, ErrInvalidFont
+	}
+	segCountX2 := int(binary.BigEndian.Uint16(table[6:]))
+	if 16+4*segCountX2 > len(table) {
+		return 0, ErrInvalidFont
+	}
+	c := uint16(r)
+	for i := 0; i < segCountX2; i += 2 {
+		end := binary.BigEndian.Uint16(table[14+i:])
+		if end < c {
+			continue
+		}
+		start := binary.BigEndian.Uint16(table[16+segCountX2+i:])
+		if start > c {
+			return 0, nil
+		}
+		delta := binary.BigEndian.Uint16(table[16+2*segCountX2+i:])
+		rangeOffsetPos := 16 + 3*segCountX2 + i
+		rangeOffset := int(binary.BigEndian.Uint16(table[rangeOffsetPos:]))
+		if rangeOffset == 0 {
+			return c + delta, nil
+		}
+		pos := rangeOffsetPos + rangeOffset + 2*int(c-start)
+		if pos+2 > len(table) {
+			return 0, ErrInvalidFont
+		}
+		gid := binary.BigEndian.Uint16(table[pos:])
+		if gid == 0 {
+			return 0, nil
+		}
+		return gid + delta, nil
+	}
+	return 0, nil
+}
+
+func lookupFormat12(table []byte, r rune) (uint16, error) {
+	if len(table) < 16 {
+		return 0, ErrInvalidFont
+	}
+	numGroups := int(binary.BigEndian.Uint32(table[12:]))
+	if 16+12*numGroups > len(table) {
+		return 0, ErrInvalidFont
+	}
+	c := uint32(r)
+	lo, hi := 0, numGroups
+	for lo < hi { // 分组按起始字符升序排列
+		mid := (lo + hi) / 2
+		group := table[16+12*mid:]
+		start, end := binary.BigEndian.Uint32(group), binary.BigEndian.Uint32(group[4:])
+		switch {
+		case c < start:
+			hi = mid
+		case c > end:
+			lo = mid + 1
+		default:
+			return uint16(binary.BigEndian.Uint32(group[8:]) + c - start), nil
+		}
+	}
+	return 0, nil
+}
+
+// 生成只包含指定字符的字体
+//
+// 保留原字体的字形编号和除 glyf、loca 以外的表，只清空未使用字形的轮廓数据，
+// 因此不需要重建 cmap、hmtx 等表，字体中的 CJK 字形轮廓通常占据绝大部分体积
+func (face *sfntFace) subset(runes []rune) ([]byte, error) {
+	glyf, loca, head, maxp := face.tables["glyf"], face.tables["loca"], face.tables["head"], face.tables["maxp"]
+	if glyf == nil || loca == nil {
+		return nil, ErrUnsupportedFont
+	}
+	if len(head) < 54 || len(maxp) < 6 {
+		return nil, ErrInvalidFont
+	}
+	numGlyphs := int(binary.BigEndian.Uint16(maxp[4:]))
+	longLoca := binary.BigEndian.Uint16(head[50:]) == 1
+	glyphData := func(gid int) ([]byte, error) {
+		var start, end int
+		if longLoca {
+			if 4*(gid+2) > len(loca) {
+				return nil, ErrInvalidFont
+			}
+			start, end = int(binary.BigEndian.Uint32(loca[4*gid:])), int(binary.BigEndian.Uint32(loca[4*gid+4:]))
+		} else {
+			if 2*(gid+2) > len(loca) {
+				return nil, ErrInvalidFont
+			}
+			start, end = 2*int(binary.BigEndian.Uint16(loca[2*gid:])), 2*int(binary.BigEndian.Uint16(loca[2*gid+2:]))
+		}
+		if start > end || end > len(glyf) {
+			return nil, ErrInvalidFont
+		}
+		return glyf[start:end], nil
+	}
+
+	glyphs, err := face.glyphIDs(runes)
+	if err != nil {
+		return nil, err
+	}
+	keep := make([]bool, numGlyphs)
+	queue := []uint16{0} // 始终保留 .notdef
+	for _, gid := range glyphs {
+		queue = append(queue, gid)
+	}
+	for len(queue) > 0 {
+		gid := queue[len(queue)-1]
+		queue = queue[:len(queue)-1]
+		if int(gid) >= numGlyphs || keep[gid] {
+			continue
+		}
+		keep[gid] = true
+		data, err := glyphData(int(gid))
+		if err != nil {
+			return nil, err
+		}
+		components, err := compositeComponents(data)
+		if err != nil {
+			return nil, err
+		}
+		queue = append(queue, components...)
+	}
+
+	var (
+		newGlyf []byte
+		newLoca = make([]byte, 4*(numGlyphs+1))
+	)
+	for gid := range numGlyphs {
+		binary.BigEndian.PutUint32(newLoca[4*gid:], uint32(len(newGlyf)))
+		if !keep[gid] {
+			continue
+		}
+		data, _ := glyphData(gid)
+		newGlyf = append(newGlyf, data...)
+		for len(newGlyf)%4 != 0 {
+			newGlyf = append(newGlyf, 0)
+		}
+	}
+	binary.BigEndian.PutUint32(newLoca[4*numGlyphs:], uint32(len(newGlyf)))
+
+	newHead := slices.Clone(head)
+	binary.BigEndian.PutUint16(newHead[50:], 1) // 使用 32 位 loca
+	tables := make([]sfntTable, 0, len(face.tables))
+	for tag, data := range face.tables {
+		switch tag {
+		case "glyf":
+			data = newGlyf
+		case "loca":
+			data = newLoca
+		case "head":
+			data = newHead
+		case "DSIG": // 修改后签名失效
+			continue
+		}
+		tables = append(tables, sfntTable{tag: tag, data: data})
+	}
+	return writeSFNT(0x00010000, tables), nil
+}
+
+// 复合字形引用的字形
+func compositeComponents(data []byte) ([]uint16, error) {
+	if len(data) < 10 || int16(binary.BigEndian.Uint16(data)) >= 0 {
+		return nil, nil
+	}
+	const (
+		argsAreWords   = 0x0001
+		haveScale      = 0x0008
+		moreComponents = 0x0020
+		haveXYScale    = 0x0040
+		haveTwoByTwo   = 0x0080
+	)
+	var components []uint16
+	for pos := 10; ; {
+		if pos+4 > len(data) {
+			return nil, ErrInvalidFont
+		}
+		flags := binary.BigEndian.Uint16(data[pos:])
+		components = append(components, binary.BigEndian.Uint16(data[pos+2:]))
+		pos += 4
+		if flags&argsAreWords != 0 {
+			pos += 4
+		} else {
+			pos += 2
+		}
+		switch {
+		case flags&haveScale != 0:
+			pos += 2
+		case flags&haveXYScale != 0:
+			pos += 4
+		case flags&haveTwoByTwo != 0:
+			pos += 8
+		}
+		if flags&moreComponents == 0 {
+			return components, nil
+		}
+	}
+}
+
+// 生成字体文件
+//
+// 表按标签排序，并重新计算校验和
+func writeSFNT(version uint32, tables []sfntTable) []byte {
+	slices.SortFunc(tables, func(a, b sfntTable) int {
+		switch {
+		case a.tag < b.tag:
+			return -1
+		case a.tag > b.tag:
+			return 1
+		}
+		return 0
+	})
+
+	numTables := len(tables)
+	entrySelector := 0
+	for 1<<(entrySelector+1) <= numTables {
+		entrySelector++
+	}
+	searchRange := 16 << entrySelector
+
+	size := 12 + 16*numTables
+	for _, table := range tables {
+		size += (len(table.data) + 3) &^ 3
+	}
+	out := make([]byte, 12+16*numTables, size)
+	binary.BigEndian.PutUint32(out, version)
+	binary.BigEndian.PutUint16(out[4:], uint16(numTables))
+	binary.BigEndian.PutUint16(out[6:], uint16(searchRange))
+	binary.BigEndian.PutUint16(out[8:], uint16(entrySelector))
+	binary.BigEndian.PutUint16(out[10:], uint16(16*numTables-searchRange))
+
+	headOffset := -1
+	for i, table := range tables {
+		data := table.data
+		if table.tag == "head" {
+			data = slices.Clone(data)
+			binary.BigEndian.PutUint32(data[8:], 0) // 计算校验和时 checkSumAdjustment 为 0
+			headOffset = len(out)
+		}
+		record := out[12+16*i:]
+		copy(record, table.tag)
+		binary.BigEndian.PutUint32(record[4:], checksum(data))
+		binary.BigEndian.PutUint32(record[8:], uint32(len(out)))
+		binary.BigEndian.PutUint32(record[12:], uint32(len(data)))
+		out = append(out, data...)
+		for len(out)%4 != 0 {
+			out = append(out, 0)
+		}
+	}
+	if headOffset >= 0 {
+		binary.BigEndian.PutUint32(out[headOffset+8:], 0xB1B0AFBA-checksum(out))
+	}
+	return out
+}
+
+func checksum(data []byte) uint32 {
+	var sum uint32
+	for i := 0; i < len(data); i += 4 {
+		var word [4]byte
+		copy(word[:], data[i:])
+		sum += binary.BigEndian.Uint32(word[:])
+	}
+	return sum
+}
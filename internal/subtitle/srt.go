@@ -0,0 +1,82 @@
+package subtitle
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// 解析 SRT 字幕
+//
+// 序号可以缺失，时间行之后到空行之前的内容为字幕文本
+func parseSRT(text string) (*Subtitle, error) {
+	s := &Subtitle{}
+	lines := strings.Split(text, "\n")
+	for i := 0; i < len(lines); i++ {
+		line := strings.TrimSpace(lines[i])
+		if !srtTimeLinePattern.MatchString(line) {
+			continue
+		}
+		start, end, err := parseTimeRange(line)
+		if err != nil {
+			return nil, err
+		}
+		var body []string
+		for i+1 < len(lines) && strings.TrimSpace(lines[i+1]) != "" {
+			if srtTimeLinePattern.MatchString(lines[i+1]) { // 缺少空行分隔
+				if n := len(body); n > 0 && isIndex(body[n-1]) {
+					body = body[:n-1]
+				}
+				break
+			}
+			i++
+			body = append(body, strings.TrimRight(lines[i], " \t"))
+		}
+		runs, _ := parseHTML(strings.Join(body, "\n"), false)
+		s.Cues = append(s.Cues, &Cue{Start: start, End: end, Text: runsToASS(runs, 0)})
+	}
+	if len(s.Cues) == 0 {
+		return nil, ErrUnknownFormat
+	}
+	return s, nil
+}
+
+// 解析 SRT、WebVTT 时间行，返回开始和结束时间
+func parseTimeRange(line string) (start, end time.Duration, err error) {
+	from, to, ok := strings.Cut(line, "-->")
+	if !ok {
+		return 0, 0, fmt.Errorf("无效的时间行: %q", line)
+	}
+	if start, err = parseClock(from); err != nil {
+		return 0, 0, err
+	}
+	fields := strings.Fields(to) // WebVTT 的时间之后是设置
+	if len(fields) == 0 {
+		return 0, 0, fmt.Errorf("无效的时间行: %q", line)
+	}
+	end, err = parseClock(fields[0])
+	return start, end, err
+}
+
+func isIndex(line string) bool {
+	_, err := parseUint(strings.TrimSpace(line))
+	return err == nil
+}
+
+// 生成 SRT 字幕
+//
+// 支持 b、i、u、s 和 font color 标签，非底部居中的对齐方式使用 {\anN} 标签
+func (s *Subtitle) marshalSRT() []byte {
+	var b bytes.Buffer
+	for i, cue := range s.Cues {
+		runs, alignment := parseRuns(cue.Text)
+		fmt.Fprintf(&b, "%d\n%s --> %s\n", i+1, formatTime(cue.Start, 2, ",", 3), formatTime(cue.End, 2, ",", 3))
+		if alignment != 0 && alignment != 2 {
+			fmt.Fprintf(&b, `{\an%d}`, alignment)
+		}
+		b.WriteString(runsToHTML(runs, false))
+		b.WriteString("\n\n")
+	}
+	return b.Bytes()
+}
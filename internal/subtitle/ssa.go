@@ -0,0 +1,433 @@
+package subtitle
+
+import (
+	"bytes"
+	"fmt"
+	"slices"
+	"strconv"
+	"strings"
+)
+
+var (
+	assStyleFormat = []string{"Name", "Fontname", "Fontsize", "PrimaryColour", "SecondaryColour", "OutlineColour", "BackColour", "Bold", "Italic", "Underline", "StrikeOut", "ScaleX", "ScaleY", "Spacing", "Angle", "BorderStyle", "Outline", "Shadow", "Alignment", "MarginL", "MarginR", "MarginV", "Encoding"}
+	ssaStyleFormat = []string{"Name", "Fontname", "Fontsize", "PrimaryColour", "SecondaryColour", "TertiaryColour", "BackColour", "Bold", "Italic", "BorderStyle", "Outline", "Shadow", "Alignment", "MarginL", "MarginR", "MarginV", "AlphaLevel", "Encoding"}
+	assEventFormat = []string{"Layer", "Start", "End", "Style", "Name", "MarginL", "MarginR", "MarginV", "Effect", "Text"}
+	ssaEventFormat = []string{"Marked", "Start", "End", "Style", "Name", "MarginL", "MarginR", "MarginV", "Effect", "Text"}
+	infoOrder      = []string{"Title", "ScriptType", "WrapStyle", "ScaledBorderAndShadow", "PlayResX", "PlayResY"} // 写入 [Script Info] 的顺序，其余属性按名称排序
+)
+
+// 解析 ASS、SSA 字幕
+//
+// 按 Format 行解析样式和对话，忽略 Comment 和 [Fonts]、[Graphics] 段
+func parseSSA(text string) (*Subtitle, error) {
+	s := &Subtitle{Info: make(map[string]string)}
+	var (
+		section     string
+		legacy      bool // SSA 使用旧的对齐方式
+		styleFormat []string
+		eventFormat = splitFormat(strings.Join(assEventFormat, ","))
+	)
+	for _, line := range strings.Split(text, "\n") {
+		line = strings.TrimSpace(line)
+		if line == "" || strings.HasPrefix(line, ";") {
+			continue
+		}
+		if strings.HasPrefix(line, "[") && strings.HasSuffix(line, "]") {
+			section = strings.ToLower(line)
+			if section == "[v4 styles]" {
+				legacy = true
+			}
+			continue
+		}
+		key, value, ok := strings.Cut(line, ":")
+		if !ok {
+			continue
+		}
+		key, value = strings.TrimSpace(key), strings.TrimSpace(value)
+
+		switch {
+		case section == "[script info]":
+			s.Info[key] = value
+			if strings.EqualFold(key, "ScriptType") && !strings.Contains(strings.ToLower(value), "v4.00+") {
+				legacy = true
+			}
+		case strings.HasPrefix(section, "[v4") && strings.Contains(section, "styles"):
+			switch strings.ToLower(key) {
+			case "format":
+				styleFormat = splitFormat(value)
+			case "style":
+				style, err := parseStyle(splitFields(value, len(styleFormat)), styleFormat, legacy)
+				if err != nil {
+					return nil, err
+				}
+				s.Styles = append(s.Styles, style)
+			}
+		case section == "[events]":
+			switch strings.ToLower(key) {
+			case "format":
+				eventFormat = splitFormat(value)
+			case "dialogue":
+				cue, err := parseEvent(splitFields(value, len(eventFormat)), eventFormat)
+				if err != nil {
+					return nil, err
+				}
+				if legacy {
+					cue.Text = convertLegacyAlignment(cue.Text)
+				}
+				s.Cues = append(s.Cues, cue)
+			}
+		}
+	}
+	delete(s.Info, "ScriptType") // 写入时按格式重新生成
+	return s, nil
+}
+
+func parseStyle(fields []string, format []string, legacy bool) (*Style, error) {
+	style := DefaultStyle()
+	for i, name := range format {
+		if i >= len(fields) {
+			break
+		}
+		value := strings.TrimSpace(fields[i])
+		var err error
+		switch name {
+		case "name":
+			style.Name = value
+		case "fontname":
+			style.FontName = value
+		case "fontsize":
+			style.FontSize, err = strconv.ParseFloat(value, 64)
+		case "primarycolour":
+			style.PrimaryColour, err = parseColor(value)
+		case "secondarycolour":
+			style.SecondaryColour, err = parseColor(value)
+		case "outlinecolour", "tertiarycolour":
+			style.OutlineColour, err = parseColor(value)
+		case "backcolour":
+			style.BackColour, err = parseColor(value)
+		case "bold":
+			style.Bold = value != "0"
+		case "italic":
+			style.Italic = value != "0"
+		case "underline":
+			style.Underline = value != "0"
+		case "strikeout":
+			style.StrikeOut = value != "0"
+		case "scalex":
+			style.ScaleX, err = strconv.ParseFloat(value, 64)
+		case "scaley":
+			style.ScaleY, err = strconv.ParseFloat(value, 64)
+		case "spacing":
+			style.Spacing, err = strconv.ParseFloat(value, 64)
+		case "angle":
+			style.Angle, err = strconv.ParseFloat(value, 64)
+		case "borderstyle":
+			style.BorderStyle, err = strconv.Atoi(value)
+		case "outline":
+			style.Outline, err = strconv.ParseFloat(value, 64)
+		case "shadow":
+			style.Shadow, err = strconv.ParseFloat(value, 64)
+		case "alignment":
+			style.Alignment, err = strconv.Atoi(value)
+			if legacy {
+				style.Alignment = legacyAlignment(style.Alignment)
+			}
+		case "marginl":
+			style.MarginL, err = strconv.Atoi(value)
+		case "marginr":
+			style.MarginR, err = strconv.Atoi(value)
+		case "marginv":
+			style.MarginV, err = strconv.Atoi(value)
+		case "encoding":
+			style.Encoding, err = strconv.Atoi(value)
+		}
+		if err != nil {
+			return nil, fmt.Errorf("样式 %s 的 %s 无效: %q", style.Name, name, value)
+		}
+	}
+	return style, nil
+}
+
+func parseEvent(fields []string, format []string) (*Cue, error) {
+	cue := &Cue{}
+	for i, name := range format {
+		if i >= len(fields) {
+			break
+		}
+		value := fields[i]
+		if name != "text" {
+			value = strings.TrimSpace(value)
+		}
+		var err error
+		switch name {
+		case "layer":
+			cue.Layer, err = strconv.Atoi(value)
+		case "start":
+			cue.Start, err = parseClock(value)
+		case "end":
+			cue.End, err = parseClock(value)
+		case "style":
+			cue.Style = value
+		case "name", "actor":
+			cue.Name = value
+		case "marginl":
+			cue.MarginL, err = strconv.Atoi(value)
+		case "marginr":
+			cue.MarginR, err = strconv.Atoi(value)
+		case "marginv":
+			cue.MarginV, err = strconv.Atoi(value)
+		case "effect":
+			cue.Effect = value
+		case "text":
+			cue.Text = value
+		}
+		if err != nil {
+			return nil, fmt.Errorf("对话的 %s 无效: %q", name, value)
+		}
+	}
+	return cue, nil
+}
+
+// 将对话中 SSA 的 \a 对齐标签转换为 \an
+func convertLegacyAlignment(text string) string {
+	if !strings.Contains(text, `\a`) {
+		return text
+	}
+	var b strings.Builder
+	for i := 0; i < len(text); i++ {
+		if text[i] == '\\' && i+2 < len(text) && text[i+1] == 'a' && text[i+2] >= '0' && text[i+2] <= '9' {
+			j := i + 2
+			for j < len(text) && text[j] >= '0' && text[j] <= '9' {
+				j++
+			}
+			n, _ := strconv.Atoi(text[i+2 : j])
+			fmt.Fprintf(&b, `\an%d`, legacyAlignment(n))
+			i = j - 1
+			continue
+		}
+		b.WriteByte(text[i])
+	}
+	return b.String()
+}
+
+// 解析 ASS 的 &HAABBGGRR 或 SSA 的十进制颜色
+func parseColor(value string) (uint32, error) {
+	value = strings.TrimSpace(value)
+	if hex, ok := strings.CutPrefix(strings.ToUpper(value), "&H"); ok {
+		n, err := strconv.ParseUint(strings.TrimSuffix(hex, "&"), 16, 32)
+		return uint32(n), err
+	}
+	n, err := strconv.ParseInt(value, 10, 64)
+	return uint32(n), err
+}
+
+// 解析 ASS 样式行
+//
+// lines 中第一行为 Format，之后为 Style，如 subtitle.ass_style 设置
+func ParseStyles(lines []string) ([]*Style, error) {
+	s, err := parseSSA("[V4+ Styles]\n" + strings.Join(lines, "\n"))
+	if err != nil {
+		return nil, err
+	}
+	return s.Styles, nil
+}
+
+// 在原始 ASS、SSA 字幕上调整对话
+//
+// adjust 对由 Dialogue 行解析出的字幕进行调整（平移时间、简繁转换等），之后只改写这些行的开始时间、结束时间和文本，
+// [Fonts]、[Graphics] 等其他段、Comment 行以及样式均原样保留；adjust 中删除的对话对应的行同样被删除
+func AdjustSSA(data []byte, adjust func(s *Subtitle)) ([]byte, error) {
+	type dialogue struct {
+		line   int
+		cue    *Cue
+		fields []string
+		format []string
+	}
+	var (
+		lines     = strings.SplitAfter(string(bytes.TrimPrefix(data, utf8BOM)), "\n")
+		section   string
+		format    = splitFormat(strings.Join(assEventFormat, ","))
+		dialogues []dialogue
+		s         = &Subtitle{}
+	)
+	for i, line := range lines {
+		line = strings.TrimSpace(line)
+		if strings.HasPrefix(line, "[") && strings.HasSuffix(line, "]") {
+			section = strings.ToLower(line)
+			continue
+		}
+		if section != "[events]" {
+			continue
+		}
+		key, value, ok := strings.Cut(line, ":")
+		if !ok {
+			continue
+		}
+		switch strings.ToLower(strings.TrimSpace(key)) {
+		case "format":
+			format = splitFormat(value)
+		case "dialogue":
+			fields := splitFields(strings.TrimSpace(value), len(format))
+			cue, err := parseEvent(fields, format)
+			if err != nil {
+				return nil, fmt.Errorf("解析 ASS 字幕失败: %w", err)
+			}
+			dialogues = append(dialogues, dialogue{line: i, cue: cue, fields: fields, format: format})
+			s.Cues = append(s.Cues, cue)
+		}
+	}
+
+	adjust(s)
+	kept := make(map[*Cue]struct{}, len(s.Cues))
+	for _, cue := range s.Cues {
+		kept[cue] = struct{}{}
+	}
+	for _, d := range dialogues {
+		if _, ok := kept[d.cue]; !ok {
+			lines[d.line] = ""
+			continue
+		}
+		for i, name := range d.format {
+			if i >= len(d.fields) {
+				break
+			}
+			switch name {
+			case "start":
+				d.fields[i] = formatTime(d.cue.Start, 1, ".", 2)
+			case "end":
+				d.fields[i] = formatTime(d.cue.End, 1, ".", 2)
+			case "text":
+				d.fields[i] = strings.ReplaceAll(d.cue.Text, "\n", `\N`)
+			}
+		}
+		newLine := lines[d.line][len(strings.TrimRight(lines[d.line], "\r\n")):]
+		lines[d.line] = "Dialogue: " + strings.Join(d.fields, ",") + newLine
+	}
+	return []byte(strings.Join(lines, "")), nil
+}
+
+// 生成 ASS 或 SSA 字幕
+func (s *Subtitle) marshalSSA(legacy bool) []byte {
+	var b bytes.Buffer
+	newLine := func(format string, args ...any) {
+		fmt.Fprintf(&b, format, args...)
+		b.WriteString("\n")
+	}
+
+	newLine("[Script Info]")
+	info := map[string]string{"ScriptType": "v4.00+"}
+	if legacy {
+		info["ScriptType"] = "v4.00"
+	}
+	for key, value := range s.Info {
+		if key != "ScriptType" {
+			info[key] = value
+		}
+	}
+	keys := make([]string, 0, len(info))
+	for key := range info {
+		keys = append(keys, key)
+	}
+	slices.SortFunc(keys, func(a, b string) int {
+		i, j := slices.Index(infoOrder, a), slices.Index(infoOrder, b)
+		switch {
+		case i >= 0 && j >= 0:
+			return i - j
+		case i >= 0:
+			return -1
+		case j >= 0:
+			return 1
+		}
+		return strings.Compare(a, b)
+	})
+	for _, key := range keys {
+		newLine("%s: %s", key, info[key])
+	}
+	newLine("")
+
+	styles := s.Styles
+	if len(styles) == 0 {
+		styles = []*Style{DefaultStyle()}
+	}
+	if legacy {
+		newLine("[V4 Styles]")
+		newLine("Format: %s", strings.Join(ssaStyleFormat, ", "))
+	} else {
+		newLine("[V4+ Styles]")
+		newLine("Format: %s", strings.Join(assStyleFormat, ", "))
+	}
+	for _, style := range styles {
+		if legacy {
+			newLine("Style: %s,%s,%s,%d,%d,%d,%d,%s,%s,%d,%s,%s,%d,%d,%d,%d,0,%d",
+				style.Name, style.FontName, formatFloat(style.FontSize),
+				bgr(style.PrimaryColour), bgr(style.SecondaryColour), bgr(style.OutlineColour), bgr(style.BackColour),
+				assBool(style.Bold), assBool(style.Italic), style.BorderStyle, formatFloat(style.Outline), formatFloat(style.Shadow),
+				toLegacyAlignment(style.Alignment), style.MarginL, style.MarginR, style.MarginV, style.Encoding)
+		} else {
+			newLine("Style: %s,%s,%s,&H%08X,&H%08X,&H%08X,&H%08X,%s,%s,%s,%s,%s,%s,%s,%s,%d,%s,%s,%d,%d,%d,%d,%d",
+				style.Name, style.FontName, formatFloat(style.FontSize),
+				style.PrimaryColour, style.SecondaryColour, style.OutlineColour, style.BackColour,
+				assBool(style.Bold), assBool(style.Italic), assBool(style.Underline), assBool(style.StrikeOut),
+				formatFloat(style.ScaleX), formatFloat(style.ScaleY), formatFloat(style.Spacing), formatFloat(style.Angle),
+				style.BorderStyle, formatFloat(style.Outline), formatFloat(style.Shadow),
+				style.Alignment, style.MarginL, style.MarginR, style.MarginV, style.Encoding)
+		}
+	}
+	newLine("")
+
+	newLine("[Events]")
+	if legacy {
+		newLine("Format: %s", strings.Join(ssaEventFormat, ", "))
+	} else {
+		newLine("Format: %s", strings.Join(assEventFormat, ", "))
+	}
+	for _, cue := range s.Cues {
+		style := cue.Style
+		if style == "" {
+			style = styles[0].Name
+		}
+		text := cue.Text
+		first := fmt.Sprint(cue.Layer)
+		if legacy {
+			text = toLegacyAlignmentTags(text)
+			first = "Marked=0"
+		}
+		newLine("Dialogue: %s,%s,%s,%s,%s,%d,%d,%d,%s,%s",
+			first, formatTime(cue.Start, 1, ".", 2), formatTime(cue.End, 1, ".", 2),
+			style, cue.Name, cue.MarginL, cue.MarginR, cue.MarginV, cue.Effect, strings.ReplaceAll(text, "\n", `\N`))
+	}
+	return b.Bytes()
+}
+
+// 将对话中的 \an 对齐标签转换为 SSA 的 \a
+func toLegacyAlignmentTags(text string) string {
+	if !strings.Contains(text, `\an`) {
+		return text
+	}
+	var b strings.Builder
+	for i := 0; i < len(text); i++ {
+		if strings.HasPrefix(text[i:], `\an`) && i+3 < len(text) && text[i+3] >= '1' && text[i+3] <= '9' {
+			fmt.Fprintf(&b, `\a%d`, toLegacyAlignment(int(text[i+3]-'0')))
+			i += 3
+			continue
+		}
+		b.WriteByte(text[i])
+	}
+	return b.String()
+}
+
+// SSA 使用十进制的 BGR 颜色，不包含透明度
+func bgr(color uint32) uint32 {
+	return color & 0xFFFFFF
+}
+
+func assBool(v bool) string {
+	if v {
+		return "-1"
+	}
+	return "0"
+}
+
+func formatFloat(v float64) string {
+	return strconv.FormatFloat(v, 'f', -1, 64)
+}
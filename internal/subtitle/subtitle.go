@@ -0,0 +1,307 @@
+package subtitle
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"path"
+	"regexp"
+	"strings"
+	"time"
+)
+
+// 字幕格式
+type Format string
+
+const (
+	FormatSRT      Format = "srt"
+	FormatVTT      Format = "vtt"
+	FormatASS      Format = "ass"
+	FormatSSA      Format = "ssa"
+	FormatMicroDVD Format = "sub"
+	FormatTTML     Format = "ttml"
+)
+
+var ErrUnknownFormat = errors.New("无法识别的字幕格式")
+
+var (
+	utf8BOM            = []byte{0xEF, 0xBB, 0xBF}
+	srtTimeLinePattern = regexp.MustCompile(`(?m)^\s*-?\d+:\d{2}:\d{2}[,.]\d{1,3}\s*-->\s*-?\d+:\d{2}:\d{2}[,.]\d{1,3}`)
+	microDVDPattern    = regexp.MustCompile(`^\{\d+\}\{\d*\}`)
+)
+
+// 字幕
+//
+// 对话文本统一使用 ASS 标记：\N 换行，{\i1} 等覆盖标签表示样式和位置，
+// 读取其他格式时转换为 ASS 标记，写入时再转换为对应格式支持的标记
+type Subtitle struct {
+	Info   map[string]string // ASS [Script Info] 中的属性，如 PlayResX、PlayResY
+	Styles []*Style
+	Cues   []*Cue
+	FPS    float64 // MicroDVD 使用的帧率，为 0 时使用 DefaultFPS
+}
+
+// 字幕样式
+//
+// 颜色使用 ASS 的 &HAABBGGRR 格式，对齐方式使用小键盘方位（1-9）
+type Style struct {
+	Name            string
+	FontName        string
+	FontSize        float64
+	PrimaryColour   uint32
+	SecondaryColour uint32
+	OutlineColour   uint32
+	BackColour      uint32
+	Bold            bool
+	Italic          bool
+	Underline       bool
+	StrikeOut       bool
+	ScaleX          float64
+	ScaleY          float64
+	Spacing         float64
+	Angle           float64
+	BorderStyle     int
+	Outline         float64
+	Shadow          float64
+	Alignment       int
+	MarginL         int
+	MarginR         int
+	MarginV         int
+	Encoding        int
+}
+
+// 一条字幕
+type Cue struct {
+	Start   time.Duration
+	End     time.Duration
+	Layer   int
+	Style   string // 样式名称，为空时使用 Default
+	Name    string // 说话人
+	MarginL int
+	MarginR int
+	MarginV int
+	Effect  string
+	Text    string // ASS 标记的文本
+}
+
+// MicroDVD 未声明帧率时使用的帧率
+const DefaultFPS = 23.976
+
+// 默认样式
+func DefaultStyle() *Style {
+	return &Style{
+		Name:            "Default",
+		FontName:        "Arial",
+		FontSize:        20,
+		PrimaryColour:   0x00FFFFFF,
+		SecondaryColour: 0x000000FF,
+		ScaleX:          100,
+		ScaleY:          100,
+		BorderStyle:     1,
+		Outline:         2,
+		Shadow:          2,
+		Alignment:       2,
+		MarginL:         10,
+		MarginR:         10,
+		MarginV:         10,
+		Encoding:        1,
+	}
+}
+
+// 识别字幕格式
+func Detect(data []byte) Format {
+	data = bytes.TrimSpace(bytes.TrimPrefix(data, utf8BOM))
+	switch {
+	case bytes.HasPrefix(data, []byte("WEBVTT")):
+		return FormatVTT
+	case bytes.Contains(data, []byte("[Script Info]")) || bytes.Contains(data, []byte("[Events]")):
+		if bytes.Contains(bytes.ToLower(data), []byte("[v4+ styles]")) || bytes.Contains(bytes.ToLower(data), []byte("v4.00+")) {
+			return FormatASS
+		}
+		return FormatSSA
+	case bytes.HasPrefix(data, []byte("<")) && bytes.Contains(data, []byte("<tt")):
+		return FormatTTML
+	case microDVDPattern.Match(data):
+		return FormatMicroDVD
+	case srtTimeLinePattern.Match(data):
+		return FormatSRT
+	}
+	return ""
+}
+
+// 根据文件扩展名获取字幕格式
+//
+// 不支持的扩展名返回空字符串
+func FormatFromExt(name string) Format {
+	switch strings.ToLower(strings.TrimPrefix(path.Ext(name), ".")) {
+	case "srt", "subrip":
+		return FormatSRT
+	case "vtt", "webvtt":
+		return FormatVTT
+	case "ass":
+		return FormatASS
+	case "ssa":
+		return FormatSSA
+	case "sub":
+		return FormatMicroDVD
+	case "ttml", "dfxp", "xml":
+		return FormatTTML
+	}
+	return ""
+}
+
+// 字幕格式对应的 Content-Type
+func (format Format) ContentType() string {
+	switch format {
+	case FormatSRT:
+		return "application/x-subrip; charset=utf-8"
+	case FormatVTT:
+		return "text/vtt; charset=utf-8"
+	case FormatASS, FormatSSA:
+		return "text/x-ssa; charset=utf-8"
+	case FormatTTML:
+		return "application/ttml+xml; charset=utf-8"
+	}
+	return "text/plain; charset=utf-8"
+}
+
+// 解析字幕，自动识别格式
+func Parse(data []byte) (*Subtitle, Format, error) {
+	format := Detect(data)
+	if format == "" {
+		return nil, "", ErrUnknownFormat
+	}
+	s, err := ParseFormat(data, format)
+	return s, format, err
+}
+
+// 按指定格式解析字幕
+func ParseFormat(data []byte, format Format) (*Subtitle, error) {
+	text := strings.ReplaceAll(string(bytes.TrimPrefix(data, utf8BOM)), "\r\n", "\n")
+	text = strings.ReplaceAll(text, "\r", "\n")
+	var (
+		s   *Subtitle
+		err error
+	)
+	switch format {
+	case FormatSRT:
+		s, err = parseSRT(text)
+	case FormatVTT:
+		s, err = parseVTT(text)
+	case FormatASS, FormatSSA:
+		s, err = parseSSA(text)
+	case FormatMicroDVD:
+		s, err = parseMicroDVD(text)
+	case FormatTTML:
+		s, err = parseTTML(data)
+	default:
+		return nil, ErrUnknownFormat
+	}
+	if err != nil {
+		return nil, fmt.Errorf("解析 %s 字幕失败: %w", format, err)
+	}
+	return s, nil
+}
+
+// 将字幕编码为指定格式
+func (s *Subtitle) Marshal(format Format) ([]byte, error) {
+	switch format {
+	case FormatSRT:
+		return s.marshalSRT(), nil
+	case FormatVTT:
+		return s.marshalVTT(), nil
+	case FormatASS:
+		return s.marshalSSA(false), nil
+	case FormatSSA:
+		return s.marshalSSA(true), nil
+	case FormatMicroDVD:
+		return s.marshalMicroDVD(), nil
+	case FormatTTML:
+		return s.marshalTTML()
+	}
+	return nil, ErrUnknownFormat
+}
+
+// 获取样式，不存在时返回 Default 样式或默认样式
+func (s *Subtitle) Style(name string) *Style {
+	name = strings.TrimPrefix(name, "*")
+	var fallback *Style
+	for _, style := range s.Styles {
+		if style.Name == name {
+			return style
+		}
+		if strings.EqualFold(style.Name, "Default") {
+			fallback = style
+		}
+	}
+	if fallback == nil {
+		fallback = DefaultStyle()
+	}
+	return fallback
+}
+
+// 将时间格式化为 H:MM:SS 加指定分隔符和小数位
+func formatTime(d time.Duration, hourDigits int, separator string, fractionDigits int) string {
+	if d < 0 {
+		d = 0
+	}
+	unit := time.Second
+	for range fractionDigits {
+		unit /= 10
+	}
+	d = d.Round(unit)
+	hours := int(d / time.Hour)
+	minutes := int(d % time.Hour / time.Minute)
+	seconds := int(d % time.Minute / time.Second)
+	fraction := int(d % time.Second / unit)
+	return fmt.Sprintf("%0*d:%02d:%02d%s%0*d", hourDigits, hours, minutes, seconds, separator, fractionDigits, fraction)
+}
+
+// 解析 [H:]MM:SS[.,]fff 格式的时间
+func parseClock(value string) (time.Duration, error) {
+	value = strings.TrimSpace(value)
+	var sign time.Duration = 1
+	if strings.HasPrefix(value, "-") {
+		sign, value = -1, value[1:]
+	}
+	main, fraction, _ := strings.Cut(strings.ReplaceAll(value, ",", "."), ".")
+	parts := strings.Split(main, ":")
+	if len(parts) < 2 || len(parts) > 3 {
+		return 0, fmt.Errorf("无效的时间: %q", value)
+	}
+	var d time.Duration
+	for _, part := range parts {
+		n, err := parseUint(part)
+		if err != nil {
+			return 0, fmt.Errorf("无效的时间: %q", value)
+		}
+		d = d*60 + time.Duration(n)
+	}
+	d *= time.Second
+	if fraction != "" {
+		n, err := parseUint(fraction)
+		if err != nil {
+			return 0, fmt.Errorf("无效的时间: %q", value)
+		}
+		unit := time.Second
+		for range len(fraction) {
+			unit /= 10
+		}
+		d += time.Duration(n) * unit
+	}
+	return sign * d, nil
+}
+
+func parseUint(value string) (int, error) {
+	if value == "" {
+		return 0, errors.New("空数字")
+	}
+	n := 0
+	for _, c := range value {
+		if c < '0' || c > '9' {
+			return 0, fmt.Errorf("无效的数字: %q", value)
+		}
+		n = n*10 + int(c-'0')
+	}
+	return n, nil
+}
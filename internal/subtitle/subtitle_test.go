@@ -1,10 +1,10 @@
-package utils_test
+package subtitle_test
 
 import (
-	"MediaWarp/utils"
-	"bytes"
+	"MediaWarp/internal/subtitle"
 	"strings"
 	"testing"
+	"time"
 )
 
 var subtitle1 = `
@@ -373,39 +373,83 @@ var subtitle4 = `
 00:00:05,000 --> 00:00:08,000
 第二行字幕示例`
 
-type TestCase struct {
-	Text   string
-	Result bool
+var srtSamples = map[string]string{
+	"字幕1": subtitle1,
+	"字幕2": subtitle2,
+	"字幕3": subtitle3,
+	"字幕4": subtitle4,
 }
 
-var testCases = map[string]TestCase{
-	"字幕1": {subtitle1, true},
-	"字幕2": {subtitle2, true},
-	"字幕3": {subtitle3, true},
-	"字幕4": {subtitle4, true},
-}
-
-func TestIsSrt(t *testing.T) {
-
-	for caseName, testCase := range testCases {
-		t.Run(caseName, func(t *testing.T) {
-			result := utils.IsSRT([]byte(testCase.Text))
-			if testCase.Result != result {
-				t.Errorf("%s 解析错误。期望: %t, 实际: %t", caseName, testCase.Result, result)
+func TestDetectSRT(t *testing.T) {
+	for name, text := range srtSamples {
+		t.Run(name, func(t *testing.T) {
+			if format := subtitle.Detect([]byte(text)); format != subtitle.FormatSRT {
+				t.Errorf("%s 格式识别错误。期望: %s, 实际: %s", name, subtitle.FormatSRT, format)
 			}
 		})
 	}
 }
 
-func BenchmarkIsSrt(b *testing.B) {
+func BenchmarkDetect(b *testing.B) {
 	for i := 0; i < b.N; i++ {
-		for _, testCase := range testCases {
-			utils.IsSRT([]byte(testCase.Text))
+		for _, text := range srtSamples {
+			subtitle.Detect([]byte(text))
+		}
+	}
+}
+
+// 使用 SRT 字幕测试各格式之间的往返转换
+func TestSubtitleRoundTrip(t *testing.T) {
+	formats := map[subtitle.Format]time.Duration{ // 格式 -> 允许的时间误差
+		subtitle.FormatSRT:      0,
+		subtitle.FormatVTT:      0,
+		subtitle.FormatASS:      5 * time.Millisecond,
+		subtitle.FormatSSA:      5 * time.Millisecond,
+		subtitle.FormatMicroDVD: time.Second / 46,
+		subtitle.FormatTTML:     0,
+	}
+	for caseName, text := range srtSamples {
+		source, format, err := subtitle.Parse([]byte(text))
+		if err != nil {
+			t.Fatalf("%s 解析失败：%v", caseName, err)
+		}
+		if format != subtitle.FormatSRT {
+			t.Fatalf("%s 格式识别错误。期望: %s, 实际: %s", caseName, subtitle.FormatSRT, format)
+		}
+		for format, tolerance := range formats {
+			t.Run(caseName+"/"+string(format), func(t *testing.T) {
+				data, err := source.Marshal(format)
+				if err != nil {
+					t.Fatal(err)
+				}
+				if detected := subtitle.Detect(data); detected != format {
+					t.Errorf("格式识别错误。期望: %s, 实际: %s", format, detected)
+				}
+				result, err := subtitle.ParseFormat(data, format)
+				if err != nil {
+					t.Fatalf("解析失败：%v\n%s", err, data)
+				}
+				if len(result.Cues) != len(source.Cues) {
+					t.Fatalf("字幕数量错误。期望: %d, 实际: %d", len(source.Cues), len(result.Cues))
+				}
+				for i, want := range source.Cues {
+					got := result.Cues[i]
+					if got.Text != want.Text {
+						t.Errorf("第 %d 条字幕文本错误。期望: %q, 实际: %q", i+1, want.Text, got.Text)
+					}
+					if (got.Start-want.Start).Abs() > tolerance || (got.End-want.End).Abs() > tolerance {
+						t.Errorf("第 %d 条字幕时间错误。期望: %s --> %s, 实际: %s --> %s", i+1, want.Start, want.End, got.Start, got.End)
+					}
+				}
+			})
 		}
 	}
 }
 
-func TestSRT2ASS(t *testing.T) {
+// SRT 转换为 ASS 后的对话与 Python 脚本的转换结果一致
+//
+// Python 脚本使用字面量 \n 换行，ASS 标准写法为 \N
+func TestSRTToASS(t *testing.T) {
 	var (
 		srt = `1
 00:00:00,490 --> 00:00:02,290
@@ -2148,7 +2192,7 @@ func TestSRT2ASS(t *testing.T) {
 00:23:36,410 --> 00:23:39,950
 (下集 魔法劍)
 `
-		python_ass = `[Script Info]
+		pythonASS = `[Script Info]
 ; This is an Advanced Sub Station Alpha v4+ script.
 Title:
 ScriptType: v4.00+
@@ -2592,21 +2636,30 @@ Dialogue: 0,0:23:17.20,0:23:25.54,Default,,0,0,0,,(那天被愛著的“我” 
 Dialogue: 0,0:23:36.41,0:23:39.95,Default,,0,0,0,,(下集 魔法劍)
 `
 	)
-	var ass_lines []string
-	for _, line := range bytes.Split(utils.SRT2ASS([]byte(srt), []string{}), []byte("\n")) {
-		if len(line) != 0 {
-			ass_lines = append(ass_lines, string(line))
-		}
+	source, err := subtitle.ParseFormat([]byte(srt), subtitle.FormatSRT)
+	if err != nil {
+		t.Fatal(err)
 	}
-	var python_ass_line []string
-	for _, line := range strings.Split(python_ass, "\n") {
-		if line != "" {
-			python_ass_line = append(python_ass_line, line)
+	data, err := source.Marshal(subtitle.FormatASS)
+	if err != nil {
+		t.Fatal(err)
+	}
+	dialogues := func(text string) []string {
+		var lines []string
+		for _, line := range strings.Split(text, "\n") {
+			if strings.HasPrefix(line, "Dialogue:") {
+				lines = append(lines, strings.TrimSpace(line))
+			}
 		}
+		return lines
+	}
+	got, want := dialogues(string(data)), dialogues(strings.ReplaceAll(pythonASS, `\n`, `\N`))
+	if len(got) != len(want) {
+		t.Fatalf("对话数量错误。期望: %d，实际: %d", len(want), len(got))
 	}
-	for inedx, line := range ass_lines {
-		if line != python_ass_line[inedx] {
-			t.Errorf("解析有差异：\nGo：\t%s\nPython：\t%s\n", line, python_ass_line[inedx])
+	for i, line := range got {
+		if line != want[i] {
+			t.Errorf("转换结果有差异：\nGo：\t%s\nPython：\t%s", line, want[i])
 		}
 	}
 }
@@ -0,0 +1,381 @@
+package subtitle
+
+import (
+	"fmt"
+	"html"
+	"strconv"
+	"strings"
+)
+
+// 一段样式相同的文本
+type run struct {
+	text      string // 使用 \n 换行
+	italic    bool
+	bold      bool
+	underline bool
+	strike    bool
+	color     string // #RRGGBB，为空表示使用样式的颜色
+}
+
+func (r run) sameStyle(other run) bool {
+	return r.italic == other.italic && r.bold == other.bold && r.underline == other.underline && r.strike == other.strike && r.color == other.color
+}
+
+// 解析 ASS 标记的文本
+//
+// 返回按样式拆分的文本和 \an 对齐方式（0 表示未设置），忽略其他覆盖标签和绘图指令
+func parseRuns(text string) ([]run, int) {
+	var (
+		runs      []run
+		current   run
+		buf       strings.Builder
+		alignment int
+		drawing   bool
+	)
+	flush := func() {
+		if buf.Len() == 0 {
+			return
+		}
+		current.text = buf.String()
+		buf.Reset()
+		if n := len(runs); n > 0 && runs[n-1].sameStyle(current) {
+			runs[n-1].text += current.text
+		} else {
+			runs = append(runs, current)
+		}
+	}
+	flag := func(value string) (bool, bool) { // 解析 \i1、\b700 等标签的值
+		if value == "" {
+			return false, true
+		}
+		n, err := strconv.Atoi(value)
+		if err != nil {
+			return false, false
+		}
+		return n != 0, true
+	}
+
+	for i := 0; i < len(text); {
+		switch {
+		case text[i] == '{':
+			end := strings.IndexByte(text[i:], '}')
+			if end < 0 {
+				buf.WriteString(text[i:])
+				i = len(text)
+				continue
+			}
+			next := current
+			for _, tag := range strings.Split(text[i+1:i+end], `\`) {
+				tag = strings.TrimSpace(tag)
+				switch {
+				case strings.HasPrefix(tag, "an"):
+					if n, err := strconv.Atoi(tag[2:]); err == nil && n >= 1 && n <= 9 && alignment == 0 {
+						alignment = n
+					}
+				case strings.HasPrefix(tag, "a") && len(tag) > 1 && tag[1] >= '0' && tag[1] <= '9':
+					if n, err := strconv.Atoi(tag[1:]); err == nil && alignment == 0 {
+						alignment = legacyAlignment(n)
+					}
+				case strings.HasPrefix(tag, "i"):
+					if v, ok := flag(tag[1:]); ok {
+						next.italic = v
+					}
+				case strings.HasPrefix(tag, "b") && !strings.HasPrefix(tag, "blur") && !strings.HasPrefix(tag, "bord") && !strings.HasPrefix(tag, "be"):
+					if v, ok := flag(tag[1:]); ok {
+						next.bold = v
+					}
+				case strings.HasPrefix(tag, "u"):
+					if v, ok := flag(tag[1:]); ok {
+						next.underline = v
+					}
+				case strings.HasPrefix(tag, "s") && !strings.HasPrefix(tag, "shad"):
+					if v, ok := flag(tag[1:]); ok {
+						next.strike = v
+					}
+				case (strings.HasPrefix(tag, "c") && !strings.HasPrefix(tag, "clip")) || strings.HasPrefix(tag, "1c"):
+					next.color = parseTagColor(strings.TrimPrefix(strings.TrimPrefix(tag, "1"), "c"))
+				case strings.HasPrefix(tag, "r"):
+					next = run{}
+				case strings.HasPrefix(tag, "p") && len(tag) > 1 && tag[1] >= '0' && tag[1] <= '9':
+					drawing = strings.Trim(tag[1:], "0") != ""
+				}
+			}
+			if !next.sameStyle(current) {
+				flush()
+				current = next
+			}
+			i += end + 1
+			continue
+		case text[i] == '\\' && i+1 < len(text) && (text[i+1] == 'N' || text[i+1] == 'n'):
+			if !drawing {
+				buf.WriteByte('\n')
+			}
+			i += 2
+			continue
+		case text[i] == '\\' && i+1 < len(text) && text[i+1] == 'h':
+			if !drawing {
+				buf.WriteString("\u00a0")
+			}
+			i += 2
+			continue
+		}
+		if !drawing {
+			buf.WriteByte(text[i])
+		}
+		i++
+	}
+	flush()
+	return runs, alignment
+}
+
+// 将 SSA 的对齐方式转换为小键盘方位
+//
+// SSA 中 1-3 为底部，5-7 为顶部，9-11 为中间
+func legacyAlignment(n int) int {
+	switch {
+	case n >= 1 && n <= 3:
+		return n
+	case n >= 5 && n <= 7:
+		return n + 2
+	case n >= 9 && n <= 11:
+		return n - 5
+	}
+	return 2
+}
+
+// 将小键盘方位转换为 SSA 的对齐方式
+func toLegacyAlignment(n int) int {
+	switch {
+	case n >= 7 && n <= 9:
+		return n - 2
+	case n >= 4 && n <= 6:
+		return n + 5
+	case n >= 1 && n <= 3:
+		return n
+	}
+	return 2
+}
+
+// 解析 \c&HBBGGRR& 中的颜色，返回 #RRGGBB，无效时返回空字符串
+func parseTagColor(value string) string {
+	value = strings.Trim(strings.TrimPrefix(strings.TrimPrefix(value, "&H"), "&h"), "&")
+	n, err := strconv.ParseUint(value, 16, 32)
+	if value == "" || err != nil {
+		return ""
+	}
+	return fmt.Sprintf("#%02X%02X%02X", n&0xFF, n>>8&0xFF, n>>16&0xFF)
+}
+
+// 将 #RRGGBB 转换为 ASS 颜色 &HBBGGRR&
+func assTagColor(color string) string {
+	color = strings.TrimPrefix(color, "#")
+	if len(color) != 6 {
+		return ""
+	}
+	return "&H" + strings.ToUpper(color[4:6]+color[2:4]+color[0:2]) + "&"
+}
+
+// 将文本生成为 ASS 标记
+func runsToASS(runs []run, alignment int) string {
+	var (
+		b       strings.Builder
+		current run
+	)
+	if alignment != 0 && alignment != 2 {
+		fmt.Fprintf(&b, `{\an%d}`, alignment)
+	}
+	for _, r := range runs {
+		var tags strings.Builder
+		if r.italic != current.italic {
+			tags.WriteString(`\i` + boolTag(r.italic))
+		}
+		if r.bold != current.bold {
+			tags.WriteString(`\b` + boolTag(r.bold))
+		}
+		if r.underline != current.underline {
+			tags.WriteString(`\u` + boolTag(r.underline))
+		}
+		if r.strike != current.strike {
+			tags.WriteString(`\s` + boolTag(r.strike))
+		}
+		if r.color != current.color {
+			tags.WriteString(`\c` + assTagColor(r.color))
+		}
+		if tags.Len() > 0 {
+			b.WriteString("{" + tags.String() + "}")
+		}
+		b.WriteString(strings.ReplaceAll(r.text, "\n", `\N`))
+		current = r
+	}
+	return b.String()
+}
+
+func boolTag(v bool) string {
+	if v {
+		return "1"
+	}
+	return "0"
+}
+
+// 将文本生成为 SRT、WebVTT 使用的 HTML 标记
+//
+// 标签按 b、i、u、s、font 的顺序嵌套，样式变化时只关闭发生变化的标签及其内层标签，保证嵌套正确
+func runsToHTML(runs []run, vtt bool) string {
+	var (
+		b    strings.Builder
+		open []string // 已打开的标签，font 标签包含颜色
+	)
+	for _, r := range runs {
+		var tags []string
+		for _, tag := range []struct {
+			on   bool
+			name string
+		}{{r.bold, "b"}, {r.italic, "i"}, {r.underline, "u"}, {r.strike && !vtt, "s"}} {
+			if tag.on {
+				tags = append(tags, tag.name)
+			}
+		}
+		if r.color != "" && !vtt {
+			tags = append(tags, `font color="`+r.color+`"`)
+		}
+		keep := 0
+		for keep < len(open) && keep < len(tags) && open[keep] == tags[keep] {
+			keep++
+		}
+		for i := len(open) - 1; i >= keep; i-- {
+			name, _, _ := strings.Cut(open[i], " ")
+			b.WriteString("</" + name + ">")
+		}
+		for _, tag := range tags[keep:] {
+			b.WriteString("<" + tag + ">")
+		}
+		open = tags
+
+		text := r.text
+		if vtt {
+			text = strings.NewReplacer("&", "&amp;", "<", "&lt;", ">", "&gt;", "\u00a0", "&nbsp;").Replace(text)
+		}
+		b.WriteString(text)
+	}
+	for i := len(open) - 1; i >= 0; i-- {
+		name, _, _ := strings.Cut(open[i], " ")
+		b.WriteString("</" + name + ">")
+	}
+	return b.String()
+}
+
+// 去除所有标记，返回使用 \n 换行的纯文本
+func plainText(text string) string {
+	runs, _ := parseRuns(text)
+	var b strings.Builder
+	for _, r := range runs {
+		b.WriteString(r.text)
+	}
+	return b.String()
+}
+
+// 解析 SRT、WebVTT 中的 HTML 标记
+//
+// 支持 b、i、u、s、font color 和 WebVTT 的 c、v、lang、ruby 标签及时间戳，返回说话人（<v>）
+func parseHTML(text string, vtt bool) ([]run, string) {
+	var (
+		runs    []run
+		current run
+		buf     strings.Builder
+		colors  []string
+		counts  = make(map[string]int)
+		voice   string
+	)
+	flush := func() {
+		if buf.Len() == 0 {
+			return
+		}
+		current.text = buf.String()
+		if vtt {
+			current.text = html.UnescapeString(current.text)
+		}
+		buf.Reset()
+		if n := len(runs); n > 0 && runs[n-1].sameStyle(current) {
+			runs[n-1].text += current.text
+		} else {
+			runs = append(runs, current)
+		}
+	}
+
+	for i := 0; i < len(text); {
+		if text[i] != '<' {
+			buf.WriteByte(text[i])
+			i++
+			continue
+		}
+		end := strings.IndexByte(text[i:], '>')
+		if end < 0 {
+			buf.WriteString(text[i:])
+			break
+		}
+		raw := text[i+1 : i+end]
+		closing := strings.HasPrefix(raw, "/")
+		name, attrs, _ := strings.Cut(strings.TrimPrefix(raw, "/"), " ")
+		name, _, _ = strings.Cut(strings.ToLower(name), ".") // WebVTT 的 <c.yellow>
+		switch name {
+		case "b", "i", "u", "s", "font", "c", "v", "lang", "ruby", "rt":
+		default:
+			if !vtt || !isTimestamp(raw) { // 不认识的标签保留原文
+				buf.WriteString(text[i : i+end+1])
+			}
+			i += end + 1
+			continue
+		}
+		i += end + 1
+
+		flush()
+		if closing {
+			counts[name] = max(0, counts[name]-1)
+			if name == "font" && len(colors) > 0 {
+				colors = colors[:len(colors)-1]
+			}
+		} else {
+			counts[name]++
+			switch name {
+			case "font":
+				colors = append(colors, parseHTMLColor(attrs))
+			case "v":
+				if voice == "" {
+					voice = strings.TrimSpace(attrs)
+				}
+			}
+		}
+		current.bold, current.italic, current.underline, current.strike = counts["b"] > 0, counts["i"] > 0, counts["u"] > 0, counts["s"] > 0
+		current.color = ""
+		for j := len(colors) - 1; j >= 0; j-- {
+			if colors[j] != "" {
+				current.color = colors[j]
+				break
+			}
+		}
+	}
+	flush()
+	return runs, voice
+}
+
+// 解析 font 标签中的 color 属性，返回 #RRGGBB
+func parseHTMLColor(attrs string) string {
+	_, value, ok := strings.Cut(strings.ToLower(attrs), "color=")
+	if !ok {
+		return ""
+	}
+	value = strings.Trim(strings.Fields(value + " ")[0], `"'`)
+	value = strings.TrimPrefix(value, "#")
+	if len(value) == 8 { // #AARRGGBB
+		value = value[2:]
+	}
+	if _, err := strconv.ParseUint(value, 16, 32); err != nil || len(value) != 6 {
+		return ""
+	}
+	return "#" + strings.ToUpper(value)
+}
+
+func isTimestamp(value string) bool {
+	_, err := parseClock(value)
+	return err == nil
+}
@@ -0,0 +1,309 @@
+package subtitle
+
+import (
+	"bytes"
+	"encoding/xml"
+	"errors"
+	"fmt"
+	"io"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	ttmlNamespace        = "http://www.w3.org/ns/ttml"
+	ttmlStyleNamespace   = "http://www.w3.org/ns/ttml#styling"
+	ttmlParamNamespace   = "http://www.w3.org/ns/ttml#parameter"
+	ttmlDefaultTickRate  = 1
+	ttmlDefaultFrameRate = 30
+)
+
+// TTML 文档的时间参数
+type ttmlTiming struct {
+	frameRate float64
+	tickRate  float64
+}
+
+// 解析 TTML（DFXP）字幕
+//
+// 读取 p 元素的 begin、end、dur 和 span 的 fontStyle、fontWeight、textDecoration、color 样式，
+// 忽略 region 和 head 中定义的样式
+func parseTTML(data []byte) (*Subtitle, error) {
+	var (
+		s       = &Subtitle{}
+		timing  = ttmlTiming{frameRate: ttmlDefaultFrameRate, tickRate: ttmlDefaultTickRate}
+		cue     *Cue
+		runs    []run
+		styles  []run // span 样式栈
+		inHead  bool
+		decoder = xml.NewDecoder(bytes.NewReader(data))
+	)
+	decoder.Strict = false
+	decoder.CharsetReader = func(charset string, input io.Reader) (io.Reader, error) { return input, nil }
+
+	current := func() run {
+		if len(styles) == 0 {
+			return run{}
+		}
+		return styles[len(styles)-1]
+	}
+	appendText := func(text string) {
+		r := current()
+		r.text = text
+		if n := len(runs); n > 0 && runs[n-1].sameStyle(r) {
+			runs[n-1].text += text
+		} else {
+			runs = append(runs, r)
+		}
+	}
+
+	for {
+		token, err := decoder.Token()
+		if errors.Is(err, io.EOF) {
+			break
+		}
+		if err != nil {
+			return nil, err
+		}
+		switch t := token.(type) {
+		case xml.StartElement:
+			switch t.Name.Local {
+			case "tt":
+				for _, attr := range t.Attr {
+					switch attr.Name.Local {
+					case "frameRate":
+						if n, err := strconv.ParseFloat(attr.Value, 64); err == nil && n > 0 {
+							timing.frameRate = n
+						}
+					case "tickRate":
+						if n, err := strconv.ParseFloat(attr.Value, 64); err == nil && n > 0 {
+							timing.tickRate = n
+						}
+					}
+				}
+			case "head":
+				inHead = true
+			case "p":
+				if inHead {
+					continue
+				}
+				cue, runs, styles = &Cue{}, nil, []run{ttmlStyle(run{}, t.Attr)}
+				var (
+					begin, end, dur time.Duration
+					hasEnd, hasDur  bool
+				)
+				for _, attr := range t.Attr {
+					var err error
+					switch attr.Name.Local {
+					case "begin":
+						begin, err = timing.parse(attr.Value)
+					case "end":
+						end, err = timing.parse(attr.Value)
+						hasEnd = true
+					case "dur":
+						dur, err = timing.parse(attr.Value)
+						hasDur = true
+					}
+					if err != nil {
+						return nil, err
+					}
+				}
+				cue.Start, cue.End = begin, begin
+				switch {
+				case hasEnd:
+					cue.End = end
+				case hasDur:
+					cue.End = begin + dur
+				}
+			case "span":
+				if cue != nil {
+					styles = append(styles, ttmlStyle(current(), t.Attr))
+				}
+			case "br":
+				if cue != nil {
+					appendText("\n")
+				}
+			}
+		case xml.EndElement:
+			switch t.Name.Local {
+			case "head":
+				inHead = false
+			case "span":
+				if cue != nil && len(styles) > 1 {
+					styles = styles[:len(styles)-1]
+				}
+			case "p":
+				if cue == nil {
+					continue
+				}
+				cue.Text = runsToASS(trimRuns(runs), 0)
+				s.Cues = append(s.Cues, cue)
+				cue = nil
+			}
+		case xml.CharData:
+			if cue != nil {
+				appendText(collapseSpace(string(t)))
+			}
+		}
+	}
+	return s, nil
+}
+
+// 合并 XML 中的空白字符
+func collapseSpace(text string) string {
+	fields := strings.Fields(text)
+	if len(fields) == 0 {
+		if text != "" {
+			return " "
+		}
+		return ""
+	}
+	result := strings.Join(fields, " ")
+	if strings.TrimLeft(text, " \t\r\n") != text {
+		result = " " + result
+	}
+	if strings.TrimRight(text, " \t\r\n") != text {
+		result += " "
+	}
+	return result
+}
+
+// 去除每行首尾的空格
+func trimRuns(runs []run) []run {
+	var result []run
+	for i, r := range runs {
+		lines := strings.Split(r.text, "\n")
+		for j := range lines {
+			if j > 0 || i == 0 || strings.HasSuffix(runs[i-1].text, "\n") {
+				lines[j] = strings.TrimLeft(lines[j], " ")
+			}
+			if j < len(lines)-1 || i == len(runs)-1 {
+				lines[j] = strings.TrimRight(lines[j], " ")
+			}
+		}
+		if r.text = strings.Join(lines, "\n"); r.text != "" {
+			result = append(result, r)
+		}
+	}
+	return result
+}
+
+// 读取 tts 样式属性
+func ttmlStyle(parent run, attrs []xml.Attr) run {
+	r := parent
+	for _, attr := range attrs {
+		value := strings.TrimSpace(attr.Value)
+		switch attr.Name.Local {
+		case "fontStyle":
+			r.italic = value == "italic" || value == "oblique"
+		case "fontWeight":
+			r.bold = value == "bold"
+		case "textDecoration":
+			for _, decoration := range strings.Fields(value) {
+				switch decoration {
+				case "underline":
+					r.underline = true
+				case "noUnderline":
+					r.underline = false
+				case "lineThrough":
+					r.strike = true
+				case "noLineThrough":
+					r.strike = false
+				}
+			}
+		case "color":
+			if strings.HasPrefix(value, "#") {
+				r.color = parseHTMLColor("color=" + value[:min(len(value), 7)])
+			}
+		}
+	}
+	return r
+}
+
+// 解析 TTML 时间表达式
+//
+// 支持 HH:MM:SS[.fff]、HH:MM:SS:FF 和 1.5s、500ms、30f、100t 等偏移时间
+func (timing ttmlTiming) parse(value string) (time.Duration, error) {
+	value = strings.TrimSpace(value)
+	if parts := strings.Split(value, ":"); len(parts) == 4 {
+		d, err := parseClock(strings.Join(parts[:3], ":"))
+		if err != nil {
+			return 0, err
+		}
+		frames, err := strconv.ParseFloat(parts[3], 64)
+		if err != nil {
+			return 0, fmt.Errorf("无效的时间: %q", value)
+		}
+		return d + time.Duration(frames/timing.frameRate*float64(time.Second)), nil
+	}
+	if strings.Contains(value, ":") {
+		return parseClock(value)
+	}
+	for _, unit := range []struct {
+		suffix string
+		scale  float64
+	}{{"ms", 1e-3}, {"h", 3600}, {"m", 60}, {"s", 1}, {"f", 1 / timing.frameRate}, {"t", 1 / timing.tickRate}} {
+		if number, ok := strings.CutSuffix(value, unit.suffix); ok {
+			n, err := strconv.ParseFloat(number, 64)
+			if err != nil {
+				return 0, fmt.Errorf("无效的时间: %q", value)
+			}
+			return time.Duration(n * unit.scale * float64(time.Second)), nil
+		}
+	}
+	return 0, fmt.Errorf("无效的时间: %q", value)
+}
+
+// 生成 TTML 字幕
+//
+// 样式使用 span 的 tts 属性，换行使用 br
+func (s *Subtitle) marshalTTML() ([]byte, error) {
+	var b bytes.Buffer
+	b.WriteString(xml.Header)
+	fmt.Fprintf(&b, `<tt xmlns="%s" xmlns:tts="%s" xmlns:ttp="%s" ttp:timeBase="media">`, ttmlNamespace, ttmlStyleNamespace, ttmlParamNamespace)
+	b.WriteString("\n<body>\n<div>\n")
+	for _, cue := range s.Cues {
+		runs, _ := parseRuns(cue.Text)
+		fmt.Fprintf(&b, `<p begin="%s" end="%s">`, formatTime(cue.Start, 2, ".", 3), formatTime(cue.End, 2, ".", 3))
+		for _, r := range runs {
+			var attrs strings.Builder
+			if r.italic {
+				attrs.WriteString(` tts:fontStyle="italic"`)
+			}
+			if r.bold {
+				attrs.WriteString(` tts:fontWeight="bold"`)
+			}
+			var decorations []string
+			if r.underline {
+				decorations = append(decorations, "underline")
+			}
+			if r.strike {
+				decorations = append(decorations, "lineThrough")
+			}
+			if len(decorations) > 0 {
+				fmt.Fprintf(&attrs, ` tts:textDecoration="%s"`, strings.Join(decorations, " "))
+			}
+			if r.color != "" {
+				fmt.Fprintf(&attrs, ` tts:color="%s"`, r.color)
+			}
+			if attrs.Len() > 0 {
+				b.WriteString("<span" + attrs.String() + ">")
+			}
+			for i, line := range strings.Split(r.text, "\n") {
+				if i > 0 {
+					b.WriteString("<br/>")
+				}
+				if err := xml.EscapeText(&b, []byte(line)); err != nil {
+					return nil, err
+				}
+			}
+			if attrs.Len() > 0 {
+				b.WriteString("</span>")
+			}
+		}
+		b.WriteString("</p>\n")
+	}
+	b.WriteString("</div>\n</body>\n</tt>\n")
+	return b.Bytes(), nil
+}
@@ -0,0 +1,107 @@
+package subtitle
+
+import (
+	"bytes"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// 解析 WebVTT 字幕
+//
+// 跳过 NOTE、STYLE、REGION 块，cue 设置中的 line、align 转换为 \an 对齐方式
+func parseVTT(text string) (*Subtitle, error) {
+	s := &Subtitle{}
+	blocks := strings.Split(strings.TrimSpace(text), "\n\n")
+	if len(blocks) == 0 || !strings.HasPrefix(blocks[0], "WEBVTT") {
+		return nil, ErrUnknownFormat
+	}
+	for _, block := range blocks[1:] {
+		lines := strings.Split(strings.Trim(block, "\n"), "\n")
+		if len(lines) > 0 && !strings.Contains(lines[0], "-->") { // cue 标识符
+			lines = lines[1:]
+		}
+		if len(lines) == 0 || !strings.Contains(lines[0], "-->") {
+			continue
+		}
+		start, end, err := parseTimeRange(lines[0])
+		if err != nil {
+			return nil, err
+		}
+		_, settings, _ := strings.Cut(lines[0], "-->")
+		runs, voice := parseHTML(strings.Join(lines[1:], "\n"), true)
+		s.Cues = append(s.Cues, &Cue{
+			Start: start,
+			End:   end,
+			Name:  voice,
+			Text:  runsToASS(runs, vttAlignment(strings.Fields(settings)[1:])),
+		})
+	}
+	return s, nil
+}
+
+// 将 WebVTT cue 设置转换为小键盘方位
+func vttAlignment(settings []string) int {
+	row, column := 0, 2 // 0：底部，3：中间，6：顶部
+	for _, setting := range settings {
+		key, value, _ := strings.Cut(setting, ":")
+		switch key {
+		case "line":
+			value, _, _ = strings.Cut(value, ",")
+			if percent, ok := strings.CutSuffix(value, "%"); ok {
+				if n, err := strconv.ParseFloat(percent, 64); err == nil {
+					switch {
+					case n < 33:
+						row = 6
+					case n < 67:
+						row = 3
+					}
+				}
+			} else if n, err := strconv.Atoi(value); err == nil && n >= 0 { // 正数行号从顶部开始计算
+				row = 6
+			}
+		case "align":
+			switch value {
+			case "start", "left":
+				column = 1
+			case "end", "right":
+				column = 3
+			}
+		}
+	}
+	return row + column
+}
+
+// 生成 WebVTT 字幕
+//
+// 支持 b、i、u 标签，说话人使用 <v> 标签，对齐方式转换为 line、align 设置
+func (s *Subtitle) marshalVTT() []byte {
+	var b bytes.Buffer
+	b.WriteString("WEBVTT\n\n")
+	for _, cue := range s.Cues {
+		runs, alignment := parseRuns(cue.Text)
+		if alignment == 0 {
+			alignment = s.Style(cue.Style).Alignment
+		}
+		fmt.Fprintf(&b, "%s --> %s", formatTime(cue.Start, 2, ".", 3), formatTime(cue.End, 2, ".", 3))
+		switch (alignment - 1) / 3 {
+		case 2:
+			b.WriteString(" line:0")
+		case 1:
+			b.WriteString(" line:50%")
+		}
+		switch (alignment - 1) % 3 {
+		case 0:
+			b.WriteString(" align:start")
+		case 2:
+			b.WriteString(" align:end")
+		}
+		b.WriteString("\n")
+		if cue.Name != "" {
+			b.WriteString("<v " + cue.Name + ">")
+		}
+		b.WriteString(runsToHTML(runs, true))
+		b.WriteString("\n\n")
+	}
+	return b.Bytes()
+}
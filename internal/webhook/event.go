@@ -0,0 +1,151 @@
+package webhook
+
+import (
+	"MediaWarp/constants"
+	"MediaWarp/internal/config"
+	"MediaWarp/internal/service/emby"
+	"MediaWarp/internal/service/jellyfin"
+	"errors"
+	"net/http"
+	"time"
+)
+
+// 事件类型
+//
+// 不同媒体服务器的事件统一为以下类型，其他事件保留媒体服务器的原始名称
+type EventType string
+
+const (
+	EventPlaybackStart EventType = "playback.start" // 开始播放
+	EventPlaybackStop  EventType = "playback.stop"  // 停止播放
+	EventItemAdded     EventType = "item.added"     // 媒体库新增项目（Emby 的 library.new）
+)
+
+var ErrUnsupported = errors.New("当前媒体服务器不支持 Webhook")
+
+// 媒体服务器事件
+type Event struct {
+	Type      EventType                 `json:"type"`
+	RawType   string                    `json:"raw_type"` // 媒体服务器原始事件名称
+	Server    constants.MediaServerType `json:"server"`
+	Time      time.Time                 `json:"time"`
+	UserID    string                    `json:"user_id,omitempty"`
+	UserName  string                    `json:"user_name,omitempty"`
+	DeviceID  string                    `json:"device_id,omitempty"`
+	Device    string                    `json:"device,omitempty"`
+	Client    string                    `json:"client,omitempty"`
+	ItemID    string                    `json:"item_id,omitempty"`
+	ItemName  string                    `json:"item_name,omitempty"`
+	ItemType  string                    `json:"item_type,omitempty"` // Movie、Episode 等
+	ItemPath  string                    `json:"item_path,omitempty"` // 仅 Emby 提供
+	SeriesID  string                    `json:"series_id,omitempty"`
+	SeasonID  string                    `json:"season_id,omitempty"`
+	Season    int64                     `json:"season,omitempty"`
+	Episode   int64                     `json:"episode,omitempty"`
+	MediaID   string                    `json:"media_source_id,omitempty"`
+	Position  int64                     `json:"position_ticks,omitempty"`
+	Completed bool                      `json:"played_to_completion,omitempty"`
+}
+
+// 按当前媒体服务器类型解析 Webhook 请求
+func Parse(req *http.Request) (*Event, error) {
+	switch config.Get().MediaServer.Type {
+	case constants.EMBY:
+		event, err := emby.ParseWebhook(req)
+		if err != nil {
+			return nil, err
+		}
+		return fromEmby(event), nil
+	case constants.JELLYFIN:
+		event, err := jellyfin.ParseWebhook(req)
+		if err != nil {
+			return nil, err
+		}
+		return fromJellyfin(event), nil
+	default:
+		return nil, ErrUnsupported
+	}
+}
+
+func fromEmby(raw *emby.WebhookEvent) *Event {
+	event := Event{
+		RawType: string(raw.Event),
+		Server:  constants.EMBY,
+		Time:    time.Now(),
+	}
+	switch raw.Event {
+	case emby.WebhookPlaybackStart:
+		event.Type = EventPlaybackStart
+	case emby.WebhookPlaybackStop:
+		event.Type = EventPlaybackStop
+	case emby.WebhookLibraryNew:
+		event.Type = EventItemAdded
+	default:
+		event.Type = EventType(raw.Event)
+	}
+	if raw.Date != nil {
+		if t, err := time.Parse(time.RFC3339Nano, *raw.Date); err == nil {
+			event.Time = t
+		}
+	}
+	if raw.User != nil {
+		event.UserID, event.UserName = value(raw.User.ID), value(raw.User.Name)
+	}
+	if raw.Session != nil {
+		event.DeviceID, event.Device, event.Client = value(raw.Session.DeviceID), value(raw.Session.DeviceName), value(raw.Session.Client)
+	}
+	if item := raw.Item; item != nil {
+		event.ItemID, event.ItemName, event.ItemType, event.ItemPath = value(item.ID), value(item.Name), value(item.Type), value(item.Path)
+		event.SeriesID, event.SeasonID = value(item.SeriesID), value(item.SeasonID)
+		event.Season, event.Episode = value(item.ParentIndexNumber), value(item.IndexNumber)
+	}
+	if info := raw.PlaybackInfo; info != nil {
+		event.MediaID, event.Position, event.Completed = value(info.MediaSourceID), value(info.PositionTicks), value(info.PlayedToCompletion)
+	}
+	return &event
+}
+
+func fromJellyfin(raw *jellyfin.WebhookEvent) *Event {
+	event := Event{
+		RawType:   string(raw.NotificationType),
+		Server:    constants.JELLYFIN,
+		Time:      time.Now(),
+		UserID:    raw.UserID,
+		UserName:  raw.NotificationUsername,
+		DeviceID:  raw.DeviceID,
+		Device:    raw.DeviceName,
+		Client:    raw.ClientName,
+		ItemID:    raw.ItemID,
+		ItemName:  raw.Name,
+		ItemType:  raw.ItemType,
+		SeriesID:  raw.SeriesID,
+		SeasonID:  raw.SeasonID,
+		Season:    int64(raw.SeasonNumber),
+		Episode:   int64(raw.EpisodeNumber),
+		MediaID:   raw.MediaSourceID,
+		Position:  int64(raw.PlaybackPositionTicks),
+		Completed: bool(raw.PlayedToCompletion),
+	}
+	switch raw.NotificationType {
+	case jellyfin.WebhookPlaybackStart:
+		event.Type = EventPlaybackStart
+	case jellyfin.WebhookPlaybackStop:
+		event.Type = EventPlaybackStop
+	case jellyfin.WebhookItemAdded:
+		event.Type = EventItemAdded
+	default:
+		event.Type = EventType(raw.NotificationType)
+	}
+	if t, err := time.Parse(time.RFC3339Nano, raw.UtcTimestamp); err == nil {
+		event.Time = t
+	}
+	return &event
+}
+
+func value[T any](p *T) T {
+	var zero T
+	if p == nil {
+		return zero
+	}
+	return *p
+}
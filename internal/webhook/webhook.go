@@ -0,0 +1,199 @@
+package webhook
+
+import (
+	"MediaWarp/internal/config"
+	"MediaWarp/internal/logging"
+	"MediaWarp/internal/strmgen"
+	"MediaWarp/utils"
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"sync"
+	"time"
+)
+
+const (
+	forwardTimeout           = 10 * time.Second // 转发通知的超时时间
+	defaultStrmGenerateDelay = time.Minute      // 触发 Strm 生成任务前默认等待的时间
+)
+
+// 事件消费者
+type Consumer func(event Event)
+
+type subscriber struct {
+	name    string
+	events  map[EventType]struct{} // 为空时接收所有事件
+	consume Consumer
+}
+
+func newSubscriber(name string, consume Consumer, events []EventType) subscriber {
+	s := subscriber{name: name, consume: consume}
+	if len(events) > 0 {
+		s.events = make(map[EventType]struct{}, len(events))
+		for _, event := range events {
+			s.events[event] = struct{}{}
+		}
+	}
+	return s
+}
+
+func (s subscriber) match(event EventType) bool {
+	if s.events == nil {
+		return true
+	}
+	_, ok := s.events[event]
+	return ok
+}
+
+var (
+	mutex       sync.RWMutex
+	subscribers []subscriber // 通过 Subscribe 注册，重新加载配置时保留
+	configured  []subscriber // 由配置文件生成，重新加载配置时重建
+)
+
+// 注册事件消费者
+//
+// events 为空时接收所有事件
+func Subscribe(name string, consume Consumer, events ...EventType) {
+	mutex.Lock()
+	defer mutex.Unlock()
+	subscribers = append(subscribers, newSubscriber(name, consume, events))
+}
+
+// 初始化 Webhook
+//
+// 重新加载配置时会再次调用，按配置重建 Strm 生成和转发消费者
+func Init() {
+	cfg := config.Get()
+	var list []subscriber
+	for i, setting := range cfg.Webhook.StrmGenerate {
+		events := toEventTypes(setting.Events)
+		if len(events) == 0 {
+			events = []EventType{EventItemAdded}
+		}
+		list = append(list, newSubscriber(fmt.Sprintf("strm_generate[%d]", i), strmGenerateConsumer(setting), events))
+	}
+	for i, setting := range cfg.Webhook.Forward {
+		list = append(list, newSubscriber(fmt.Sprintf("forward[%d]", i), forwardConsumer(setting), toEventTypes(setting.Events)))
+	}
+
+	mutex.Lock()
+	configured = list
+	mutex.Unlock()
+}
+
+func toEventTypes(events []string) []EventType {
+	types := make([]EventType, len(events))
+	for i, event := range events {
+		types[i] = EventType(event)
+	}
+	return types
+}
+
+// 将事件分发给所有匹配的消费者
+//
+// 每个消费者在单独的 goroutine 中运行，不阻塞 Webhook 请求
+func Dispatch(event Event) {
+	mutex.RLock()
+	list := make([]subscriber, 0, len(subscribers)+len(configured))
+	list = append(list, subscribers...)
+	list = append(list, configured...)
+	mutex.RUnlock()
+
+	logging.Debugf("收到 %s Webhook 事件 %s：%s（%s）", event.Server, event.RawType, event.ItemName, event.ItemID)
+	for _, s := range list {
+		if !s.match(event.Type) {
+			continue
+		}
+		go func(s subscriber) {
+			defer func() {
+				if r := recover(); r != nil {
+					logging.Errorf("Webhook 消费者 %s 处理事件 %s 时发生 panic：%v", s.name, event.Type, r)
+				}
+			}()
+			s.consume(event)
+		}(s)
+	}
+}
+
+// 收到事件后运行 Strm 生成任务
+//
+// 收到第一个事件后等待 delay，期间的事件合并为一次运行
+// 生成的 Strm 文件入库后同样会产生 item.added 事件，合并后最多再触发一次没有新文件的运行，不会循环触发
+// 任务仍在运行时跳过本次运行
+func strmGenerateConsumer(setting config.WebhookStrmGenerateSetting) Consumer {
+	tasks := setting.Tasks
+	if len(tasks) == 0 {
+		tasks = []string{""} // 运行所有任务
+	}
+	delay := setting.Delay
+	if delay <= 0 {
+		delay = defaultStrmGenerateDelay
+	}
+
+	var (
+		mutex   sync.Mutex
+		pending int // 等待运行期间收到的事件数
+	)
+	run := func() {
+		mutex.Lock()
+		count := pending
+		pending = 0
+		mutex.Unlock()
+
+		logging.Infof("%d 个 Webhook 事件触发 Strm 生成任务", count)
+		for _, task := range tasks {
+			if err := strmgen.Run(task); err != nil {
+				logging.Warningf("Webhook 事件触发 Strm 生成任务失败：%v", err)
+			}
+		}
+	}
+	return func(event Event) {
+		mutex.Lock()
+		defer mutex.Unlock()
+		if pending == 0 {
+			time.AfterFunc(delay, run)
+		}
+		pending++
+	}
+}
+
+// 将事件转发至其他地址
+func forwardConsumer(setting config.WebhookForwardSetting) Consumer {
+	return func(event Event) {
+		if err := forward(setting, event); err != nil {
+			logging.Warningf("转发 Webhook 事件 %s 至 %s 失败：%v", event.Type, setting.URL, err)
+		}
+	}
+}
+
+func forward(setting config.WebhookForwardSetting, event Event) error {
+	body, err := json.Marshal(event)
+	if err != nil {
+		return err
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), forwardTimeout)
+	defer cancel()
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, setting.URL, bytes.NewReader(body))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	for key, value := range setting.Headers {
+		req.Header.Set(key, value)
+	}
+
+	resp, err := utils.GetHTTPClient().Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode < http.StatusOK || resp.StatusCode >= http.StatusMultipleChoices {
+		msg, _ := io.ReadAll(io.LimitReader(resp.Body, 512))
+		return fmt.Errorf("状态码：%d，响应：%s", resp.StatusCode, msg)
+	}
+	return nil
+}
@@ -0,0 +1,129 @@
+package webhook_test
+
+import (
+	"MediaWarp/constants"
+	"MediaWarp/internal/config"
+	"MediaWarp/internal/webhook"
+	"bytes"
+	"encoding/json"
+	"mime/multipart"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+const embyPayload = `{
+	"Title": "admin 在 Infuse 上开始播放 第 1 集",
+	"Date": "2025-01-02T03:04:05.0000000Z",
+	"Event": "playback.start",
+	"User": {"Name": "admin", "Id": "u1"},
+	"Item": {"Name": "第 1 集", "Id": "100", "Type": "Episode", "Path": "/media/剧集/S01E01.strm", "SeriesId": "10", "SeasonId": "11", "IndexNumber": 1, "ParentIndexNumber": 1},
+	"Session": {"DeviceId": "d1", "DeviceName": "iPhone", "Client": "Infuse"},
+	"PlaybackInfo": {"PositionTicks": 0, "MediaSourceId": "mediasource_100"}
+}`
+
+func TestParse(t *testing.T) {
+	tests := map[string]struct {
+		server constants.MediaServerType
+		req    func() *http.Request
+		want   webhook.Event
+	}{
+		"Emby JSON": {
+			server: constants.EMBY,
+			req: func() *http.Request {
+				return httptest.NewRequest(http.MethodPost, "/MediaWarp/webhook", strings.NewReader(embyPayload))
+			},
+			want: webhook.Event{Type: webhook.EventPlaybackStart, ItemID: "100", ItemPath: "/media/剧集/S01E01.strm", SeriesID: "10", Season: 1, Episode: 1, UserID: "u1", Client: "Infuse", MediaID: "mediasource_100"},
+		},
+		"Emby multipart/form-data": {
+			server: constants.EMBY,
+			req: func() *http.Request {
+				var body bytes.Buffer
+				writer := multipart.NewWriter(&body)
+				writer.WriteField("data", strings.Replace(embyPayload, "playback.start", "library.new", 1))
+				writer.Close()
+				req := httptest.NewRequest(http.MethodPost, "/MediaWarp/webhook", &body)
+				req.Header.Set("Content-Type", writer.FormDataContentType())
+				return req
+			},
+			want: webhook.Event{Type: webhook.EventItemAdded, ItemID: "100", ItemPath: "/media/剧集/S01E01.strm", SeriesID: "10", Season: 1, Episode: 1, UserID: "u1", Client: "Infuse", MediaID: "mediasource_100"},
+		},
+		"Jellyfin": {
+			server: constants.JELLYFIN,
+			req: func() *http.Request {
+				payload := `{"NotificationType": "PlaybackStop", "ItemId": "200", "SeriesId": "20", "SeasonNumber": "2", "EpisodeNumber": 3, "UserId": "u2", "ClientName": "Web", "PlayedToCompletion": "True"}`
+				return httptest.NewRequest(http.MethodPost, "/MediaWarp/webhook", strings.NewReader(payload))
+			},
+			want: webhook.Event{Type: webhook.EventPlaybackStop, ItemID: "200", SeriesID: "20", Season: 2, Episode: 3, UserID: "u2", Client: "Web", Completed: true},
+		},
+	}
+
+	for name, test := range tests {
+		t.Run(name, func(t *testing.T) {
+			config.Set(&config.Setting{MediaServer: config.MediaServerSetting{Type: test.server}})
+			event, err := webhook.Parse(test.req())
+			if err != nil {
+				t.Fatal(err)
+			}
+			got := *event
+			// 只比较主要字段
+			got.RawType, got.Time, got.UserName, got.Device, got.DeviceID, got.ItemName, got.ItemType, got.SeasonID = "", time.Time{}, "", "", "", "", "", ""
+			test.want.Server = test.server
+			if got != test.want {
+				t.Errorf("事件解析错误。\n期望: %+v\n实际: %+v", test.want, got)
+			}
+		})
+	}
+}
+
+func TestDispatch(t *testing.T) {
+	forwarded := make(chan webhook.Event, 1)
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "Bearer token" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		var event webhook.Event
+		json.NewDecoder(r.Body).Decode(&event)
+		forwarded <- event
+	}))
+	defer server.Close()
+
+	config.Set(&config.Setting{Webhook: config.WebhookSetting{
+		Enable: true,
+		Forward: []config.WebhookForwardSetting{
+			{URL: server.URL, Events: []string{"playback.stop"}, Headers: map[string]string{"Authorization": "Bearer token"}},
+		},
+	}})
+	webhook.Init()
+
+	subscribed := make(chan webhook.Event, 2)
+	webhook.Subscribe("test", func(event webhook.Event) { subscribed <- event }, webhook.EventPlaybackStart)
+
+	webhook.Dispatch(webhook.Event{Type: webhook.EventPlaybackStart, ItemID: "1"})
+	webhook.Dispatch(webhook.Event{Type: webhook.EventPlaybackStop, ItemID: "2"})
+
+	select {
+	case event := <-subscribed:
+		if event.ItemID != "1" {
+			t.Errorf("消费者收到错误的事件：%+v", event)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("消费者未收到事件")
+	}
+	select {
+	case event := <-forwarded:
+		if event.ItemID != "2" || event.Type != webhook.EventPlaybackStop {
+			t.Errorf("转发了错误的事件：%+v", event)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("事件未转发")
+	}
+	select {
+	case event := <-subscribed:
+		t.Errorf("消费者收到了未订阅的事件：%+v", event)
+	case <-time.After(50 * time.Millisecond):
+	}
+}
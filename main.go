@@ -2,17 +2,29 @@ package main
 
 import (
 	"MediaWarp/constants"
+	"MediaWarp/internal/cache"
+	"MediaWarp/internal/clientfilter"
 	"MediaWarp/internal/config"
 	"MediaWarp/internal/handler"
 	"MediaWarp/internal/logging"
+	"MediaWarp/internal/pathmap"
+	"MediaWarp/internal/playurl"
+	"MediaWarp/internal/policy"
+	"MediaWarp/internal/refresh"
 	"MediaWarp/internal/router"
 	"MediaWarp/internal/service"
+	"MediaWarp/internal/strm"
+	"MediaWarp/internal/strmgen"
+	"MediaWarp/internal/subtitle"
+	"MediaWarp/internal/webhook"
 	"MediaWarp/utils"
 	"flag"
 	"fmt"
+	"net/http"
 	"os"
 	"os/signal"
 	"syscall"
+	"time"
 
 	"encoding/json"
 
@@ -24,14 +36,20 @@ var (
 	isDebug     bool   // 开启调试模式
 	showVersion bool   // 显示版本信息
 	configPath  string // 配置文件路径
+	checkConfig bool   // 检查配置文件
+	probe       bool   // 检查配置文件时检测服务连通性
 )
 
+const configWatchInterval = 3 * time.Second // 配置文件变化检测间隔
+
 func init() {
 	gin.SetMode(gin.ReleaseMode)
 
 	flag.BoolVar(&showVersion, "version", false, "显示版本信息")
 	flag.BoolVar(&isDebug, "debug", false, "是否启用调试模式")
 	flag.StringVar(&configPath, "config", "config/config.yaml", "指定配置文件路径")
+	flag.BoolVar(&checkConfig, "check", false, "检查配置文件后退出")
+	flag.BoolVar(&probe, "probe", false, "配合 -check 使用，检测媒体服务器和 Alist 服务器连通性")
 	flag.Parse()
 
 	fmt.Print(constants.LOGO)
@@ -45,6 +63,13 @@ func main() {
 		return
 	}
 
+	if checkConfig {
+		if !runCheck() {
+			os.Exit(1)
+		}
+		return
+	}
+
 	if isDebug {
 		logging.SetLevel(logrus.DebugLevel)
 		logging.Info("已启用调试模式")
@@ -52,7 +77,9 @@ func main() {
 
 	signChan := make(chan os.Signal, 1)
 	errChan := make(chan error, 1)
+	reloadChan := make(chan os.Signal, 1)
 	signal.Notify(signChan, syscall.SIGINT, syscall.SIGTERM)
+	signal.Notify(reloadChan, syscall.SIGHUP)
 	defer func() {
 		logging.Info("MediaWarp 已退出")
 	}()
@@ -61,26 +88,161 @@ func main() {
 		panic("配置初始化失败: " + err.Error())
 	}
 
-	logging.Init()                                                                           // 初始化日志
-	logging.Infof("上游媒体服务器类型：%s，服务器地址：%s", config.MediaServer.Type, config.MediaServer.ADDR) // 日志打印
-	service.InitAlistClient()                                                                // 初始化Alist服务器
-	if err := handler.Init(); err != nil {                                                   // 初始化媒体服务器处理器
+	cfg := config.Get()
+	logging.Init()                                                                     // 初始化日志
+	logConfigDiagnostics()                                                             // 输出配置检查中的警告和错误
+	logging.Infof("上游媒体服务器类型：%s，服务器地址：%s", cfg.MediaServer.Type, cfg.MediaServer.ADDR) // 日志打印
+	if err := cache.Init(); err != nil {                                               // 初始化缓存
+		panic("缓存初始化失败: " + err.Error())
+	}
+	service.InitAlistClient()           // 初始化Alist服务器
+	if err := strm.Init(); err != nil { // 初始化 Strm 匹配规则
+		panic("Strm 匹配规则初始化失败: " + err.Error())
+	}
+	if err := pathmap.Init(); err != nil { // 初始化路径映射
+		panic("路径映射初始化失败: " + err.Error())
+	}
+	if err := playurl.Init(); err != nil { // 初始化签名播放链接
+		panic("签名播放链接初始化失败: " + err.Error())
+	}
+	if err := subtitle.Init(); err != nil { // 初始化字幕字体
+		panic("字幕字体初始化失败: " + err.Error())
+	}
+	if err := clientfilter.Init(); err != nil { // 初始化客户端过滤规则
+		panic("客户端过滤规则初始化失败: " + err.Error())
+	}
+	policy.Init()                          // 初始化访问策略
+	if err := handler.Init(); err != nil { // 初始化媒体服务器处理器
 		panic("媒体服务器处理器初始化失败: " + err.Error())
 	}
+	if err := refresh.Init(); err != nil { // 初始化媒体库刷新
+		panic("媒体库刷新初始化失败: " + err.Error())
+	}
+	strmgen.Init() // 初始化 Strm 文件生成定时任务
+	webhook.Init() // 初始化 Webhook 消费者
 
-	logging.Info("MediaWarp 监听端口：", config.Port)
-	ginR := router.InitRouter() // 路由初始化
+	logging.Info("MediaWarp 监听端口：", cfg.Port)
+	ginR := router.NewReloadableRouter() // 路由初始化
 	logging.Info("MediaWarp 启动成功")
 	go func() {
-		if err := ginR.Run(config.ListenAddr()); err != nil {
+		if err := http.ListenAndServe(config.ListenAddr(), ginR); err != nil {
 			errChan <- err
 		}
 	}()
 
-	select {
-	case sig := <-signChan:
-		logging.Info("MediaWarp 正在退出，信号：", sig)
-	case err := <-errChan:
-		logging.Error("MediaWarp 运行出错：", err)
+	configChanged := make(chan struct{}, 1)
+	stopWatch := config.Watch(configWatchInterval, func() { // 监听配置文件变化
+		select {
+		case configChanged <- struct{}{}:
+		default:
+		}
+	})
+	defer stopWatch()
+
+	for {
+		select {
+		case sig := <-signChan:
+			logging.Info("MediaWarp 正在退出，信号：", sig)
+			return
+		case err := <-errChan:
+			logging.Error("MediaWarp 运行出错：", err)
+			return
+		case <-reloadChan:
+			logging.Info("收到 SIGHUP 信号，重新加载配置文件")
+			reload(ginR)
+		case <-configChanged:
+			logging.Info("配置文件发生变化，重新加载配置文件")
+			reload(ginR)
+		}
+	}
+}
+
+// 重新加载配置文件
+//
+// 先根据新配置构建缓存、Alist 客户端、Strm 匹配规则、路径映射、媒体服务器处理器等组件，
+// 全部构建成功后再与路由一起替换，任一组件构建失败时继续使用原配置和原组件
+// 日志设置需要重启后生效
+func reload(ginR *router.ReloadableRouter) {
+	old := config.Get()
+	err := config.Reload(func(cfg *config.Setting) (func(), error) {
+		caches := cache.Current()
+		if cfg.Cache != old.Cache { // 缓存设置未变化时保留已有缓存
+			var err error
+			if caches, err = cache.New(cfg); err != nil {
+				return nil, fmt.Errorf("缓存初始化失败: %w", err)
+			}
+		}
+		publishes := []func(){
+			func() { cache.Use(caches) },
+			service.PrepareAlistClient(cfg, caches.AlistAPI),
+		}
+		for _, component := range []struct {
+			name    string
+			prepare func(*config.Setting) (func(), error)
+		}{
+			{"Strm 匹配规则", strm.Prepare},
+			{"路径映射", pathmap.Prepare},
+			{"签名播放链接", playurl.Prepare},
+			{"字幕字体", subtitle.Prepare},
+			{"客户端过滤规则", clientfilter.Prepare},
+			{"媒体服务器处理器", handler.Prepare},
+			{"媒体库刷新", refresh.Prepare},
+		} {
+			publish, err := component.prepare(cfg)
+			if err != nil {
+				return nil, fmt.Errorf("%s初始化失败: %w", component.name, err)
+			}
+			publishes = append(publishes, publish)
+		}
+		return func() {
+			for _, publish := range publishes {
+				publish()
+			}
+			policy.Init() // 以下组件直接读取已生效的新配置
+			strmgen.Init()
+			webhook.Init()
+			ginR.Reload()
+		}, nil
+	})
+	if err != nil {
+		logging.Warning("重新加载配置文件失败，继续使用原配置：", err)
+		return
+	}
+	logging.Info("配置文件重新加载成功")
+}
+
+// 检查配置文件并输出报告
+//
+// 返回检查是否通过
+func runCheck() bool {
+	setting, report := config.Check(configPath)
+	if setting != nil && probe && !report.Failed() {
+		if msg, err := service.ProbeMediaServer(setting.MediaServer); err != nil {
+			report.Add(config.CheckError, "媒体服务器连通性", "%v", err)
+		} else {
+			report.Add(config.CheckPass, "媒体服务器连通性", "%s", msg)
+		}
+		if setting.AlistStrm.Enable {
+			for i, alist := range setting.AlistStrm.List {
+				name := fmt.Sprintf("alist_strm.list[%d] 连通性", i)
+				if msg, err := service.ProbeAlist(alist); err != nil {
+					report.Add(config.CheckError, name, "%v", err)
+				} else {
+					report.Add(config.CheckPass, name, "%s", msg)
+				}
+			}
+		}
+	}
+	report.Print(os.Stdout)
+	return !report.Failed()
+}
+
+// 启动时输出配置检查中的警告和错误
+func logConfigDiagnostics() {
+	_, report := config.Check(configPath)
+	for _, item := range report.Items {
+		if item.Level != config.CheckPass {
+			logging.Warningf("配置检查%s：%s，%s", item.Level, item.Name, item.Message)
+		}
 	}
 }
@@ -4,8 +4,16 @@ import (
 	"errors"
 	"io"
 	"os"
+	"strings"
 )
 
+// 判断路径 p 是否等于 prefix 或位于 prefix 目录之下
+//
+// 按路径分隔符边界比较，/media/movie 不属于 /media/mov
+func HasPathPrefix(p string, prefix string) bool {
+	return p == prefix || strings.HasPrefix(p, strings.TrimSuffix(prefix, "/")+"/")
+}
+
 // 判断路径是否存在
 func PathExists(path string) (bool, error) {
 	_, err := os.Stat(path)
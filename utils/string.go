@@ -6,7 +6,6 @@ import (
 	"fmt"
 	"net/url"
 	"regexp"
-	"strconv"
 	"strings"
 )
 
@@ -109,12 +108,6 @@ func ResolveEmbyAPIKVPairs(urlString *string) (string, error) {
 	return "", nil
 }
 
-// 判断字符串是否为整型数字
-func isInt[T ~[]byte | ~[]rune | ~string](s T) bool {
-	_, err := strconv.Atoi(string(s))
-	return err == nil
-}
-
 // 在 []string 中找到某个字符串的索引
 // 如果未找到，返回 -1
 //